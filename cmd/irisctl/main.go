@@ -15,9 +15,16 @@ import (
 	"github.com/dioptra-io/irisctl/internal/users"
 
 	"github.com/dioptra-io/irisctl/internal/analyze"
+	"github.com/dioptra-io/irisctl/internal/apply"
+	"github.com/dioptra-io/irisctl/internal/cache"
 	"github.com/dioptra-io/irisctl/internal/check"
 	"github.com/dioptra-io/irisctl/internal/clickhouse"
+	"github.com/dioptra-io/irisctl/internal/convert"
+	"github.com/dioptra-io/irisctl/internal/doctor"
+	"github.com/dioptra-io/irisctl/internal/explain"
+	"github.com/dioptra-io/irisctl/internal/export"
 	"github.com/dioptra-io/irisctl/internal/list"
+	"github.com/dioptra-io/irisctl/internal/serve"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -25,20 +32,30 @@ import (
 
 var (
 	// Command, its flags, subcommands, and their flags.
-	//	irisctl [--brief] [--curl] [--no-delete] [--no-auto-login] [--stdout] [--verbose] <command>
-	cmdName          = "irisctl"
-	apiSubcmdNames   = []string{"auth", "users", "agents", "targets", "meas", "status", "maint"}
-	extSubcmdNames   = []string{"api", "ext", "check", "analyze", "clickhouse", "list"}
-	subcmdNames      = append(apiSubcmdNames, extSubcmdNames...)
-	fRootBrief       bool
-	fRootCurl        bool
-	fRootNoDelete    bool
-	fRootNoAutoLogin bool
-	fRootStdout      bool
-	fRootVerbose     bool
-	fRootJqFilter    string
-	fIrisAPIUrl      string
-	fMeasurementUUID string
+	//	irisctl [--brief] [--curl] [--no-cache] [--no-delete] [--no-auto-login] [--no-pager] [--schema-warnings] [--stdout] [--strict] [--strict-filters] [--strict-schema] [--summary-json] [--timing] [--verbose]... [--profile <profile>] [--credential-helper <helper>] <command>
+	cmdName           = "irisctl"
+	apiSubcmdNames    = []string{"auth", "users", "agents", "targets", "meas", "status", "maint"}
+	extSubcmdNames    = []string{"api", "ext", "check", "analyze", "clickhouse", "list", "doctor", "convert", "cache", "apply", "export", "serve", "explain"}
+	subcmdNames       = append(apiSubcmdNames, extSubcmdNames...)
+	fRootBrief        bool
+	fRootCurl         bool
+	fRootNoCache      bool
+	fRootNoDelete     bool
+	fRootNoAutoLogin  bool
+	fRootNoPager      bool
+	fRootSchemaWarn   bool
+	fRootStdout       bool
+	fRootStrict       bool
+	fRootStrictFilter bool
+	fRootStrictSchema bool
+	fRootSummaryJSON  bool
+	fRootTiming       bool
+	fRootVerbose      int
+	fRootJqFilter     string
+	fIrisAPIUrl       string
+	fMeasurementUUID  string
+	fRootProfile      string
+	fRootCredHelper   string
 
 	allCmds = []*cobra.Command{}
 
@@ -49,6 +66,18 @@ var (
 )
 
 func main() {
+	irisctlCmd := newIrisctlCmd()
+	// Run the tool.
+	if err := irisctlCmd.Execute(); err != nil {
+		fatal(err)
+	}
+	common.StopPager()
+	common.PrintTiming()
+	common.PrintSummary()
+}
+
+// newIrisctlCmd returns the irisctl command with all its subcommands.
+func newIrisctlCmd() *cobra.Command {
 	irisctlCmd := &cobra.Command{
 		Use:              cmdName,
 		ValidArgs:        subcmdNames,
@@ -60,14 +89,24 @@ func main() {
 	}
 	irisctlCmd.PersistentFlags().BoolVarP(&fRootBrief, "brief", "b", false, "enable brief mode (less output)")
 	irisctlCmd.PersistentFlags().BoolVarP(&fRootCurl, "curl", "c", false, "show curl commands that are executed but not their output")
+	irisctlCmd.PersistentFlags().BoolVar(&fRootNoCache, "no-cache", false, "do not reuse responses of identical GET requests")
 	irisctlCmd.PersistentFlags().BoolVarP(&fRootNoDelete, "no-delete", "d", false, "do not delete temporary files")
 	irisctlCmd.PersistentFlags().BoolVarP(&fRootNoAutoLogin, "no-auto-login", "l", false, "do not auto login")
+	irisctlCmd.PersistentFlags().BoolVar(&fRootSchemaWarn, "schema-warnings", false, "warn once about API fields that irisctl does not recognize or expects but are missing")
+	irisctlCmd.PersistentFlags().BoolVar(&fRootNoPager, "no-pager", false, "do not send output that exceeds the screen to a pager ($PAGER or less)")
 	irisctlCmd.PersistentFlags().BoolVarP(&fRootStdout, "stdout", "o", false, "print results to stdout instead of saving to a file")
-	irisctlCmd.PersistentFlags().BoolVarP(&fRootVerbose, "verbose", "v", false, "enable verbose mode (more output)")
+	irisctlCmd.PersistentFlags().BoolVar(&fRootStrict, "strict", false, "fail on malformed measurement records and inconsistent metadata files instead of skipping them or warning")
+	irisctlCmd.PersistentFlags().BoolVar(&fRootStrictFilter, "strict-filters", false, "fail on --tag or --agent values that match nothing instead of warning")
+	irisctlCmd.PersistentFlags().BoolVar(&fRootStrictSchema, "strict-schema", false, "fail on API responses with fields that irisctl does not recognize or expects but are missing")
+	irisctlCmd.PersistentFlags().BoolVar(&fRootSummaryJSON, "summary-json", false, "print a JSON summary of the outcome (exit code, duration, items, warnings, errors, and outputs) to stderr when the command ends")
+	irisctlCmd.PersistentFlags().BoolVar(&fRootTiming, "timing", false, "print a breakdown of the time spent in api calls, clickhouse, ssh, local processing, and output rendering")
+	irisctlCmd.PersistentFlags().CountVarP(&fRootVerbose, "verbose", "v", "repeatable: enable verbose mode (-v info, -vv debug, -vvv trace)")
 	irisctlCmd.PersistentFlags().StringVarP(&fRootJqFilter, "jq-filter", "j", ".", "jq filter")
 	irisctlCmd.PersistentFlags().StringVarP(&fIrisAPIUrl, "iris-api-url", "u", "https://api.iris.dioptra.io", "specify the iris api url")
 	// TODO: Instead of hard-coding a default value, we should find a measurement UUID of the user.
 	irisctlCmd.PersistentFlags().StringVarP(&fMeasurementUUID, "meas-uuid", "m", "a75482d1-8c5c-4d56-845e-fc3861047992", "specify the measurement uuid for the gusethosue credentials")
+	irisctlCmd.PersistentFlags().StringVar(&fRootCredHelper, "credential-helper", "", "store passwords with keychain, secret-service, wincred, or file (default: the store of the operating system if available, file otherwise)")
+	irisctlCmd.PersistentFlags().StringVar(&fRootProfile, "profile", "", "use the specified profile of the configuration file ($HOME/"+common.ConfigFile+")")
 	irisctlCmd.SetUsageFunc(common.Usage)
 	irisctlCmd.SetHelpFunc(common.Help)
 
@@ -90,13 +129,33 @@ func main() {
 	// all commands and their subcommands.
 	_ = viper.BindPFlag("brief", irisctlCmd.PersistentFlags().Lookup("brief"))
 	_ = viper.BindPFlag("curl", irisctlCmd.PersistentFlags().Lookup("curl"))
+	_ = viper.BindPFlag("no-cache", irisctlCmd.PersistentFlags().Lookup("no-cache"))
 	_ = viper.BindPFlag("no-delete", irisctlCmd.PersistentFlags().Lookup("no-delete"))
 	_ = viper.BindPFlag("no-auto-login", irisctlCmd.PersistentFlags().Lookup("no-auto-login"))
+	_ = viper.BindPFlag("no-pager", irisctlCmd.PersistentFlags().Lookup("no-pager"))
+	_ = viper.BindPFlag("schema-warnings", irisctlCmd.PersistentFlags().Lookup("schema-warnings"))
 	_ = viper.BindPFlag("stdout", irisctlCmd.PersistentFlags().Lookup("stdout"))
+	_ = viper.BindPFlag("strict", irisctlCmd.PersistentFlags().Lookup("strict"))
+	_ = viper.BindPFlag("strict-filters", irisctlCmd.PersistentFlags().Lookup("strict-filters"))
+	_ = viper.BindPFlag("strict-schema", irisctlCmd.PersistentFlags().Lookup("strict-schema"))
+	_ = viper.BindPFlag("summary-json", irisctlCmd.PersistentFlags().Lookup("summary-json"))
+	_ = viper.BindPFlag("timing", irisctlCmd.PersistentFlags().Lookup("timing"))
 	_ = viper.BindPFlag("verbose", irisctlCmd.PersistentFlags().Lookup("verbose"))
 	_ = viper.BindPFlag("jq-filter", irisctlCmd.PersistentFlags().Lookup("jq-filter"))
 	_ = viper.BindPFlag("iris-api-url", irisctlCmd.PersistentFlags().Lookup("iris-api-url"))
 	_ = viper.BindPFlag("meas-uuid", irisctlCmd.PersistentFlags().Lookup("meas-uuid"))
+	_ = viper.BindPFlag("profile", irisctlCmd.PersistentFlags().Lookup("profile"))
+	_ = viper.BindPFlag("credential-helper", irisctlCmd.PersistentFlags().Lookup("credential-helper"))
+	// Read the configuration file after the flags are parsed so
+	// --profile is known.
+	cobra.OnInitialize(func() {
+		if err := common.LoadConfig(); err != nil {
+			fatal(err)
+		}
+		common.AutoCleanTempDir()
+		common.StartPager()
+		common.StartSummary()
+	})
 	// Iris API commands.
 	allCmds = append(allCmds, auth.AuthCmd())
 	allCmds = append(allCmds, users.UsersCmd())
@@ -105,6 +164,12 @@ func main() {
 	allCmds = append(allCmds, meas.MeasCmd())
 	allCmds = append(allCmds, status.StatusCmd())
 	allCmds = append(allCmds, maint.MaintCmd())
+	// users activity needs measurements but meas imports users.
+	users.GetMeasMdFile = meas.GetMeasMdFile
+	meas.TableRowCounts = clickhouse.TableRowCounts
+	meas.DownloadTable = clickhouse.DownloadTable
+	// Native SSH looks up agent addresses but auth imports common.
+	common.GetAccessToken = auth.GetAccessToken
 	// Extension (non-API) commands.
 	allCmds = append(allCmds, apiCmd)
 	allCmds = append(allCmds, extCmd)
@@ -112,14 +177,22 @@ func main() {
 	allCmds = append(allCmds, analyze.AnalyzeCmd())
 	allCmds = append(allCmds, clickhouse.ClickHouseCmd())
 	allCmds = append(allCmds, list.ListCmd())
+	allCmds = append(allCmds, doctor.DoctorCmd())
+	allCmds = append(allCmds, convert.ConvertCmd())
+	allCmds = append(allCmds, cache.CacheCmd())
+	allCmds = append(allCmds, apply.ApplyCmd())
+	allCmds = append(allCmds, export.ExportCmd())
+	allCmds = append(allCmds, serve.ServeCmd())
+	allCmds = append(allCmds, explain.ExplainCmd())
 	// Add all API and extension (non-API) commands.
 	for _, cmd := range allCmds {
 		irisctlCmd.AddCommand(cmd)
 	}
-	// Run the tool.
-	if err := irisctlCmd.Execute(); err != nil {
-		fatal(err)
-	}
+	// Complete tags, hostnames, and measurement UUIDs from the cache.
+	cache.RegisterCompletions(irisctlCmd)
+	// Show examples in the help of each command.
+	common.RegisterExamples(irisctlCmd)
+	return irisctlCmd
 }
 
 func irisctlArgs(cmd *cobra.Command, args []string) error {
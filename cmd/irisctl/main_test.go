@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/dioptra-io/irisctl/internal/common"
+	"github.com/spf13/cobra"
+)
+
+// exampleArgsEnv is set to the JSON-encoded arguments of an example
+// when the test binary is run to replay it.
+const exampleArgsEnv = "IRISCTL_TEST_EXAMPLE_ARGS"
+
+func TestMain(m *testing.M) {
+	if args := os.Getenv(exampleArgsEnv); args != "" {
+		replayExample(args)
+		return
+	}
+	os.Exit(m.Run())
+}
+
+// replayExample runs irisctl with the specified arguments in replay
+// mode: the command line goes through flag parsing and the argument
+// checks of the command, but the command itself is replaced by one
+// that prints its path.  Errors exit with a non-zero status.
+func replayExample(jsonArgs string) {
+	var args []string
+	if err := json.Unmarshal([]byte(jsonArgs), &args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	irisctlCmd := newIrisctlCmd()
+	var walk func(cmd *cobra.Command)
+	walk = func(cmd *cobra.Command) {
+		if cmd.Run != nil {
+			cmd.Run = func(cmd *cobra.Command, args []string) {
+				fmt.Printf("replayed: %s\n", cmd.CommandPath())
+			}
+		}
+		for _, c := range cmd.Commands() {
+			walk(c)
+		}
+	}
+	walk(irisctlCmd)
+	irisctlCmd.SetArgs(args)
+	if err := irisctlCmd.Execute(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// TestExamples replays every example of common.Examples and checks that
+// it runs the command it is registered for.
+func TestExamples(t *testing.T) {
+	home := t.TempDir()
+	config := filepath.Join(home, common.ConfigFile)
+	if err := os.MkdirAll(filepath.Dir(config), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(config, []byte("profiles:\n  staging:\n    iris-api-url: http://127.0.0.1:1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	paths := make([]string, 0, len(common.Examples))
+	for path := range common.Examples {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		for _, example := range common.Examples[path] {
+			t.Run(example, func(t *testing.T) {
+				args, err := exampleArgs(example)
+				if err != nil {
+					t.Fatal(err)
+				}
+				jsonArgs, err := json.Marshal(args)
+				if err != nil {
+					t.Fatal(err)
+				}
+				// Examples are run as is after replacing their
+				// file names, so the files they read must exist.
+				dir := t.TempDir()
+				for _, arg := range args {
+					if strings.HasPrefix(arg, "-") || strings.ContainsAny(arg, "/=") {
+						continue
+					}
+					if _, err := os.Stat(filepath.Join(dir, arg)); err == nil {
+						continue
+					}
+					if err := os.WriteFile(filepath.Join(dir, arg), nil, 0644); err != nil {
+						t.Fatal(err)
+					}
+				}
+				cmd := exec.Command(os.Args[0], "-test.run=^$")
+				cmd.Dir = dir
+				cmd.Env = append(os.Environ(), exampleArgsEnv+"="+string(jsonArgs), "HOME="+home)
+				output, err := cmd.CombinedOutput()
+				if err != nil {
+					t.Fatalf("%v\n%s", err, output)
+				}
+				want := "replayed: irisctl " + path
+				if !strings.Contains(string(output), want+"\n") {
+					t.Errorf("got %q, want %q", output, want)
+				}
+			})
+		}
+	}
+}
+
+// exampleArgs returns the arguments of the irisctl command line in an
+// example, which may be part of a shell command line (e.g., in a
+// command substitution or followed by a redirection).
+func exampleArgs(example string) ([]string, error) {
+	if i := strings.Index(example, "$(irisctl "); i >= 0 {
+		example = example[i+2:]
+		if j := strings.Index(example, ")"); j >= 0 {
+			example = example[:j]
+		}
+	}
+	words, err := splitWords(example)
+	if err != nil {
+		return nil, err
+	}
+	for i, word := range words {
+		if word != "irisctl" {
+			continue
+		}
+		var args []string
+		for _, arg := range words[i+1:] {
+			if common.Contains([]string{"|", ">", ">>", "<", "&&", "||", ";"}, arg) {
+				break
+			}
+			args = append(args, arg)
+		}
+		return args, nil
+	}
+	return nil, fmt.Errorf("%q: not an irisctl command line", example)
+}
+
+// splitWords splits a shell command line into words, removing single
+// and double quotes.
+func splitWords(s string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	var quote rune
+	for _, r := range s {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			word.WriteRune(r)
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case r == ' ' || r == '\t':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("%q: unterminated quote", s)
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
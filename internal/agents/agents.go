@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/dioptra-io/irisctl/internal/auth"
 	"github.com/dioptra-io/irisctl/internal/common"
@@ -14,11 +16,36 @@ import (
 
 var (
 	// Command, its flags, subcommands, and their flags.
-	//	agents [--tag]
-	//	agents [<agent>...]
-	cmdName     = "agents"
-	subcmdNames = []string{}
-	fAgentsTag  string
+	//	agents [--tag <tag>] [--state online|offline|working] [--format json|csv|table] [--columns <column>,...]
+	//	agents --watch [--interval <duration>] [--tag <tag>]
+	//	agents [--format json|csv|table] [--columns <column>,...] [<agent>...]
+	//	agents history [--since <time>] [<hostname>...]
+	//	agents history record
+	//	agents restart [--dry-run] [--yes] [--wait <duration>] <hostname>...
+	//	agents versions [--expected <version>]
+	cmdName         = "agents"
+	subcmdNames     = []string{"history", "restart", "versions"}
+	fAgentsTag      string
+	fAgentsState    string
+	fAgentsWatch    bool
+	fAgentsInterval time.Duration
+	fAgentsFormat   string
+	fAgentsColumns  []string
+	fHistorySince   common.CustomTime
+	fRestartDryRun  bool
+	fRestartYes     bool
+	fRestartWait    time.Duration
+
+	fVersionsExpected string
+
+	// jq conditions of the agent states of --state.  Agents are idle
+	// or working while they are connected; Iris reports the state of
+	// agents that stopped sending heartbeats as unknown.
+	agentStateConds = map[string]string{
+		"online":  `.state == "idle" or .state == "working"`,
+		"offline": `.state != "idle" and .state != "working"`,
+		"working": `.state == "working"`,
+	}
 
 	agentsUUIDName = make(map[string]string)
 
@@ -40,9 +67,57 @@ func AgentsCmd() *cobra.Command {
 		Run:       agents,
 	}
 	agentsCmd.Flags().StringVar(&fAgentsTag, "tag", "", "get only agents that have the specified tag")
+	agentsCmd.Flags().StringVar(&fAgentsState, "state", "", "get only agents in the specified state: online, offline, or working")
+	agentsCmd.Flags().BoolVar(&fAgentsWatch, "watch", false, "poll agents and print only state and version changes")
+	agentsCmd.Flags().DurationVar(&fAgentsInterval, "interval", 30*time.Second, "interval between polls of --watch")
+	agentsCmd.Flags().StringVar(&fAgentsFormat, "format", "json", "output format: json, csv, or table")
+	agentsCmd.Flags().StringSliceVar(&fAgentsColumns, "columns", agentsColumns, "comma-separated columns of --format csv or table: "+strings.Join(agentsColumns, ","))
 	agentsCmd.SetUsageFunc(common.Usage)
 	agentsCmd.SetHelpFunc(common.Help)
 
+	// agents history and its flags and subcommand
+	historySubcmd := &cobra.Command{
+		Use:       "history",
+		ValidArgs: []string{"record"},
+		Short:     "show uptime and flapping of agents",
+		Long:      "show the uptime percentage and the number of times each agent went offline from the agent state snapshots in " + HistoryFile + " of the irisctl directory",
+		Args:      agentsHistoryArgs,
+		Run:       agentsHistory,
+	}
+	historySubcmd.Flags().Var(&fHistorySince, "since", "report only snapshots at or after this time (e.g., --since 7d)")
+	historySubcmd.AddCommand(&cobra.Command{
+		Use:   "record",
+		Short: "record a snapshot of agent states",
+		Long:  "append the current state of all agents to " + HistoryFile + " of the irisctl directory (agents --watch also records a snapshot at every poll)",
+		Args:  agentsHistoryRecordArgs,
+		Run:   agentsHistoryRecord,
+	})
+	agentsCmd.AddCommand(historySubcmd)
+
+	// agents restart and its flags
+	restartSubcmd := &cobra.Command{
+		Use:   "restart",
+		Short: "restart the iris-agent container of agent(s)",
+		Long:  "restart the iris-agent container of agent(s) over gcloud SSH and wait for the agents to reconnect to Iris",
+		Args:  agentsRestartArgs,
+		Run:   agentsRestart,
+	}
+	restartSubcmd.Flags().BoolVar(&fRestartDryRun, "dry-run", false, "enable dry-run mode (i.e., only print the commands)")
+	restartSubcmd.Flags().BoolVarP(&fRestartYes, "yes", "y", false, "do not ask for confirmation before restarting the containers")
+	restartSubcmd.Flags().DurationVar(&fRestartWait, "wait", 2*time.Minute, "how long to wait for the agents to reconnect (0 to not wait)")
+	agentsCmd.AddCommand(restartSubcmd)
+
+	// agents versions and its flags
+	versionsSubcmd := &cobra.Command{
+		Use:   "versions",
+		Short: "report version skew of agents",
+		Long:  "group agents by version and flag the agents that do not run the version of the majority of agents (or the version of --expected), exiting with an error if any agent is flagged",
+		Args:  agentsVersionsArgs,
+		Run:   agentsVersions,
+	}
+	versionsSubcmd.Flags().StringVar(&fVersionsExpected, "expected", "", "version that all agents should run (default: the version of the majority of agents)")
+	agentsCmd.AddCommand(versionsSubcmd)
+
 	return agentsCmd
 }
 
@@ -55,13 +130,33 @@ func GetAgentName(uuid string) string {
 }
 
 func GetAgents(hostname string, printOut bool) ([]byte, error) {
-	var url string
+	return getResults(agentsURL(), hostname, printOut)
+}
+
+// GetAgentsUncached returns the current agents, bypassing the response
+// cache of Curl.
+func GetAgentsUncached() ([]byte, error) {
+	jsonData, err := common.CurlNoCache(auth.GetAccessToken(), false, "GET", agentsURL())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", err, jsonData)
+	}
+	return jsonData, nil
+}
+
+// agentsURL returns the URL of all agents or of the agents with the
+// tag of --tag.
+func agentsURL() string {
 	if fAgentsTag != "" {
-		url = fmt.Sprintf("%s/?tag=%v&offset=0&limit=200", common.APIEndpoint(common.AgentsAPISuffix), fAgentsTag)
-	} else {
-		url = fmt.Sprintf("%s/?&offset=0&limit=200", common.APIEndpoint(common.AgentsAPISuffix))
+		return fmt.Sprintf("%s/?tag=%v&offset=0&limit=200", common.APIEndpoint(common.AgentsAPISuffix), fAgentsTag)
 	}
-	return getResults(url, hostname, printOut)
+	return fmt.Sprintf("%s/?&offset=0&limit=200", common.APIEndpoint(common.AgentsAPISuffix))
+}
+
+// filterAgentsState returns the agents of jsonData that are in the
+// state of --state.
+func filterAgentsState(jsonData []byte) ([]byte, error) {
+	filter := fmt.Sprintf(".results |= map(select(%s)) | .count = (.results | length)", agentStateConds[fAgentsState])
+	return common.JqBytes(jsonData, []string{filter})
 }
 
 func ReplaceAgentUUIDs(s string) string {
@@ -74,14 +169,47 @@ func ReplaceAgentUUIDs(s string) string {
 func agentsArgs(cmd *cobra.Command, args []string) error {
 	if format, ok := common.IsUsage(args); ok {
 		fmt.Printf(format, "<agent>...", "one or more agent UUIDs or hostnames")
+		return nil
+	}
+	if _, ok := agentStateConds[fAgentsState]; fAgentsState != "" && !ok {
+		cliFatal(fmt.Sprintf("%s: %v%s (one of online, offline, working)", fAgentsState, common.ErrInvalidState, common.DidYouMean(fAgentsState, []string{"online", "offline", "working"})))
+	}
+	if !common.Contains(agentsFormats, fAgentsFormat) {
+		cliFatal("invalid --format: ", fAgentsFormat, " (one of these: ", strings.Join(agentsFormats, " "), ")")
+	}
+	if err := validateColumns(fAgentsColumns); err != nil {
+		cliFatal(err)
+	}
+	if fAgentsWatch {
+		if len(args) != 0 || fAgentsState != "" {
+			cliFatal("cannot use --watch with --state or an agent uuid")
+		}
+		if fAgentsFormat != "json" {
+			cliFatal("cannot use --watch with --format")
+		}
+		if fAgentsInterval <= 0 {
+			cliFatal("--interval must be positive")
+		}
 	}
 	return nil
 }
 
 func agents(cmd *cobra.Command, args []string) {
-	if fAgentsTag != "" || len(args) == 0 {
+	if fAgentsWatch {
+		if err := watchAgents(fAgentsInterval); err != nil {
+			fatal(err)
+		}
+		return
+	}
+	if fAgentsFormat != "json" {
+		if err := printAgentsTable(args); err != nil {
+			fatal(err)
+		}
+		return
+	}
+	if fAgentsTag != "" || fAgentsState != "" || len(args) == 0 {
 		if len(args) != 0 {
-			cliFatal("cannot use --tag and also specify an agent uuid")
+			cliFatal("cannot use --tag or --state and also specify an agent uuid")
 		}
 		if _, err := GetAgents("", !common.RootFlagBool("curl")); err != nil {
 			fatal(err)
@@ -101,6 +229,30 @@ func agents(cmd *cobra.Command, args []string) {
 	}
 }
 
+// printAgentsTable prints the agents of --tag and --state, or only
+// those whose UUID or hostname is in args, in the format of --format.
+func printAgentsTable(args []string) error {
+	if len(args) != 0 && (fAgentsTag != "" || fAgentsState != "") {
+		cliFatal("cannot use --tag or --state and also specify an agent uuid")
+	}
+	jsonData, err := GetAgents("", false)
+	if err != nil {
+		return err
+	}
+	var data common.AgentsData
+	if err := common.DecodeJSON(jsonData, &data); err != nil {
+		return err
+	}
+	var selected []common.AgentsResult
+	for _, agent := range data.Results {
+		if len(args) == 0 || common.Contains(args, agent.UUID) || common.Contains(args, agent.Parameters.Hostname) {
+			selected = append(selected, agent)
+		}
+	}
+	sort.Slice(selected, func(i, j int) bool { return selected[i].Parameters.Hostname < selected[j].Parameters.Hostname })
+	return printAgents(selected, fAgentsFormat, fAgentsColumns)
+}
+
 func getAgentByUUID(uuid string) error {
 	url := fmt.Sprintf("%s/%s", common.APIEndpoint(common.AgentsAPISuffix), uuid)
 	_, err := getResults(url, "", true)
@@ -113,6 +265,11 @@ func getResults(url, hostname string, printOut bool) ([]byte, error) {
 		fmt.Println(string(jsonData))
 		return nil, err
 	}
+	if fAgentsState != "" && hostname == "" {
+		if jsonData, err = filterAgentsState(jsonData); err != nil {
+			return nil, err
+		}
+	}
 	file, err := common.WriteResults("irisctl-agents", jsonData)
 	if !common.RootFlagBool("no-delete") {
 		defer func(f string) { verbose("removing %s\n", f); os.Remove(f) }(file)
@@ -0,0 +1,75 @@
+package agents
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/dioptra-io/irisctl/internal/common"
+)
+
+var (
+	// Output formats of agents.
+	agentsFormats = []string{"json", "csv", "table"}
+
+	// Columns that can be selected for agents --format csv|table in
+	// their default order.
+	agentsColumns = []string{"hostname", "uuid", "state", "version", "external_ipv4", "external_ipv6", "cpus", "memory", "max_probing_rate", "tags"}
+	columnValues  = map[string]func(common.AgentsResult) string{
+		"hostname":         func(a common.AgentsResult) string { return a.Parameters.Hostname },
+		"uuid":             func(a common.AgentsResult) string { return a.UUID },
+		"state":            func(a common.AgentsResult) string { return a.State },
+		"version":          func(a common.AgentsResult) string { return a.Parameters.Version },
+		"external_ipv4":    func(a common.AgentsResult) string { return a.Parameters.ExternalIPv4Address },
+		"external_ipv6":    func(a common.AgentsResult) string { return a.Parameters.ExternalIPv6Address },
+		"cpus":             func(a common.AgentsResult) string { return strconv.Itoa(a.Parameters.CPUs) },
+		"memory":           func(a common.AgentsResult) string { return strconv.FormatFloat(a.Parameters.Memory, 'f', -1, 64) },
+		"max_probing_rate": func(a common.AgentsResult) string { return strconv.Itoa(a.Parameters.MaxProbingRate) },
+		"tags":             func(a common.AgentsResult) string { return strings.Join(a.Parameters.Tags, ",") },
+	}
+)
+
+// validateColumns returns an error if any of the columns is unknown.
+func validateColumns(columns []string) error {
+	if len(columns) == 0 {
+		return fmt.Errorf("no columns (one or more of these: %s)", strings.Join(agentsColumns, " "))
+	}
+	for _, column := range columns {
+		if _, ok := columnValues[column]; !ok {
+			return fmt.Errorf("invalid column: %s (one or more of these: %s)", column, strings.Join(agentsColumns, " "))
+		}
+	}
+	return nil
+}
+
+// printAgents prints the specified columns of agents in CSV format
+// with a header or as a table with aligned columns.  Empty values are
+// printed as - in tables so that columns stay aligned.
+func printAgents(agents []common.AgentsResult, format string, columns []string) error {
+	rows := [][]string{columns}
+	for _, agent := range agents {
+		row := make([]string, len(columns))
+		for i, column := range columns {
+			row[i] = columnValues[column](agent)
+			if row[i] == "" && format == "table" {
+				row[i] = "-"
+			}
+		}
+		rows = append(rows, row)
+	}
+	if format == "csv" {
+		w := csv.NewWriter(os.Stdout)
+		if err := w.WriteAll(rows); err != nil {
+			return err
+		}
+		return w.Error()
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, row := range rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	return w.Flush()
+}
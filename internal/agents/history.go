@@ -0,0 +1,248 @@
+package agents
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dioptra-io/irisctl/internal/auth"
+	"github.com/dioptra-io/irisctl/internal/common"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// HistoryFile is the file (in the irisctl directory) that stores
+	// the snapshots of agent states, one JSON object per line.
+	HistoryFile = "agents-history.jsonl"
+	// An agent that went offline at least flappingThreshold times in
+	// the reported period is flagged as flapping.
+	flappingThreshold = 3
+)
+
+var (
+	ErrNoHistory = errors.New("no agent state snapshots")
+)
+
+// historySnapshot defines a line of the history file: the states of
+// all agents at a time.
+type historySnapshot struct {
+	Time   time.Time      `json:"time"`
+	Agents []historyAgent `json:"agents"`
+}
+
+type historyAgent struct {
+	Hostname string `json:"hostname"`
+	UUID     string `json:"uuid"`
+	State    string `json:"state"`
+	Version  string `json:"version"`
+}
+
+// agentHistory defines what agents history reports for an agent.
+type agentHistory struct {
+	name     string
+	online   time.Duration
+	observed time.Duration
+	flaps    int
+	lastSeen time.Time
+	state    string
+}
+
+func agentsHistoryArgs(cmd *cobra.Command, args []string) error {
+	if format, ok := common.IsUsage(args); ok {
+		fmt.Printf(format, "<hostname>...", "zero or more agent hostnames (default: all agents)")
+		return nil
+	}
+	return nil
+}
+
+func agentsHistory(cmd *cobra.Command, args []string) {
+	snapshots, err := readHistory()
+	if err != nil {
+		fatal(err)
+	}
+	if len(snapshots) == 0 {
+		fatal(fmt.Errorf("%w: run irisctl agents history record (e.g., from cron) or irisctl agents --watch", ErrNoHistory))
+	}
+	histories := summarizeHistory(snapshots, fHistorySince.Time, time.Now())
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "hostname\tuptime\tobserved\twent offline\tlast seen\tlast state\tstatus\n")
+	for _, h := range histories {
+		if len(args) > 0 && !common.Contains(args, h.name) {
+			continue
+		}
+		uptime := "-"
+		if h.observed > 0 {
+			uptime = fmt.Sprintf("%.1f%%", 100*float64(h.online)/float64(h.observed))
+		}
+		status := "ok"
+		if h.flaps >= flappingThreshold {
+			status = "FLAPPING"
+		} else if h.state != "idle" && h.state != "working" {
+			status = "offline"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\t%s\n", h.name, uptime, h.observed.Round(time.Second), h.flaps, h.lastSeen.Format("2006-01-02 15:04"), h.state, status)
+	}
+	w.Flush()
+}
+
+func agentsHistoryRecordArgs(cmd *cobra.Command, args []string) error {
+	if _, ok := common.IsUsage(args); ok {
+		return nil
+	}
+	if len(args) != 0 {
+		cliFatal("agents history record does not take any arguments")
+	}
+	return nil
+}
+
+func agentsHistoryRecord(cmd *cobra.Command, args []string) {
+	jsonData, err := common.CurlNoCache(auth.GetAccessToken(), false, "GET", agentsURL())
+	if err != nil {
+		fatal(fmt.Errorf("%w: %s", err, jsonData))
+	}
+	var data common.AgentsData
+	if err := common.DecodeJSON(jsonData, &data); err != nil {
+		fatal(err)
+	}
+	if err := appendHistory(data, time.Now()); err != nil {
+		fatal(err)
+	}
+	fmt.Printf("recorded the states of %d agents\n", len(data.Results))
+}
+
+// historyFile returns the path of the history file.  Agents of
+// different profiles are of different Iris deployments, so each
+// profile has its own history file.
+func historyFile() (string, error) {
+	irisDir, err := common.IrisDir()
+	if err != nil {
+		return "", err
+	}
+	file := filepath.Join(irisDir, HistoryFile)
+	if profile := common.RootFlagString("profile"); profile != "" {
+		file = filepath.Join(irisDir, fmt.Sprintf("agents-history-%s.jsonl", profile))
+	}
+	return file, nil
+}
+
+// appendHistory appends a snapshot of the agents to the history file.
+func appendHistory(data common.AgentsData, t time.Time) error {
+	snapshot := historySnapshot{Time: t.UTC(), Agents: []historyAgent{}}
+	for _, agent := range data.Results {
+		snapshot.Agents = append(snapshot.Agents, historyAgent{
+			Hostname: agent.Parameters.Hostname,
+			UUID:     agent.UUID,
+			State:    agent.State,
+			Version:  agent.Parameters.Version,
+		})
+	}
+	file, err := historyFile()
+	if err != nil {
+		return err
+	}
+	line, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = fmt.Fprintf(f, "%s\n", line)
+	return err
+}
+
+// readHistory returns the snapshots of the history file sorted by time.
+func readHistory() ([]historySnapshot, error) {
+	file, err := historyFile()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var snapshots []historySnapshot
+	scanner := bufio.NewScanner(f)
+	// Snapshots of many agents are longer than the default maximum.
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var s historySnapshot
+		if err := json.Unmarshal(scanner.Bytes(), &s); err != nil {
+			fmt.Fprintf(os.Stderr, "%s %s: %v\n", common.Warning(common.WarnMalformedRecord), file, err)
+			continue
+		}
+		snapshots = append(snapshots, s)
+	}
+	sort.SliceStable(snapshots, func(i, j int) bool { return snapshots[i].Time.Before(snapshots[j].Time) })
+	return snapshots, scanner.Err()
+}
+
+// summarizeHistory returns the history of each agent seen in the
+// snapshots taken at or after since, sorted by name.  An agent is
+// assumed to stay in its state until the next snapshot (or until now
+// for the last snapshot), and an agent missing from a snapshot is
+// offline.
+func summarizeHistory(snapshots []historySnapshot, since, now time.Time) []agentHistory {
+	histories := make(map[string]*agentHistory)
+	wasOnline := make(map[string]bool)
+	for i, s := range snapshots {
+		if s.Time.Before(since) {
+			continue
+		}
+		end := now
+		if i+1 < len(snapshots) {
+			end = snapshots[i+1].Time
+		}
+		seen := make(map[string]bool)
+		for _, agent := range s.Agents {
+			name := agent.Hostname
+			if name == "" {
+				name = agent.UUID
+			}
+			seen[name] = true
+			h, ok := histories[name]
+			if !ok {
+				h = &agentHistory{name: name}
+				histories[name] = h
+			}
+			online := agent.State == "idle" || agent.State == "working"
+			if online {
+				h.online += end.Sub(s.Time)
+			} else if wasOnline[name] {
+				h.flaps++
+			}
+			wasOnline[name] = online
+			h.observed += end.Sub(s.Time)
+			h.lastSeen, h.state = s.Time, agent.State
+		}
+		for name, h := range histories {
+			if seen[name] {
+				continue
+			}
+			if wasOnline[name] {
+				h.flaps++
+			}
+			wasOnline[name] = false
+			h.observed += end.Sub(s.Time)
+			h.state = "unregistered"
+		}
+	}
+	var result []agentHistory
+	for _, h := range histories {
+		result = append(result, *h)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].name < result[j].name })
+	return result
+}
@@ -0,0 +1,119 @@
+package agents
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dioptra-io/irisctl/internal/auth"
+	"github.com/dioptra-io/irisctl/internal/common"
+	"github.com/spf13/cobra"
+)
+
+const (
+	restartCmd = "docker restart iris-agent"
+	// Interval between polls of the API for the state of the
+	// restarted agents.
+	restartPollInterval = 10 * time.Second
+)
+
+var (
+	ErrRestartFailed = errors.New("agent restart failed")
+)
+
+func agentsRestartArgs(cmd *cobra.Command, args []string) error {
+	if format, ok := common.IsUsage(args); ok {
+		fmt.Printf(format, "<hostname>...", "one or more agent hostnames")
+		return nil
+	}
+	if len(args) == 0 {
+		cliFatal("agents restart requires at least one argument: <hostname>...")
+	}
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "iris-") {
+			cliFatal(fmt.Sprintf("%s: not an agent hostname (e.g., iris-us-east4)", arg))
+		}
+	}
+	if fRestartWait < 0 {
+		cliFatal("--wait cannot be negative")
+	}
+	return nil
+}
+
+func agentsRestart(cmd *cobra.Command, args []string) {
+	if fRestartDryRun {
+		for _, hostname := range args {
+			fmt.Printf("dry-run: would run %q on %s\n", restartCmd, hostname)
+		}
+		return
+	}
+	if !fRestartYes && !common.Confirm(fmt.Sprintf("restart the iris-agent container of %d agent(s) (%s)?", len(args), strings.Join(args, " "))) {
+		fmt.Println("aborted")
+		return
+	}
+	var restarted []string
+	var errs []error
+	for _, hostname := range args {
+		verbose("running %q on %s\n", restartCmd, hostname)
+		if _, err := common.SSH(hostname, restartCmd); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", hostname, err))
+			continue
+		}
+		fmt.Printf("restarted iris-agent on %s\n", hostname)
+		restarted = append(restarted, hostname)
+	}
+	if len(restarted) > 0 && fRestartWait > 0 {
+		errs = append(errs, waitAgentsOnline(restarted, fRestartWait)...)
+	}
+	if len(errs) > 0 {
+		fatal(fmt.Errorf("%w: %v", ErrRestartFailed, errors.Join(errs...)))
+	}
+}
+
+// waitAgentsOnline polls the API until the agents with the specified
+// hostnames are connected or until timeout, and prints their state.
+// A restarted agent registers with a new UUID, so an agent is
+// connected if any agent with its hostname is idle or working.  The
+// first poll is after restartPollInterval so that the state of the
+// agent before its restart is not mistaken for its new state.
+func waitAgentsOnline(hostnames []string, timeout time.Duration) []error {
+	fmt.Printf("waiting up to %v for the agents to reconnect\n", timeout)
+	pending := make(map[string]bool)
+	for _, hostname := range hostnames {
+		pending[hostname] = true
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		time.Sleep(restartPollInterval)
+		jsonData, err := common.CurlNoCache(auth.GetAccessToken(), false, "GET", agentsURL())
+		if err != nil {
+			return []error{fmt.Errorf("%w: %s", err, jsonData)}
+		}
+		var data common.AgentsData
+		if err := common.DecodeJSON(jsonData, &data); err != nil {
+			return []error{err}
+		}
+		for _, agent := range data.Results {
+			hostname := agent.Parameters.Hostname
+			if pending[hostname] && (agent.State == "idle" || agent.State == "working") {
+				if agent.Parameters.Version != "" {
+					fmt.Printf("%s: %s (version %s)\n", hostname, agent.State, agent.Parameters.Version)
+				} else {
+					fmt.Printf("%s: %s\n", hostname, agent.State)
+				}
+				delete(pending, hostname)
+			}
+		}
+		if len(pending) == 0 || !time.Now().Add(restartPollInterval).Before(deadline) {
+			break
+		}
+	}
+	var errs []error
+	for _, hostname := range hostnames {
+		if pending[hostname] {
+			errs = append(errs, fmt.Errorf("%s: not connected after %v", hostname, timeout))
+		}
+	}
+	return errs
+}
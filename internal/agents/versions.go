@@ -0,0 +1,91 @@
+package agents
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/dioptra-io/irisctl/internal/common"
+	"github.com/spf13/cobra"
+)
+
+var (
+	ErrVersionSkew = errors.New("agent version skew")
+)
+
+func agentsVersionsArgs(cmd *cobra.Command, args []string) error {
+	if _, ok := common.IsUsage(args); ok {
+		return nil
+	}
+	if len(args) != 0 {
+		cliFatal("agents versions does not take any arguments")
+	}
+	return nil
+}
+
+func agentsVersions(cmd *cobra.Command, args []string) {
+	jsonData, err := GetAgents("", false)
+	if err != nil {
+		fatal(err)
+	}
+	var data common.AgentsData
+	if err := common.DecodeJSON(jsonData, &data); err != nil {
+		fatal(err)
+	}
+	if len(data.Results) == 0 {
+		fmt.Println("no agents")
+		return
+	}
+	groups := make(map[string][]string)
+	for _, agent := range data.Results {
+		version := agent.Parameters.Version
+		if version == "" {
+			version = "-"
+		}
+		groups[version] = append(groups[version], agent.Parameters.Hostname)
+	}
+	versions := make([]string, 0, len(groups))
+	for version := range groups {
+		versions = append(versions, version)
+		sort.Strings(groups[version])
+	}
+	// The most common version first; ties are broken by version.
+	sort.Slice(versions, func(i, j int) bool {
+		if len(groups[versions[i]]) != len(groups[versions[j]]) {
+			return len(groups[versions[i]]) > len(groups[versions[j]])
+		}
+		return versions[i] < versions[j]
+	})
+
+	// Without a majority version, all agents are flagged.
+	expected, noMajority := fVersionsExpected, false
+	if expected == "" {
+		if len(versions) > 1 && len(groups[versions[0]]) == len(groups[versions[1]]) {
+			noMajority = true
+			fmt.Fprintf(os.Stderr, "no majority version: %s and %s have %d agents each\n", versions[0], versions[1], len(groups[versions[0]]))
+		} else {
+			expected = versions[0]
+		}
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "version\tagents\thostnames\tstatus\n")
+	nSkewed := 0
+	for _, version := range versions {
+		status := "ok"
+		if noMajority || version != expected {
+			status = "FLAGGED"
+			nSkewed += len(groups[version])
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", version, len(groups[version]), strings.Join(groups[version], ","), status)
+	}
+	w.Flush()
+	if nSkewed > 0 {
+		if noMajority {
+			fatal(fmt.Errorf("%w: %d agent(s) without a majority version", ErrVersionSkew, nSkewed))
+		}
+		fatal(fmt.Errorf("%w: %d agent(s) not running version %s", ErrVersionSkew, nSkewed, expected))
+	}
+}
@@ -0,0 +1,124 @@
+package agents
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/dioptra-io/irisctl/internal/auth"
+	"github.com/dioptra-io/irisctl/internal/common"
+	"golang.org/x/term"
+)
+
+const (
+	// ANSI colors of the changes printed by --watch on a terminal.
+	colorRed    = "\033[31m"
+	colorGreen  = "\033[32m"
+	colorYellow = "\033[33m"
+	colorReset  = "\033[0m"
+)
+
+// agentStatus defines what --watch compares between polls.
+type agentStatus struct {
+	state   string
+	version string
+}
+
+// online returns true if the agent is connected (see agentStateConds).
+func (s agentStatus) online() bool {
+	return s.state == "idle" || s.state == "working"
+}
+
+// watchAgents polls the agents every interval and prints the agents
+// that went offline, came back, changed state, or changed version.
+// Each poll is also recorded in the history file.
+// Agents are keyed by hostname because an agent gets a new UUID when
+// it restarts.
+func watchAgents(interval time.Duration) error {
+	// Live output is not paged.
+	common.StopPager()
+	color := term.IsTerminal(int(os.Stdout.Fd()))
+	var prev map[string]agentStatus
+	for {
+		jsonData, err := common.CurlNoCache(auth.GetAccessToken(), false, "GET", agentsURL())
+		if err != nil {
+			return fmt.Errorf("%w: %s", err, jsonData)
+		}
+		var data common.AgentsData
+		if err := common.DecodeJSON(jsonData, &data); err != nil {
+			return err
+		}
+		// Snapshots of the agents with a tag would make the other
+		// agents look offline in agents history.
+		if fAgentsTag == "" {
+			if err := appendHistory(data, time.Now()); err != nil {
+				return err
+			}
+		}
+		cur := make(map[string]agentStatus)
+		for _, agent := range data.Results {
+			name := agent.Parameters.Hostname
+			if name == "" {
+				name = agent.UUID
+			}
+			cur[name] = agentStatus{state: agent.State, version: agent.Parameters.Version}
+		}
+		now := time.Now().Format("2006-01-02 15:04:05")
+		if prev == nil {
+			online := 0
+			for _, s := range cur {
+				if s.online() {
+					online++
+				}
+			}
+			fmt.Printf("%s watching %d agents (%d online, %d offline)\n", now, len(cur), online, len(cur)-online)
+		} else {
+			for _, change := range agentChanges(prev, cur) {
+				if color && change.color != "" {
+					change.text = change.color + change.text + colorReset
+				}
+				fmt.Printf("%s %-30s %s\n", now, change.name, change.text)
+			}
+		}
+		prev = cur
+		time.Sleep(interval)
+	}
+}
+
+// agentChange defines a change of an agent between two polls.
+type agentChange struct {
+	name  string
+	text  string
+	color string
+}
+
+// agentChanges returns the changes from prev to cur sorted by agent
+// name.
+func agentChanges(prev, cur map[string]agentStatus) []agentChange {
+	var changes []agentChange
+	for name, c := range cur {
+		p, ok := prev[name]
+		switch {
+		case !ok:
+			changes = append(changes, agentChange{name, fmt.Sprintf("appeared (%s, version %s)", c.state, c.version), colorGreen})
+			continue
+		case !p.online() && c.online():
+			changes = append(changes, agentChange{name, fmt.Sprintf("came back (%s)", c.state), colorGreen})
+		case p.online() && !c.online():
+			changes = append(changes, agentChange{name, fmt.Sprintf("went offline (%s)", c.state), colorRed})
+		case p.state != c.state:
+			changes = append(changes, agentChange{name, fmt.Sprintf("%s -> %s", p.state, c.state), ""})
+		}
+		if p.version != c.version {
+			changes = append(changes, agentChange{name, fmt.Sprintf("version changed from %s to %s", p.version, c.version), colorYellow})
+		}
+	}
+	for name := range prev {
+		if _, ok := cur[name]; !ok {
+			changes = append(changes, agentChange{name, "went offline (no longer registered)", colorRed})
+		}
+	}
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].name < changes[j].name })
+	return changes
+}
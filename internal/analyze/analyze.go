@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"regexp"
 	"sort"
 	"strings"
 	"time"
@@ -13,10 +14,10 @@ import (
 	"gonum.org/v1/gonum/stat"
 
 	"github.com/dioptra-io/irisctl/internal/agents"
+	"github.com/dioptra-io/irisctl/internal/cache"
 	"github.com/dioptra-io/irisctl/internal/common"
 	"github.com/dioptra-io/irisctl/internal/meas"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 )
 
 const (
@@ -29,20 +30,29 @@ const (
 )
 
 type tableDetails struct {
-	modTime string
-	rows    int
-	bytes   int
+	modTime   string
+	rows      int
+	bytes     int
+	agentUUID string
 }
 
 var (
 	// Command, its flags, subcommands, and their flags.
-	//      analyze [--all-users] [--before <yyyy-mm-ddThh:mm:ss>] [--after <yyyy-mm-ddThh:mm:ss>] [--state <state>]... [--tag <tag>]... [--tags-and] [--agent <agent-hostname>]...
-	//      analyze hours [--chart]
+	//      analyze [--all-users] [--before <yyyy-mm-ddThh:mm:ss>] [--after <yyyy-mm-ddThh:mm:ss>] [--since <time>] [--state <state>]... [--tag <tag>]... [--tags-and] [--agent <agent-hostname>]... [--project <project>]...
+	//		[--format text|parquet] [--output <file>]
+	//      analyze hours [--chart] [--html] [--template <template-file>]
 	//      analyze tags
 	//      analyze states
-	//      analyze tables [--meas-uuid <meas-uuid>] <meas-md-file>
+	//      analyze projects
+	//      analyze tables [--meas-uuid <meas-uuid>] [--sort name|rows|bytes|modtime|agent] [--desc] <meas-md-file>
+	//      analyze sql <query> [<meas-md-file>]
+	//      analyze params [<meas-md-file>]
+	//      analyze seasonality [--top <n>] [<meas-md-file>]
+	//      analyze trend [--days <n>] [--alert] [--drop <percent>] [--spike <percent>] [<meas-md-file>]
+	//      analyze failure-correlation [--window <duration>] [<meas-md-file>]
+	//      analyze agent-readiness [--since <duration>] [--ready-pattern <regexp>] [--slo <duration>] [--alert]
 	cmdName          = "analyze"
-	subcmdNames      = []string{"hours", "tags", "states", "tables"}
+	subcmdNames      = []string{"hours", "tags", "states", "projects", "tables", "sql", "params", "seasonality", "trend", "failure-correlation", "agent-readiness"}
 	fAnalyzeAllUsers bool
 	fAnalyzeBefore   common.CustomTime
 	fAnalyzeAfter    common.CustomTime
@@ -50,11 +60,27 @@ var (
 	fAnalyzeTag      []string
 	fAnalyzeTagsAnd  bool
 	fAnalyzeAgents   []string
+	fAnalyzeProject  []string
+	fAnalyzeFormat   string
+	fAnalyzeOutput   string
 	fHoursChart      bool
+	fHoursHTML       bool
+	fHoursTemplate   string
 	fTablesMeasUUID  string
+	fTablesSort      string
+	fTablesDesc      bool
+	fSeasonalityTop  int
+	fTrendDays       int
+	fTrendAlert      bool
+	fTrendDrop       float64
+	fTrendSpike      float64
+	fFailureWindow   time.Duration
+	fReadinessSince  time.Duration
+	fReadinessRegexp string
+	fReadinessSLO    time.Duration
+	fReadinessAlert  bool
 
 	// Errors.
-	ErrInvalidTableName = errors.New("invalid table name")
 
 	// Test code changes Fatal to Panic so a fatal error won't exit
 	// the process and can be recovered.
@@ -68,6 +94,8 @@ var (
 		"16", "17", "18", "19", "20", "21", "22", "23",
 	}
 
+	analyzeProjectUserIDs []string
+
 	totFound        = 0
 	totAgentFailure = 0
 	totCanceled     = 0
@@ -77,6 +105,7 @@ var (
 	durationCS      = []float64{}
 	durationSE      = []float64{}
 	agentsPerMeas   = make(map[int]int)
+	tablesSortKeys  = []string{"name", "rows", "bytes", "modtime", "agent"}
 	abbrState       = map[string]string{
 		"agent_failure": "E",
 		"canceled":      "C",
@@ -105,12 +134,16 @@ func AnalyzeCmd() *cobra.Command {
 		Run:       analyze,
 	}
 	analyzeCmd.Flags().BoolVar(&fAnalyzeAllUsers, "all-users", false, "match all measurements of all users (admin only)")
-	analyzeCmd.Flags().Var(&fAnalyzeBefore, "before", "match measurements before the specified date (exclusive)")
-	analyzeCmd.Flags().Var(&fAnalyzeAfter, "after", "match measurements after the specified date (inclusive)")
+	analyzeCmd.Flags().Var(&fAnalyzeBefore, "before", "match measurements before the specified date or relative time such as 24h, 7d, today, or yesterday (exclusive)")
+	analyzeCmd.Flags().Var(&fAnalyzeAfter, "after", "match measurements after the specified date or relative time such as 24h, 7d, today, or yesterday (inclusive)")
+	analyzeCmd.Flags().Var(&fAnalyzeAfter, "since", "same as --after (e.g., --since 24h)")
 	analyzeCmd.Flags().StringArrayVarP(&fAnalyzeState, "state", "s", []string{}, "repeatable: match measurements with the specified state (agent_failure, canceled, finished, ongoing)")
 	analyzeCmd.Flags().StringArrayVarP(&fAnalyzeTag, "tag", "t", []string{}, "repeatable: match measurements with the specified tag (also see --tags-and)")
 	analyzeCmd.Flags().BoolVar(&fAnalyzeTagsAnd, "tags-and", false, "match measurements that have all specified tags")
 	analyzeCmd.Flags().StringArrayVarP(&fAnalyzeAgents, "agent", "a", []string{}, "repeatable: match measurements that ran on the specified agent")
+	analyzeCmd.Flags().StringVar(&fAnalyzeFormat, "format", "text", "output format: text or parquet")
+	analyzeCmd.Flags().StringVar(&fAnalyzeOutput, "output", "analysis.parquet", "output file or gs:// or s3:// URL for --format parquet")
+	analyzeCmd.Flags().StringArrayVar(&fAnalyzeProject, "project", []string{}, "repeatable: match measurements of users in the specified local project")
 	analyzeCmd.SetUsageFunc(common.Usage)
 	analyzeCmd.SetHelpFunc(common.Help)
 
@@ -123,6 +156,8 @@ func AnalyzeCmd() *cobra.Command {
 		Run:   analyzeHours,
 	}
 	hoursCmd.Flags().BoolVar(&fHoursChart, "chart", false, "create a dot chart file")
+	hoursCmd.Flags().BoolVar(&fHoursHTML, "html", false, "create an html heatmap file")
+	hoursCmd.Flags().StringVar(&fHoursTemplate, "template", "", "html template file to use instead of the built-in template")
 	analyzeCmd.AddCommand(hoursCmd)
 
 	// analyze tags and its flags
@@ -145,6 +180,16 @@ func AnalyzeCmd() *cobra.Command {
 	}
 	analyzeCmd.AddCommand(statesCmd)
 
+	// analyze projects (has no flags)
+	projectsCmd := &cobra.Command{
+		Use:   "projects",
+		Short: "analyze projects",
+		Long:  "analyze the states of measurement runs per local project",
+		Args:  analyzeProjectsArgs,
+		Run:   analyzeProjects,
+	}
+	analyzeCmd.AddCommand(projectsCmd)
+
 	// analyze tables and its flags
 	tablesSubcmd := &cobra.Command{
 		Use:   "tables",
@@ -154,8 +199,81 @@ func AnalyzeCmd() *cobra.Command {
 		Run:   analyzeTables,
 	}
 	tablesSubcmd.Flags().StringVar(&fTablesMeasUUID, "meas-uuid", "", "measurement UUID")
+	tablesSubcmd.Flags().StringVar(&fTablesSort, "sort", "name", "sort tables by name, rows, bytes, modtime, or agent")
+	tablesSubcmd.Flags().BoolVar(&fTablesDesc, "desc", false, "sort tables in descending order")
 	analyzeCmd.AddCommand(tablesSubcmd)
 
+	// analyze sql (has no flags)
+	sqlCmd := &cobra.Command{
+		Use:   "sql",
+		Short: "run an SQL query on measurements",
+		Long: "load the matching measurements into an in-memory DuckDB table named " + SQLTable +
+			" and run the specified SQL query against it; the table has the following columns:\n\n" + SQLSchema,
+		Args: analyzeSQLArgs,
+		Run:  analyzeSQL,
+	}
+	analyzeCmd.AddCommand(sqlCmd)
+
+	// analyze params (has no flags)
+	paramsCmd := &cobra.Command{
+		Use:   "params",
+		Short: "show changes of tool parameters",
+		Long:  "show when tool parameters changed across the series of measurements with the specified tag(s)",
+		Args:  analyzeParamsArgs,
+		Run:   analyzeParams,
+	}
+	analyzeCmd.AddCommand(paramsCmd)
+
+	// analyze seasonality and its flags
+	seasonalityCmd := &cobra.Command{
+		Use:   "seasonality",
+		Short: "show measurement starts per hour of day and day of week",
+		Long:  "aggregate measurement starts by hour of day and day of week (UTC) and show the busiest windows",
+		Args:  analyzeSeasonalityArgs,
+		Run:   analyzeSeasonality,
+	}
+	seasonalityCmd.Flags().IntVar(&fSeasonalityTop, "top", 5, "number of busiest windows to show")
+	analyzeCmd.AddCommand(seasonalityCmd)
+
+	// analyze trend and its flags
+	trendCmd := &cobra.Command{
+		Use:   "trend",
+		Short: "compare today's measurement volume to a trailing average",
+		Long:  "compare the number of measurements created today (UTC) and their packets received to the average of the previous days",
+		Args:  analyzeTrendArgs,
+		Run:   analyzeTrend,
+	}
+	trendCmd.Flags().IntVar(&fTrendDays, "days", 7, "number of previous days of the trailing average")
+	trendCmd.Flags().BoolVar(&fTrendAlert, "alert", false, "exit with an error if today's volume drops or spikes beyond the thresholds")
+	trendCmd.Flags().Float64Var(&fTrendDrop, "drop", 50, "percent decrease from the average that is alerted")
+	trendCmd.Flags().Float64Var(&fTrendSpike, "spike", 200, "percent increase from the average that is alerted")
+	analyzeCmd.AddCommand(trendCmd)
+
+	// analyze failure-correlation and its flags
+	failureCorrelationCmd := &cobra.Command{
+		Use:   "failure-correlation",
+		Short: "correlate agent failures with GCP VM events",
+		Long:  "align the agent_failure times of measurements with the GCP events of the agents' VM instances (preemptions, live migrations, host errors, restarts) to tell infrastructure-caused failures from software bugs",
+		Args:  analyzeFailureCorrelationArgs,
+		Run:   analyzeFailureCorrelation,
+	}
+	failureCorrelationCmd.Flags().DurationVar(&fFailureWindow, "window", 30*time.Minute, "maximum time between a failure and a VM event for them to be correlated")
+	analyzeCmd.AddCommand(failureCorrelationCmd)
+
+	// analyze agent-readiness and its flags
+	agentReadinessCmd := &cobra.Command{
+		Use:   "agent-readiness",
+		Short: "measure the time-to-ready of restarted agents",
+		Long:  "measure, for agents whose VM or container was recently (re)started, the delay between the start and the agent logging that it is ready, and compare it to an SLO",
+		Args:  analyzeAgentReadinessArgs,
+		Run:   analyzeAgentReadiness,
+	}
+	agentReadinessCmd.Flags().DurationVar(&fReadinessSince, "since", 24*time.Hour, "only agents (re)started within this period")
+	agentReadinessCmd.Flags().StringVar(&fReadinessRegexp, "ready-pattern", "(idle|working)", "extended regular expression of the agent log line that marks it ready")
+	agentReadinessCmd.Flags().DurationVar(&fReadinessSLO, "slo", 10*time.Minute, "maximum acceptable time-to-ready")
+	agentReadinessCmd.Flags().BoolVar(&fReadinessAlert, "alert", false, "exit with an error if an agent exceeds the SLO or is not ready")
+	analyzeCmd.AddCommand(agentReadinessCmd)
+
 	return analyzeCmd
 }
 
@@ -169,7 +287,7 @@ func analyzeArgs(cmd *cobra.Command, args []string) error {
 	}
 	validateFlags()
 	if fAnalyzeAllUsers && len(args) > 0 {
-		fmt.Printf("WARNING: ignoring --all-users because a measurement metadata file is specidfied\n")
+		fmt.Printf("%s ignoring --all-users because a measurement metadata file is specidfied\n", common.Warning(common.WarnAllUsersIgnored))
 		fAnalyzeAllUsers = false
 	}
 	return nil
@@ -180,6 +298,7 @@ func analyze(cmd *cobra.Command, args []string) {
 	if err != nil {
 		fatal(err)
 	}
+	rows := []common.MeasurementRow{}
 	for _, measurement := range measurements {
 		if measSkip(measurement) {
 			continue
@@ -197,8 +316,20 @@ func analyze(cmd *cobra.Command, args []string) {
 		if measAgents(measurement.Agents) == 0 { // does not print anything
 			issues = append(issues, "has no agents")
 		}
+		if fAnalyzeFormat == "parquet" {
+			rows = append(rows, common.NewMeasurementRow(measurement, issues))
+			continue
+		}
 		printMeasDetails(measurement, issues)
 	}
+	if fAnalyzeFormat == "parquet" {
+		fmt.Printf("saving in %s\n", fAnalyzeOutput)
+		common.AddSummaryOutput(fAnalyzeOutput)
+		if err := common.WriteToSink(fAnalyzeOutput, func(file string) error { return common.WriteParquet(file, rows) }); err != nil {
+			fatal(err)
+		}
+		return
+	}
 	printAnalysis("all")
 }
 
@@ -210,6 +341,12 @@ func analyzeHoursArgs(cmd *cobra.Command, args []string) error {
 	if len(args) > 1 {
 		cliFatal("analyze hours takes at most one argument: <meas-md-file>")
 	}
+	if fHoursChart && fHoursHTML {
+		cliFatal("specify either --chart or --html")
+	}
+	if fHoursTemplate != "" && !fHoursHTML {
+		cliFatal("--template requires --html")
+	}
 	validateFlags()
 	return nil
 }
@@ -267,6 +404,10 @@ func analyzeHours(cmd *cobra.Command, args []string) {
 		if err := dotChart(measPerHour); err != nil {
 			fatal(err)
 		}
+	} else if fHoursHTML {
+		if err := heatmapReport(measPerHour, sortedDates, fHoursTemplate); err != nil {
+			fatal(err)
+		}
 	} else {
 		if err := textChart(measPerHour, sortedDates); err != nil {
 			fatal(err)
@@ -378,6 +519,49 @@ func analyzeStates(cmd *cobra.Command, args []string) {
 	}
 }
 
+func analyzeProjectsArgs(cmd *cobra.Command, args []string) error {
+	if format, ok := common.IsUsage(args); ok {
+		fmt.Printf(format, "<meas-md-file>", "optional: measurements metadata file")
+		return nil
+	}
+	if len(args) > 1 {
+		cliFatal("analyze projects takes at most one argument: <meas-md-file>")
+	}
+	validateFlags()
+	return nil
+}
+
+func analyzeProjects(cmd *cobra.Command, args []string) {
+	projects, err := common.ReadProjects()
+	if err != nil {
+		cliFatal(err, "\nprojects file format:", common.ProjectsFile)
+	}
+	names := fAnalyzeProject
+	if len(names) == 0 {
+		for name := range projects {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+	measurements, err := getMeasurements(args)
+	if err != nil {
+		fatal(err)
+	}
+	fmt.Printf("%-24s %5s %13s %8s %8s %7s\n", "project", "total", "agent_failure", "canceled", "finished", "ongoing")
+	for _, name := range names {
+		states := make(map[string]int)
+		total := 0
+		for _, measurement := range measurements {
+			if measSkip(measurement) || !common.Contains(projects[name], measurement.UserID) {
+				continue
+			}
+			total++
+			states[measurement.State]++
+		}
+		fmt.Printf("%-24s %5d %13d %8d %8d %7d\n", name, total, states["agent_failure"], states["canceled"], states["finished"], states["ongoing"])
+	}
+}
+
 func analyzeTablesArgs(cmd *cobra.Command, args []string) error {
 	if format, ok := common.IsUsage(args); ok {
 		fmt.Printf(format, "<meas-md-file>", "optional: measurements metadata file")
@@ -386,6 +570,9 @@ func analyzeTablesArgs(cmd *cobra.Command, args []string) error {
 	if len(args) > 1 {
 		cliFatal("analyze tables takes at most one argument: <meas-md-file>")
 	}
+	if !common.Contains(tablesSortKeys, fTablesSort) {
+		cliFatal("invalid --sort key: ", fTablesSort, " (one of these: ", strings.Join(tablesSortKeys, " "), ")")
+	}
 	validateFlags()
 	return nil
 }
@@ -406,8 +593,8 @@ func analyzeTables(cmd *cobra.Command, args []string) {
 	verbose("analyze tables of measurement(s)\n")
 
 	// Handle case 1.
-	ta, _ := time.Parse("2006-01-02", FirstMeasurementDate)
-	tb, _ := time.Parse("2006-01-02", LastMeasurementDate)
+	ta, _ := time.Parse("2006-01-02T15:04:05.000", FirstMeasurementDate)
+	tb, _ := time.Parse("2006-01-02T15:04:05.000", LastMeasurementDate)
 	if fAnalyzeAllUsers && len(args) == 0 && len(fAnalyzeTag) == 0 && len(fAnalyzeState) == 0 &&
 		fTablesMeasUUID == "" && ta.Equal(fAnalyzeAfter.Time) && tb.Equal(fAnalyzeBefore.Time) {
 		if err := analyzeTablesByName(); err != nil {
@@ -430,12 +617,196 @@ func analyzeTables(cmd *cobra.Command, args []string) {
 	}
 }
 
+func analyzeSQLArgs(cmd *cobra.Command, args []string) error {
+	if format, ok := common.IsUsage(args); ok {
+		fmt.Printf(format, "<query> [<meas-md-file>]", "SQL query on the "+SQLTable+" table and optional measurements metadata file")
+		return nil
+	}
+	if len(args) < 1 || len(args) > 2 {
+		cliFatal("analyze sql takes one or two arguments: <query> [<meas-md-file>]")
+	}
+	validateFlags()
+	return nil
+}
+
+func analyzeSQL(cmd *cobra.Command, args []string) {
+	measurements, err := getMeasurements(args[1:])
+	if err != nil {
+		fatal(err)
+	}
+	rows := []common.MeasurementRow{}
+	for _, measurement := range measurements {
+		if !measSkip(measurement) {
+			rows = append(rows, common.NewMeasurementRow(measurement, nil))
+		}
+	}
+	verbose("loading %d measurements into %s\n", len(rows), SQLTable)
+	output, err := runSQL(rows, args[0])
+	fmt.Print(string(output))
+	if err != nil {
+		fatal(err)
+	}
+}
+
+func analyzeParamsArgs(cmd *cobra.Command, args []string) error {
+	if format, ok := common.IsUsage(args); ok {
+		fmt.Printf(format, "<meas-md-file>", "optional: measurements metadata file")
+		return nil
+	}
+	if len(args) > 1 {
+		cliFatal("analyze params takes at most one argument: <meas-md-file>")
+	}
+	if len(fAnalyzeTag) == 0 {
+		cliFatal("analyze params requires at least one --tag to select a series of measurements")
+	}
+	validateFlags()
+	return nil
+}
+
+func analyzeParams(cmd *cobra.Command, args []string) {
+	measurements, err := getMeasurements(args)
+	if err != nil {
+		fatal(err)
+	}
+	if err := printParamChanges(measurements); err != nil {
+		fatal(err)
+	}
+}
+
+func analyzeSeasonalityArgs(cmd *cobra.Command, args []string) error {
+	if format, ok := common.IsUsage(args); ok {
+		fmt.Printf(format, "<meas-md-file>", "optional: measurements metadata file")
+		return nil
+	}
+	if len(args) > 1 {
+		cliFatal("analyze seasonality takes at most one argument: <meas-md-file>")
+	}
+	if fSeasonalityTop < 1 {
+		cliFatal("--top must be at least 1")
+	}
+	validateFlags()
+	return nil
+}
+
+func analyzeSeasonality(cmd *cobra.Command, args []string) {
+	measurements, err := getMeasurements(args)
+	if err != nil {
+		fatal(err)
+	}
+	printSeasonality(measurements, fSeasonalityTop)
+}
+
+func analyzeTrendArgs(cmd *cobra.Command, args []string) error {
+	if format, ok := common.IsUsage(args); ok {
+		fmt.Printf(format, "<meas-md-file>", "optional: measurements metadata file")
+		return nil
+	}
+	if len(args) > 1 {
+		cliFatal("analyze trend takes at most one argument: <meas-md-file>")
+	}
+	if fTrendDays < 1 {
+		cliFatal("--days must be at least 1")
+	}
+	if fTrendDrop <= 0 || fTrendDrop > 100 {
+		cliFatal("--drop must be between 0 and 100")
+	}
+	if fTrendSpike <= 0 {
+		cliFatal("--spike must be positive")
+	}
+	validateFlags()
+	return nil
+}
+
+func analyzeTrend(cmd *cobra.Command, args []string) {
+	measurements, err := getMeasurements(args)
+	if err != nil {
+		fatal(err)
+	}
+	volumes := dailyVolumes(measurements, time.Now().UTC(), fTrendDays)
+	alerts := printTrend(volumes, fTrendDrop, fTrendSpike)
+	if fTrendAlert && len(alerts) > 0 {
+		fatal(fmt.Errorf("%w: %s", ErrTrendAlert, strings.Join(alerts, "; ")))
+	}
+}
+
+func analyzeFailureCorrelationArgs(cmd *cobra.Command, args []string) error {
+	if format, ok := common.IsUsage(args); ok {
+		fmt.Printf(format, "<meas-md-file>", "optional: measurements metadata file")
+		return nil
+	}
+	if len(args) > 1 {
+		cliFatal("analyze failure-correlation takes at most one argument: <meas-md-file>")
+	}
+	if fFailureWindow <= 0 {
+		cliFatal("--window must be positive")
+	}
+	validateFlags()
+	return nil
+}
+
+func analyzeFailureCorrelation(cmd *cobra.Command, args []string) {
+	measurements, err := getMeasurements(args)
+	if err != nil {
+		fatal(err)
+	}
+	failures := agentFailures(measurements)
+	if len(failures) == 0 {
+		fmt.Println("no agent failures")
+		return
+	}
+	var since time.Time
+	for _, f := range failures {
+		if !f.time.IsZero() {
+			since = f.time.Add(-fFailureWindow)
+			break
+		}
+	}
+	events, err := common.GcloudInstanceEvents(since)
+	if err != nil {
+		fatal(err)
+	}
+	verbose("%d VM events since %v\n", len(events), since)
+	correlateFailures(failures, events, fFailureWindow)
+	printFailureCorrelation(failures)
+}
+
+func analyzeAgentReadinessArgs(cmd *cobra.Command, args []string) error {
+	if _, ok := common.IsUsage(args); ok {
+		return nil
+	}
+	if len(args) != 0 {
+		cliFatal("analyze agent-readiness does not take any arguments")
+	}
+	if fReadinessSince <= 0 || fReadinessSLO <= 0 {
+		cliFatal("--since and --slo must be positive")
+	}
+	if _, err := regexp.Compile(fReadinessRegexp); err != nil || strings.Contains(fReadinessRegexp, "'") {
+		cliFatal("--ready-pattern must be a valid regular expression without single quotes")
+	}
+	return nil
+}
+
+func analyzeAgentReadiness(cmd *cobra.Command, args []string) {
+	readiness, err := getAgentReadiness(time.Now().Add(-fReadinessSince), fReadinessRegexp)
+	if err != nil {
+		fatal(err)
+	}
+	if len(readiness) == 0 {
+		fmt.Printf("no agents (re)started in the last %v\n", fReadinessSince)
+		return
+	}
+	n := printAgentReadiness(readiness, fReadinessSLO)
+	if fReadinessAlert && n > 0 {
+		fatal(fmt.Errorf("%w: %d agent(s)", ErrReadinessSLO, n))
+	}
+}
+
 func analyzeTablesByName() error {
 	measTables, err := getAllMeasTables()
 	if err != nil {
 		return err
 	}
-	return printTables(measTables)
+	return printTables(measTables, "")
 }
 
 func analyzeTablesByMeasurement(measurements []common.Measurement) (int, error) {
@@ -454,18 +825,25 @@ func analyzeTablesByMeasurement(measurements []common.Measurement) (int, error)
 			if !errors.Is(err, common.ErrZeroLength) {
 				return n, err
 			}
-			fmt.Printf("WARNING: no ClickHouse tables for measurement %v\n", measurement.UUID)
+			fmt.Printf("%s no ClickHouse tables for measurement %v\n", common.Warning(common.WarnNoTables), measurement.UUID)
 			continue
 		}
 		n++
-		// Each measurement produces four tables: results_, prefixes_, links_, and _probes.
-		nFound := len(measTables)
+		// Each agent of a measurement produces up to four tables
+		// (results, prefixes, links, and probes) depending on the tool.
+		nFound := 0
+		for _, table := range measTables {
+			c, err := common.ClassifyTable(table.Name)
+			if tableMismatch(table.Name, c, err, measurement.UUID) == "" {
+				nFound++
+			}
+		}
 		output := fmt.Sprintf("%v [tags: %v] [state: %v] %d tables", measurement.UUID, strings.Join(measurement.Tags, ","), measurement.State, nFound)
-		nExpected := len(measurement.Agents) * 4
+		nExpected := len(measurement.Agents) * common.ExpectedTables(measurement.Tool)
 		if nFound != nExpected {
-			output = fmt.Sprintf("%s <== ERROR: expected %d", output, nExpected)
+			output = fmt.Sprintf("%s <== ERROR [%s]: expected %d", output, common.WarnExpectedTables, nExpected)
 		}
-		if viper.GetBool("verbose") {
+		if common.VerboseLevel() >= common.VerboseInfo {
 			fmt.Println(output)
 		} else {
 			fmt.Printf("%d %s", n, measurement.UUID)
@@ -475,18 +853,23 @@ func analyzeTablesByMeasurement(measurements []common.Measurement) (int, error)
 				fmt.Printf("\r")
 			}
 		}
-		if err := printTables(measTables); err != nil {
+		if err := printTables(measTables, measurement.UUID); err != nil {
 			return n, err
 		}
 	}
-	if !viper.GetBool("verbose") {
+	if common.VerboseLevel() < common.VerboseInfo {
 		fmt.Println()
 	}
 	return n, nil
 }
 
-func printTables(measTables []MeasTable) error {
+// printTables prints the tables grouped by measurement and then, in a
+// separate section, the tables whose names are unknown or are not the
+// names that Iris gives to the tables of the measurement with the
+// specified UUID (of any measurement if it is empty).
+func printTables(measTables []MeasTable, measUUID string) error {
 	data := map[string]tableDetails{}
+	var others []string
 	prevMeasUUID := ""
 	for _, table := range measTables {
 		modTime, err := time.Parse("2006-01-02 15:04:05", table.ModTime)
@@ -497,36 +880,63 @@ func printTables(measTables []MeasTable) error {
 			verbose("skipping %v\n", table.Name)
 			continue
 		}
-		measUUID, _, err := parseMeasAgentUUIDs(table.Name)
-		if err != nil {
-			return err
+		c, err := common.ClassifyTable(table.Name)
+		if reason := tableMismatch(table.Name, c, err, measUUID); reason != "" {
+			others = append(others, fmt.Sprintf("    %-86s %s", table.Name, reason))
+			continue
 		}
 		if prevMeasUUID == "" {
-			prevMeasUUID = measUUID
+			prevMeasUUID = c.MeasUUID
 		}
-		if prevMeasUUID != measUUID {
+		if prevMeasUUID != c.MeasUUID {
 			fmt.Printf("%v\n", prevMeasUUID)
 			printTableDetails(data)
 			data = map[string]tableDetails{}
-			prevMeasUUID = measUUID
+			prevMeasUUID = c.MeasUUID
 		}
 		data[table.Name] = tableDetails{
-			modTime: table.ModTime,
-			rows:    table.Rows,
-			bytes:   table.Bytes,
+			modTime:   table.ModTime,
+			rows:      table.Rows,
+			bytes:     table.Bytes,
+			agentUUID: c.AgentUUID,
 		}
 	}
 	printTableDetails(data)
+	if len(others) > 0 {
+		fmt.Println("unknown or mismatched tables:")
+		for _, other := range others {
+			fmt.Println(other)
+		}
+	}
 	return nil
 }
 
-func printTableDetails(data map[string]tableDetails) {
-	for _, tblName := range sortByKey(data) {
-		_, agentUUID, err := parseMeasAgentUUIDs(tblName)
-		if err != nil {
-			panic(err) // cannot happen
+// tableMismatch returns why the table with the specified name and
+// class (and error of common.ClassifyTable) is not a table of the
+// measurement with the specified UUID, or "" if it is.
+func tableMismatch(name string, c common.TableClass, err error, measUUID string) string {
+	switch {
+	case errors.Is(err, common.ErrUnknownTableType):
+		return fmt.Sprintf("unknown table type %s", c.Type)
+	case err != nil:
+		return "unknown table name"
+	case measUUID != "" && c.MeasUUID != measUUID:
+		return fmt.Sprintf("%s table of measurement %s", c.Type, c.MeasUUID)
+	case c.Inner:
+		return fmt.Sprintf("internal table of a materialized view of %s", common.TableName(c.Type, c.MeasUUID, c.AgentUUID))
+	case !c.Canonical(name):
+		if c.Variant != "" {
+			return fmt.Sprintf("%s table variant %s (expected %s)", c.Type, c.Variant, common.TableName(c.Type, c.MeasUUID, c.AgentUUID))
 		}
-		h := agents.GetAgentName(strings.ReplaceAll(agentUUID, "_", "-"))
+		return fmt.Sprintf("%s table with a non-canonical name (expected %s)", c.Type, common.TableName(c.Type, c.MeasUUID, c.AgentUUID))
+	}
+	return ""
+}
+
+func printTableDetails(data map[string]tableDetails) {
+	for _, tblName := range sortTables(data) {
+		tblDetails := data[tblName]
+		h := agents.GetAgentName(tblDetails.agentUUID)
 		skip := false
 		if len(fAnalyzeAgents) > 0 {
 			skip = true
@@ -540,39 +950,43 @@ func printTableDetails(data map[string]tableDetails) {
 		if skip {
 			continue
 		}
-		tblDetails := data[tblName]
 		output := fmt.Sprintf("    %-86s %s %10s %10s  %s", tblName, tblDetails.modTime, common.HumanReadable(tblDetails.rows), common.HumanReadable(tblDetails.bytes), h)
 		if tblDetails.rows == 0 || tblDetails.bytes == 0 {
-			output = fmt.Sprintf("%s <== WARNING: expected > 0", output)
+			output = fmt.Sprintf("%s <== %s expected > 0", output, common.Warning(common.WarnEmptyTable))
 		}
 		//output += "\n"
 		fmt.Println(output)
 	}
 }
 
-func sortByKey(data map[string]tableDetails) []string {
+// sortTables returns the table names in data sorted as specified by
+// the --sort and --desc flags.  Ties are broken by table name.
+func sortTables(data map[string]tableDetails) []string {
 	var keys []string
 	for key := range data {
 		keys = append(keys, key)
 	}
 	sort.Strings(keys)
-	return keys
-}
-
-func parseMeasAgentUUIDs(tableName string) (string, string, error) {
-	start := strings.Index(tableName, "__")
-	if start == -1 {
-		return "", "", ErrInvalidTableName
+	less := func(i, j int) bool {
+		a, b := data[keys[i]], data[keys[j]]
+		switch fTablesSort {
+		case "rows":
+			return a.rows < b.rows
+		case "bytes":
+			return a.bytes < b.bytes
+		case "modtime":
+			return a.modTime < b.modTime
+		case "agent":
+			return agents.GetAgentName(a.agentUUID) < agents.GetAgentName(b.agentUUID)
+		}
+		return keys[i] < keys[j]
 	}
-	start += 2
-	end := strings.Index(tableName[start:], "__")
-	if end == -1 {
-		return "", "", ErrInvalidTableName
+	if fTablesDesc {
+		sort.SliceStable(keys, func(i, j int) bool { return less(j, i) })
+	} else {
+		sort.SliceStable(keys, less)
 	}
-	measUUID := strings.ReplaceAll(tableName[start:start+end], "_", "-")
-	end += start + 2
-	agentUUID := tableName[end:]
-	return measUUID, agentUUID, nil
+	return keys
 }
 
 func textChart(measPerHour map[string]map[string]int, sortedDates []string) error {
@@ -584,7 +998,7 @@ func textChart(measPerHour map[string]map[string]int, sortedDates []string) erro
 	fmt.Println()
 	for _, date := range sortedDates {
 		// Any runs on this day?
-		if !viper.GetBool("verbose") {
+		if common.VerboseLevel() < common.VerboseInfo {
 			found := false
 			for _, hour := range hours {
 				number, exists := measPerHour[date][hour]
@@ -612,7 +1026,7 @@ func textChart(measPerHour map[string]map[string]int, sortedDates []string) erro
 }
 
 func printMeasDetails(measurement common.Measurement, issues []string) {
-	if !viper.GetBool("verbose") && len(issues) == 0 {
+	if common.VerboseLevel() < common.VerboseInfo && len(issues) == 0 {
 		return
 	}
 	c := time.Time(measurement.CreationTime.Time)
@@ -628,11 +1042,24 @@ func printMeasDetails(measurement common.Measurement, issues []string) {
 	fmt.Printf("%s %10s  ", e.Format("06-01-02.15:04:05"), e.Sub(s).Round(time.Second))
 	fmt.Printf("%q", measurement.Tags)
 	if len(issues) > 0 {
-		fmt.Printf(" <== WARNING: %v", strings.Join(issues, ","))
+		fmt.Printf(" <== %s %v", common.Warning(issueCodes(issues)), strings.Join(issues, ","))
 	}
 	fmt.Println()
 }
 
+// issueCodes returns the comma-separated warning codes of issues.
+func issueCodes(issues []string) string {
+	codes := map[string]string{
+		"took too long": common.WarnTooLong,
+		"has no agents": common.WarnNoAgents,
+	}
+	var c []string
+	for _, issue := range issues {
+		c = append(c, codes[issue])
+	}
+	return strings.Join(c, ",")
+}
+
 func printAnalysis(what string) {
 	if totFound == 0 {
 		fmt.Printf("nothing to print\n")
@@ -710,7 +1137,7 @@ func printAnalysis(what string) {
 
 func initHoursTable(measPerHour map[string]map[string]int) error {
 	currentDate := time.Now()
-	startDate, err := time.Parse("2006-01-02", FirstMeasurementDate)
+	startDate, err := time.Parse("2006-01-02T15:04:05.000", FirstMeasurementDate)
 	if err != nil {
 		return fmt.Errorf("failed to parse start date: %s", err)
 	}
@@ -731,6 +1158,9 @@ func measSkip(measurement common.Measurement) bool {
 	if len(fAnalyzeState) > 0 && !common.MatchState(measurement.State, fAnalyzeState) {
 		return true
 	}
+	if len(fAnalyzeProject) > 0 && !common.Contains(analyzeProjectUserIDs, measurement.UserID) {
+		return true
+	}
 	if !measurement.CreationTime.After(fAnalyzeAfter.Time) ||
 		!measurement.CreationTime.Before(fAnalyzeBefore.Time) {
 		return true
@@ -763,17 +1193,17 @@ func measAgents(agents []common.Agent) int {
 func measDuration(measurement common.Measurement) int {
 	c := time.Time(measurement.CreationTime.Time)
 	if c.Year() == 1 && c.Month() == 1 && c.Day() == 1 {
-		fmt.Printf("WARNING: skipping %s due to uninitialized creation time -- internal error?!\n", measurement.UUID)
+		fmt.Printf("%s skipping %s due to uninitialized creation time -- internal error?!\n", common.Warning(common.WarnNoCreationTime), measurement.UUID)
 		return DurationNone
 	}
 	s := time.Time(measurement.StartTime.Time)
 	if s.Year() == 1 && s.Month() == 1 && s.Day() == 1 {
-		fmt.Printf("WARNING: skipping %s due to uninitialized start time -- created at %v, waiting to start\n", measurement.UUID, c)
+		fmt.Printf("%s skipping %s due to uninitialized start time -- created at %v, waiting to start\n", common.Warning(common.WarnNoStartTime), measurement.UUID, c)
 		return DurationNone
 	}
 	e := time.Time(measurement.EndTime.Time)
 	if e.Year() == 1 && e.Month() == 1 && e.Day() == 1 {
-		fmt.Printf("WARNING: skipping %s due to uninitialized end time -- started at %v, waiting to end\n", measurement.UUID, s)
+		fmt.Printf("%s skipping %s due to uninitialized end time -- started at %v, waiting to end\n", common.Warning(common.WarnNoEndTime), measurement.UUID, s)
 		return DurationNone
 	}
 	durationCS = append(durationCS, float64(s.Sub(c).Seconds()))
@@ -798,13 +1228,32 @@ func getMeasurements(args []string) ([]common.Measurement, error) {
 			return nil, err
 		}
 	}
-	return common.GetMeasurementsSorted(measMdFile)
+	measurements, err := common.GetMeasurementsSorted(measMdFile)
+	if err != nil {
+		return nil, err
+	}
+	if err := common.WarnUnmatchedTags(fAnalyzeTag, measurements, cache.Tags()); err != nil {
+		return nil, err
+	}
+	if err := common.WarnUnmatchedAgents(fAnalyzeAgents, measurements, cache.Hostnames()); err != nil {
+		return nil, err
+	}
+	return measurements, nil
 }
 
 func validateFlags() {
+	if fAnalyzeFormat != "text" && fAnalyzeFormat != "parquet" {
+		cliFatal("invalid --format: ", fAnalyzeFormat, " (one of these: text parquet)")
+	}
 	if len(fAnalyzeState) > 0 {
 		if s, err := common.ValidateState(fAnalyzeState); err != nil {
 			cliFatal(fmt.Sprintf("%v: %v", s, err))
 		}
 	}
+	if len(fAnalyzeProject) > 0 {
+		var err error
+		if analyzeProjectUserIDs, err = common.ProjectUserIDs(fAnalyzeProject); err != nil {
+			cliFatal(err)
+		}
+	}
 }
@@ -0,0 +1,39 @@
+package analyze
+
+import (
+	"testing"
+
+	"github.com/dioptra-io/irisctl/internal/common"
+)
+
+func TestTableMismatch(t *testing.T) {
+	const (
+		measUUID  = "a75482d1-8c5c-4d56-845e-fc3861047992"
+		otherUUID = "0b6f6c8e-2f0e-4d4b-9a51-6a3e2c1d9f00"
+		agentUUID = "3f2504e0-4f89-11d3-9a0c-0305e82c3301"
+	)
+	canonical := common.TableName("results", measUUID, agentUUID)
+	tests := []struct {
+		name     string
+		table    string
+		measUUID string
+		want     string
+	}{
+		{"canonical", canonical, measUUID, ""},
+		{"any measurement", canonical, "", ""},
+		{"other measurement", common.TableName("links", otherUUID, agentUUID), measUUID, "links table of measurement " + otherUUID},
+		{"unknown type", "traces" + canonical[len("results"):], measUUID, "unknown table type traces"},
+		{"unknown name", "query_log", measUUID, "unknown table name"},
+		{"materialized view", ".inner." + canonical, measUUID, "internal table of a materialized view of " + canonical},
+		{"variant", common.TableName("probes", measUUID, agentUUID) + "__round_2", measUUID, "probes table variant round_2 (expected " + common.TableName("probes", measUUID, agentUUID) + ")"},
+		{"non-canonical", "results__" + measUUID + "__" + agentUUID, measUUID, "results table with a non-canonical name (expected " + canonical + ")"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := common.ClassifyTable(tt.table)
+			if got := tableMismatch(tt.table, c, err, tt.measUUID); got != tt.want {
+				t.Errorf("tableMismatch(%q, %q) = %q, want %q", tt.table, tt.measUUID, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package analyze
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dioptra-io/irisctl/internal/common"
+)
+
+var (
+	// VM events that are caused by the infrastructure (i.e., not by
+	// the agent software), keyed by the suffix of their operation type.
+	infraEvents = map[string]string{
+		"preempted":                "preemption",
+		"migrateOnHostMaintenance": "live migration",
+		"hostError":                "host error",
+		"automaticRestart":         "automatic restart",
+		"reset":                    "reset",
+		"stop":                     "stop",
+		"start":                    "start",
+		"guestTerminate":           "guest terminate",
+	}
+)
+
+// agentFailure defines an agent in state agent_failure in a measurement
+// and the VM events of its instance around the time of the failure.
+type agentFailure struct {
+	measUUID string
+	hostname string
+	time     time.Time
+	events   []common.InstanceEvent
+}
+
+// agentFailures returns the agents in state agent_failure in the
+// specified measurements, oldest failure first.
+func agentFailures(measurements []common.Measurement) []agentFailure {
+	var failures []agentFailure
+	for _, measurement := range measurements {
+		if measSkip(measurement) {
+			verbose("skipping %v\n", measurement.UUID)
+			continue
+		}
+		for _, agent := range measurement.Agents {
+			if agent.State != "agent_failure" {
+				continue
+			}
+			hostname := agent.AgentParameters.Hostname
+			if hostname == "" {
+				hostname = agent.AgentUUID
+			}
+			failures = append(failures, agentFailure{
+				measUUID: measurement.UUID,
+				hostname: hostname,
+				time:     failureTime(measurement, agent),
+			})
+		}
+	}
+	sort.SliceStable(failures, func(i, j int) bool { return failures[i].time.Before(failures[j].time) })
+	return failures
+}
+
+// failureTime returns the time of the last activity of the agent in the
+// measurement, which is the closest the metadata gets to the time of
+// its failure.  If the agent has no probing statistics, the end time
+// (or else the start time) of the measurement is used.
+func failureTime(measurement common.Measurement, agent common.Agent) time.Time {
+	var last time.Time
+	for _, stats := range agent.ProbingStatistics {
+		for _, s := range []string{stats.StartTime, stats.EndTime} {
+			var t common.CustomTime
+			if err := t.UnmarshalJSON([]byte(strconv.Quote(s))); err == nil && t.After(last) {
+				last = t.Time
+			}
+		}
+	}
+	if last.IsZero() {
+		last = measurement.EndTime.Time
+	}
+	if last.IsZero() {
+		last = measurement.StartTime.Time
+	}
+	return last
+}
+
+// correlateFailures attaches to each failure the infrastructure events
+// of the instance of its agent that happened within window of it.
+func correlateFailures(failures []agentFailure, events []common.InstanceEvent, window time.Duration) {
+	for i := range failures {
+		f := &failures[i]
+		for _, e := range events {
+			if e.Instance() != f.hostname || eventName(e) == "" {
+				continue
+			}
+			if d := e.InsertTime.Sub(f.time); d >= -window && d <= window {
+				f.events = append(f.events, e)
+			}
+		}
+	}
+}
+
+// eventName returns the name of an infrastructure event or an empty
+// string if the event is not in infraEvents.
+func eventName(e common.InstanceEvent) string {
+	return infraEvents[e.OperationType[strings.LastIndex(e.OperationType, ".")+1:]]
+}
+
+// printFailureCorrelation prints the agent failures with their cause
+// followed by a summary.
+func printFailureCorrelation(failures []agentFailure) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "MEASUREMENT\tAGENT\tFAILED\tCAUSE\tVM EVENTS\n")
+	nInfra := 0
+	for _, f := range failures {
+		failed := "-"
+		if !f.time.IsZero() {
+			failed = f.time.UTC().Format(time.RFC3339)
+		}
+		cause := "software?"
+		var events []string
+		for _, e := range f.events {
+			events = append(events, fmt.Sprintf("%s %s (%+v)", eventName(e), e.InsertTime.UTC().Format(time.RFC3339), e.InsertTime.Sub(f.time).Round(time.Second)))
+		}
+		if len(events) > 0 {
+			cause = "infrastructure"
+			nInfra++
+		} else {
+			events = []string{"-"}
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", f.measUUID, f.hostname, failed, cause, strings.Join(events, ", "))
+	}
+	w.Flush()
+	fmt.Printf("%d agent failures: %d coincide with VM events (infrastructure), %d do not (possibly software)\n", len(failures), nInfra, len(failures)-nInfra)
+}
@@ -0,0 +1,133 @@
+package analyze
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/dioptra-io/irisctl/internal/common"
+)
+
+// paramChange defines a tool parameter whose value changed between two
+// consecutive measurements of a series.
+type paramChange struct {
+	name string
+	from string
+	to   string
+}
+
+// measParams returns the tool parameters of the measurement keyed by
+// their JSON names (e.g., failure_probability, flow_mapper_kwargs.seed).
+// If agents of the measurement have different values for the same
+// parameter, all distinct values are returned separated by commas.
+func measParams(measurement common.Measurement) (map[string]string, error) {
+	values := map[string]map[string]bool{}
+	for _, agent := range measurement.Agents {
+		jsonData, err := json.Marshal(agent.ToolParameters)
+		if err != nil {
+			return nil, err
+		}
+		var m map[string]interface{}
+		if err := json.Unmarshal(jsonData, &m); err != nil {
+			return nil, err
+		}
+		flattenParams("", m, values)
+	}
+	params := map[string]string{}
+	for name, set := range values {
+		var vals []string
+		for v := range set {
+			vals = append(vals, v)
+		}
+		sort.Strings(vals)
+		params[name] = strings.Join(vals, ",")
+	}
+	return params, nil
+}
+
+func flattenParams(prefix string, m map[string]interface{}, values map[string]map[string]bool) {
+	for k, v := range m {
+		name := prefix + k
+		if nested, ok := v.(map[string]interface{}); ok {
+			flattenParams(name+".", nested, values)
+			continue
+		}
+		if values[name] == nil {
+			values[name] = map[string]bool{}
+		}
+		values[name][fmt.Sprintf("%v", v)] = true
+	}
+}
+
+// diffParams returns the parameters that changed from prev to cur
+// sorted by name.
+func diffParams(prev, cur map[string]string) []paramChange {
+	var changes []paramChange
+	for name, v := range cur {
+		if prev[name] != v {
+			changes = append(changes, paramChange{name, prev[name], v})
+		}
+	}
+	for name, v := range prev {
+		if _, ok := cur[name]; !ok {
+			changes = append(changes, paramChange{name, v, ""})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].name < changes[j].name
+	})
+	return changes
+}
+
+// printParamChanges prints the tool parameters of the first
+// measurement and then the parameters that changed in each of the
+// following measurements.
+func printParamChanges(measurements []common.Measurement) error {
+	var prev map[string]string
+	nMeas, nChanged := 0, 0
+	for _, measurement := range measurements {
+		if measSkip(measurement) || len(measurement.Agents) == 0 {
+			continue
+		}
+		params, err := measParams(measurement)
+		if err != nil {
+			return err
+		}
+		nMeas++
+		created := measurement.CreationTime.Format("2006-01-02 15:04:05")
+		if prev == nil {
+			fmt.Printf("%s %s initial parameters\n", created, measurement.UUID)
+			var names []string
+			for name := range params {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				fmt.Printf("    %-24s %s\n", name, paramValue(params[name]))
+			}
+			prev = params
+			continue
+		}
+		changes := diffParams(prev, params)
+		if len(changes) == 0 {
+			verbose("%s %s no changes\n", created, measurement.UUID)
+			continue
+		}
+		nChanged++
+		fmt.Printf("%s %s\n", created, measurement.UUID)
+		for _, c := range changes {
+			fmt.Printf("    %-24s %s -> %s\n", c.name, paramValue(c.from), paramValue(c.to))
+		}
+		prev = params
+	}
+	fmt.Printf("\nparameters changed in %d of %d measurements\n", nChanged, nMeas)
+	return nil
+}
+
+func paramValue(v string) string {
+	if v == "" {
+		return "(none)"
+	}
+	return v
+}
@@ -0,0 +1,165 @@
+package analyze
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dioptra-io/irisctl/internal/agents"
+	"github.com/dioptra-io/irisctl/internal/common"
+)
+
+var (
+	ErrReadinessSLO = errors.New("agent readiness SLO exceeded")
+)
+
+// agentReadiness defines when an agent was (re)started and when it
+// logged that it was ready.
+type agentReadiness struct {
+	hostname       string
+	state          string
+	vmStart        time.Time
+	containerStart time.Time
+	ready          time.Time
+	start          time.Time
+	err            error
+}
+
+// timeToReady returns the time between start and ready or zero if the
+// agent is not ready.
+func (r agentReadiness) timeToReady() time.Duration {
+	if r.ready.IsZero() || r.start.IsZero() {
+		return 0
+	}
+	return r.ready.Sub(r.start)
+}
+
+// getAgentReadiness returns the readiness of the agents whose VM or
+// container was started after since, sorted by hostname.
+func getAgentReadiness(since time.Time, readyPattern string) ([]agentReadiness, error) {
+	jsonData, err := agents.GetAgents("", false)
+	if err != nil {
+		return nil, err
+	}
+	var agentsData common.AgentsData
+	if err := common.DecodeJSON(jsonData, &agentsData); err != nil {
+		return nil, err
+	}
+	vmStarts, err := common.GcloudInstanceStarts()
+	if err != nil {
+		return nil, err
+	}
+	// The container start time is printed first, followed by the
+	// first log line since then that matches readyPattern.
+	remoteCmd := fmt.Sprintf("s=$(docker inspect --format '{{.State.StartedAt}}' iris-agent) && echo $s && docker logs --timestamps --since $s iris-agent 2>&1 | grep -m 1 -E '%s' || true", readyPattern)
+	var wg sync.WaitGroup
+	readiness := make([]agentReadiness, len(agentsData.Results))
+	for i, result := range agentsData.Results {
+		hostname := result.Parameters.Hostname
+		readiness[i] = agentReadiness{hostname: hostname, state: result.State, vmStart: vmStarts[hostname]}
+		wg.Add(1)
+		go func(r *agentReadiness) {
+			defer wg.Done()
+			verbose("checking agent %v\n", r.hostname)
+			output, err := common.SSH(r.hostname, remoteCmd)
+			if err != nil {
+				r.err = err
+				return
+			}
+			r.containerStart, r.ready = parseReadiness(output)
+			// Readiness is measured from the start of the VM if it
+			// was restarted since and the container started with it,
+			// from the start of the container otherwise.
+			r.start = r.containerStart
+			if r.vmStart.After(since) && !r.containerStart.Before(r.vmStart) {
+				r.start = r.vmStart
+			}
+		}(&readiness[i])
+	}
+	wg.Wait()
+	var recent []agentReadiness
+	for _, r := range readiness {
+		if r.err != nil {
+			fmt.Fprintf(os.Stderr, "WARNING: %s: %v\n", r.hostname, r.err)
+			continue
+		}
+		if r.start.After(since) {
+			recent = append(recent, r)
+		} else {
+			verbose("skipping %s: not started since %v\n", r.hostname, since)
+		}
+	}
+	sort.SliceStable(recent, func(i, j int) bool { return recent[i].hostname < recent[j].hostname })
+	return recent, nil
+}
+
+// parseReadiness returns the container start time and the time of the
+// ready log line in the output of the remote command of
+// getAgentReadiness.  Both times are zero if they cannot be parsed.
+func parseReadiness(output []string) (time.Time, time.Time) {
+	var containerStart, ready time.Time
+	for i, line := range output {
+		line = strings.TrimSpace(line)
+		if i == 0 || line == "" || strings.HasPrefix(line, "Connection to ") {
+			continue
+		}
+		timestamp, _, _ := strings.Cut(line, " ")
+		t, err := time.Parse(time.RFC3339Nano, timestamp)
+		if err != nil {
+			continue
+		}
+		if containerStart.IsZero() {
+			containerStart = t
+		} else if ready.IsZero() {
+			ready = t
+		}
+	}
+	return containerStart, ready
+}
+
+// printAgentReadiness prints the readiness of the agents followed by a
+// summary and returns the number of agents that exceeded the SLO or
+// are not ready.
+func printAgentReadiness(readiness []agentReadiness, slo time.Duration) int {
+	format := func(t time.Time) string {
+		if t.IsZero() {
+			return "-"
+		}
+		return t.UTC().Format(time.RFC3339)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "AGENT\tVM START\tCONTAINER START\tREADY\tTIME-TO-READY\tSTATUS\n")
+	var ttrs []time.Duration
+	nExceeded := 0
+	for _, r := range readiness {
+		ttr := "-"
+		status := "ok"
+		switch {
+		case r.ready.IsZero() || (r.state != "idle" && r.state != "working"):
+			status = fmt.Sprintf("not ready (%s)", r.state)
+			nExceeded++
+		case r.timeToReady() > slo:
+			status = "SLO exceeded"
+			nExceeded++
+		}
+		if d := r.timeToReady(); d > 0 {
+			ttr = d.Round(time.Second).String()
+			ttrs = append(ttrs, d)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", r.hostname, format(r.vmStart), format(r.containerStart), format(r.ready), ttr, status)
+	}
+	w.Flush()
+	if len(ttrs) == 0 {
+		fmt.Printf("%d recently (re)started agents, none ready\n", len(readiness))
+		return nExceeded
+	}
+	sort.Slice(ttrs, func(i, j int) bool { return ttrs[i] < ttrs[j] })
+	fmt.Printf("%d recently (re)started agents: median time-to-ready %v, max %v, %d exceeded the SLO of %v or are not ready\n",
+		len(readiness), ttrs[len(ttrs)/2].Round(time.Second), ttrs[len(ttrs)-1].Round(time.Second), nExceeded, slo)
+	return nExceeded
+}
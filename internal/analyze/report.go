@@ -0,0 +1,92 @@
+package analyze
+
+import (
+	"embed"
+	"fmt"
+	"html/template"
+	"os"
+	"time"
+
+	"github.com/dioptra-io/irisctl/internal/common"
+)
+
+// The default templates are embedded in the binary so reports can
+// be generated without any external files.
+//
+//go:embed templates/*.html
+var templates embed.FS
+
+type heatmapCell struct {
+	Count int
+	Alpha float64
+}
+
+type heatmapRow struct {
+	Date  string
+	Cells []heatmapCell
+}
+
+type heatmapData struct {
+	Title     string
+	Generated string
+	Total     int
+	Hours     []string
+	Rows      []heatmapRow
+}
+
+// heatmapReport writes an HTML heatmap of the number of measurements
+// per hour per day.  If templateFile is not empty, it is used instead
+// of the embedded template.
+func heatmapReport(measPerHour map[string]map[string]int, sortedDates []string, templateFile string) error {
+	tmpl, err := loadTemplate("hours.html", templateFile)
+	if err != nil {
+		return err
+	}
+	max := 0
+	for _, date := range sortedDates {
+		for _, hour := range hours {
+			if measPerHour[date][hour] > max {
+				max = measPerHour[date][hour]
+			}
+		}
+	}
+	data := heatmapData{
+		Title:     "Number of Measurements by Hours",
+		Generated: time.Now().Format("2006-01-02 15:04:05"),
+		Hours:     hours,
+	}
+	for _, date := range sortedDates {
+		row := heatmapRow{Date: date}
+		found := false
+		for _, hour := range hours {
+			n := measPerHour[date][hour]
+			cell := heatmapCell{Count: n}
+			if n > 0 {
+				found = true
+				data.Total += n
+				cell.Alpha = 0.2 + 0.8*float64(n)/float64(max)
+			}
+			row.Cells = append(row.Cells, cell)
+		}
+		if found {
+			data.Rows = append(data.Rows, row)
+		}
+	}
+
+	f, err := os.Create("measurements_per_hour.html")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fmt.Printf("saving in %s\n", f.Name())
+	common.AddSummaryOutput(f.Name())
+	return tmpl.Execute(f, data)
+}
+
+func loadTemplate(name, templateFile string) (*template.Template, error) {
+	if templateFile != "" {
+		verbose("using template file %s\n", templateFile)
+		return template.ParseFiles(templateFile)
+	}
+	return template.ParseFS(templates, "templates/"+name)
+}
@@ -0,0 +1,120 @@
+package analyze
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/dioptra-io/irisctl/internal/common"
+)
+
+// seasonWindow defines a one-hour window of the week.
+type seasonWindow struct {
+	weekday time.Weekday
+	hour    int
+	count   int
+}
+
+// measStart returns the start time of the measurement or its creation
+// time if it has not started.
+func measStart(measurement common.Measurement) time.Time {
+	if measurement.StartTime.IsZero() {
+		return measurement.CreationTime.Time
+	}
+	return measurement.StartTime.Time
+}
+
+// seasonality returns the number of measurement starts per weekday
+// and hour of day (UTC) and the total number of starts.
+func seasonality(measurements []common.Measurement) ([7][24]int, int) {
+	var counts [7][24]int
+	total := 0
+	for _, measurement := range measurements {
+		if measSkip(measurement) {
+			continue
+		}
+		t := measStart(measurement).UTC()
+		counts[t.Weekday()][t.Hour()]++
+		total++
+	}
+	return counts, total
+}
+
+// busiestWindows returns the top one-hour windows of the week with the
+// most measurement starts.
+func busiestWindows(counts [7][24]int, top int) []seasonWindow {
+	var windows []seasonWindow
+	for d := 0; d < 7; d++ {
+		for h := 0; h < 24; h++ {
+			if counts[d][h] > 0 {
+				windows = append(windows, seasonWindow{time.Weekday(d), h, counts[d][h]})
+			}
+		}
+	}
+	sort.SliceStable(windows, func(i, j int) bool {
+		return windows[i].count > windows[j].count
+	})
+	if len(windows) > top {
+		windows = windows[:top]
+	}
+	return windows
+}
+
+// printSeasonality prints the number of measurement starts per weekday
+// and hour of day, the totals of each, and the busiest windows.
+func printSeasonality(measurements []common.Measurement, top int) {
+	counts, total := seasonality(measurements)
+	if total == 0 {
+		fmt.Println("no matching measurements")
+		return
+	}
+
+	var perHour [24]int
+	var perDay [7]int
+	fmt.Printf("UTC  ")
+	for _, hour := range hours {
+		fmt.Printf("%s ", hour)
+	}
+	fmt.Printf("total\n")
+	// Start the week on Monday.
+	for i := 1; i <= 7; i++ {
+		d := time.Weekday(i % 7)
+		fmt.Printf("%s  ", d.String()[:3])
+		for h := 0; h < 24; h++ {
+			perHour[h] += counts[d][h]
+			perDay[d] += counts[d][h]
+			if counts[d][h] == 0 {
+				fmt.Printf(" . ")
+				continue
+			}
+			fmt.Printf("%2d ", counts[d][h])
+		}
+		fmt.Printf("%5d\n", perDay[d])
+	}
+	fmt.Printf("all  ")
+	for h := 0; h < 24; h++ {
+		fmt.Printf("%2d ", perHour[h])
+	}
+	fmt.Printf("%5d\n", total)
+
+	busiestHour, busiestDay := 0, time.Monday
+	for h := 0; h < 24; h++ {
+		if perHour[h] > perHour[busiestHour] {
+			busiestHour = h
+		}
+	}
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if perDay[d] > perDay[busiestDay] {
+			busiestDay = d
+		}
+	}
+	fmt.Printf("\nbusiest hour of day: %02d:00-%02d:00 UTC (%d starts, %.1f%%)\n",
+		busiestHour, busiestHour+1, perHour[busiestHour], 100*float64(perHour[busiestHour])/float64(total))
+	fmt.Printf("busiest day of week: %s (%d starts, %.1f%%)\n",
+		busiestDay, perDay[busiestDay], 100*float64(perDay[busiestDay])/float64(total))
+	fmt.Printf("\nbusiest windows:\n")
+	for _, w := range busiestWindows(counts, top) {
+		fmt.Printf("    %-9s %02d:00-%02d:00 UTC %5d starts %5.1f%%\n",
+			w.weekday, w.hour, w.hour+1, w.count, 100*float64(w.count)/float64(total))
+	}
+}
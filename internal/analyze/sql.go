@@ -0,0 +1,61 @@
+package analyze
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/dioptra-io/irisctl/internal/common"
+)
+
+const (
+	// SQLTable is the name of the table that analyze sql creates from
+	// the measurements that match the command line filters.
+	SQLTable = "measurements"
+
+	// SQLSchema documents the columns of SQLTable (see
+	// common.MeasurementRow).
+	SQLSchema = `    uuid             VARCHAR      measurement UUID
+    user_id          VARCHAR      UUID of the user who submitted the measurement
+    tool             VARCHAR      probing tool (e.g., diamond-miner, yarrp, ping)
+    state            VARCHAR      agent_failure, canceled, finished, or ongoing
+    tags             VARCHAR[]    measurement tags
+    creation_time    TIMESTAMP    when the measurement was created
+    start_time       TIMESTAMP    when the measurement started (NULL if not started)
+    end_time         TIMESTAMP    when the measurement ended (NULL if not ended)
+    agents           INTEGER      number of agents the measurement was requested to run on
+    agents_finished  INTEGER      number of agents that finished
+    issues           VARCHAR[]    not set by analyze sql (see analyze --format parquet)`
+)
+
+// runSQL loads the specified rows into an in-memory DuckDB database
+// as SQLTable and executes query against it.  The rows are handed
+// to the duckdb command line tool through a temporary Parquet file.
+func runSQL(rows []common.MeasurementRow, query string) ([]byte, error) {
+	if _, err := exec.LookPath("duckdb"); err != nil {
+		return nil, fmt.Errorf("%w: install duckdb to use analyze sql", err)
+	}
+	f, err := common.CreateTemp("irisctl-analyze-sql-*.parquet")
+	if err != nil {
+		return nil, err
+	}
+	f.Close()
+	if common.RootFlagBool("no-delete") {
+		common.SavingIn(f.Name())
+	} else {
+		defer func(f string) { verbose("removing %s\n", f); os.Remove(f) }(f.Name())
+	}
+	if err := common.WriteParquet(f.Name(), rows); err != nil {
+		return nil, err
+	}
+
+	load := fmt.Sprintf("CREATE TABLE %s AS SELECT * FROM read_parquet('%s');", SQLTable, f.Name())
+	cmd := exec.Command("duckdb", "-c", load+"\n"+strings.TrimSpace(query))
+	verbose("%v\n", cmd)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return output, fmt.Errorf("duckdb: %w", err)
+	}
+	return output, nil
+}
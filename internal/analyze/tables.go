@@ -2,7 +2,9 @@ package analyze
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"strings"
 	"time"
 
@@ -91,18 +93,20 @@ func getOneMeasTables(uuid string) ([]MeasTable, error) {
 }
 
 func parseMeasTables(filename string) ([]MeasTable, error) {
-	contents, err := common.ReadCompressedFile(filename)
+	r, err := common.OpenCompressedFile(filename)
 	if err != nil {
 		return nil, err
 	}
-	lines := strings.Split(contents, "\n")
+	defer r.Close()
+	// The file has one JSON object per line (JSONEachRow format).
+	dec := json.NewDecoder(r)
 	measTables := []MeasTable{}
-	for _, line := range lines {
-		if line == "" {
-			continue
-		}
+	for {
 		var t MeasTable
-		if err := json.Unmarshal([]byte(line), &t); err != nil {
+		if err := dec.Decode(&t); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
 			return measTables, err
 		}
 		measTables = append(measTables, t)
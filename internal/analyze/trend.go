@@ -0,0 +1,101 @@
+package analyze
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dioptra-io/irisctl/internal/common"
+)
+
+var (
+	ErrTrendAlert = errors.New("measurement volume alert")
+)
+
+// dayVolume defines the number of measurements created in a day and
+// their data volume (i.e., the packets received by their agents).
+type dayVolume struct {
+	date    string
+	count   int
+	packets int
+}
+
+// dailyVolumes returns the volumes of the specified number of days
+// before today (UTC) followed by the volume of today.
+func dailyVolumes(measurements []common.Measurement, today time.Time, days int) []dayVolume {
+	volumes := make([]dayVolume, days+1)
+	index := make(map[string]int)
+	for i := range volumes {
+		volumes[i].date = today.AddDate(0, 0, i-days).Format("2006-01-02")
+		index[volumes[i].date] = i
+	}
+	for _, measurement := range measurements {
+		if measSkip(measurement) {
+			continue
+		}
+		i, ok := index[measurement.CreationTime.UTC().Format("2006-01-02")]
+		if !ok {
+			continue
+		}
+		volumes[i].count++
+		for _, agent := range measurement.Agents {
+			for _, stats := range agent.ProbingStatistics {
+				volumes[i].packets += stats.PacketsReceived
+			}
+		}
+	}
+	return volumes
+}
+
+// trendChange returns the change of value relative to the average in
+// percent and whether it is a drop of at least drop percent or a spike
+// of at least spike percent.  There is no alert without a baseline
+// (i.e., if the average is zero).
+func trendChange(value int, average, drop, spike float64) (float64, bool) {
+	if average == 0 {
+		return 0, false
+	}
+	change := 100 * (float64(value) - average) / average
+	return change, change <= -drop || change >= spike
+}
+
+// printTrend prints the daily volumes and the change of today's volume
+// relative to the trailing average of the previous days and returns
+// the alerts.
+func printTrend(volumes []dayVolume, drop, spike float64) []string {
+	days := len(volumes) - 1
+	var sumCount, sumPackets int
+	fmt.Printf("date        count     packets\n")
+	for _, v := range volumes {
+		fmt.Printf("%s  %5d  %10d\n", v.date, v.count, v.packets)
+	}
+	for _, v := range volumes[:days] {
+		sumCount += v.count
+		sumPackets += v.packets
+	}
+	avgCount := float64(sumCount) / float64(days)
+	avgPackets := float64(sumPackets) / float64(days)
+	today := volumes[days]
+
+	var alerts []string
+	fmt.Printf("\n%d-day trailing average: %.1f measurements, %.0f packets\n", days, avgCount, avgPackets)
+	for _, m := range []struct {
+		name    string
+		value   int
+		average float64
+	}{
+		{"measurement(s)", today.count, avgCount},
+		{"packets", today.packets, avgPackets},
+	} {
+		change, alert := trendChange(m.value, m.average, drop, spike)
+		if m.average == 0 {
+			fmt.Printf("%s: %d %s (no baseline)\n", today.date, m.value, m.name)
+			continue
+		}
+		fmt.Printf("%s: %d %s (%+.1f%%)\n", today.date, m.value, m.name, change)
+		if alert {
+			alerts = append(alerts, fmt.Sprintf("%s: %d %s differs by %+.1f%% from the %d-day average %.1f", today.date, m.value, m.name, change, days, m.average))
+		}
+	}
+	return alerts
+}
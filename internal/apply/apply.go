@@ -0,0 +1,260 @@
+// Package apply implements a command for declaratively managing the
+// users, agent tags, and recurring measurements of an Iris instance
+// (not in the Iris API).
+package apply
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/dioptra-io/irisctl/internal/agents"
+	"github.com/dioptra-io/irisctl/internal/common"
+	"github.com/dioptra-io/irisctl/internal/meas"
+	"github.com/dioptra-io/irisctl/internal/users"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// DesiredState defines the desired state of an Iris instance.  The
+// state may be split across several YAML files.
+type DesiredState struct {
+	Users        []map[string]interface{} `yaml:"users"`
+	Agents       []DesiredAgent           `yaml:"agents"`
+	Measurements []DesiredMeasurement     `yaml:"measurements"`
+}
+
+// DesiredAgent defines the desired tags of an agent.
+type DesiredAgent struct {
+	Hostname string   `yaml:"hostname"`
+	Tags     []string `yaml:"tags"`
+}
+
+// DesiredMeasurement defines a recurring measurement.  Request is the
+// measurement definition in the format of meas request.
+type DesiredMeasurement struct {
+	Name    string                 `yaml:"name"`
+	Every   string                 `yaml:"every"`
+	Request map[string]interface{} `yaml:"request"`
+}
+
+var (
+	// Command, its flags, subcommands, and their flags.
+	//	apply -f <file-or-dir>... [--dry-run]
+	cmdName     = "apply"
+	subcmdNames = []string{}
+	fFiles      []string
+	fDryRun     bool
+
+	// Errors.
+	ErrInvalidState = errors.New("invalid desired state")
+	ErrDrift        = errors.New("drift that cannot be applied")
+
+	// Test code changes Fatal to Panic so a fatal error won't exit
+	// the process and can be recovered.
+	fatal    = log.Fatal
+	cliFatal = common.CliFatal
+	verbose  = common.Verbose
+)
+
+// ApplyCmd returns the command structure for apply.
+func ApplyCmd() *cobra.Command {
+	applyCmd := &cobra.Command{
+		Use:       cmdName,
+		ValidArgs: subcmdNames,
+		Short:     "apply a desired state",
+		Long:      "make the users, agent tags, and recurring measurements of the Iris instance match the desired state in the specified YAML file(s)",
+		Args:      applyArgs,
+		Run:       apply,
+	}
+	applyCmd.Flags().StringArrayVarP(&fFiles, "filename", "f", []string{}, "repeatable: YAML file or directory of YAML files with the desired state")
+	applyCmd.Flags().BoolVar(&fDryRun, "dry-run", false, "enable dry-run mode (i.e., only print the changes)")
+	applyCmd.SetUsageFunc(common.Usage)
+	applyCmd.SetHelpFunc(common.Help)
+
+	return applyCmd
+}
+
+func applyArgs(cmd *cobra.Command, args []string) error {
+	if _, ok := common.IsUsage(args); ok {
+		return nil
+	}
+	if len(args) != 0 {
+		cliFatal("apply does not take any arguments")
+	}
+	if len(fFiles) == 0 {
+		cliFatal("apply requires at least one -f <file-or-dir>")
+	}
+	return nil
+}
+
+func apply(cmd *cobra.Command, args []string) {
+	state, err := readDesiredState(fFiles)
+	if err != nil {
+		fatal(err)
+	}
+	recurring, err := recurringMeasurements(state.Measurements)
+	if err != nil {
+		fatal(err)
+	}
+
+	nUsers, err := users.ApplyUsers(state.Users, fDryRun)
+	if err != nil {
+		fatal(err)
+	}
+	nDrift, err := checkAgentTags(state.Agents)
+	if err != nil {
+		fatal(err)
+	}
+	nMeas, err := meas.ApplyMeasurements(recurring, fDryRun)
+	if err != nil {
+		fatal(err)
+	}
+	verb := "applied"
+	if fDryRun {
+		verb = "dry-run: would apply"
+	}
+	fmt.Printf("%s %d user change(s) and %d measurement request(s)\n", verb, nUsers, nMeas)
+	if nDrift > 0 {
+		fatal(fmt.Errorf("%w: %d agent(s)", ErrDrift, nDrift))
+	}
+}
+
+// readDesiredState reads and merges the desired state in the specified
+// files and in the .yaml and .yml files of the specified directories.
+func readDesiredState(paths []string) (DesiredState, error) {
+	var state DesiredState
+	var files []string
+	for _, path := range paths {
+		fi, err := os.Stat(path)
+		if err != nil {
+			return state, err
+		}
+		if !fi.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		for _, pattern := range []string{"*.yaml", "*.yml"} {
+			matches, err := filepath.Glob(filepath.Join(path, pattern))
+			if err != nil {
+				return state, err
+			}
+			files = append(files, matches...)
+		}
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		verbose("reading desired state in %s\n", file)
+		contents, err := os.ReadFile(file)
+		if err != nil {
+			return state, err
+		}
+		var s DesiredState
+		dec := yaml.NewDecoder(bytes.NewReader(contents))
+		dec.KnownFields(true)
+		if err := dec.Decode(&s); err != nil && !errors.Is(err, io.EOF) {
+			return state, fmt.Errorf("%s: %w: %v", file, ErrInvalidState, err)
+		}
+		state.Users = append(state.Users, s.Users...)
+		state.Agents = append(state.Agents, s.Agents...)
+		state.Measurements = append(state.Measurements, s.Measurements...)
+	}
+	// Values decoded from YAML (e.g., int) are converted to the values
+	// decoded from JSON (e.g., float64) that the users API expects.
+	for i, user := range state.Users {
+		jsonData, err := json.Marshal(user)
+		if err != nil {
+			return state, err
+		}
+		state.Users[i] = nil
+		if err := json.Unmarshal(jsonData, &state.Users[i]); err != nil {
+			return state, err
+		}
+	}
+	return state, nil
+}
+
+// recurringMeasurements returns the recurring measurements defined by
+// the desired measurements.
+func recurringMeasurements(desired []DesiredMeasurement) ([]meas.RecurringMeasurement, error) {
+	var recurring []meas.RecurringMeasurement
+	names := make(map[string]bool)
+	for _, d := range desired {
+		if names[d.Name] {
+			return nil, fmt.Errorf("%s: %w: duplicate measurement name", d.Name, ErrInvalidState)
+		}
+		names[d.Name] = true
+		every, err := time.ParseDuration(d.Every)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w: every: %v", d.Name, ErrInvalidState, err)
+		}
+		request, err := json.Marshal(d.Request)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w: %v", d.Name, ErrInvalidState, err)
+		}
+		recurring = append(recurring, meas.RecurringMeasurement{Name: d.Name, Every: every, Request: request})
+	}
+	return recurring, nil
+}
+
+// checkAgentTags compares the desired tags of agents with their
+// current tags and returns the number of agents that differ.  Agent
+// tags are set in the configuration of the agents and cannot be
+// changed with the Iris API, so differences are only reported.
+func checkAgentTags(desired []DesiredAgent) (int, error) {
+	if len(desired) == 0 {
+		return 0, nil
+	}
+	jsonData, err := agents.GetAgents("", false)
+	if err != nil {
+		return 0, err
+	}
+	var agentsData common.AgentsData
+	if err := common.DecodeJSON(jsonData, &agentsData); err != nil {
+		return 0, err
+	}
+	current := make(map[string][]string)
+	for _, result := range agentsData.Results {
+		current[result.Parameters.Hostname] = result.Parameters.Tags
+	}
+	nDrift := 0
+	for _, d := range desired {
+		tags, ok := current[d.Hostname]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "%s agent %s is not connected\n", common.Warning(common.WarnAgentDisconnect), d.Hostname)
+			nDrift++
+			continue
+		}
+		if !sameTags(tags, d.Tags) {
+			fmt.Fprintf(os.Stderr, "%s agent %s has tags %q instead of %q (update the agent configuration)\n", common.Warning(common.WarnAgentTags), d.Hostname, tags, d.Tags)
+			nDrift++
+			continue
+		}
+		verbose("agent %s is up to date\n", d.Hostname)
+	}
+	return nDrift, nil
+}
+
+// sameTags returns true if a and b have the same tags in any order.
+func sameTags(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a = append([]string{}, a...)
+	b = append([]string{}, b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
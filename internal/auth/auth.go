@@ -3,10 +3,12 @@ package auth
 
 import (
 	"bufio"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"strings"
 	"syscall"
@@ -17,19 +19,35 @@ import (
 	"golang.org/x/term"
 )
 
+const (
+	// The refresh token is saved in the file of the access token
+	// with this suffix.
+	refreshTokenSuffix = "-refresh"
+)
+
 var (
 	// Command, its flags, subcommands, and their flags.
 	//	auth <subcommand>
 	//	auth login [--cookie]
 	//	auth logout [--cookie]
-	//	auth register <user-details>...
-	cmdName       = "auth"
-	subcmdNames   = []string{"login", "logout", "register"}
-	fLoginCookie  bool
-	fLogoutCookie bool
+	//	auth register [--interactive] <user-details>...
+	//	auth credentials <subcommand>
+	//	auth credentials set
+	//	auth credentials unset
+	cmdName              = "auth"
+	subcmdNames          = []string{"login", "logout", "register", "credentials"}
+	credSubcmdNames      = []string{"set", "unset"}
+	fLoginCookie         bool
+	fLogoutCookie        bool
+	fRegisterInteractive bool
 
 	// Errors.
-	ErrNoAccessToken = errors.New("no access token")
+	ErrNoAccessToken      = errors.New("no access token")
+	ErrPasswordMismatch   = errors.New("passwords do not match")
+	ErrEmptyField         = errors.New("empty field")
+	ErrRefreshRejected    = errors.New("refresh token rejected")
+	ErrInvalidAccessToken = errors.New("invalid access token")
+	ErrRegisterRejected   = errors.New("registration rejected")
 
 	// Test code can change Fatal to Panic, allowing recovery
 	// from a fatal error without causing the process to exit.
@@ -73,16 +91,42 @@ func AuthCmd() *cobra.Command {
 	logoutSubcmd.Flags().BoolVar(&fLogoutCookie, "cookie", false, "use cookie instead of json web token (jwt) to logout")
 	authCmd.AddCommand(logoutSubcmd)
 
-	// auth register (has no flags)
+	// auth register and its flags
 	registerSubcmd := &cobra.Command{
 		Use:   "register",
 		Short: "register a user",
-		Long:  "register a user whose details are in the specified file",
+		Long:  "register a user whose details are in the specified file or are entered interactively",
 		Args:  authRegisterArgs,
 		Run:   authRegister,
 	}
+	registerSubcmd.Flags().BoolVar(&fRegisterInteractive, "interactive", false, "prompt for user details instead of reading them from a file")
 	authCmd.AddCommand(registerSubcmd)
 
+	// auth credentials and its subcommands (have no flags)
+	credentialsSubcmd := &cobra.Command{
+		Use:       "credentials",
+		ValidArgs: credSubcmdNames,
+		Short:     "manage stored password",
+		Long:      "manage the password of the current user stored by the credential helper (see --credential-helper)",
+		Args:      authCredentialsArgs,
+		Run:       authCredentials,
+	}
+	credentialsSubcmd.AddCommand(&cobra.Command{
+		Use:   "set",
+		Short: "store password",
+		Long:  "prompt for the password of the current user and store it with the credential helper",
+		Args:  authCredentialsSetArgs,
+		Run:   authCredentialsSet,
+	})
+	credentialsSubcmd.AddCommand(&cobra.Command{
+		Use:   "unset",
+		Short: "remove stored password",
+		Long:  "remove the password of the current user stored with the credential helper",
+		Args:  authCredentialsUnsetArgs,
+		Run:   authCredentialsUnset,
+	})
+	authCmd.AddCommand(credentialsSubcmd)
+
 	return authCmd
 }
 
@@ -151,13 +195,29 @@ func authRegisterArgs(cmd *cobra.Command, args []string) error {
 		fmt.Printf(format, "<user-details>", "file containing user details in JSON format")
 		return nil
 	}
+	if fRegisterInteractive {
+		if len(args) != 0 {
+			cliFatal("auth register --interactive does not take any arguments")
+		}
+		return nil
+	}
 	if len(args) < 1 {
-		cliFatal("auth register requires exactly one argument: <user-details>", common.UserFile)
+		cliFatal("auth register requires at least one argument: <user-details>...", common.UserFile)
 	}
 	return nil
 }
 
 func authRegister(cmd *cobra.Command, args []string) {
+	if fRegisterInteractive {
+		userDetails, err := promptUserDetails()
+		if err != nil {
+			fatal(err)
+		}
+		if err := registerUser(userDetails); err != nil {
+			fatal(err)
+		}
+		return
+	}
 	for _, arg := range args {
 		if err := postAuthRegister(arg); err != nil {
 			fatal(err)
@@ -165,26 +225,104 @@ func authRegister(cmd *cobra.Command, args []string) {
 	}
 }
 
+func authCredentialsArgs(cmd *cobra.Command, args []string) error {
+	if _, ok := common.IsUsage(args); ok {
+		return nil
+	}
+	if len(args) == 0 {
+		cliFatal("auth credentials requires one of these subcommands: ", strings.Join(credSubcmdNames, " "))
+	}
+	cliFatal("unknown subcommand: ", args[0])
+	return nil
+}
+
+func authCredentials(cmd *cobra.Command, args []string) {
+	fatal("authCredentials()")
+}
+
+func authCredentialsSetArgs(cmd *cobra.Command, args []string) error {
+	if _, ok := common.IsUsage(args); ok {
+		return nil
+	}
+	if len(args) != 0 {
+		cliFatal("auth credentials set does not take any arguments")
+	}
+	return nil
+}
+
+func authCredentialsSet(cmd *cobra.Command, args []string) {
+	store, username, err := credentialStore()
+	if err != nil {
+		fatal(err)
+	}
+	fmt.Fprintf(os.Stderr, "Enter password for Iris user %s: ", username)
+	line, err := term.ReadPassword(int(syscall.Stdin))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		fatal(err)
+	}
+	if err := store.Set(username, string(line)); err != nil {
+		fatal(err)
+	}
+	fmt.Printf("stored password of %s with %s credential helper\n", username, store.Name())
+}
+
+func authCredentialsUnsetArgs(cmd *cobra.Command, args []string) error {
+	if _, ok := common.IsUsage(args); ok {
+		return nil
+	}
+	if len(args) != 0 {
+		cliFatal("auth credentials unset does not take any arguments")
+	}
+	return nil
+}
+
+func authCredentialsUnset(cmd *cobra.Command, args []string) {
+	store, username, err := credentialStore()
+	if err != nil {
+		fatal(err)
+	}
+	if err := store.Unset(username); err != nil {
+		fatal(err)
+	}
+	fmt.Printf("removed password of %s from %s credential helper\n", username, store.Name())
+}
+
+// credentialStore returns the credential store and the current user.
+func credentialStore() (common.CredentialStore, string, error) {
+	irisHome, err := common.IrisDir()
+	if err != nil {
+		return nil, "", err
+	}
+	username, err := getIrisUser(fmt.Sprintf("%s/credentials", irisHome))
+	if err != nil {
+		return nil, "", err
+	}
+	store, err := common.NewCredentialStore()
+	return store, username, err
+}
+
 func postAuthLogin() (string, error) {
 	if fLoginCookie {
 		fmt.Printf("auth login --cookie not implemented yet\n")
 		return "", nil
 	}
-	home := os.Getenv("HOME")
-	if home == "" {
-		return "", common.ErrHomeEnv
-	}
-	irisHome := fmt.Sprintf("%s/.iris", home)
-	if err := os.MkdirAll(irisHome, 0700); err != nil {
+	irisHome, err := common.IrisDir()
+	if err != nil {
 		return "", err
 	}
 
 	credentialsFile := fmt.Sprintf("%s/credentials", irisHome)
-	if _, err := common.CheckFile("credentials", credentialsFile); err != nil {
-		return "", err
+	if common.RootFlagString("username") == "" {
+		if _, err := common.CheckFile("credentials", credentialsFile); err != nil {
+			return "", err
+		}
 	}
 
-	accessTokenFile := fmt.Sprintf("%s/jwt", irisHome)
+	accessTokenFile, err := common.AccessTokenFile()
+	if err != nil {
+		return "", err
+	}
 	fi, err := common.CheckFile("access token", accessTokenFile)
 	if errors.Is(err, os.ErrNotExist) {
 		verbose("creating access token file %s\n", accessTokenFile)
@@ -199,8 +337,11 @@ func postAuthLogin() (string, error) {
 		oneHourAgo := now.Add(-time.Hour)
 		if fi.ModTime().Before(oneHourAgo) {
 			verbose("recreating access token file %s because it's too old\n", accessTokenFile)
-			if err = createAccessToken(credentialsFile, accessTokenFile); err != nil {
-				return "", err
+			if err = refreshAccessToken(accessTokenFile); err != nil {
+				verbose("cannot refresh access token: %v\n", err)
+				if err = createAccessToken(credentialsFile, accessTokenFile); err != nil {
+					return "", err
+				}
 			}
 		} else {
 			verbose("access token file exists and is current\n")
@@ -220,8 +361,89 @@ func postAuthLogout() error {
 }
 
 func postAuthRegister(userFile string) error {
-	fmt.Println("auth register not implemented yet, user file:", userFile)
-	return nil
+	userDetails, err := os.ReadFile(userFile)
+	if err != nil {
+		return err
+	}
+	if !json.Valid(userDetails) {
+		return fmt.Errorf("%s: invalid JSON, user file format:%s", userFile, common.UserFile)
+	}
+	return registerUser(userDetails)
+}
+
+func registerUser(userDetails []byte) error {
+	url := fmt.Sprintf("%s/register", common.APIEndpoint(common.AuthAPISuffix))
+	jsonData, status, err := common.CurlStatus("", false, "POST", url,
+		"-H", "Content-Type: application/json",
+		"-d", string(userDetails),
+	)
+	if err != nil {
+		fmt.Println(string(jsonData))
+		return err
+	}
+	if status < 200 || status >= 300 {
+		return fmt.Errorf("register: %w: %d: %s", ErrRegisterRejected, status, jsonData)
+	}
+	return common.SaveOrPrint(jsonData, "irisctl-auth-register-")
+}
+
+// promptUserDetails prompts for the details of a new user and returns
+// them in the JSON format of common.UserFile.  Fields that only a
+// superuser can set (e.g., is_superuser) keep their default values.
+func promptUserDetails() ([]byte, error) {
+	reader := bufio.NewReader(os.Stdin)
+	prompt := func(name string) (string, error) {
+		fmt.Fprintf(os.Stderr, "%s: ", name)
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			return "", fmt.Errorf("%s: %w", name, ErrEmptyField)
+		}
+		return line, nil
+	}
+	readPassword := func(name string) (string, error) {
+		fmt.Fprintf(os.Stderr, "%s: ", name)
+		line, err := term.ReadPassword(int(syscall.Stdin))
+		fmt.Fprintln(os.Stderr)
+		return string(line), err
+	}
+
+	email, err := prompt("Email")
+	if err != nil {
+		return nil, err
+	}
+	firstName, err := prompt("First name")
+	if err != nil {
+		return nil, err
+	}
+	lastName, err := prompt("Last name")
+	if err != nil {
+		return nil, err
+	}
+	password, err := readPassword("Password")
+	if err != nil {
+		return nil, err
+	}
+	if password == "" {
+		return nil, fmt.Errorf("password: %w", ErrEmptyField)
+	}
+	confirm, err := readPassword("Confirm password")
+	if err != nil {
+		return nil, err
+	}
+	if password != confirm {
+		return nil, ErrPasswordMismatch
+	}
+
+	return json.Marshal(map[string]interface{}{
+		"email":     email,
+		"password":  password,
+		"firstname": firstName,
+		"lastname":  lastName,
+	})
 }
 
 func createAccessToken(credentialsFile, accessTokenFile string) error {
@@ -230,6 +452,9 @@ func createAccessToken(credentialsFile, accessTokenFile string) error {
 		return err
 	}
 	password := os.Getenv("IRIS_PASSWORD")
+	if password == "" {
+		password = storedPassword(username)
+	}
 	if password == "" {
 		fmt.Fprintf(os.Stderr, "Enter password for Iris user %s: ", username)
 		line, err := term.ReadPassword(int(syscall.Stdin))
@@ -238,7 +463,7 @@ func createAccessToken(credentialsFile, accessTokenFile string) error {
 			return err
 		}
 		password = string(line)
-	} else {
+	} else if os.Getenv("IRIS_PASSWORD") != "" {
 		fmt.Fprintf(os.Stderr, "using IRIS_PASSWORD environment variable\n")
 	}
 
@@ -252,6 +477,91 @@ func createAccessToken(credentialsFile, accessTokenFile string) error {
 		fmt.Println(string(jsonData))
 		return err
 	}
+	return saveTokens(jsonData, accessTokenFile)
+}
+
+// storedPassword returns the password of the user stored by the
+// credential helper or "" if there is none.
+func storedPassword(username string) string {
+	store, err := common.NewCredentialStore()
+	if err != nil {
+		verbose("cannot use credential helper: %v\n", err)
+		return ""
+	}
+	password, err := store.Get(username)
+	if err != nil {
+		verbose("%s credential helper: %v\n", store.Name(), err)
+		return ""
+	}
+	verbose("using password stored with %s credential helper\n", store.Name())
+	return password
+}
+
+// refreshAccessToken gets a new access token with the refresh token
+// saved at the last login so long-running commands do not have to
+// prompt for the password again.
+func refreshAccessToken(accessTokenFile string) error {
+	refreshTokenFile := RefreshTokenFile(accessTokenFile)
+	refreshToken, err := os.ReadFile(refreshTokenFile)
+	if err != nil {
+		return err
+	}
+	verbose("refreshing access token with %s\n", refreshTokenFile)
+	url := fmt.Sprintf("%s/jwt/refresh", common.APIEndpoint(common.AuthAPISuffix))
+	data := fmt.Sprintf("grant_type=refresh_token&refresh_token=%s", strings.TrimSpace(string(refreshToken)))
+	jsonData, status, err := common.CurlStatus("", false, "POST", url,
+		"-H", "Content-Type: application/x-www-form-urlencoded",
+		"-d", data,
+	)
+	if err != nil {
+		return fmt.Errorf("%v: %s", err, jsonData)
+	}
+	switch {
+	case status == http.StatusBadRequest || status == http.StatusUnauthorized:
+		// The refresh token is not valid anymore.
+		verbose("removing rejected refresh token %s\n", refreshTokenFile)
+		os.Remove(refreshTokenFile)
+		return fmt.Errorf("%w: %d: %s", ErrRefreshRejected, status, jsonData)
+	case status < 200 || status >= 300:
+		// The refresh token may still be valid (e.g., the server
+		// is unavailable), so it is kept for the next attempt.
+		return fmt.Errorf("refresh failed: %d: %s", status, jsonData)
+	}
+	return saveTokens(jsonData, accessTokenFile)
+}
+
+// RefreshTokenFile returns the path of the file of the refresh token
+// saved with the specified access token file.
+func RefreshTokenFile(accessTokenFile string) string {
+	return accessTokenFile + refreshTokenSuffix
+}
+
+// AccessTokenExpiry returns the expiration time of the access token,
+// which is the exp claim of its JWT payload.
+func AccessTokenExpiry(accessToken string) (time.Time, error) {
+	parts := strings.Split(strings.TrimSpace(accessToken), ".")
+	if len(parts) != 3 {
+		return time.Time{}, fmt.Errorf("%w: not a JWT", ErrInvalidAccessToken)
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %v", ErrInvalidAccessToken, err)
+	}
+	var claims struct {
+		Exp *float64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return time.Time{}, fmt.Errorf("%w: %v", ErrInvalidAccessToken, err)
+	}
+	if claims.Exp == nil {
+		return time.Time{}, fmt.Errorf("%w: no exp claim", ErrInvalidAccessToken)
+	}
+	return time.Unix(int64(*claims.Exp), 0), nil
+}
+
+// saveTokens saves the access token and, if the response has one, the
+// refresh token of a login or refresh response.
+func saveTokens(jsonData []byte, accessTokenFile string) error {
 	var responseData map[string]interface{}
 	if err := json.Unmarshal(jsonData, &responseData); err != nil {
 		return err
@@ -262,10 +572,19 @@ func createAccessToken(credentialsFile, accessTokenFile string) error {
 		verbose("access_token not found or not a string\n")
 		return ErrNoAccessToken
 	}
+	if refreshToken, ok := responseData["refresh_token"].(string); ok && refreshToken != "" {
+		if err := os.WriteFile(RefreshTokenFile(accessTokenFile), []byte(refreshToken), 0600); err != nil {
+			return err
+		}
+	}
 	return os.WriteFile(accessTokenFile, []byte(accessToken), 0600)
 }
 
 func getIrisUser(credentialsFile string) (string, error) {
+	if user := common.RootFlagString("username"); user != "" {
+		verbose("using username %s of the configuration file\n", user)
+		return user, nil
+	}
 	file, err := os.Open(credentialsFile)
 	if err != nil {
 		return "", err
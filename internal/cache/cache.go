@@ -0,0 +1,425 @@
+// Package cache implements commands for the cache of values used for
+// shell completions (not in the Iris API).
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dioptra-io/irisctl/internal/agents"
+	"github.com/dioptra-io/irisctl/internal/common"
+	"github.com/dioptra-io/irisctl/internal/meas"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+const (
+	// MaxAge is the age after which the cache is refreshed in the
+	// background when it is used for completions.
+	MaxAge = 24 * time.Hour
+
+	// MaxMeasUUIDs is the number of most recent measurement UUIDs
+	// kept in the cache.
+	MaxMeasUUIDs = 100
+
+	// RefreshTimeout is the time after which a background refresh
+	// that has not finished (e.g., because it failed) is considered
+	// abandoned and another one can start.
+	RefreshTimeout = 5 * time.Minute
+)
+
+var (
+	// Command, its flags, subcommands, and their flags.
+	//	cache <subcommand>
+	//	cache gc [--days <n>] [--dry-run]
+	//	cache refresh
+	//	cache show
+	cmdName     = "cache"
+	subcmdNames = []string{"gc", "refresh", "show"}
+	fGcDays     int
+	fGcDryRun   bool
+
+	// Test code changes Fatal to Panic so a fatal error won't exit
+	// the process and can be recovered.
+	fatal    = log.Fatal
+	cliFatal = common.CliFatal
+	verbose  = common.Verbose
+
+	// Completion functions of flags with cached values keyed by
+	// flag name.
+	flagCompletions = map[string]func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective){
+		"tag":       CompleteTags,
+		"from":      CompleteTags,
+		"agent":     CompleteHostnames,
+		"meas-uuid": CompleteMeasUUIDs,
+	}
+	// Commands whose arguments are measurement UUIDs.
+	measUUIDCmds = []string{
+		"irisctl meas delete",
+		"irisctl meas manifest",
+		"irisctl meas publish",
+		"irisctl meas unpublish",
+		"irisctl meas replay",
+		"irisctl meas clone",
+		"irisctl meas retry",
+		"irisctl meas progress",
+		"irisctl meas wait",
+		"irisctl meas watch",
+		"irisctl clickhouse export",
+	}
+)
+
+// Cache defines the values cached for shell completions.
+type Cache struct {
+	RefreshTime time.Time `json:"refresh_time"`
+	Tags        []string  `json:"tags"`
+	Hostnames   []string  `json:"hostnames"`
+	MeasUUIDs   []string  `json:"meas_uuids"`
+}
+
+// CacheCmd returns the command structure for cache.
+func CacheCmd() *cobra.Command {
+	cacheCmd := &cobra.Command{
+		Use:       cmdName,
+		ValidArgs: subcmdNames,
+		Short:     "completion cache commands",
+		Long:      "commands to manage the cache of tags, agent hostnames, and measurement UUIDs used for shell completions and the temporary files of irisctl",
+		Args:      cacheArgs,
+		Run:       cache,
+	}
+	cacheCmd.SetUsageFunc(common.Usage)
+	cacheCmd.SetHelpFunc(common.Help)
+
+	// cache gc
+	gcSubcmd := &cobra.Command{
+		Use:   "gc",
+		Short: "remove old temporary files",
+		Long:  "remove the temporary files of irisctl (in the per-user temporary directory) older than the specified number of days",
+		Args:  cacheGcArgs,
+		Run:   cacheGc,
+	}
+	gcSubcmd.Flags().IntVar(&fGcDays, "days", common.DefaultTempMaxAgeDays, "remove files older than this many days (default: temp-gc-days of the configuration file or 7)")
+	gcSubcmd.Flags().BoolVar(&fGcDryRun, "dry-run", false, "print the files that would be removed without removing them")
+	cacheCmd.AddCommand(gcSubcmd)
+
+	// cache refresh (has no flags)
+	refreshSubcmd := &cobra.Command{
+		Use:   "refresh",
+		Short: "refresh the completion cache",
+		Long:  "get tags, agent hostnames, and recent measurement UUIDs from Iris API and save them in the completion cache",
+		Args:  cacheRefreshArgs,
+		Run:   cacheRefresh,
+	}
+	cacheCmd.AddCommand(refreshSubcmd)
+
+	// cache show (has no flags)
+	showSubcmd := &cobra.Command{
+		Use:   "show",
+		Short: "show the completion cache",
+		Long:  "show the contents of the completion cache",
+		Args:  cacheShowArgs,
+		Run:   cacheShow,
+	}
+	cacheCmd.AddCommand(showSubcmd)
+
+	return cacheCmd
+}
+
+// RegisterCompletions registers completion functions that use the
+// cache for the flags and arguments of all commands under root.
+func RegisterCompletions(root *cobra.Command) {
+	var walk func(cmd *cobra.Command)
+	walk = func(cmd *cobra.Command) {
+		cmd.Flags().VisitAll(func(flag *pflag.Flag) {
+			if f, ok := flagCompletions[flag.Name]; ok {
+				_ = cmd.RegisterFlagCompletionFunc(flag.Name, f)
+			}
+		})
+		if common.Contains(measUUIDCmds, cmd.CommandPath()) {
+			cmd.ValidArgsFunction = CompleteMeasUUIDs
+		}
+		for _, c := range cmd.Commands() {
+			walk(c)
+		}
+	}
+	walk(root)
+}
+
+// CompleteTags returns the cached measurement tags.
+func CompleteTags(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return complete(func(c Cache) []string { return c.Tags }, toComplete)
+}
+
+// CompleteHostnames returns the cached agent hostnames.
+func CompleteHostnames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return complete(func(c Cache) []string { return c.Hostnames }, toComplete)
+}
+
+// CompleteMeasUUIDs returns the cached measurement UUIDs.
+func CompleteMeasUUIDs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return complete(func(c Cache) []string { return c.MeasUUIDs }, toComplete)
+}
+
+// Tags returns the cached measurement tags or nil if the cache cannot
+// be read.
+func Tags() []string {
+	c, _ := readCache()
+	return c.Tags
+}
+
+// Hostnames returns the cached agent hostnames or nil if the cache
+// cannot be read.
+func Hostnames() []string {
+	c, _ := readCache()
+	return c.Hostnames
+}
+
+// complete returns the cached values that start with toComplete.  It
+// never queries Iris API so completions are instant and work offline
+// but starts a refresh in the background if the cache is stale.
+func complete(values func(Cache) []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	c, err := readCache()
+	if err != nil || time.Since(c.RefreshTime) > MaxAge {
+		refreshInBackground()
+	}
+	var completions []string
+	for _, v := range values(c) {
+		if strings.HasPrefix(v, toComplete) {
+			completions = append(completions, v)
+		}
+	}
+	return completions, cobra.ShellCompDirectiveNoFileComp
+}
+
+// refreshInBackground starts a refresh of the cache unless one started
+// less than RefreshTimeout ago, so that completions do not start a
+// refresh on every TAB.  The lock file records when the refresh started
+// and is removed by the refresh when it finishes.
+func refreshInBackground() {
+	lockFile, err := refreshLockFile()
+	if err != nil {
+		return
+	}
+	if fi, err := os.Stat(lockFile); err == nil {
+		if time.Since(fi.ModTime()) < RefreshTimeout {
+			return
+		}
+		os.Remove(lockFile)
+	}
+	f, err := os.OpenFile(lockFile, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		// Another completion started a refresh.
+		return
+	}
+	fmt.Fprintf(f, "%s\n", time.Now().UTC().Format(time.RFC3339))
+	f.Close()
+	args := []string{"cache", "refresh"}
+	if profile := common.RootFlagString("profile"); profile != "" {
+		args = append([]string{"--profile", profile}, args...)
+	}
+	// The refresh runs without a terminal, so it fails instead of
+	// prompting for a password if there is no valid access token.
+	cmd := exec.Command(os.Args[0], args...)
+	if err := cmd.Start(); err != nil {
+		os.Remove(lockFile)
+	}
+}
+
+func cacheArgs(cmd *cobra.Command, args []string) error {
+	if _, ok := common.IsUsage(args); ok {
+		return nil
+	}
+	if len(args) == 0 {
+		cliFatal("cache requires one of these subcommands: ", strings.Join(subcmdNames, " "))
+	}
+	cliFatal("unknown subcommand: ", args[0])
+	return nil
+}
+
+func cache(cmd *cobra.Command, args []string) {
+	fatal("cache()")
+}
+
+func cacheGcArgs(cmd *cobra.Command, args []string) error {
+	if _, ok := common.IsUsage(args); ok {
+		return nil
+	}
+	if len(args) != 0 {
+		cliFatal("cache gc does not take any arguments")
+	}
+	if fGcDays < 0 {
+		cliFatal("--days cannot be negative")
+	}
+	return nil
+}
+
+func cacheGc(cmd *cobra.Command, args []string) {
+	maxAge := common.TempMaxAge()
+	if cmd.Flags().Changed("days") {
+		maxAge = time.Duration(fGcDays) * 24 * time.Hour
+	}
+	removed, err := common.CleanTempDir(maxAge, fGcDryRun)
+	for _, name := range removed {
+		if fGcDryRun {
+			fmt.Printf("dry-run: would remove %s\n", name)
+		} else {
+			fmt.Printf("removed %s\n", name)
+		}
+	}
+	if err != nil {
+		fatal(err)
+	}
+	if len(removed) == 0 {
+		fmt.Printf("no temporary files older than %v\n", maxAge)
+	}
+}
+
+func cacheRefreshArgs(cmd *cobra.Command, args []string) error {
+	if _, ok := common.IsUsage(args); ok {
+		return nil
+	}
+	if len(args) != 0 {
+		cliFatal("cache refresh does not take any arguments")
+	}
+	return nil
+}
+
+func cacheRefresh(cmd *cobra.Command, args []string) {
+	c, err := refreshCache()
+	// A failed refresh keeps the lock file so that completions do not
+	// retry it before RefreshTimeout.
+	if err != nil {
+		fatal(err)
+	}
+	if lockFile, err := refreshLockFile(); err == nil {
+		os.Remove(lockFile)
+	}
+	fmt.Printf("cached %d tags, %d hostnames, and %d measurement UUIDs\n", len(c.Tags), len(c.Hostnames), len(c.MeasUUIDs))
+}
+
+func cacheShowArgs(cmd *cobra.Command, args []string) error {
+	if _, ok := common.IsUsage(args); ok {
+		return nil
+	}
+	if len(args) != 0 {
+		cliFatal("cache show does not take any arguments")
+	}
+	return nil
+}
+
+func cacheShow(cmd *cobra.Command, args []string) {
+	c, err := readCache()
+	if err != nil {
+		fatal(err)
+	}
+	fmt.Printf("refresh_time %v (%v ago)\n", c.RefreshTime.Format(time.RFC3339), time.Since(c.RefreshTime).Round(time.Second))
+	fmt.Printf("tags         %s\n", strings.Join(c.Tags, " "))
+	fmt.Printf("hostnames    %s\n", strings.Join(c.Hostnames, " "))
+	fmt.Printf("meas_uuids   %s\n", strings.Join(c.MeasUUIDs, " "))
+}
+
+// refreshCache gets the cached values from Iris API and saves them in
+// the cache file.
+func refreshCache() (Cache, error) {
+	c := Cache{RefreshTime: time.Now().UTC()}
+	measMdFile, err := meas.GetMeasMdFile(false)
+	if err != nil {
+		return c, err
+	}
+	if !common.RootFlagBool("no-delete") {
+		defer func(f string) { verbose("removing %s\n", f); os.Remove(f) }(measMdFile)
+	}
+	measurements, err := common.GetMeasurementsSorted(measMdFile)
+	if err != nil {
+		return c, err
+	}
+	tags := make(map[string]bool)
+	for i := len(measurements) - 1; i >= 0; i-- {
+		for _, tag := range measurements[i].Tags {
+			tags[tag] = true
+		}
+		if len(c.MeasUUIDs) < MaxMeasUUIDs {
+			c.MeasUUIDs = append(c.MeasUUIDs, measurements[i].UUID)
+		}
+	}
+	for tag := range tags {
+		c.Tags = append(c.Tags, tag)
+	}
+	sort.Strings(c.Tags)
+
+	jsonData, err := agents.GetAgents("", false)
+	if err != nil {
+		return c, err
+	}
+	var agentsData common.AgentsData
+	if err := common.DecodeJSON(jsonData, &agentsData); err != nil {
+		return c, err
+	}
+	for _, result := range agentsData.Results {
+		c.Hostnames = append(c.Hostnames, result.Parameters.Hostname)
+	}
+	sort.Strings(c.Hostnames)
+	return c, writeCache(c)
+}
+
+// cacheFile returns the path of the cache file.  Each profile has its
+// own cache file because it has its own Iris instance.
+func cacheFile() (string, error) {
+	irisHome, err := common.IrisDir()
+	if err != nil {
+		return "", err
+	}
+	file := fmt.Sprintf("%s/completion-cache", irisHome)
+	if profile := common.RootFlagString("profile"); profile != "" {
+		file += "-" + profile
+	}
+	return file + ".json", nil
+}
+
+// refreshLockFile returns the path of the file that exists while the
+// cache file is refreshed in the background.
+func refreshLockFile() (string, error) {
+	file, err := cacheFile()
+	if err != nil {
+		return "", err
+	}
+	return file + ".lock", nil
+}
+
+func readCache() (Cache, error) {
+	var c Cache
+	file, err := cacheFile()
+	if err != nil {
+		return c, err
+	}
+	contents, err := os.ReadFile(file)
+	if err != nil {
+		return c, err
+	}
+	err = json.Unmarshal(contents, &c)
+	return c, err
+}
+
+func writeCache(c Cache) error {
+	file, err := cacheFile()
+	if err != nil {
+		return err
+	}
+	jsonData, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Write to a temporary file first so completions never read a
+	// partially written cache.
+	if err := os.WriteFile(file+".tmp", jsonData, 0600); err != nil {
+		return err
+	}
+	verbose("saving in %s\n", file)
+	return os.Rename(file+".tmp", file)
+}
@@ -3,12 +3,13 @@ package check
 
 import (
 	"bytes"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os/exec"
+	"sort"
 	"strings"
-	"sync"
+	"time"
 
 	"github.com/dioptra-io/irisctl/internal/agents"
 	"github.com/dioptra-io/irisctl/internal/common"
@@ -26,15 +27,37 @@ const (
 var (
 	// Command, its flags, subcommands, and their flags.
 	//	check <subcommand>
-	//	check agents [--uptime] [--net]
-	//	check containers [--errors] [--logs] [<agent>...]
+	//	check agents [--uptime] [--net] [--format <format>]
+	//	check containers [--errors] [--logs] [--format <format>] [<agent>...]
 	//	check uuids [<meas-md-file>] <uuid>...
+	//	check inventory
+	//	check collect [--output <file>] [--tail <n>] <hostname>...
+	//	check ingestion --meas-uuid <meas-uuid> [--window <duration>]
+	//	check versions [--matrix <file-or-url>]
+	//	check docker-restart [--policy <policy>] [--compose-file <file>] [--unit <unit>] [<hostname>...]
+	//	check regression [--record [--call <path>]...] [--ignore <field>]... <snapshot-file>
 	cmdName          = "check"
-	subcmdNames      = []string{"agents", "containers", "uuids"}
+	subcmdNames      = []string{"agents", "containers", "uuids", "inventory", "collect", "ingestion", "versions", "docker-restart", "regression"}
 	fAgentUptime     bool
 	fAgentNet        bool
 	fContainerErrors bool
 	fContainerLogs   bool
+	fCheckFormat     string
+	fCollectOutput   string
+	fCollectTail     int
+
+	fIngestionMeasUUID  string
+	fIngestionWindow    time.Duration
+	fVersionsMatrix     string
+	fRestartPolicy      string
+	fRestartComposeFile string
+	fRestartUnit        string
+	fRegressionRecord   bool
+	fRegressionCalls    []string
+	fRegressionIgnore   []string
+
+	// Errors.
+	ErrIngestionStalled = errors.New("ingestion stalled")
 
 	// Test code changes Fatal to Panic so a fatal error won't exit
 	// the process and can be recovered.
@@ -66,6 +89,7 @@ func CheckCmd() *cobra.Command {
 	}
 	agentsSubcmd.Flags().BoolVar(&fAgentUptime, "uptime", false, "show uptime")
 	agentsSubcmd.Flags().BoolVar(&fAgentNet, "net", false, "show network bytes and packets sent and received")
+	agentsSubcmd.Flags().StringVar(&fCheckFormat, "format", "text", "output format of --uptime and --net: text or json")
 	checkCmd.AddCommand(agentsSubcmd)
 
 	// check containers and its flags
@@ -78,6 +102,7 @@ func CheckCmd() *cobra.Command {
 	}
 	containersSubcmd.Flags().BoolVar(&fContainerErrors, "errors", false, "show errors in container logs")
 	containersSubcmd.Flags().BoolVar(&fContainerLogs, "logs", false, "show container logs")
+	containersSubcmd.Flags().StringVar(&fCheckFormat, "format", "text", "output format: text or json")
 	checkCmd.AddCommand(containersSubcmd)
 
 	// check uuids (has no flags)
@@ -90,6 +115,77 @@ func CheckCmd() *cobra.Command {
 	}
 	checkCmd.AddCommand(uuidsSubcmd)
 
+	// check inventory (has no flags)
+	inventorySubcmd := &cobra.Command{
+		Use:   "inventory",
+		Short: "compare agents with GCP instances",
+		Long:  "compare agents registered in Iris with VM instances in GCP",
+		Args:  checkInventoryArgs,
+		Run:   checkInventory,
+	}
+	checkCmd.AddCommand(inventorySubcmd)
+
+	// check collect and its flags
+	collectSubcmd := &cobra.Command{
+		Use:   "collect",
+		Short: "collect a support bundle",
+		Long:  "collect logs, configuration, and system information of agent(s) into a support bundle",
+		Args:  checkCollectArgs,
+		Run:   checkCollect,
+	}
+	collectSubcmd.Flags().StringVar(&fCollectOutput, "output", "", "support bundle file (default irisctl-support-<time>.tar.gz)")
+	collectSubcmd.Flags().IntVar(&fCollectTail, "tail", 10000, "number of lines of container logs to collect")
+	checkCmd.AddCommand(collectSubcmd)
+
+	// check ingestion and its flags
+	ingestionSubcmd := &cobra.Command{
+		Use:   "ingestion",
+		Short: "detect stalled agents of a measurement",
+		Long:  "detect agents of an ongoing measurement whose results table has not grown within a time window",
+		Args:  checkIngestionArgs,
+		Run:   checkIngestion,
+	}
+	ingestionSubcmd.Flags().StringVar(&fIngestionMeasUUID, "meas-uuid", "", "measurement UUID")
+	ingestionSubcmd.Flags().DurationVar(&fIngestionWindow, "window", 30*time.Minute, "flag agents with no inserts within this window")
+	checkCmd.AddCommand(ingestionSubcmd)
+
+	// check versions and its flags
+	versionsSubcmd := &cobra.Command{
+		Use:   "versions",
+		Short: "check version skew across components",
+		Long:  "show the versions of irisctl, the API, and the agents and flag unsupported combinations based on a compatibility matrix",
+		Args:  checkVersionsArgs,
+		Run:   checkVersions,
+	}
+	versionsSubcmd.Flags().StringVar(&fVersionsMatrix, "matrix", "", "compatibility matrix file or URL (default: the matrix embedded in irisctl)")
+	checkCmd.AddCommand(versionsSubcmd)
+
+	// check docker-restart and its flags
+	dockerRestartSubcmd := &cobra.Command{
+		Use:   "docker-restart",
+		Short: "check that agent containers survive reboots",
+		Long:  "check that the iris-agent container of each agent has the expected restart policy and is started by docker compose or a systemd unit, flagging hand-started containers",
+		Args:  checkDockerRestartArgs,
+		Run:   checkDockerRestart,
+	}
+	dockerRestartSubcmd.Flags().StringVar(&fRestartPolicy, "policy", "always", "expected restart policy of the container")
+	dockerRestartSubcmd.Flags().StringVar(&fRestartComposeFile, "compose-file", "", "expected docker compose file of the container (default: any compose file)")
+	dockerRestartSubcmd.Flags().StringVar(&fRestartUnit, "unit", "iris-agent.service", "systemd unit that may start the container instead of docker compose")
+	checkCmd.AddCommand(dockerRestartSubcmd)
+
+	// check regression and its flags
+	regressionSubcmd := &cobra.Command{
+		Use:   "regression",
+		Short: "check API responses against a snapshot",
+		Long:  "record the responses of read-only API calls in a snapshot file, or replay the calls of a snapshot against the live API and diff the responses structurally to catch breaking changes before and after Iris upgrades",
+		Args:  checkRegressionArgs,
+		Run:   checkRegression,
+	}
+	regressionSubcmd.Flags().BoolVar(&fRegressionRecord, "record", false, "record the responses in the snapshot file instead of replaying its calls")
+	regressionSubcmd.Flags().StringArrayVar(&fRegressionCalls, "call", nil, "path of an API call to record relative to the API URL (default: status, agents, users/me, targets, and measurements)")
+	regressionSubcmd.Flags().StringArrayVar(&fRegressionIgnore, "ignore", nil, "volatile field to ignore in addition to probing_statistics")
+	checkCmd.AddCommand(regressionSubcmd)
+
 	return checkCmd
 }
 
@@ -112,6 +208,10 @@ func checkAgentsArgs(cmd *cobra.Command, args []string) error {
 	if _, ok := common.IsUsage(args); ok {
 		return nil
 	}
+	checkFormat()
+	if fCheckFormat == "json" && !fAgentUptime && !fAgentNet {
+		cliFatal("--format json requires --uptime or --net")
+	}
 	if len(args) != 0 {
 		cliFatal("check agents does not take any arguments")
 	}
@@ -123,8 +223,10 @@ func checkAgents(cmd *cobra.Command, args []string) {
 	if err != nil {
 		fatal(err)
 	}
-	if err := printAgentsStatus(jsonData); err != nil {
-		fatal(err)
+	if fCheckFormat == "text" {
+		if err := printAgentsStatus(jsonData); err != nil {
+			fatal(err)
+		}
 	}
 	if !fAgentUptime && !fAgentNet {
 		return
@@ -133,18 +235,16 @@ func checkAgents(cmd *cobra.Command, args []string) {
 	if err != nil {
 		fatal(err)
 	}
+	var results []hostResult
 	if fAgentUptime {
 		verbose("getting agent uptimes takes a few seconds\n")
-		fmt.Printf("%-30s   %-68s\n", "hostname", "uptime")
-		if errs := agentDetails(gcpHostnames, "uptime"); errs != nil {
-			fatal(errs)
-		}
+		results = append(results, runHostCheck(gcpHostnames, hostChecks["uptime"])...)
 	}
 	if fAgentNet {
-		fmt.Printf("%-30s   %-12s  %-12s  %-10s  %-10s\n", "hostname", "rx_bytes", "tx_bytes", "rx_packets", "tx_packets")
-		if errs := agentDetails(gcpHostnames, "net"); errs != nil {
-			fatal(errs)
-		}
+		results = append(results, runHostCheck(gcpHostnames, hostChecks["net"])...)
+	}
+	if err := printHostResults(results); err != nil {
+		fatal(err)
 	}
 }
 
@@ -153,9 +253,18 @@ func checkContainersArgs(cmd *cobra.Command, args []string) error {
 		fmt.Printf(format, "<agent>...", "one or more agent UUIDs or hostnames")
 		return nil
 	}
+	checkFormat()
 	return nil
 }
 
+// checkFormat validates the --format flag of check agents and check
+// containers.
+func checkFormat() {
+	if fCheckFormat != "text" && fCheckFormat != "json" {
+		cliFatal("invalid --format: ", fCheckFormat, " (one of these: text json)")
+	}
+}
+
 func checkContainers(cmd *cobra.Command, args []string) {
 	var gcpHostnames []string
 	if len(args) > 0 {
@@ -216,7 +325,7 @@ func checkUuids(cmd *cobra.Command, args []string) {
 		fatal(err)
 	}
 	var users common.Users
-	if err := json.Unmarshal(jsonData, &users); err != nil {
+	if err := common.DecodeJSON(jsonData, &users); err != nil {
 		fatal(err)
 	}
 	for _, arg := range args[n:] {
@@ -235,23 +344,61 @@ func checkUuids(cmd *cobra.Command, args []string) {
 	}
 }
 
-func checkContainersAgent(gcpHostnames []string) []error {
-	if !fContainerErrors && !fContainerLogs {
-		if errs := agentDetails(gcpHostnames, "dockerps"); errs != nil {
-			return errs
+func checkInventoryArgs(cmd *cobra.Command, args []string) error {
+	if _, ok := common.IsUsage(args); ok {
+		return nil
+	}
+	if len(args) != 0 {
+		cliFatal("check inventory does not take any arguments")
+	}
+	return nil
+}
+
+func checkInventory(cmd *cobra.Command, args []string) {
+	jsonData, err := agents.GetAgents("", false)
+	if err != nil {
+		fatal(err)
+	}
+	gcpHostnames, err := common.ParseGCPHostnames(jsonData)
+	if err != nil {
+		fatal(err)
+	}
+	instances, err := common.GcloudInstances()
+	if err != nil {
+		fatal(err)
+	}
+	sort.Strings(gcpHostnames)
+	sort.Strings(instances)
+	ok := true
+	for _, instance := range instances {
+		if !common.Contains(gcpHostnames, instance) {
+			fmt.Printf("%-30s VM without a registered agent\n", instance)
+			ok = false
 		}
 	}
-	if fContainerErrors {
-		if errs := agentDetails(gcpHostnames, "errors"); errs != nil {
-			return errs
+	for _, hostname := range gcpHostnames {
+		if !common.Contains(instances, hostname) {
+			fmt.Printf("%-30s agent without a VM\n", hostname)
+			ok = false
 		}
 	}
+	if ok {
+		fmt.Printf("all %d agents match the %d VM instances in %s\n", len(gcpHostnames), len(instances), common.GCPProject)
+	}
+}
+
+func checkContainersAgent(gcpHostnames []string) error {
+	var results []hostResult
+	if !fContainerErrors && !fContainerLogs {
+		results = append(results, runHostCheck(gcpHostnames, hostChecks["dockerps"])...)
+	}
+	if fContainerErrors {
+		results = append(results, runHostCheck(gcpHostnames, hostChecks["errors"])...)
+	}
 	if fContainerLogs {
-		if errs := agentDetails(gcpHostnames, "logs"); errs != nil {
-			return errs
-		}
+		results = append(results, runHostCheck(gcpHostnames, hostChecks["logs"])...)
 	}
-	return nil
+	return printHostResults(results)
 }
 
 func printAgentsStatus(jsonData []byte) error {
@@ -269,80 +416,3 @@ func printAgentsStatus(jsonData []byte) error {
 	fmt.Println(string(output))
 	return err
 }
-
-func agentDetails(gcpHostnames []string, what string) []error {
-	var remoteCmd string
-	switch what {
-	case "uptime":
-		remoteCmd = uptimeCmd
-	case "net":
-		remoteCmd = netCmd
-	case "dockerps":
-		remoteCmd = dockerPsCmd
-	case "errors":
-		remoteCmd = dockerAgentLogsCmd
-	case "logs":
-		remoteCmd = dockerAgentLogsCmd
-	default:
-		fatal(what)
-	}
-	var wg sync.WaitGroup
-	allOutput := make(chan []string, len(gcpHostnames))
-	allErrors := make(chan error, len(gcpHostnames))
-	for _, hostname := range gcpHostnames {
-		verbose("checking agent %v\n", hostname)
-		wg.Add(1)
-		go func(hostname string) {
-			defer wg.Done()
-			output, err := common.GcloudSSH(hostname, remoteCmd)
-			if err != nil {
-				allErrors <- fmt.Errorf("%s: %v", hostname, err)
-				return
-			}
-			allOutput <- output
-		}(hostname)
-	}
-	wg.Wait()
-	close(allOutput)
-	close(allErrors)
-	for output := range allOutput {
-		s := []string{}
-		for i, o := range output {
-			o = strings.TrimRight(o, "\r")
-			if strings.HasPrefix(o, "Connection to ") || strings.HasPrefix(o, "CONTAINER ID") {
-				continue
-			}
-			switch what {
-			case "uptime":
-				fallthrough
-			case "net":
-				o = strings.TrimRight(o, "\r\n")
-				if i == 0 {
-					s = append(s, fmt.Sprintf("%-30s", o)) // hostname that we wrote to channel
-				} else {
-					s = append(s, o, "  ") // output lines of the command
-				}
-			case "dockerps":
-				s = append(s, o)
-			case "errors":
-				if strings.Contains(strings.ToLower(o), "error") {
-					s = append(s, agents.ReplaceAgentUUIDs(o))
-				}
-			case "logs":
-				s = append(s, o)
-			default:
-				fatal(what)
-			}
-		}
-		if len(s) > 0 {
-			fmt.Println(strings.Join(s, "  "))
-		}
-	}
-	var errors []error
-	for err := range allErrors {
-		if err != nil {
-			errors = append(errors, err)
-		}
-	}
-	return errors
-}
@@ -0,0 +1,153 @@
+package check
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dioptra-io/irisctl/internal/agents"
+	"github.com/dioptra-io/irisctl/internal/common"
+)
+
+// hostCheck defines a check that runs a command on each host over SSH.
+// If filter is not nil, it returns the line to keep for each line of
+// the output of the command and whether to keep it.
+type hostCheck struct {
+	name      string
+	remoteCmd string
+	header    string
+	filter    func(line string) (string, bool)
+}
+
+// hostResult defines the result of a check of one host.
+type hostResult struct {
+	Host     string        `json:"host"`
+	Check    string        `json:"check"`
+	Duration time.Duration `json:"duration_ns"`
+	Output   []string      `json:"output"`
+	Error    string        `json:"error,omitempty"`
+}
+
+var (
+	// Checks of check agents and check containers keyed by name.
+	hostChecks = map[string]hostCheck{
+		"uptime": {
+			name:      "uptime",
+			remoteCmd: uptimeCmd,
+			header:    fmt.Sprintf("%-30s   %-68s", "hostname", "uptime"),
+		},
+		"net": {
+			name:      "net",
+			remoteCmd: netCmd,
+			header:    fmt.Sprintf("%-30s   %-12s  %-12s  %-10s  %-10s", "hostname", "rx_bytes", "tx_bytes", "rx_packets", "tx_packets"),
+		},
+		"dockerps": {
+			name:      "dockerps",
+			remoteCmd: dockerPsCmd,
+			filter: func(line string) (string, bool) {
+				return line, !strings.HasPrefix(line, "CONTAINER ID")
+			},
+		},
+		"errors": {
+			name:      "errors",
+			remoteCmd: dockerAgentLogsCmd,
+			filter: func(line string) (string, bool) {
+				return agents.ReplaceAgentUUIDs(line), strings.Contains(strings.ToLower(line), "error")
+			},
+		},
+		"logs": {
+			name:      "logs",
+			remoteCmd: dockerAgentLogsCmd,
+		},
+	}
+)
+
+// runHostCheck runs the check on all hosts concurrently and returns
+// the results sorted by host.
+func runHostCheck(gcpHostnames []string, c hostCheck) []hostResult {
+	var wg sync.WaitGroup
+	results := make([]hostResult, len(gcpHostnames))
+	for i, hostname := range gcpHostnames {
+		verbose("checking agent %v\n", hostname)
+		results[i] = hostResult{Host: hostname, Check: c.name, Output: []string{}}
+		wg.Add(1)
+		go func(r *hostResult) {
+			defer wg.Done()
+			start := time.Now()
+			output, err := common.SSH(r.Host, c.remoteCmd)
+			r.Duration = time.Since(start)
+			if err != nil {
+				r.Error = fmt.Sprintf("%s: %v", r.Host, err)
+				return
+			}
+			r.Output = hostOutput(output, c.filter)
+		}(&results[i])
+	}
+	wg.Wait()
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Host < results[j].Host })
+	return results
+}
+
+// hostOutput returns the lines of the output of SSH without its
+// first line (the hostname) and the messages of ssh.
+func hostOutput(output []string, filter func(string) (string, bool)) []string {
+	lines := []string{}
+	for i, line := range output {
+		line = strings.TrimRight(line, "\r\n")
+		if i == 0 || strings.HasPrefix(line, "Connection to ") {
+			continue
+		}
+		if filter != nil {
+			var keep bool
+			if line, keep = filter(line); !keep {
+				continue
+			}
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// printHostResults prints the results in the format of --format and
+// returns the errors of the hosts that could not be checked.
+func printHostResults(results []hostResult) error {
+	var errs []error
+	for _, r := range results {
+		if r.Error != "" {
+			errs = append(errs, errors.New(r.Error))
+		}
+	}
+	if fCheckFormat == "json" {
+		jsonData, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(jsonData))
+		return errors.Join(errs...)
+	}
+	prevCheck := ""
+	for _, r := range results {
+		c := hostChecks[r.Check]
+		if r.Check != prevCheck && c.header != "" {
+			fmt.Println(c.header)
+		}
+		prevCheck = r.Check
+		if r.Error != "" || len(r.Output) == 0 {
+			continue
+		}
+		switch r.Check {
+		case "uptime", "net":
+			fmt.Printf("%-30s   %s\n", r.Host, strings.Join(r.Output, "  "))
+		default:
+			fmt.Println(r.Host)
+			for _, line := range r.Output {
+				fmt.Printf("  %s\n", line)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
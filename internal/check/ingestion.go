@@ -0,0 +1,117 @@
+package check
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dioptra-io/irisctl/internal/clickhouse"
+	"github.com/dioptra-io/irisctl/internal/common"
+	"github.com/dioptra-io/irisctl/internal/meas"
+	"github.com/spf13/cobra"
+)
+
+// lastInsert defines the result of the query for the most recent
+// capture timestamp in a results table.
+type lastInsert struct {
+	LastInsert string `json:"last_insert"`
+}
+
+func checkIngestionArgs(cmd *cobra.Command, args []string) error {
+	if _, ok := common.IsUsage(args); ok {
+		return nil
+	}
+	if len(args) != 0 {
+		cliFatal("check ingestion does not take any arguments")
+	}
+	if fIngestionMeasUUID == "" {
+		cliFatal("check ingestion requires --meas-uuid")
+	}
+	if err := common.ValidateFormat([]string{fIngestionMeasUUID}, common.MeasurementUUID); err != nil {
+		cliFatal(err)
+	}
+	if fIngestionWindow <= 0 {
+		cliFatal("--window must be positive")
+	}
+	return nil
+}
+
+func checkIngestion(cmd *cobra.Command, args []string) {
+	measurement, err := meas.GetMeasurementAllDetails(fIngestionMeasUUID)
+	if err != nil {
+		fatal(err)
+	}
+	if measurement.State != "ongoing" {
+		fmt.Printf("measurement %s is %s, not ongoing\n", measurement.UUID, measurement.State)
+		return
+	}
+	stalled, err := printIngestion(measurement, fIngestionWindow)
+	if err != nil {
+		fatal(err)
+	}
+	if stalled > 0 {
+		fatal(fmt.Errorf("%w: %d of %d agent(s)", ErrIngestionStalled, stalled, len(measurement.Agents)))
+	}
+}
+
+// printIngestion prints the time of the most recent insert into the
+// results table of each agent of the measurement and returns the
+// number of ongoing agents that have not inserted within window.
+func printIngestion(measurement common.Measurement, window time.Duration) (int, error) {
+	now := time.Now().UTC()
+	stalled := 0
+	fmt.Printf("%-30s  %-36s  %-10s  %-19s  %s\n", "hostname", "agent_uuid", "state", "last_insert", "status")
+	for _, agent := range measurement.Agents {
+		t, err := agentLastInsert(measurement.UUID, agent.AgentUUID)
+		if err != nil {
+			return stalled, err
+		}
+		last, status := "-", "ok"
+		if !t.IsZero() {
+			last = t.Format("2006-01-02 15:04:05")
+		}
+		switch {
+		case agent.State != "ongoing":
+			status = "-"
+		case now.Sub(t) > window:
+			status = fmt.Sprintf("STALLED (no inserts in the last %v)", window)
+			stalled++
+		}
+		fmt.Printf("%-30s  %-36s  %-10s  %-19s  %s\n", agent.AgentParameters.Hostname, agent.AgentUUID, agent.State, last, status)
+	}
+	return stalled, nil
+}
+
+// agentLastInsert returns the most recent capture timestamp in the
+// results table of the specified agent of the measurement, or the
+// zero time if the table is empty.
+func agentLastInsert(measUUID, agentUUID string) (time.Time, error) {
+	table := meas.TableNames(measUUID, agentUUID)[0] // results table
+	query := fmt.Sprintf("SELECT toString(max(capture_timestamp)) AS last_insert FROM %s", table)
+	filename, output, err := clickhouse.RunQueryString(query)
+	if filename != "" {
+		defer os.Remove(filename)
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s: %v: %s", table, err, output)
+	}
+	contents, err := os.ReadFile(filename)
+	if err != nil {
+		return time.Time{}, err
+	}
+	var r lastInsert
+	if err := json.Unmarshal([]byte(strings.TrimSpace(string(contents))), &r); err != nil {
+		return time.Time{}, fmt.Errorf("%s: %v", table, err)
+	}
+	t, err := time.Parse("2006-01-02 15:04:05", r.LastInsert)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s: %v", table, err)
+	}
+	// ClickHouse returns the epoch for empty tables.
+	if t.Unix() == 0 {
+		return time.Time{}, nil
+	}
+	return t, nil
+}
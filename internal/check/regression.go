@@ -0,0 +1,255 @@
+package check
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dioptra-io/irisctl/internal/auth"
+	"github.com/dioptra-io/irisctl/internal/common"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Read-only API calls recorded by default, relative to the API
+	// URL so that a snapshot can be replayed against another
+	// deployment.
+	defaultRegressionCalls = []string{
+		common.StatusAPISuffix + "/",
+		common.AgentsAPISuffix + "/",
+		common.UsersAPISuffix + "/me",
+		common.TargetsAPISuffix + "/?offset=0&limit=10",
+		common.MeasurementsAPISuffix + "/?offset=0&limit=10",
+	}
+	// Fields whose keys change between calls (e.g., probing statistics
+	// are keyed by round) and are not compared by default.
+	defaultRegressionIgnore = []string{"probing_statistics"}
+
+	// Errors.
+	ErrRegression       = errors.New("API regression")
+	ErrUnexpectedStatus = errors.New("unexpected HTTP status")
+)
+
+// regressionSnapshot defines the responses of API calls recorded by
+// check regression --record.
+type regressionSnapshot struct {
+	APIURL   string           `json:"api_url"`
+	Irisctl  string           `json:"irisctl"`
+	Recorded time.Time        `json:"recorded"`
+	Calls    []regressionCall `json:"calls"`
+}
+
+// regressionCall defines an API call and its JSON response.
+type regressionCall struct {
+	Path     string          `json:"path"`
+	Response json.RawMessage `json:"response"`
+}
+
+// regressionDiff defines a structural difference between a recorded
+// and a live response.  Breaking differences (removed fields and
+// changed types) affect irisctl users; added fields do not.
+type regressionDiff struct {
+	path     string
+	what     string
+	breaking bool
+}
+
+func checkRegressionArgs(cmd *cobra.Command, args []string) error {
+	if format, ok := common.IsUsage(args); ok {
+		fmt.Printf(format, "<snapshot-file>", "file of recorded API responses")
+		return nil
+	}
+	if len(args) != 1 {
+		cliFatal("check regression requires exactly one argument: <snapshot-file>")
+	}
+	if len(fRegressionCalls) > 0 && !fRegressionRecord {
+		cliFatal("check regression --call requires --record (replay uses the calls of the snapshot)")
+	}
+	for _, call := range fRegressionCalls {
+		if !strings.HasPrefix(call, "/") {
+			cliFatal("--call must be a path relative to the API URL starting with /: ", call)
+		}
+	}
+	return nil
+}
+
+func checkRegression(cmd *cobra.Command, args []string) {
+	if fRegressionRecord {
+		calls := fRegressionCalls
+		if len(calls) == 0 {
+			calls = defaultRegressionCalls
+		}
+		if err := recordSnapshot(args[0], calls); err != nil {
+			fatal(err)
+		}
+		return
+	}
+	snapshot, err := readSnapshot(args[0])
+	if err != nil {
+		fatal(err)
+	}
+	ignore := append(append([]string{}, defaultRegressionIgnore...), fRegressionIgnore...)
+	nBreaking := 0
+	for _, call := range snapshot.Calls {
+		live, err := regressionGet(call.Path)
+		if err != nil {
+			fmt.Printf("GET %s: %v\n", call.Path, err)
+			nBreaking++
+			continue
+		}
+		var recorded, current interface{}
+		if err := json.Unmarshal(call.Response, &recorded); err != nil {
+			fatal(fmt.Errorf("%s: GET %s: %w", args[0], call.Path, err))
+		}
+		if err := json.Unmarshal(live, &current); err != nil {
+			fmt.Printf("GET %s: response is not JSON: %v\n", call.Path, err)
+			nBreaking++
+			continue
+		}
+		diffs := diffStructure("$", recorded, current, ignore)
+		for _, d := range diffs {
+			prefix := "+"
+			if d.breaking {
+				prefix = "-"
+				nBreaking++
+			}
+			fmt.Printf("%s GET %s: %s: %s\n", prefix, call.Path, d.path, d.what)
+		}
+		verbose("GET %s: %d differences\n", call.Path, len(diffs))
+	}
+	fmt.Printf("%d calls replayed against %s (recorded against %s on %s), %d breaking differences\n",
+		len(snapshot.Calls), common.RootFlagString("iris-api-url"), snapshot.APIURL, snapshot.Recorded.Format("2006-01-02"), nBreaking)
+	if nBreaking > 0 {
+		fatal(fmt.Errorf("%w: %d breaking differences", ErrRegression, nBreaking))
+	}
+}
+
+// recordSnapshot saves the responses of the specified API calls in
+// file.
+func recordSnapshot(file string, paths []string) error {
+	snapshot := regressionSnapshot{
+		APIURL:   common.RootFlagString("iris-api-url"),
+		Irisctl:  common.IrisctlVersion(),
+		Recorded: time.Now().UTC(),
+	}
+	for _, path := range paths {
+		response, err := regressionGet(path)
+		if err != nil {
+			return fmt.Errorf("GET %s: %w", path, err)
+		}
+		if !json.Valid(response) {
+			return fmt.Errorf("GET %s: response is not JSON: %.80s", path, response)
+		}
+		snapshot.Calls = append(snapshot.Calls, regressionCall{Path: path, Response: response})
+	}
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(file, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	common.SavingIn(file)
+	return nil
+}
+
+// readSnapshot returns the snapshot saved in file.
+func readSnapshot(file string) (regressionSnapshot, error) {
+	var snapshot regressionSnapshot
+	contents, err := os.ReadFile(file)
+	if err != nil {
+		return snapshot, err
+	}
+	if err := json.Unmarshal(contents, &snapshot); err != nil {
+		return snapshot, fmt.Errorf("%s: %w", file, err)
+	}
+	return snapshot, nil
+}
+
+// regressionGet returns the response of the API call of path.  The
+// response is never cached and error responses (non-2xx) are returned
+// as errors so they are neither recorded nor compared.
+func regressionGet(path string) ([]byte, error) {
+	response, status, err := common.CurlStatus(auth.GetAccessToken(), false, "GET", common.RootFlagString("iris-api-url")+path)
+	if err != nil {
+		return nil, err
+	}
+	if status < 200 || status >= 300 {
+		return nil, fmt.Errorf("%w: %d: %.80s", ErrUnexpectedStatus, status, response)
+	}
+	return response, nil
+}
+
+// diffStructure returns the structural differences between the
+// recorded and the current JSON values: fields that were removed or
+// added and values whose type changed.  Values themselves are not
+// compared because they are volatile (e.g., times and counts).  A null
+// value matches any type and the elements of arrays are compared with
+// the first element of the other array, so responses with different
+// results can be compared.  Fields in ignore are skipped.
+func diffStructure(path string, recorded, current interface{}, ignore []string) []regressionDiff {
+	if recorded == nil || current == nil {
+		return nil
+	}
+	if jsonType(recorded) != jsonType(current) {
+		return []regressionDiff{{path, fmt.Sprintf("type changed from %s to %s", jsonType(recorded), jsonType(current)), true}}
+	}
+	var diffs []regressionDiff
+	switch r := recorded.(type) {
+	case map[string]interface{}:
+		c := current.(map[string]interface{})
+		keys := make([]string, 0, len(r)+len(c))
+		for k := range r {
+			keys = append(keys, k)
+		}
+		for k := range c {
+			if _, ok := r[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if common.Contains(ignore, k) {
+				continue
+			}
+			rv, rok := r[k]
+			cv, cok := c[k]
+			switch {
+			case !cok:
+				diffs = append(diffs, regressionDiff{path + "." + k, "field removed", true})
+			case !rok:
+				diffs = append(diffs, regressionDiff{path + "." + k, "field added", false})
+			default:
+				diffs = append(diffs, diffStructure(path+"."+k, rv, cv, ignore)...)
+			}
+		}
+	case []interface{}:
+		c := current.([]interface{})
+		if len(r) > 0 && len(c) > 0 {
+			diffs = diffStructure(path+"[]", r[0], c[0], ignore)
+		}
+	}
+	return diffs
+}
+
+// jsonType returns the JSON type of a decoded value.
+func jsonType(v interface{}) string {
+	switch v.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	default:
+		return "null"
+	}
+}
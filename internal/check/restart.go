@@ -0,0 +1,138 @@
+package check
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/dioptra-io/irisctl/internal/agents"
+	"github.com/dioptra-io/irisctl/internal/common"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// composeFilesLabel is the label that docker compose sets on the
+	// containers it starts.
+	composeFilesLabel = "com.docker.compose.project.config_files"
+	// dockerNoValue is what docker inspect prints for missing labels.
+	dockerNoValue = "<no value>"
+)
+
+var (
+	ErrRestartPolicy = errors.New("agent container will not survive a reboot")
+)
+
+// restartAudit defines how the iris-agent container of an agent is
+// started and the problems found with it.
+type restartAudit struct {
+	hostname     string
+	policy       string
+	composeFiles string
+	unitState    string
+	problems     []string
+}
+
+func checkDockerRestartArgs(cmd *cobra.Command, args []string) error {
+	if format, ok := common.IsUsage(args); ok {
+		fmt.Printf(format, "<hostname>...", "zero or more agent hostnames (default: all agents)")
+		return nil
+	}
+	if fRestartPolicy == "" {
+		cliFatal("--policy cannot be empty")
+	}
+	return nil
+}
+
+func checkDockerRestart(cmd *cobra.Command, args []string) {
+	gcpHostnames := args
+	if len(gcpHostnames) == 0 {
+		jsonData, err := agents.GetAgents("", false)
+		if err != nil {
+			fatal(err)
+		}
+		if gcpHostnames, err = common.ParseGCPHostnames(jsonData); err != nil {
+			fatal(err)
+		}
+	}
+	audits, errs := auditRestart(gcpHostnames)
+	for _, err := range errs {
+		fmt.Fprintf(os.Stderr, "WARNING: %v\n", err)
+	}
+	n := 0
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "hostname\trestart policy\tcompose files\tunit\tstatus\n")
+	for _, a := range audits {
+		status := "ok"
+		if len(a.problems) > 0 {
+			status = "FLAGGED: " + strings.Join(a.problems, "; ")
+			n++
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", a.hostname, a.policy, a.composeFiles, a.unitState, status)
+	}
+	w.Flush()
+	if n > 0 {
+		fatal(fmt.Errorf("%w: %d agent(s)", ErrRestartPolicy, n))
+	}
+}
+
+// auditRestart inspects the iris-agent container of each agent over
+// SSH and returns the audits sorted by hostname.
+func auditRestart(gcpHostnames []string) ([]restartAudit, []error) {
+	remoteCmd := fmt.Sprintf("docker inspect --format '{{.HostConfig.RestartPolicy.Name}}|{{index .Config.Labels \"%s\"}}' iris-agent; systemctl is-enabled %s || true", composeFilesLabel, fRestartUnit)
+	var (
+		audits []restartAudit
+		errs   []error
+	)
+	for _, r := range runHostCheck(gcpHostnames, hostCheck{name: "docker-restart", remoteCmd: remoteCmd}) {
+		if r.Error != "" {
+			errs = append(errs, errors.New(r.Error))
+			continue
+		}
+		audits = append(audits, parseRestartAudit(r.Host, r.Output))
+	}
+	return audits, errs
+}
+
+// parseRestartAudit parses the output of the remote command of
+// auditRestart and records the problems of the container: a restart
+// policy other than --policy, or not being managed by docker compose
+// (with --compose-file if specified) or by an enabled systemd unit.
+func parseRestartAudit(hostname string, output []string) restartAudit {
+	a := restartAudit{hostname: hostname, policy: "-", composeFiles: "-", unitState: "-"}
+	found := false
+	for _, line := range output {
+		line = strings.TrimSpace(line)
+		if line == "" || line == hostname || strings.HasPrefix(line, "Connection to ") {
+			continue
+		}
+		if policy, files, ok := strings.Cut(line, "|"); ok && !found {
+			found = true
+			a.policy = policy
+			if files != "" && files != dockerNoValue {
+				a.composeFiles = files
+			}
+			continue
+		}
+		if !strings.Contains(line, " ") {
+			a.unitState = line
+		}
+	}
+	if !found {
+		a.problems = append(a.problems, "no iris-agent container")
+		return a
+	}
+	if a.policy != fRestartPolicy {
+		a.problems = append(a.problems, fmt.Sprintf("restart policy is %s instead of %s", a.policy, fRestartPolicy))
+	}
+	composed := a.composeFiles != "-" && (fRestartComposeFile == "" || common.Contains(strings.Split(a.composeFiles, ","), fRestartComposeFile))
+	if !composed && a.unitState != "enabled" {
+		if a.composeFiles != "-" {
+			a.problems = append(a.problems, fmt.Sprintf("started from %s instead of %s", a.composeFiles, fRestartComposeFile))
+		} else {
+			a.problems = append(a.problems, fmt.Sprintf("not started by docker compose or by an enabled %s", fRestartUnit))
+		}
+	}
+	return a
+}
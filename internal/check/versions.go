@@ -0,0 +1,270 @@
+package check
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/dioptra-io/irisctl/internal/agents"
+	"github.com/dioptra-io/irisctl/internal/common"
+	"github.com/dioptra-io/irisctl/internal/meas"
+	"github.com/spf13/cobra"
+)
+
+// The default compatibility matrix is embedded in the binary.
+//
+//go:embed compat.json
+var defaultMatrix []byte
+
+var (
+	// Errors.
+	ErrVersionSkew    = errors.New("unsupported version combination")
+	ErrInvalidMatrix  = errors.New("invalid compatibility matrix")
+	ErrUnknownVersion = errors.New("unknown version")
+)
+
+// CompatMatrix defines which versions of Iris components are
+// supported.  Each rule applies to the versions of irisctl at least
+// IrisctlMin (all versions if empty) and requires the versions of the
+// component (api or agent) to be at least Min and less than Max (if
+// not empty).  If AgentsMatchAPI is true, the major and minor versions
+// of agents must be those of the API.
+type CompatMatrix struct {
+	AgentsMatchAPI bool         `json:"agents_match_api"`
+	Rules          []CompatRule `json:"rules"`
+}
+
+// CompatRule defines a rule of a compatibility matrix.
+type CompatRule struct {
+	IrisctlMin string `json:"irisctl_min"`
+	Component  string `json:"component"`
+	Min        string `json:"min"`
+	Max        string `json:"max"`
+	Reason     string `json:"reason"`
+}
+
+// componentVersion defines the version of a component and the
+// problems found with it.
+type componentVersion struct {
+	component string
+	name      string
+	version   string
+	problems  []string
+}
+
+func checkVersionsArgs(cmd *cobra.Command, args []string) error {
+	if _, ok := common.IsUsage(args); ok {
+		return nil
+	}
+	if len(args) != 0 {
+		cliFatal("check versions does not take any arguments")
+	}
+	return nil
+}
+
+func checkVersions(cmd *cobra.Command, args []string) {
+	matrix, err := loadMatrix(fVersionsMatrix)
+	if err != nil {
+		fatal(err)
+	}
+	components, err := getVersions()
+	if err != nil {
+		fatal(err)
+	}
+	if n := checkMatrix(matrix, components); n > 0 {
+		printVersions(components)
+		fatal(fmt.Errorf("%w: %d component(s)", ErrVersionSkew, n))
+	}
+	printVersions(components)
+}
+
+// loadMatrix returns the compatibility matrix in the specified file or
+// URL, or the embedded matrix if source is empty.
+func loadMatrix(source string) (CompatMatrix, error) {
+	var matrix CompatMatrix
+	data := defaultMatrix
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		jsonData, err := common.Curl("", false, "GET", source)
+		if err != nil {
+			return matrix, fmt.Errorf("%s: %w", source, err)
+		}
+		data = jsonData
+	} else if source != "" {
+		contents, err := os.ReadFile(source)
+		if err != nil {
+			return matrix, err
+		}
+		data = contents
+	}
+	if err := json.Unmarshal(data, &matrix); err != nil {
+		return matrix, fmt.Errorf("%s: %w: %v", source, ErrInvalidMatrix, err)
+	}
+	for _, rule := range matrix.Rules {
+		if rule.Component != "api" && rule.Component != "agent" {
+			return matrix, fmt.Errorf("%s: %w: unknown component %q", source, ErrInvalidMatrix, rule.Component)
+		}
+	}
+	return matrix, nil
+}
+
+// getVersions returns the versions of irisctl, the API, and all agents.
+func getVersions() ([]*componentVersion, error) {
+	components := []*componentVersion{{component: "irisctl", name: "-", version: common.IrisctlVersion()}}
+	apiVersion, err := meas.GetAPIVersion()
+	if err != nil {
+		return nil, err
+	}
+	components = append(components, &componentVersion{component: "api", name: common.RootFlagString("iris-api-url"), version: apiVersion})
+	jsonData, err := agents.GetAgents("", false)
+	if err != nil {
+		return nil, err
+	}
+	var agentsData common.AgentsData
+	if err := common.DecodeJSON(jsonData, &agentsData); err != nil {
+		return nil, err
+	}
+	sort.Slice(agentsData.Results, func(i, j int) bool {
+		return agentsData.Results[i].Parameters.Hostname < agentsData.Results[j].Parameters.Hostname
+	})
+	for _, result := range agentsData.Results {
+		components = append(components, &componentVersion{component: "agent", name: result.Parameters.Hostname, version: result.Parameters.Version})
+	}
+	return components, nil
+}
+
+// checkMatrix records the problems of each component according to the
+// matrix and returns the number of components with problems.  The
+// version of irisctl itself is not checked; it selects the rules.
+func checkMatrix(matrix CompatMatrix, components []*componentVersion) int {
+	irisctl := components[0].version
+	apiVersion := ""
+	for _, c := range components {
+		if c.component == "api" {
+			apiVersion = c.version
+		}
+	}
+	n := 0
+	for _, c := range components[1:] {
+		if _, err := parseVersion(c.version); err != nil {
+			c.problems = append(c.problems, err.Error())
+			n++
+			continue
+		}
+		for _, rule := range matrix.Rules {
+			if rule.Component != c.component {
+				continue
+			}
+			if rule.IrisctlMin != "" && compareVersions(irisctl, rule.IrisctlMin) < 0 {
+				continue
+			}
+			if (rule.Min != "" && compareVersions(c.version, rule.Min) < 0) ||
+				(rule.Max != "" && compareVersions(c.version, rule.Max) >= 0) {
+				c.problems = append(c.problems, fmt.Sprintf("requires %s (%s)", versionRange(rule), rule.Reason))
+			}
+		}
+		if matrix.AgentsMatchAPI && c.component == "agent" && !sameMinor(c.version, apiVersion) {
+			c.problems = append(c.problems, fmt.Sprintf("API version is %s", apiVersion))
+		}
+		if len(c.problems) > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+func printVersions(components []*componentVersion) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "component\tname\tversion\tstatus\n")
+	for _, c := range components {
+		status := "ok"
+		if len(c.problems) > 0 {
+			status = "UNSUPPORTED: " + strings.Join(c.problems, "; ")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", c.component, c.name, c.version, status)
+	}
+	w.Flush()
+}
+
+func versionRange(rule CompatRule) string {
+	var r []string
+	if rule.Min != "" {
+		r = append(r, ">= "+rule.Min)
+	}
+	if rule.Max != "" {
+		r = append(r, "< "+rule.Max)
+	}
+	return strings.Join(r, " and ")
+}
+
+// parseVersion returns the numeric components of a version such as
+// v1.2.3 or 1.2.3-rc1 (pre-release and build suffixes are ignored).
+func parseVersion(version string) ([]int, error) {
+	v := strings.TrimPrefix(version, "v")
+	if i := strings.IndexAny(v, "-+"); i >= 0 {
+		v = v[:i]
+	}
+	var numbers []int
+	for _, field := range strings.Split(v, ".") {
+		n, err := strconv.Atoi(field)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownVersion, version)
+		}
+		numbers = append(numbers, n)
+	}
+	return numbers, nil
+}
+
+// compareVersions returns -1, 0, or 1 if a is older than, the same as,
+// or newer than b.  Versions that cannot be parsed (e.g., dev builds)
+// are newer than all release versions.
+func compareVersions(a, b string) int {
+	va, errA := parseVersion(a)
+	vb, errB := parseVersion(b)
+	switch {
+	case errA != nil && errB != nil:
+		return 0
+	case errA != nil:
+		return 1
+	case errB != nil:
+		return -1
+	}
+	for i := 0; i < len(va) || i < len(vb); i++ {
+		var na, nb int
+		if i < len(va) {
+			na = va[i]
+		}
+		if i < len(vb) {
+			nb = vb[i]
+		}
+		if na != nb {
+			if na < nb {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// sameMinor returns true if a and b have the same major and minor
+// versions.
+func sameMinor(a, b string) bool {
+	va, errA := parseVersion(a)
+	vb, errB := parseVersion(b)
+	if errA != nil || errB != nil {
+		return false
+	}
+	for len(va) < 2 {
+		va = append(va, 0)
+	}
+	for len(vb) < 2 {
+		vb = append(vb, 0)
+	}
+	return va[0] == vb[0] && va[1] == vb[1]
+}
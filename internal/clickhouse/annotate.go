@@ -0,0 +1,138 @@
+package clickhouse
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/dioptra-io/irisctl/internal/common"
+	"github.com/dioptra-io/irisctl/internal/meas"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Errors.
+	ErrAnnotateFailed = errors.New("failed to annotate tables")
+)
+
+func clickhouseAnnotateArgs(cmd *cobra.Command, args []string) error {
+	if _, ok := common.IsUsage(args); ok {
+		return nil
+	}
+	if len(args) != 0 {
+		cliFatal("clickhouse annotate does not take any arguments")
+	}
+	if fAnnotateMeasUUID == "" {
+		cliFatal("clickhouse annotate requires --meas-uuid")
+	}
+	if err := common.ValidateFormat([]string{fAnnotateMeasUUID}, common.MeasurementUUID); err != nil {
+		cliFatal(err)
+	}
+	return nil
+}
+
+func clickhouseAnnotate(cmd *cobra.Command, args []string) {
+	if err := annotateMeasurement(fAnnotateMeasUUID, fAnnotateDryRun); err != nil {
+		fatal(err)
+	}
+}
+
+// annotateMeasurement writes the tags and owner of the specified
+// measurement, and the agent of each table, into the comments of its
+// tables so that they can be identified without Iris API.  Tables
+// that cannot be annotated are reported and the others are still
+// annotated.
+func annotateMeasurement(measUUID string, dryRun bool) error {
+	measurement, err := meas.GetMeasurementAllDetails(measUUID)
+	if err != nil {
+		return err
+	}
+	hostnames := map[string]string{}
+	for _, agent := range measurement.Agents {
+		hostnames[strings.ReplaceAll(agent.AgentUUID, "-", "_")] = agent.AgentParameters.Hostname
+	}
+	userpass, err := getUserPass()
+	if err != nil {
+		return err
+	}
+	tmpFile, err := common.CreateTemp("irisctl-clickhouse-annotate-")
+	if err != nil {
+		return err
+	}
+	tmpFile.Close()
+	defer func(f string) { verbose("removing %s\n", f); os.Remove(f) }(tmpFile.Name())
+
+	tables, err := measurementTables(userpass, measUUID, tmpFile.Name())
+	if err != nil {
+		return err
+	}
+	if len(tables) == 0 {
+		return fmt.Errorf("%s: %w", measUUID, meas.ErrNoTables)
+	}
+	failed := 0
+	for _, table := range tables {
+		comment := tableComment(measurement, table, hostnames)
+		query := fmt.Sprintf("ALTER TABLE %s.%s MODIFY COMMENT '%s'", database(), table, quoteString(comment))
+		if dryRun {
+			fmt.Printf("dry-run: would run: %s\n", query)
+			continue
+		}
+		if output, err := runQuery(userpass, query, tmpFile.Name()); err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %s: %v: %s\n", table, err, output)
+			failed++
+			continue
+		}
+		fmt.Printf("%s: %s\n", table, comment)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%s: %w: %d of %d table(s)", measUUID, ErrAnnotateFailed, failed, len(tables))
+	}
+	return nil
+}
+
+// measurementTables returns the sorted names of the tables of the
+// specified measurement.
+func measurementTables(userpass, measUUID, outputFile string) ([]string, error) {
+	if output, err := runQuery(userpass, tablesQuery(measUUID), outputFile); err != nil {
+		return nil, fmt.Errorf("%v: %s", err, output)
+	}
+	contents, err := os.ReadFile(outputFile)
+	if err != nil {
+		return nil, err
+	}
+	var tables []string
+	for _, line := range strings.Split(string(contents), "\n") {
+		if line == "" {
+			continue
+		}
+		var t tailTable
+		if err := json.Unmarshal([]byte(line), &t); err != nil {
+			return nil, err
+		}
+		tables = append(tables, t.Name)
+	}
+	sort.Strings(tables)
+	return tables, nil
+}
+
+// tableComment returns the comment of a table of the measurement:
+// its UUID, owner, tags, and the hostname of the agent of the table
+// (if the table belongs to one agent).
+func tableComment(measurement common.Measurement, table string, hostnames map[string]string) string {
+	comment := fmt.Sprintf("irisctl: measurement %s, owner %s, tags %s", measurement.UUID, measurement.UserID, strings.Join(measurement.Tags, " "))
+	for agentUUID, hostname := range hostnames {
+		if strings.Contains(table, agentUUID) {
+			comment += fmt.Sprintf(", agent %s", hostname)
+			break
+		}
+	}
+	return comment
+}
+
+// quoteString escapes s for a single-quoted ClickHouse string literal.
+func quoteString(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s)
+}
@@ -5,21 +5,37 @@ import (
 	"log"
 	"net/url"
 	"os"
+	"time"
 
 	"github.com/dioptra-io/irisctl/internal/common"
-	"github.com/dioptra-io/irisctl/internal/users"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 var (
 	// Command, its flags, subcommands, and their flags.
-	//      clickhouse --query <query-string>
-	//      clickhouse <query-file>
+	//      clickhouse [--clickhouse-credentials <file>] [--clickhouse-user <user>] [--clickhouse-password <password>] [--clickhouse-database <database>] --query <query-string>
+	//      clickhouse [--clickhouse-credentials <file>] [--clickhouse-user <user>] [--clickhouse-password <password>] [--clickhouse-database <database>] <query-file>
+	//      clickhouse export [--jobs <n>] [--output-dir <dir>] <meas-uuid>...
+	//      clickhouse tail --meas-uuid <meas-uuid> [--interval <duration>] [--count <n>]
+	//      clickhouse annotate --meas-uuid <meas-uuid> [--dry-run]
 	cmdName           = "clickhouse"
-	subcmdNames       = []string{}
+	subcmdNames       = []string{"export", "tail", "annotate"}
 	fClickHouseQuery  string
 	fClickhouseURL    string
+	fClickhouseURLs   []string
 	fClickhouseParams string
+	fClickhouseUser   string
+	fClickhousePass   string
+	fClickhouseDB     string
+	fClickhouseCreds  string
+	fExportJobs       int
+	fExportOutputDir  string
+	fTailMeasUUID     string
+	fTailInterval     time.Duration
+	fTailCount        int
+	fAnnotateMeasUUID string
+	fAnnotateDryRun   bool
 
 	// Test code changes Fatal to Panic so a fatal error won't exit
 	// the process and can be recovered.
@@ -40,27 +56,77 @@ func ClickHouseCmd() *cobra.Command {
 	}
 	clickhouseCmd.Flags().StringVar(&fClickHouseQuery, "query", "", "clickhouse query string")
 	clickhouseCmd.Flags().StringVar(&fClickhouseURL, "clickhouse-proxy-url", "https://chproxy.iris.dioptra.io", "proxy url of the clickhouse server")
+	clickhouseCmd.Flags().StringSliceVar(&fClickhouseURLs, "clickhouse-proxy-urls", []string{}, "comma-separated proxy urls of the clickhouse server to fail over between (overrides --clickhouse-proxy-url)")
 	clickhouseCmd.Flags().StringVar(&fClickhouseParams, "clickhouse-params", "enable_http_compression=false&default_format=JSONEachRow&output_format_json_quote_64bit_integer", "raw string of clickhouse parameters")
+	// Bind --clickhouse-proxy-url so it can also be set in the
+	// configuration file and is used when other commands run queries.
+	_ = viper.BindPFlag("clickhouse-proxy-url", clickhouseCmd.Flags().Lookup("clickhouse-proxy-url"))
+	_ = viper.BindPFlag("clickhouse-proxy-urls", clickhouseCmd.Flags().Lookup("clickhouse-proxy-urls"))
+	// Admins with ClickHouse accounts can bypass users/me/services
+	// (e.g., when the API is degraded).
+	clickhouseCmd.Flags().StringVar(&fClickhouseCreds, "clickhouse-credentials", "", "JSON file with the username, password, and database of a clickhouse account")
+	clickhouseCmd.Flags().StringVar(&fClickhouseUser, "clickhouse-user", "", "clickhouse username (overrides the credentials file and iris api)")
+	clickhouseCmd.Flags().StringVar(&fClickhousePass, "clickhouse-password", "", "clickhouse password (overrides the credentials file)")
+	clickhouseCmd.Flags().StringVar(&fClickhouseDB, "clickhouse-database", "", "clickhouse database (default "+DefaultDatabase+")")
+	for _, flag := range []string{"clickhouse-credentials", "clickhouse-user", "clickhouse-password", "clickhouse-database"} {
+		_ = viper.BindPFlag(flag, clickhouseCmd.Flags().Lookup(flag))
+	}
 	clickhouseCmd.SetUsageFunc(common.Usage)
 	clickhouseCmd.SetHelpFunc(common.Help)
 
+	// clickhouse export and its flags
+	exportSubcmd := &cobra.Command{
+		Use:   "export",
+		Short: "export measurement tables",
+		Long:  "export all tables of the specified measurement(s) concurrently",
+		Args:  clickhouseExportArgs,
+		Run:   clickhouseExport,
+	}
+	exportSubcmd.Flags().IntVar(&fExportJobs, "jobs", 4, "number of measurements to export concurrently")
+	exportSubcmd.Flags().StringVar(&fExportOutputDir, "output-dir", ".", "directory or gs:// or s3:// URL to export the tables to")
+	clickhouseCmd.AddCommand(exportSubcmd)
+
+	// clickhouse tail and its flags
+	tailSubcmd := &cobra.Command{
+		Use:   "tail",
+		Short: "monitor inserts of a measurement",
+		Long:  "periodically print the number of rows in the tables of an ongoing measurement and the insert throughput",
+		Args:  clickhouseTailArgs,
+		Run:   clickhouseTail,
+	}
+	tailSubcmd.Flags().StringVar(&fTailMeasUUID, "meas-uuid", "", "measurement UUID")
+	tailSubcmd.Flags().DurationVar(&fTailInterval, "interval", 10*time.Second, "time between queries")
+	tailSubcmd.Flags().IntVar(&fTailCount, "count", 0, "number of queries before exiting (0 means until interrupted)")
+	clickhouseCmd.AddCommand(tailSubcmd)
+
+	// clickhouse annotate and its flags
+	annotateSubcmd := &cobra.Command{
+		Use:   "annotate",
+		Short: "write measurement metadata into table comments",
+		Long:  "write the UUID, owner, and tags of a measurement and the agent of each table into the comments of its tables so that they can be identified in ClickHouse without Iris API",
+		Args:  clickhouseAnnotateArgs,
+		Run:   clickhouseAnnotate,
+	}
+	annotateSubcmd.Flags().StringVar(&fAnnotateMeasUUID, "meas-uuid", "", "measurement UUID")
+	annotateSubcmd.Flags().BoolVar(&fAnnotateDryRun, "dry-run", false, "print the ALTER TABLE queries instead of running them")
+	clickhouseCmd.AddCommand(annotateSubcmd)
+
 	return clickhouseCmd
 }
 
 func RunQueryString(query string) (string, string, error) {
 	verbose("querying clickhouse with the query string %s\n", query)
-	userpass, err := users.GetUserPass()
+	userpass, err := getUserPass()
 	if err != nil {
 		return "", "", err
 	}
-	tmpFile, err := os.CreateTemp("/tmp", "irisctl-clickhouse-")
+	tmpFile, err := common.CreateTemp("irisctl-clickhouse-")
 	if err != nil {
 		return "", "", err
 	}
 	defer tmpFile.Close()
-	url := fmt.Sprintf("%v/?%v&database=iris&query=%v", fClickhouseURL, fClickhouseParams, url.QueryEscape(query))
-	output, err := common.Curl(userpass, true, "POST", url, "--http1.1", "--output", tmpFile.Name())
-	return tmpFile.Name(), string(output), err
+	output, err := runQuery(userpass, query, tmpFile.Name())
+	return tmpFile.Name(), output, err
 }
 
 func clickhouseArgs(cmd *cobra.Command, args []string) error {
@@ -100,6 +166,21 @@ func clickhouse(cmd *cobra.Command, args []string) {
 	fmt.Printf("%v\n", string(content))
 }
 
+// runQuery runs the query with the specified credentials and saves
+// its results in outputFile.  If there are several proxies, it fails
+// over to the next healthy one when the active proxy fails.
+func runQuery(userpass, query, outputFile string) (string, error) {
+	return runQueryFailover(userpass, query, outputFile)
+}
+
+// runQueryOn runs the query on the specified proxy.
+func runQueryOn(proxy, userpass, query, outputFile string, args ...string) (string, error) {
+	url := fmt.Sprintf("%v/?%v&database=%v&query=%v", proxy, fClickhouseParams, url.QueryEscape(database()), url.QueryEscape(query))
+	args = append([]string{"--http1.1", "--output", outputFile}, args...)
+	output, err := common.Curl(userpass, true, "POST", url, args...)
+	return string(output), err
+}
+
 func runQueryFromFile(queryFile string) (string, string, error) {
 	verbose("querying clickhouse with the query in %s\n", queryFile)
 	content, err := os.ReadFile(queryFile)
@@ -0,0 +1,85 @@
+package clickhouse
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/dioptra-io/irisctl/internal/common"
+	"github.com/dioptra-io/irisctl/internal/users"
+)
+
+const (
+	// DefaultDatabase is the database of Iris tables.
+	DefaultDatabase = "iris"
+)
+
+var (
+	// Credentials from --clickhouse-credentials merged with the
+	// --clickhouse-user, --clickhouse-password, and
+	// --clickhouse-database overrides.
+	overrides     common.ClickHouse
+	overridesErr  error
+	overridesOnce sync.Once
+
+	// Errors.
+	ErrInvalidCredentials = errors.New("invalid clickhouse credentials file")
+)
+
+// loadOverrides reads the credentials file, if any, and applies the
+// flag overrides.  The credentials file has the format of the
+// clickhouse object returned by users services:
+//
+//	{"username": "admin", "password": "secret", "database": "iris"}
+func loadOverrides() (common.ClickHouse, error) {
+	overridesOnce.Do(func() {
+		if file := common.RootFlagString("clickhouse-credentials"); file != "" {
+			contents, err := os.ReadFile(file)
+			if err != nil {
+				overridesErr = err
+				return
+			}
+			if err := json.Unmarshal(contents, &overrides); err != nil {
+				overridesErr = fmt.Errorf("%s: %w: %v", file, ErrInvalidCredentials, err)
+				return
+			}
+			verbose("using clickhouse credentials in %s\n", file)
+		}
+		if user := common.RootFlagString("clickhouse-user"); user != "" {
+			overrides.Username = user
+		}
+		if password := common.RootFlagString("clickhouse-password"); password != "" {
+			overrides.Password = password
+		}
+		if database := common.RootFlagString("clickhouse-database"); database != "" {
+			overrides.Database = database
+		}
+	})
+	return overrides, overridesErr
+}
+
+// getUserPass returns the username and password for ClickHouse.  If a
+// username is specified with --clickhouse-user or in the credentials
+// file, Iris API is not used; this allows admins with ClickHouse
+// accounts to run queries when the API is degraded.
+func getUserPass() (string, error) {
+	creds, err := loadOverrides()
+	if err != nil {
+		return "", err
+	}
+	if creds.Username != "" {
+		verbose("using clickhouse user %s\n", creds.Username)
+		return creds.Username + ":" + creds.Password, nil
+	}
+	return users.GetUserPass()
+}
+
+// database returns the ClickHouse database to query.
+func database() string {
+	if creds, err := loadOverrides(); err == nil && creds.Database != "" {
+		return creds.Database
+	}
+	return DefaultDatabase
+}
@@ -0,0 +1,219 @@
+package clickhouse
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dioptra-io/irisctl/internal/common"
+	"github.com/dioptra-io/irisctl/internal/meas"
+	"github.com/spf13/cobra"
+)
+
+const (
+	exportManifestFile = "export-manifest.json"
+)
+
+// ExportManifest defines the record of all files exported by
+// clickhouse export.
+type ExportManifest struct {
+	ExportTime time.Time     `json:"export_time"`
+	Files      []ExportFile  `json:"files"`
+	Failures   []ExportError `json:"failures"`
+}
+
+// ExportFile defines one exported table.
+type ExportFile struct {
+	MeasUUID string `json:"meas_uuid"`
+	Table    string `json:"table"`
+	File     string `json:"file"`
+	Bytes    int64  `json:"bytes"`
+	Resumed  bool   `json:"resumed"`
+}
+
+// ExportError defines a measurement that could not be exported.
+type ExportError struct {
+	MeasUUID string `json:"meas_uuid"`
+	Error    string `json:"error"`
+}
+
+type exportJob struct {
+	n        int
+	measUUID string
+	tables   []string
+}
+
+func clickhouseExportArgs(cmd *cobra.Command, args []string) error {
+	if format, ok := common.IsUsage(args); ok {
+		fmt.Printf(format, "<meas-uuid>...", "one or more measurement UUIDs")
+		return nil
+	}
+	if len(args) < 1 {
+		cliFatal("clickhouse export requires at least one argument: <meas-uuid>...")
+	}
+	if err := common.ValidateFormat(args, common.MeasurementUUID); err != nil {
+		cliFatal(err)
+	}
+	if fExportJobs < 1 {
+		cliFatal("--jobs must be at least 1")
+	}
+	return nil
+}
+
+func clickhouseExport(cmd *cobra.Command, args []string) {
+	manifest, err := ExportMeasurements(args, fExportOutputDir, fExportJobs)
+	if err != nil {
+		fatal(err)
+	}
+	if len(manifest.Failures) > 0 {
+		cliFatal(fmt.Sprintf("failed to export %d of %d measurement(s)", len(manifest.Failures), len(args)))
+	}
+}
+
+// ExportMeasurements exports all tables of the specified measurements
+// to outputDir (a local directory or a gs:// or s3:// URL) with up to
+// nJobs measurements exported concurrently.  Tables that were already
+// exported are not downloaded again, so an interrupted export can be
+// resumed by running it again.
+func ExportMeasurements(measUUIDs []string, outputDir string, nJobs int) (ExportManifest, error) {
+	manifest := ExportManifest{ExportTime: time.Now().UTC()}
+	sink, err := common.NewSink(outputDir)
+	if err != nil {
+		return manifest, err
+	}
+	// Tables are downloaded to the output directory if it is local
+	// and to a temporary directory otherwise.
+	stagingDir := outputDir
+	if !common.IsLocalSink(outputDir) {
+		if stagingDir, err = common.MkdirTemp("irisctl-export-"); err != nil {
+			return manifest, err
+		}
+		defer os.RemoveAll(stagingDir)
+	}
+	stagingDir = strings.TrimPrefix(stagingDir, "file://")
+	// Get measurement details and credentials before starting the
+	// jobs because they are cached and not safe for concurrent use.
+	var jobs []exportJob
+	for i, measUUID := range measUUIDs {
+		measurement, err := meas.GetMeasurementAllDetails(measUUID)
+		if err != nil {
+			return manifest, err
+		}
+		// Only export the tables that exist because the tables
+		// of a measurement depend on its tool.
+		rows, err := TableRowCounts(measUUID)
+		if err != nil {
+			return manifest, err
+		}
+		var agentUUIDs []string
+		for _, agent := range measurement.Agents {
+			agentUUIDs = append(agentUUIDs, agent.AgentUUID)
+		}
+		job := exportJob{n: i + 1, measUUID: measUUID}
+		for table := range rows {
+			c, err := common.ClassifyTable(table)
+			if err == nil && c.Canonical(table) && common.Contains(agentUUIDs, c.AgentUUID) {
+				job.tables = append(job.tables, table)
+			}
+		}
+		sort.Strings(job.tables)
+		jobs = append(jobs, job)
+	}
+	userpass, err := getUserPass()
+	if err != nil {
+		return manifest, err
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	jobsChan := make(chan exportJob)
+	for i := 0; i < nJobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobsChan {
+				files, err := exportMeasurement(job, len(jobs), userpass, sink, stagingDir)
+				mu.Lock()
+				manifest.Files = append(manifest.Files, files...)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "[%d/%d] %s: %v\n", job.n, len(jobs), job.measUUID, err)
+					manifest.Failures = append(manifest.Failures, ExportError{MeasUUID: job.measUUID, Error: err.Error()})
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, job := range jobs {
+		jobsChan <- job
+	}
+	close(jobsChan)
+	wg.Wait()
+
+	jsonData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return manifest, err
+	}
+	manifestFile := filepath.Join(stagingDir, exportManifestFile+".part")
+	if err := os.WriteFile(manifestFile, jsonData, 0644); err != nil {
+		return manifest, err
+	}
+	common.SavingIn(sink.URL(exportManifestFile))
+	return manifest, sink.Put(manifestFile, exportManifestFile)
+}
+
+func exportMeasurement(job exportJob, nJobs int, userpass string, sink common.Sink, stagingDir string) ([]ExportFile, error) {
+	var files []ExportFile
+	measDir := filepath.Join(stagingDir, job.measUUID)
+	if err := os.MkdirAll(measDir, 0755); err != nil {
+		return files, err
+	}
+	for i, table := range job.tables {
+		name := filepath.Join(job.measUUID, table+".json")
+		exportFile := ExportFile{MeasUUID: job.measUUID, Table: table, File: sink.URL(name)}
+		if size, err := sink.Size(name); err == nil {
+			verbose("[%d/%d] %s: skipping %s because it was already exported\n", job.n, nJobs, job.measUUID, table)
+			exportFile.Bytes = size
+			exportFile.Resumed = true
+			files = append(files, exportFile)
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "[%d/%d] %s: exporting table %d/%d %s\n", job.n, nJobs, job.measUUID, i+1, len(job.tables), table)
+		// Download to a temporary file first so a partial download
+		// is not mistaken for a complete one when resuming.
+		partFile := filepath.Join(stagingDir, name+".part")
+		if output, err := runQuery(userpass, "SELECT * FROM "+table, partFile); err != nil {
+			os.Remove(partFile)
+			return files, fmt.Errorf("%s: %v: %s", table, err, output)
+		}
+		fi, err := os.Stat(partFile)
+		if err != nil {
+			return files, err
+		}
+		if err := sink.Put(partFile, name); err != nil {
+			return files, err
+		}
+		exportFile.Bytes = fi.Size()
+		files = append(files, exportFile)
+	}
+	return files, nil
+}
+
+// DownloadTable downloads all rows of the specified table to file in
+// the specified ClickHouse output format (e.g., CSVWithNames).  If the
+// download fails, file is removed.
+func DownloadTable(table, format, file string) error {
+	userpass, err := getUserPass()
+	if err != nil {
+		return err
+	}
+	if output, err := runQuery(userpass, fmt.Sprintf("SELECT * FROM %s FORMAT %s", table, format), file); err != nil {
+		os.Remove(file)
+		return fmt.Errorf("%s: %v: %s", table, err, output)
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package clickhouse
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dioptra-io/irisctl/internal/common"
+)
+
+const (
+	// Maximum time of a health check of a proxy.
+	healthCheckTimeout = 10 * time.Second
+)
+
+var (
+	// Index of the proxy in common.ClickHouseProxyURLs() that queries
+	// are sent to.  It changes when the proxy fails.
+	activeProxy   int
+	activeProxyMu sync.Mutex
+
+	// HTTP status codes returned by proxies that are down (e.g., for
+	// maintenance).
+	proxyDownStatus = []string{"502", "503", "504"}
+
+	// Errors.
+	ErrNoHealthyProxy = errors.New("no healthy clickhouse proxy")
+	ErrQueryFailed    = errors.New("clickhouse query failed")
+)
+
+// proxyFailed returns true if the query failed because of the proxy
+// (i.e., curl could not connect or the proxy returned a gateway
+// error) rather than because of the query.
+func proxyFailed(output string, err error) bool {
+	return err != nil || common.Contains(proxyDownStatus, strings.TrimSpace(output))
+}
+
+// healthy returns true if the proxy answers a trivial query.
+func healthy(proxy, userpass string) bool {
+	tmpFile, err := common.CreateTemp("irisctl-clickhouse-health-")
+	if err != nil {
+		return false
+	}
+	tmpFile.Close()
+	defer os.Remove(tmpFile.Name())
+	u := fmt.Sprintf("%v/?database=%v&query=%v", proxy, url.QueryEscape(database()), url.QueryEscape("SELECT 1"))
+	output, err := common.Curl(userpass, true, "POST", u, "--http1.1", "--output", tmpFile.Name(),
+		"--max-time", fmt.Sprint(int(healthCheckTimeout.Seconds())), "-w", "%{http_code}")
+	if err != nil || strings.TrimSpace(string(output)) != "200" {
+		verbose("clickhouse proxy %s is not healthy: %s %v\n", proxy, output, err)
+		return false
+	}
+	return true
+}
+
+// failover makes the next healthy proxy after failed the active proxy
+// and returns it.  If another query already failed over, the current
+// active proxy is returned.
+func failover(proxies []string, failed int, userpass string) (int, error) {
+	activeProxyMu.Lock()
+	defer activeProxyMu.Unlock()
+	if activeProxy != failed {
+		return activeProxy, nil
+	}
+	for i := 1; i < len(proxies); i++ {
+		next := (failed + i) % len(proxies)
+		if healthy(proxies[next], userpass) {
+			fmt.Fprintf(os.Stderr, "clickhouse proxy %s failed, switching to %s\n", proxies[failed], proxies[next])
+			activeProxy = next
+			return next, nil
+		}
+	}
+	return failed, fmt.Errorf("%w: tried %s", ErrNoHealthyProxy, strings.Join(proxies, " "))
+}
+
+// queryStatus returns an error if the HTTP status code that curl wrote
+// to output is not 2xx.  ClickHouse writes its error message to
+// outputFile, so it is returned as the output.
+func queryStatus(output, outputFile string) (string, error) {
+	status := strings.TrimSpace(output)
+	if strings.HasPrefix(status, "2") && len(status) == 3 {
+		return "", nil
+	}
+	body, _ := os.ReadFile(outputFile)
+	return strings.TrimSpace(string(body)), fmt.Errorf("%w: HTTP status %s", ErrQueryFailed, status)
+}
+
+// runQueryFailover runs the query (see runQuery) on the active proxy
+// and fails over to the other proxies if it fails.
+func runQueryFailover(userpass, query, outputFile string) (string, error) {
+	proxies := common.ClickHouseProxyURLs()
+	if len(proxies) == 1 {
+		output, err := runQueryOn(proxies[0], userpass, query, outputFile, "-w", "%{http_code}")
+		if err != nil || common.RootFlagBool("curl") {
+			return output, err
+		}
+		return queryStatus(output, outputFile)
+	}
+	activeProxyMu.Lock()
+	proxy := activeProxy % len(proxies)
+	activeProxyMu.Unlock()
+	for tried := 1; ; tried++ {
+		// Get the status code to detect proxies that are down.
+		output, err := runQueryOn(proxies[proxy], userpass, query, outputFile, "-w", "%{http_code}")
+		if common.RootFlagBool("curl") {
+			return output, err
+		}
+		if !proxyFailed(output, err) {
+			return queryStatus(output, outputFile)
+		}
+		if tried == len(proxies) {
+			return output, fmt.Errorf("%w: tried %s", ErrNoHealthyProxy, strings.Join(proxies, " "))
+		}
+		if proxy, err = failover(proxies, proxy, userpass); err != nil {
+			return output, err
+		}
+	}
+}
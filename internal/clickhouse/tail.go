@@ -0,0 +1,157 @@
+package clickhouse
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dioptra-io/irisctl/internal/common"
+	"github.com/spf13/cobra"
+)
+
+var (
+	tailTableKinds = []string{"results", "prefixes", "links", "probes"}
+)
+
+// tailTable defines the row count of one measurement table.
+type tailTable struct {
+	Name string `json:"name"`
+	Rows int    `json:"total_rows"`
+}
+
+func clickhouseTailArgs(cmd *cobra.Command, args []string) error {
+	if _, ok := common.IsUsage(args); ok {
+		return nil
+	}
+	if len(args) != 0 {
+		cliFatal("clickhouse tail does not take any arguments")
+	}
+	if fTailMeasUUID == "" {
+		cliFatal("clickhouse tail requires --meas-uuid")
+	}
+	if err := common.ValidateFormat([]string{fTailMeasUUID}, common.MeasurementUUID); err != nil {
+		cliFatal(err)
+	}
+	if fTailInterval < time.Second {
+		cliFatal("--interval must be at least 1s")
+	}
+	if fTailCount < 0 {
+		cliFatal("--count cannot be negative")
+	}
+	return nil
+}
+
+func clickhouseTail(cmd *cobra.Command, args []string) {
+	if err := tailMeasurement(fTailMeasUUID, fTailInterval, fTailCount); err != nil {
+		fatal(err)
+	}
+}
+
+// tailMeasurement periodically prints the number of rows in each kind
+// of table of the specified measurement (summed over its agents) and
+// the rate at which rows are inserted.  If count is zero, it runs
+// until interrupted.
+func tailMeasurement(measUUID string, interval time.Duration, count int) error {
+	userpass, err := getUserPass()
+	if err != nil {
+		return err
+	}
+	tmpFile, err := common.CreateTemp("irisctl-clickhouse-tail-")
+	if err != nil {
+		return err
+	}
+	tmpFile.Close()
+	defer func(f string) { verbose("removing %s\n", f); os.Remove(f) }(tmpFile.Name())
+
+	query := tablesQuery(measUUID)
+	fmt.Printf("%-8s %14s %14s %14s %14s %12s\n", "time", "results", "prefixes", "links", "probes", "rows/sec")
+	var prevTotal int
+	var prevTime time.Time
+	for n := 1; ; n++ {
+		now := time.Now()
+		rows, err := tailRows(userpass, query, tmpFile.Name())
+		if err != nil {
+			return err
+		}
+		total := 0
+		for _, kind := range tailTableKinds {
+			total += rows[kind]
+		}
+		rate := "-"
+		if !prevTime.IsZero() {
+			rate = fmt.Sprintf("%.1f", float64(total-prevTotal)/now.Sub(prevTime).Seconds())
+		}
+		fmt.Printf("%-8s %14d %14d %14d %14d %12s\n", now.Format("15:04:05"), rows["results"], rows["prefixes"], rows["links"], rows["probes"], rate)
+		if n == count {
+			return nil
+		}
+		prevTotal, prevTime = total, now
+		time.Sleep(interval)
+	}
+}
+
+// tailRows runs the query and returns the total number of rows of
+// each kind of table.
+func tailRows(userpass, query, outputFile string) (map[string]int, error) {
+	if output, err := runQuery(userpass, query, outputFile); err != nil {
+		return nil, fmt.Errorf("%v: %s", err, output)
+	}
+	rows := map[string]int{}
+	contents, err := os.ReadFile(outputFile)
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range strings.Split(string(contents), "\n") {
+		if line == "" {
+			continue
+		}
+		var t tailTable
+		if err := json.Unmarshal([]byte(line), &t); err != nil {
+			return nil, err
+		}
+		c, err := common.ClassifyTable(t.Name)
+		if err != nil || !c.Canonical(t.Name) {
+			verbose("skipping %v\n", t.Name)
+			continue
+		}
+		rows[c.Type] += t.Rows
+	}
+	return rows, nil
+}
+
+// tablesQuery returns the query for the row counts of the tables of
+// the specified measurement.
+func tablesQuery(measUUID string) string {
+	return fmt.Sprintf("SELECT name, total_rows FROM system.tables WHERE database = '%s' AND name LIKE '%%%s%%'",
+		database(), strings.ReplaceAll(measUUID, "-", "_"))
+}
+
+// TableRowCounts returns the number of rows of each table of the
+// specified measurement.
+func TableRowCounts(measUUID string) (map[string]int, error) {
+	outputFile, output, err := RunQueryString(tablesQuery(measUUID))
+	if outputFile != "" {
+		defer func(f string) { verbose("removing %s\n", f); os.Remove(f) }(outputFile)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%v: %s", err, output)
+	}
+	contents, err := os.ReadFile(outputFile)
+	if err != nil {
+		return nil, err
+	}
+	rows := map[string]int{}
+	for _, line := range strings.Split(string(contents), "\n") {
+		if line == "" {
+			continue
+		}
+		var t tailTable
+		if err := json.Unmarshal([]byte(line), &t); err != nil {
+			return nil, err
+		}
+		rows[t.Name] = t.Rows
+	}
+	return rows, nil
+}
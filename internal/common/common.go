@@ -2,19 +2,30 @@
 package common
 
 import (
+	"bufio"
 	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log"
+	"net/http"
+	"net/netip"
 	"os"
 	"os/exec"
 	"regexp"
+	"runtime/debug"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/klauspost/compress/zstd"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
@@ -34,6 +45,9 @@ const (
 
 	GCPProject = "mlab-edgenet"
 
+	// Tag that makes a measurement public.
+	PublicTag = "visibility:public"
+
 	UserFile = `
 {
   "email": "user@example.com",
@@ -49,6 +63,14 @@ const (
   "allow_tag_public": true
 }`
 
+	ProjectsFile = `
+{
+  "project-name": [
+    "<user-id>",
+    "<user-id>"
+  ]
+}`
+
 	TargetListFile = `
 Each line of the target-list file must have the following format:
 target,protocol,min_ttl,max_ttl,n_initial_flows
@@ -75,11 +97,34 @@ and the prococol is icmp, icmp6 or udp.`
   ]
 }`
 
+	MeasurementPatchFile = `
+{
+  "tags": [
+    "test",
+    "exhaustive"
+  ]
+}`
+
+	// Verbosity levels of the repeatable --verbose flag.
+	VerboseInfo  = 1
+	VerboseDebug = 2
+	VerboseTrace = 3
+
 	// Width of usage column.
 	UsageWidth     = 40
 	UsageSignature = "usagesignature"
+
+	// How long and how many responses of GET requests are cached.
+	curlCacheTTL        = 30 * time.Second
+	maxCurlCacheEntries = 256
 )
 
+// curlCacheEntry defines a cached response and when it was received.
+type curlCacheEntry struct {
+	output []byte
+	time   time.Time
+}
+
 type Users struct {
 	Count    int     `json:"count"`
 	Next     *string `json:"next"`
@@ -88,8 +133,8 @@ type Users struct {
 }
 
 type User struct {
-	UUID              string     `json:"id"`
-	Email             string     `json:"email"`
+	UUID              string     `json:"id" schema:"required"`
+	Email             string     `json:"email" schema:"required"`
 	IsActive          bool       `json:"is_active"`
 	IsSuperuser       bool       `json:"is_superuser"`
 	IsVerified        bool       `json:"is_verified"`
@@ -114,7 +159,7 @@ type Agent struct {
 	BatchSize         interface{}     `json:"batch_size"`
 	ProbingRate       interface{}     `json:"probing_rate"`
 	TargetFile        string          `json:"target_file"`
-	AgentUUID         string          `json:"agent_uuid"`
+	AgentUUID         string          `json:"agent_uuid" schema:"required"`
 	ProbingStatistics map[string]struct {
 		Round struct {
 			Limit  int `json:"limit"`
@@ -171,12 +216,12 @@ type AgentOld struct {
 type Measurement struct {
 	Tool         string     `json:"tool"`
 	Tags         []string   `json:"tags"`
-	UUID         string     `json:"uuid"`
+	UUID         string     `json:"uuid" schema:"required"`
 	UserID       string     `json:"user_id"`
-	CreationTime CustomTime `json:"creation_time"`
+	CreationTime CustomTime `json:"creation_time" schema:"required"`
 	StartTime    CustomTime `json:"start_time"`
 	EndTime      CustomTime `json:"end_time"`
-	State        string     `json:"state"`
+	State        string     `json:"state" schema:"required"`
 	Agents       []Agent    `json:"agents"`
 }
 
@@ -209,14 +254,14 @@ type AgentsData struct {
 }
 
 type AgentsResult struct {
-	UUID       string          `json:"uuid"`
-	State      string          `json:"state"`
-	Parameters AgentParameters `json:"parameters"`
+	UUID       string          `json:"uuid" schema:"required"`
+	State      string          `json:"state" schema:"required"`
+	Parameters AgentParameters `json:"parameters" schema:"required"`
 }
 
 type AgentParameters struct {
 	Version             string   `json:"version"`
-	Hostname            string   `json:"hostname"`
+	Hostname            string   `json:"hostname" schema:"required"`
 	InternalIPv4Address string   `json:"internal_ipv4_address"`
 	InternalIPv6Address string   `json:"internal_ipv6_address"`
 	ExternalIPv4Address string   `json:"external_ipv4_address"`
@@ -279,8 +324,42 @@ var (
 		"iris-us-west4",
 	}
 
+	// Version is the version of irisctl.  It is set at build time
+	// with -ldflags "-X .../internal/common.Version=<version>".
+	Version = ""
+
 	calledFromHelp bool
 
+	// Successful responses of GET requests keyed by access token and
+	// URL.
+	curlCache   = make(map[string]curlCacheEntry)
+	curlCacheMu sync.Mutex
+
+	// Layouts accepted for CustomTime values.  Fractional seconds
+	// are optional in all layouts.
+	customTimeLayouts = []string{
+		"2006-01-02T15:04:05.999999999",       // Iris API
+		"2006-01-02T15:04:05.999999999Z07:00", // RFC 3339
+		"2006-01-02T15:04:05.999999999Z0700",
+		"2006-01-02 15:04:05.999999999",
+		"2006-01-02",
+	}
+
+	// Units of relative times (e.g., 7d is 7 days ago).
+	relativeTimeUnits = map[byte]time.Duration{
+		'm': time.Minute,
+		'h': time.Hour,
+		'd': 24 * time.Hour,
+		'w': 7 * 24 * time.Hour,
+	}
+
+	// States of measurements.
+	MeasStates = []string{"agent_failure", "canceled", "finished", "ongoing"}
+
+	// Magic numbers of compressed files.
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
 	// Errors.
 	ErrNoSubCmd       = errors.New("missing subcommand")
 	ErrHomeEnv        = errors.New("HOME environment variable is not set")
@@ -289,17 +368,28 @@ var (
 	ErrInvalidLine    = errors.New("invalid line")
 	ErrInvalidState   = errors.New("invalid state")
 	ErrInvalidUUID    = errors.New("invalid UUID")
+	ErrInvalidTime    = errors.New("invalid time")
+	ErrChecksum       = errors.New("checksum mismatch")
+	ErrUnknownProject = errors.New("unknown project")
+	ErrUnknownProfile = errors.New("unknown profile")
+	ErrJqUnsupported  = errors.New("unsupported jq option")
+	ErrNoMatch        = errors.New("filter matches nothing")
 
 	// Test code changes Fatal to Panic so a fatal error won't exit
 	// the process and can be recovered.
 	fatal = log.Fatal
 )
 
-// Set implements the pflag.Value interface Set method.
+// Set implements the pflag.Value interface Set method.  In addition to
+// the layouts of parseCustomTime, flags accept times relative to now
+// (see parseRelativeTime).
 func (c *CustomTime) Set(value string) error {
-	parsedTime, err := time.Parse("2006-01-02T15:04:05.999999", value)
+	parsedTime, err := parseCustomTime(value)
 	if err != nil {
-		return err
+		var ok bool
+		if parsedTime, ok = parseRelativeTime(value, time.Now()); !ok {
+			return fmt.Errorf("%w (or a duration like 7d or 24h, today, or yesterday)", err)
+		}
 	}
 	c.Time = parsedTime
 	return nil
@@ -317,7 +407,11 @@ func (c *CustomTime) UnmarshalJSON(b []byte) error {
 		c.Time = time.Time{}
 		return nil
 	}
-	date, err := time.Parse(`"2006-01-02T15:04:05.999999"`, string(b))
+	unquoted, err := strconv.Unquote(s)
+	if err != nil {
+		return fmt.Errorf("invalid time %s: %w", s, err)
+	}
+	date, err := parseCustomTime(unquoted)
 	if err != nil {
 		return err
 	}
@@ -325,6 +419,43 @@ func (c *CustomTime) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
+// parseCustomTime parses the specified time with the first matching
+// layout in customTimeLayouts.
+func parseCustomTime(value string) (time.Time, error) {
+	for _, layout := range customTimeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%q: %w", value, ErrInvalidTime)
+}
+
+// parseRelativeTime parses a time relative to now: a number followed
+// by a unit of relativeTimeUnits (e.g., 24h is 24 hours ago), now,
+// today (midnight), or yesterday (midnight of the previous day).
+// Times of Iris are in UTC, so relative times are also in UTC.
+func parseRelativeTime(value string, now time.Time) (time.Time, bool) {
+	now = now.UTC()
+	today := now.Truncate(24 * time.Hour)
+	switch value {
+	case "now":
+		return now, true
+	case "today":
+		return today, true
+	case "yesterday":
+		return today.AddDate(0, 0, -1), true
+	}
+	if len(value) < 2 {
+		return time.Time{}, false
+	}
+	unit, ok := relativeTimeUnits[value[len(value)-1]]
+	n, err := strconv.Atoi(value[:len(value)-1])
+	if !ok || err != nil || n < 0 {
+		return time.Time{}, false
+	}
+	return now.Add(-time.Duration(n) * unit), true
+}
+
 // Less returns true if the measurement time of the measurement
 // argument is earlier.
 func (m Measurement) Less(t CustomTime) bool {
@@ -349,12 +480,41 @@ func CliFatal(args ...interface{}) {
 	log.Fatal(args...)
 }
 
+// VerboseLevel returns the number of times --verbose was specified.
+func VerboseLevel() int {
+	return viper.GetInt("verbose")
+}
+
 func Verbose(s string, args ...interface{}) {
-	if RootFlagBool("verbose") {
+	if VerboseLevel() >= VerboseInfo {
+		fmt.Printf(s, args...)
+	}
+}
+
+func Debug(s string, args ...interface{}) {
+	if VerboseLevel() >= VerboseDebug {
+		fmt.Printf(s, args...)
+	}
+}
+
+func Trace(s string, args ...interface{}) {
+	if VerboseLevel() >= VerboseTrace {
 		fmt.Printf(s, args...)
 	}
 }
 
+// Confirm prints the specified prompt and returns true if the user
+// answers yes on standard input.
+func Confirm(prompt string) bool {
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", prompt)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
 func Usage(cmd *cobra.Command) error {
 	if !calledFromHelp {
 		os.Exit(1)
@@ -375,6 +535,12 @@ func Usage(cmd *cobra.Command) error {
 			printFlagsArgs(cmd, sc)
 		}
 	}
+	if cmd.Example != "" {
+		fmt.Printf("\nexamples:\n")
+		for _, example := range strings.Split(cmd.Example, "\n") {
+			fmt.Printf("    $ %s\n", example)
+		}
+	}
 	return nil
 }
 
@@ -428,7 +594,36 @@ func Contains(ss []string, s string) bool {
 	return false
 }
 
+// Curl executes curl with the specified arguments and returns its
+// output.  Successful responses of plain GET requests are reused for
+// curlCacheTTL unless --no-cache is set.  Callers that poll for
+// changes (e.g., of the state of agents) use CurlNoCache instead.
 func Curl(accessToken string, basicToken bool, method, url string, args ...string) ([]byte, error) {
+	output, _, err := curl(true, accessToken, basicToken, method, url, args...)
+	return output, err
+}
+
+// CurlNoCache is like Curl but always sends the request.
+func CurlNoCache(accessToken string, basicToken bool, method, url string, args ...string) ([]byte, error) {
+	output, _, err := curl(false, accessToken, basicToken, method, url, args...)
+	return output, err
+}
+
+// CurlStatus is like CurlNoCache but also returns the status code of
+// the response (0 if it is unknown, e.g., with --curl).
+func CurlStatus(accessToken string, basicToken bool, method, url string, args ...string) ([]byte, int, error) {
+	return curl(false, accessToken, basicToken, method, url, args...)
+}
+
+func curl(useCache bool, accessToken string, basicToken bool, method, url string, args ...string) ([]byte, int, error) {
+	cacheable := useCache && method == "GET" && len(args) == 0 && !RootFlagBool("no-cache") && !RootFlagBool("curl")
+	cacheKey := accessToken + " " + url
+	if cacheable {
+		if output, ok := cachedResponse(cacheKey); ok {
+			Debug("%s %s: using cached response\n", method, url)
+			return output, http.StatusOK, nil
+		}
+	}
 	var curlArgs []string
 	curlArgs = append(curlArgs, "-s", "-X", method, "-H", "User-Agent: irisctl", "-H", "Accept: application/json")
 	if accessToken != "" {
@@ -441,18 +636,142 @@ func Curl(accessToken string, basicToken bool, method, url string, args ...strin
 	}
 	curlArgs = append(curlArgs, args...)
 	curlArgs = append(curlArgs, url)
-	if RootFlagBool("curl") || RootFlagBool("verbose") {
+	if RootFlagBool("curl") || VerboseLevel() >= VerboseInfo {
 		fmt.Printf("curl ")
 		for _, a := range curlArgs {
 			fmt.Printf("%q ", a)
 		}
 		fmt.Println()
 		if RootFlagBool("curl") {
-			return nil, nil
+			return nil, 0, nil
 		}
 	}
-	cmd := exec.Command("curl", curlArgs...)
-	return cmd.CombinedOutput()
+	output, status, native, err := httpRateLimited(method, url, curlArgs)
+	if !native {
+		output, status, err = curlRateLimited(method, url, curlArgs)
+	}
+	// Errors (e.g., 404 or 429) are not cached so that the request is
+	// retried the next time.
+	if cacheable && err == nil && status >= 200 && status < 300 {
+		cacheResponse(cacheKey, output)
+	}
+	return output, status, err
+}
+
+// cachedResponse returns the cached response of key if it has not
+// expired.
+func cachedResponse(key string) ([]byte, bool) {
+	curlCacheMu.Lock()
+	defer curlCacheMu.Unlock()
+	entry, ok := curlCache[key]
+	if !ok || time.Since(entry.time) > curlCacheTTL {
+		return nil, false
+	}
+	return entry.output, true
+}
+
+// cacheResponse caches the response of key, first removing the expired
+// responses (or all responses if none expired) if the cache is full.
+func cacheResponse(key string, output []byte) {
+	curlCacheMu.Lock()
+	defer curlCacheMu.Unlock()
+	if len(curlCache) >= maxCurlCacheEntries {
+		for k, entry := range curlCache {
+			if time.Since(entry.time) > curlCacheTTL {
+				delete(curlCache, k)
+			}
+		}
+		if len(curlCache) >= maxCurlCacheEntries {
+			curlCache = make(map[string]curlCacheEntry)
+		}
+	}
+	curlCache[key] = curlCacheEntry{output: output, time: time.Now()}
+}
+
+// IrisctlVersion returns the version of irisctl set at build time,
+// the module version if it was installed with go install, or "dev".
+func IrisctlVersion() string {
+	if Version != "" {
+		return Version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	return "dev"
+}
+
+// IrisDir returns the irisctl configuration directory ($HOME/.iris),
+// creating it if it does not exist.
+func IrisDir() (string, error) {
+	home := os.Getenv("HOME")
+	if home == "" {
+		return "", ErrHomeEnv
+	}
+	irisHome := fmt.Sprintf("%s/.iris", home)
+	if err := os.MkdirAll(irisHome, 0700); err != nil {
+		return "", err
+	}
+	return irisHome, nil
+}
+
+// AccessTokenFile returns the path of the access token file.  Access
+// tokens of different profiles are for different Iris deployments, so
+// each profile has its own access token file.
+func AccessTokenFile() (string, error) {
+	irisHome, err := IrisDir()
+	if err != nil {
+		return "", err
+	}
+	accessTokenFile := fmt.Sprintf("%s/jwt", irisHome)
+	if profile := RootFlagString("profile"); profile != "" {
+		accessTokenFile += "-" + profile
+	}
+	return accessTokenFile, nil
+}
+
+// ReadProjects returns the projects defined in $HOME/.iris/projects.json
+// as a map of project names to the IDs of the users in each project.
+// Iris does not have a notion of projects, so they are only local.
+func ReadProjects() (map[string][]string, error) {
+	irisHome, err := IrisDir()
+	if err != nil {
+		return nil, err
+	}
+	projectsFile := fmt.Sprintf("%s/projects.json", irisHome)
+	if _, err := CheckFile("projects", projectsFile); err != nil {
+		return nil, err
+	}
+	contents, err := os.ReadFile(projectsFile)
+	if err != nil {
+		return nil, err
+	}
+	projects := make(map[string][]string)
+	if err := json.Unmarshal(contents, &projects); err != nil {
+		return nil, fmt.Errorf("%v: %v", projectsFile, err)
+	}
+	for project, userIDs := range projects {
+		if err := ValidateFormat(userIDs, UserID); err != nil {
+			return nil, fmt.Errorf("%v: project %v: %v", projectsFile, project, err)
+		}
+	}
+	return projects, nil
+}
+
+// ProjectUserIDs returns the IDs of the users in the specified projects.
+func ProjectUserIDs(projectNames []string) ([]string, error) {
+	projects, err := ReadProjects()
+	if err != nil {
+		return nil, err
+	}
+	var userIDs []string
+	for _, name := range projectNames {
+		ids, ok := projects[name]
+		if !ok {
+			return nil, fmt.Errorf("%v: %w", name, ErrUnknownProject)
+		}
+		userIDs = append(userIDs, ids...)
+	}
+	return userIDs, nil
 }
 
 func CheckFile(desc, path string) (os.FileInfo, error) {
@@ -468,7 +787,7 @@ func CheckFile(desc, path string) (os.FileInfo, error) {
 }
 
 func WriteResults(file string, data []byte) (string, error) {
-	tmpFile, err := os.CreateTemp("/tmp", file+"-")
+	tmpFile, err := CreateTemp(file + "-")
 	if err != nil {
 		return "", err
 	}
@@ -483,7 +802,7 @@ func WriteResults(file string, data []byte) (string, error) {
 }
 
 func WriteResultsAppend(file string, data []byte) (string, error) {
-	tmpFile, err := os.CreateTemp("/tmp", file+"-")
+	tmpFile, err := CreateTemp(file + "-")
 	if err != nil {
 		return "", err
 	}
@@ -502,12 +821,12 @@ func SaveOrPrint(jsonData []byte, prefix string) error {
 		}
 		fmt.Println(string(jqOutput))
 	} else {
-		f, err := os.CreateTemp("/tmp", prefix)
+		f, err := CreateTemp(prefix)
 		if err != nil {
 			return err
 		}
 		defer f.Close()
-		fmt.Fprintf(os.Stderr, "saving in %s\n", f.Name())
+		SavingIn(f.Name())
 		if _, err := f.Write(jsonData); err != nil {
 			return err
 		}
@@ -515,36 +834,150 @@ func SaveOrPrint(jsonData []byte, prefix string) error {
 	return nil
 }
 
-func JqFile(file string, filter []string) ([]byte, error) {
-	args := append(filter, file)
-	cmd := exec.Command("jq", args...)
-	return runCmd(cmd)
+// TargetListContent returns the content of a target-list file from its
+// lines returned by Iris API, which include an empty last line if the
+// file ends with a newline.  Its checksum is therefore the checksum of
+// the file (e.g., computed by sha256sum).
+func TargetListContent(lines []string) []byte {
+	content := strings.Join(lines, "\n")
+	if content != "" && !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	return []byte(content)
 }
 
-func JqBytes(jsonData []byte, filter []string) ([]byte, error) {
-	cmd := exec.Command("jq", filter...)
-	cmd.Stdin = bytes.NewBuffer(jsonData)
-	return runCmd(cmd)
+// DecodeTargetListContent returns the content of the target-list in
+// jsonData, a response of Iris API with the lines of the target-list.
+func DecodeTargetListContent(jsonData []byte) ([]byte, error) {
+	var target struct {
+		Content []string `json:"content"`
+	}
+	if err := json.Unmarshal(jsonData, &target); err != nil {
+		return nil, err
+	}
+	return TargetListContent(target.Content), nil
 }
 
+// VerifyChecksum computes the SHA-256 checksum of data downloaded as
+// the specified artifact and records it in checksumFile in sha256sum
+// format.  If checksumFile already has a checksum for the artifact,
+// it must match the computed checksum.
+func VerifyChecksum(checksumFile, artifact string, data []byte) error {
+	sum := sha256.Sum256(data)
+	checksum := hex.EncodeToString(sum[:])
+	contents, err := os.ReadFile(checksumFile)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	for _, line := range strings.Split(string(contents), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 || fields[1] != artifact {
+			continue
+		}
+		if fields[0] != checksum {
+			return fmt.Errorf("%v: %w: expected %v, got %v", artifact, ErrChecksum, fields[0], checksum)
+		}
+		Verbose("%v: checksum %v verified\n", artifact, checksum)
+		return nil
+	}
+	f, err := os.OpenFile(checksumFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	Verbose("%v: recording checksum %v in %v\n", artifact, checksum, checksumFile)
+	_, err = fmt.Fprintf(f, "%s  %s\n", checksum, artifact)
+	return err
+}
+
+// GcloudSSH runs remoteCmd on the agent with the specified hostname
+// with gcloud compute ssh (see SSH).
 func GcloudSSH(hostname, remoteCmd string) ([]string, error) {
+	defer AddTiming(TimingSSH, time.Now())
 	zone := strings.TrimPrefix(hostname, "iris-") + "-a"
 	cmd := exec.Command("gcloud", "compute", "ssh", "--zone", zone, hostname, "--project", GCPProject, "--command", remoteCmd, "--", "-t", "-t")
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return nil, fmt.Errorf("%v\n%v\n", string(output), err)
 	}
-	var results []string
-	results = append(results, fmt.Sprintf("%s\n", hostname))
-	lines := strings.Split(string(output), "\n")
-	for _, line := range lines {
-		if line != "" {
-			results = append(results, fmt.Sprintf("%s\n", line))
+	return sshResults(hostname, output), nil
+}
+
+// GcloudInstances returns the names of the Iris VM instances in the
+// GCP project.
+func GcloudInstances() ([]string, error) {
+	cmd := exec.Command("gcloud", "compute", "instances", "list", "--project", GCPProject, "--filter", "name~^iris-", "--format", "value(name)")
+	output, err := runCmd(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("%v\n%v\n", string(output), err)
+	}
+	var instances []string
+	for _, line := range strings.Split(string(output), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			instances = append(instances, line)
+		}
+	}
+	return instances, nil
+}
+
+// GcloudInstanceStarts returns the time each Iris VM instance in the
+// GCP project was last started, keyed by instance name.
+func GcloudInstanceStarts() (map[string]time.Time, error) {
+	cmd := exec.Command("gcloud", "compute", "instances", "list", "--project", GCPProject, "--filter", "name~^iris-", "--format", "value(name,lastStartTimestamp)")
+	output, err := runCmd(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("%v\n%v\n", string(output), err)
+	}
+	starts := map[string]time.Time{}
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, fields[1])
+		if err != nil {
+			Verbose("%s: invalid lastStartTimestamp: %v\n", fields[0], err)
+			continue
 		}
+		starts[fields[0]] = t
 	}
-	return results, nil
+	return starts, nil
 }
 
+// InstanceEvent defines an operation on an Iris VM instance as returned
+// by gcloud compute operations list.
+type InstanceEvent struct {
+	OperationType string    `json:"operationType"`
+	TargetLink    string    `json:"targetLink"`
+	InsertTime    time.Time `json:"insertTime"`
+	StatusMessage string    `json:"statusMessage"`
+}
+
+// Instance returns the name of the VM instance of the event.
+func (e InstanceEvent) Instance() string {
+	return e.TargetLink[strings.LastIndex(e.TargetLink, "/")+1:]
+}
+
+// GcloudInstanceEvents returns the operations on the Iris VM instances
+// in the GCP project since the specified time, oldest first.
+func GcloudInstanceEvents(since time.Time) ([]InstanceEvent, error) {
+	filter := fmt.Sprintf("targetLink~/instances/iris- AND insertTime>=%s", since.UTC().Format(time.RFC3339))
+	cmd := exec.Command("gcloud", "compute", "operations", "list", "--project", GCPProject, "--filter", filter, "--format", "json")
+	output, err := runCmd(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("%v\n%v\n", string(output), err)
+	}
+	var events []InstanceEvent
+	if err := json.Unmarshal(output, &events); err != nil {
+		return nil, fmt.Errorf("gcloud compute operations list: %w", err)
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].InsertTime.Before(events[j].InsertTime) })
+	return events, nil
+}
+
+// GetMeasurementsSorted returns the measurements in the specified
+// metadata file sorted by creation time.  Unless --strict is set,
+// malformed measurement records are skipped with a warning.
 func GetMeasurementsSorted(measMdFile string) ([]Measurement, error) {
 	Verbose("parsing measurements metadata file %s\n", measMdFile)
 	file, err := os.Open(measMdFile)
@@ -554,32 +987,46 @@ func GetMeasurementsSorted(measMdFile string) ([]Measurement, error) {
 	defer file.Close()
 	decoder := json.NewDecoder(file)
 	var allMeasurements []Measurement
+	nRecords, nSkipped := 0, 0
 	for {
-		var batch MeasurementBatch
+		var batch struct {
+			Results []json.RawMessage `json:"results"`
+		}
 		if err := decoder.Decode(&batch); err != nil {
 			if err.Error() == "EOF" {
 				break
 			}
 			return nil, err
 		}
-		allMeasurements = append(allMeasurements, batch.Measurements...)
+		for _, record := range batch.Results {
+			nRecords++
+			var measurement Measurement
+			if err := DecodeJSON(record, &measurement); err != nil {
+				if RootFlagBool("strict") {
+					return nil, err
+				}
+				nSkipped++
+				fmt.Fprintf(os.Stderr, "%s skipping malformed measurement record %s: %v\n", Warning(WarnMalformedRecord), recordUUID(record), err)
+				continue
+			}
+			allMeasurements = append(allMeasurements, measurement)
+		}
+	}
+	if nSkipped > 0 {
+		fmt.Fprintf(os.Stderr, "%s skipped %d of %d measurement records in %s\n", Warning(WarnMalformedRecord), nSkipped, nRecords, measMdFile)
 	}
 	sort.Slice(allMeasurements, func(i, j int) bool {
 		t := allMeasurements[j].CreationTime
 		return allMeasurements[i].Less(t)
 	})
+	AddSummaryItems(len(allMeasurements))
 	return allMeasurements, nil
 }
 
 func ValidateState(states []string) (string, error) {
 	for _, state := range states {
-		switch state {
-		case "finished":
-		case "ongoing":
-		case "canceled":
-		case "agent_failure":
-		default:
-			return state, ErrInvalidState
+		if !Contains(MeasStates, state) {
+			return state, fmt.Errorf("%w%s", ErrInvalidState, DidYouMean(state, MeasStates))
 		}
 	}
 	return "", nil
@@ -614,6 +1061,19 @@ func MatchTag(mTags, tags []string, tagsAnd bool) bool {
 	return found
 }
 
+// ParseTarget parses the target of a target-list line, which is
+// either a prefix or an address (i.e., a prefix of the full length).
+func ParseTarget(target string) (netip.Prefix, error) {
+	if strings.Contains(target, "/") {
+		return netip.ParsePrefix(target)
+	}
+	addr, err := netip.ParseAddr(target)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
 func HumanReadable(n int) string {
 	switch {
 	case n >= 1000000000:
@@ -629,7 +1089,7 @@ func HumanReadable(n int) string {
 
 func ParseGCPHostnames(jsonData []byte) ([]string, error) {
 	var data AgentsData
-	if err := json.Unmarshal(jsonData, &data); err != nil {
+	if err := DecodeJSON(jsonData, &data); err != nil {
 		return nil, err
 	}
 	gcpHostnames := []string{}
@@ -642,47 +1102,70 @@ func ParseGCPHostnames(jsonData []byte) ([]string, error) {
 	return gcpHostnames, nil
 }
 
-func ReadCompressedFile(filename string) (string, error) {
-	fileInfo, err := os.Stat(filename)
-	if err != nil {
-		return "", err
+// recordUUID returns the UUID of a measurement record that could not
+// be fully parsed or "?" if even the UUID cannot be found.
+func recordUUID(record []byte) string {
+	var r struct {
+		UUID string `json:"uuid"`
 	}
-	if fileInfo.Size() == 0 {
-		return "", fmt.Errorf("%v: %w", filename, ErrZeroLength)
+	if err := json.Unmarshal(record, &r); err != nil || r.UUID == "" {
+		return "?"
 	}
-	gziped, err := isGzipFile(filename)
+	return r.UUID
+}
+
+// OpenCompressedFile opens the specified file and returns a reader
+// of its decompressed contents.  Files compressed with gzip or zstd
+// are decompressed as they are read; other files are read as is.
+func OpenCompressedFile(filename string) (io.ReadCloser, error) {
+	file, err := os.Open(filename)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	var contents []byte
-	if gziped {
-		cmd := exec.Command("gunzip", "-c", filename)
-		if contents, err = cmd.CombinedOutput(); err != nil {
-			fmt.Printf("%v\n", string(contents))
-			return "", err
+	r := bufio.NewReader(file)
+	magic, err := r.Peek(4)
+	if len(magic) == 0 {
+		file.Close()
+		if err == nil || errors.Is(err, io.EOF) {
+			err = fmt.Errorf("%v: %w", filename, ErrZeroLength)
 		}
-	} else {
-		contents, err = os.ReadFile(filename)
+		return nil, err
+	}
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		gr, err := gzip.NewReader(r)
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("%v: %w", filename, err)
+		}
+		return &compressedFile{Reader: gr, closers: []io.Closer{gr, file}}, nil
+	case bytes.HasPrefix(magic, zstdMagic):
+		zr, err := zstd.NewReader(r)
 		if err != nil {
-			return "", err
+			file.Close()
+			return nil, fmt.Errorf("%v: %w", filename, err)
 		}
+		zrc := zr.IOReadCloser()
+		return &compressedFile{Reader: zrc, closers: []io.Closer{zrc, file}}, nil
 	}
-	return string(contents), nil
+	return &compressedFile{Reader: r, closers: []io.Closer{file}}, nil
 }
 
-func isGzipFile(filename string) (bool, error) {
-	file, err := os.Open(filename)
-	if err != nil {
-		return false, err
-	}
-	defer file.Close()
+// compressedFile closes the decompressor and the underlying file of
+// a file opened by OpenCompressedFile.
+type compressedFile struct {
+	io.Reader
+	closers []io.Closer
+}
 
-	magic := make([]byte, 2)
-	_, err = file.Read(magic)
-	if err != nil {
-		return false, err
+func (f *compressedFile) Close() error {
+	var err error
+	for _, c := range f.closers {
+		if e := c.Close(); e != nil && err == nil {
+			err = e
+		}
 	}
-	return magic[0] == 0x1f && magic[1] == 0x8b, nil
+	return err
 }
 
 func printFlagsArgs(parentCmd, cmd *cobra.Command) {
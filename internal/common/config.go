@@ -0,0 +1,147 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	// ConfigFile is the path of the configuration file relative to
+	// $HOME.  Its top-level keys have the names of command line flags
+	// (e.g., iris-api-url, clickhouse-proxy-url, meas-uuid) plus
+	// username and set their default values.  Keys under profiles.<name>
+	// override the top-level keys when --profile <name> is specified:
+	//
+	//	iris-api-url: https://api.iris.dioptra.io
+	//	username: me@example.com
+	//	profile: production
+	//	profiles:
+	//	  production: {}
+	//	  staging:
+	//	    iris-api-url: https://api.staging.iris.dioptra.io
+	//	    clickhouse-proxy-url: https://chproxy.staging.iris.dioptra.io
+	//	  failover:
+	//	    clickhouse-proxy-urls:
+	//	      - https://chproxy.iris.dioptra.io
+	//	      - https://chproxy2.iris.dioptra.io
+	ConfigFile = ".config/irisctl/config.yaml"
+)
+
+// LoadConfig reads the configuration file, if it exists, and applies
+// the overrides of the profile selected with --profile (or the profile
+// key of the configuration file).  Flags specified on the command line
+// always take precedence over the configuration file.
+func LoadConfig() error {
+	home := os.Getenv("HOME")
+	if home == "" {
+		return ErrHomeEnv
+	}
+	configFile := filepath.Join(home, ConfigFile)
+	if _, err := os.Stat(configFile); errors.Is(err, os.ErrNotExist) {
+		if profile := RootFlagString("profile"); profile != "" {
+			return fmt.Errorf("%v: %w (%v does not exist)", profile, ErrUnknownProfile, configFile)
+		}
+		return nil
+	}
+	viper.SetConfigFile(configFile)
+	if err := viper.ReadInConfig(); err != nil {
+		return err
+	}
+	Verbose("using configuration file %s\n", configFile)
+	profile := RootFlagString("profile")
+	if profile == "" {
+		return nil
+	}
+	if !viper.IsSet("profiles." + profile) {
+		return fmt.Errorf("%v: %w in %v", profile, ErrUnknownProfile, configFile)
+	}
+	Verbose("using profile %s\n", profile)
+	return viper.MergeConfigMap(viper.GetStringMap("profiles." + profile))
+}
+
+// UseProfile switches to the specified profile of the configuration
+// file for the rest of the command (e.g., to submit a measurement to
+// another Iris deployment).  Unlike --profile, values of the profile
+// take precedence over flags specified on the command line.
+func UseProfile(profile string) error {
+	if !viper.IsSet("profiles." + profile) {
+		return fmt.Errorf("%v: %w", profile, ErrUnknownProfile)
+	}
+	Verbose("switching to profile %s\n", profile)
+	for key, value := range viper.GetStringMap("profiles." + profile) {
+		viper.Set(key, value)
+	}
+	viper.Set("profile", profile)
+	return nil
+}
+
+// ClickHouseProxyURLs returns the ClickHouse proxy URLs in order of
+// preference: clickhouse-proxy-urls if set (e.g., as a list in the
+// configuration file) and clickhouse-proxy-url otherwise.
+func ClickHouseProxyURLs() []string {
+	var urls []string
+	for _, url := range viper.GetStringSlice("clickhouse-proxy-urls") {
+		if url = strings.TrimSpace(url); url != "" {
+			urls = append(urls, strings.TrimRight(url, "/"))
+		}
+	}
+	if len(urls) == 0 {
+		urls = []string{strings.TrimRight(RootFlagString("clickhouse-proxy-url"), "/")}
+	}
+	return urls
+}
+
+// Schedule defines an entry of the schedules key of the configuration
+// file that meas schedule run uses to request the measurement defined
+// in MeasFile at the times of the cron expression Cron (minute, hour,
+// day of month, month, day of week):
+//
+//	schedules:
+//	  - name: zeph-daily
+//	    cron: "0 3 * * *"
+//	    meas-file: /home/me/zeph-daily.json
+type Schedule struct {
+	Name     string `mapstructure:"name"`
+	Cron     string `mapstructure:"cron"`
+	MeasFile string `mapstructure:"meas-file"`
+}
+
+// Schedules returns the schedules of the configuration file.
+func Schedules() ([]Schedule, error) {
+	var schedules []Schedule
+	if err := viper.UnmarshalKey("schedules", &schedules); err != nil {
+		return nil, err
+	}
+	return schedules, nil
+}
+
+// DefaultExpectedTables are the numbers of ClickHouse tables per agent
+// of the measurements of each tool.  Tools that are not listed create
+// the four tables of TableTypes.
+var DefaultExpectedTables = map[string]int{
+	"diamond-miner": 4,
+	"ping":          2,
+	"yarrp":         2,
+}
+
+// ExpectedTables returns the number of ClickHouse tables that each
+// agent of a measurement of the specified tool should have.  The
+// expected-tables key of the configuration file overrides the
+// defaults of DefaultExpectedTables:
+//
+//	expected-tables:
+//	  ping: 1
+func ExpectedTables(tool string) int {
+	if n := viper.GetInt("expected-tables." + tool); viper.IsSet("expected-tables."+tool) && n >= 0 {
+		return n
+	}
+	if n, ok := DefaultExpectedTables[tool]; ok {
+		return n
+	}
+	return len(TableTypes)
+}
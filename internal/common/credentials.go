@@ -0,0 +1,229 @@
+package common
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+const (
+	// Credential helpers of --credential-helper.
+	CredentialHelperKeychain      = "keychain"
+	CredentialHelperSecretService = "secret-service"
+	CredentialHelperWincred       = "wincred"
+	CredentialHelperFile          = "file"
+
+	// PasswordsFile is the file of the file credential helper.
+	PasswordsFile = "passwords"
+)
+
+var (
+	ErrNoCredentials           = errors.New("no stored credentials")
+	ErrUnknownCredentialHelper = errors.New("unknown credential helper")
+)
+
+// CredentialStore defines a store of Iris passwords.  Passwords are
+// keyed by user and the Iris API URL so each profile has its own.
+type CredentialStore interface {
+	// Name returns the name of the credential helper.
+	Name() string
+	// Get returns the stored password or ErrNoCredentials.
+	Get(user string) (string, error)
+	// Set stores the password, replacing any stored password.
+	Set(user, password string) error
+	// Unset removes the stored password.
+	Unset(user string) error
+}
+
+// NewCredentialStore returns the credential store selected with
+// --credential-helper.  By default, it is the credential store of the
+// operating system if available and the file credential store (which
+// saves passwords in plain text) otherwise.
+func NewCredentialStore() (CredentialStore, error) {
+	helper := RootFlagString("credential-helper")
+	if helper == "" {
+		helper = defaultCredentialHelper()
+	}
+	Verbose("using %s credential helper\n", helper)
+	switch helper {
+	case CredentialHelperKeychain:
+		return keychainStore{}, nil
+	case CredentialHelperSecretService:
+		return secretServiceStore{}, nil
+	case CredentialHelperWincred:
+		return newWincredStore()
+	case CredentialHelperFile:
+		irisHome, err := IrisDir()
+		if err != nil {
+			return nil, err
+		}
+		return fileStore{file: fmt.Sprintf("%s/%s", irisHome, PasswordsFile)}, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownCredentialHelper, helper)
+}
+
+func defaultCredentialHelper() string {
+	switch runtime.GOOS {
+	case "darwin":
+		if _, err := exec.LookPath("security"); err == nil {
+			return CredentialHelperKeychain
+		}
+	case "linux":
+		if _, err := exec.LookPath("secret-tool"); err == nil {
+			return CredentialHelperSecretService
+		}
+	case "windows":
+		return CredentialHelperWincred
+	}
+	return CredentialHelperFile
+}
+
+// credentialService returns the service name under which passwords of
+// the current Iris instance are stored.
+func credentialService() string {
+	return "irisctl " + RootFlagString("iris-api-url")
+}
+
+// keychainStore stores passwords in the macOS Keychain.
+type keychainStore struct{}
+
+func (keychainStore) Name() string { return CredentialHelperKeychain }
+
+func (keychainStore) Get(user string) (string, error) {
+	output, err := exec.Command("security", "find-generic-password", "-s", credentialService(), "-a", user, "-w").Output()
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrNoCredentials, err)
+	}
+	return strings.TrimRight(string(output), "\n"), nil
+}
+
+func (keychainStore) Set(user, password string) error {
+	return runCredentialHelper(nil, "security", "add-generic-password", "-U", "-s", credentialService(), "-a", user, "-w", password)
+}
+
+func (keychainStore) Unset(user string) error {
+	return runCredentialHelper(nil, "security", "delete-generic-password", "-s", credentialService(), "-a", user)
+}
+
+// secretServiceStore stores passwords with the Secret Service API
+// (e.g., GNOME Keyring, KWallet) on Linux.
+type secretServiceStore struct{}
+
+func (secretServiceStore) Name() string { return CredentialHelperSecretService }
+
+func (secretServiceStore) Get(user string) (string, error) {
+	output, err := exec.Command("secret-tool", "lookup", "service", credentialService(), "account", user).Output()
+	if err != nil || len(output) == 0 {
+		return "", ErrNoCredentials
+	}
+	return strings.TrimRight(string(output), "\n"), nil
+}
+
+func (secretServiceStore) Set(user, password string) error {
+	return runCredentialHelper(strings.NewReader(password), "secret-tool", "store", "--label", credentialService(), "service", credentialService(), "account", user)
+}
+
+func (secretServiceStore) Unset(user string) error {
+	return runCredentialHelper(nil, "secret-tool", "clear", "service", credentialService(), "account", user)
+}
+
+func runCredentialHelper(stdin *strings.Reader, name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	if stdin != nil {
+		cmd.Stdin = stdin
+	}
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s: %w: %s", name, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// fileStore stores passwords in plain text in a file that only the
+// user can read.  Each line of the file has the service, user, and
+// password separated by tabs.
+type fileStore struct {
+	file string
+}
+
+func (fileStore) Name() string { return CredentialHelperFile }
+
+func (s fileStore) Get(user string) (string, error) {
+	lines, err := s.read()
+	if err != nil {
+		return "", err
+	}
+	prefix := credentialService() + "\t" + user + "\t"
+	for _, line := range lines {
+		if strings.HasPrefix(line, prefix) {
+			return strings.TrimPrefix(line, prefix), nil
+		}
+	}
+	return "", ErrNoCredentials
+}
+
+func (s fileStore) Set(user, password string) error {
+	if strings.ContainsAny(password, "\t\n") {
+		return fmt.Errorf("%s credential helper: password cannot contain tabs or newlines", s.Name())
+	}
+	lines, err := s.remove(user)
+	if err != nil {
+		return err
+	}
+	lines = append(lines, credentialService()+"\t"+user+"\t"+password)
+	return s.write(lines)
+}
+
+func (s fileStore) Unset(user string) error {
+	lines, err := s.remove(user)
+	if err != nil {
+		return err
+	}
+	return s.write(lines)
+}
+
+func (s fileStore) remove(user string) ([]string, error) {
+	lines, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	prefix := credentialService() + "\t" + user + "\t"
+	var kept []string
+	for _, line := range lines {
+		if !strings.HasPrefix(line, prefix) {
+			kept = append(kept, line)
+		}
+	}
+	return kept, nil
+}
+
+func (s fileStore) read() ([]string, error) {
+	file, err := os.Open(s.file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, scanner.Err()
+}
+
+func (s fileStore) write(lines []string) error {
+	contents := ""
+	for _, line := range lines {
+		contents += line + "\n"
+	}
+	Verbose("saving in %s\n", s.file)
+	return os.WriteFile(s.file, []byte(contents), 0600)
+}
@@ -0,0 +1,12 @@
+//go:build !windows
+
+package common
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func newWincredStore() (CredentialStore, error) {
+	return nil, fmt.Errorf("%w: %s is only available on windows, not %s", ErrUnknownCredentialHelper, CredentialHelperWincred, runtime.GOOS)
+}
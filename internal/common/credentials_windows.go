@@ -0,0 +1,111 @@
+//go:build windows
+
+package common
+
+import (
+	"errors"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	credTypeGeneric         = 1
+	credPersistLocalMachine = 2
+	errorNotFound           = syscall.Errno(1168)
+)
+
+var (
+	advapi32        = syscall.NewLazyDLL("advapi32.dll")
+	procCredReadW   = advapi32.NewProc("CredReadW")
+	procCredWriteW  = advapi32.NewProc("CredWriteW")
+	procCredDeleteW = advapi32.NewProc("CredDeleteW")
+	procCredFree    = advapi32.NewProc("CredFree")
+)
+
+// credential is the CREDENTIALW structure of the Windows API.
+type credential struct {
+	Flags              uint32
+	Type               uint32
+	TargetName         *uint16
+	Comment            *uint16
+	LastWritten        syscall.Filetime
+	CredentialBlobSize uint32
+	CredentialBlob     *byte
+	Persist            uint32
+	AttributeCount     uint32
+	Attributes         uintptr
+	TargetAlias        *uint16
+	UserName           *uint16
+}
+
+// wincredStore stores passwords in the Windows Credential Manager.
+type wincredStore struct{}
+
+func newWincredStore() (CredentialStore, error) {
+	if err := advapi32.Load(); err != nil {
+		return nil, err
+	}
+	return wincredStore{}, nil
+}
+
+func (wincredStore) Name() string { return CredentialHelperWincred }
+
+func (wincredStore) Get(user string) (string, error) {
+	target, err := syscall.UTF16PtrFromString(credentialService() + ":" + user)
+	if err != nil {
+		return "", err
+	}
+	var cred *credential
+	if r, _, err := procCredReadW.Call(uintptr(unsafe.Pointer(target)), credTypeGeneric, 0, uintptr(unsafe.Pointer(&cred))); r == 0 {
+		if errors.Is(err, errorNotFound) {
+			return "", ErrNoCredentials
+		}
+		return "", err
+	}
+	defer procCredFree.Call(uintptr(unsafe.Pointer(cred)))
+	if cred.CredentialBlobSize == 0 {
+		return "", ErrNoCredentials
+	}
+	return string(unsafe.Slice(cred.CredentialBlob, cred.CredentialBlobSize)), nil
+}
+
+func (wincredStore) Set(user, password string) error {
+	if password == "" {
+		return ErrNoCredentials
+	}
+	target, err := syscall.UTF16PtrFromString(credentialService() + ":" + user)
+	if err != nil {
+		return err
+	}
+	userName, err := syscall.UTF16PtrFromString(user)
+	if err != nil {
+		return err
+	}
+	blob := []byte(password)
+	cred := credential{
+		Type:               credTypeGeneric,
+		TargetName:         target,
+		CredentialBlobSize: uint32(len(blob)),
+		CredentialBlob:     &blob[0],
+		Persist:            credPersistLocalMachine,
+		UserName:           userName,
+	}
+	if r, _, err := procCredWriteW.Call(uintptr(unsafe.Pointer(&cred)), 0); r == 0 {
+		return err
+	}
+	return nil
+}
+
+func (wincredStore) Unset(user string) error {
+	target, err := syscall.UTF16PtrFromString(credentialService() + ":" + user)
+	if err != nil {
+		return err
+	}
+	if r, _, err := procCredDeleteW.Call(uintptr(unsafe.Pointer(target)), credTypeGeneric, 0); r == 0 {
+		if errors.Is(err, errorNotFound) {
+			return ErrNoCredentials
+		}
+		return err
+	}
+	return nil
+}
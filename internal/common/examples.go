@@ -0,0 +1,405 @@
+package common
+
+import (
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// Examples are the examples shown in the help of each command keyed by
+// the command path without the leading "irisctl".  Each example is a
+// complete command line that can be run as is (after replacing the
+// UUIDs and file names).
+var Examples = map[string][]string{
+	// Iris API commands.
+	"auth login": {
+		"irisctl auth login",
+		"irisctl --profile staging auth login",
+	},
+	"auth logout": {
+		"irisctl auth logout",
+	},
+	"auth register": {
+		"irisctl auth register user.json",
+		"irisctl auth register --interactive",
+	},
+	"auth credentials set": {
+		"irisctl auth credentials set",
+		"irisctl --credential-helper file auth credentials set",
+	},
+	"auth credentials unset": {
+		"irisctl auth credentials unset",
+		"irisctl --profile staging auth credentials unset",
+	},
+	"users me": {
+		"irisctl users me",
+		"irisctl --stdout --jq-filter .email users me",
+	},
+	"users all": {
+		"irisctl users all",
+		"irisctl users all --verified",
+		"irisctl users all --format csv --columns email,uuid,probing_limit",
+	},
+	"users delete": {
+		"irisctl users delete --dry-run 3f2504e0-4f89-11d3-9a0c-0305e82c3301",
+		"irisctl users delete 3f2504e0-4f89-11d3-9a0c-0305e82c3301",
+		"irisctl users delete --cascade 3f2504e0-4f89-11d3-9a0c-0305e82c3301",
+	},
+	"users patch": {
+		"irisctl users patch 3f2504e0-4f89-11d3-9a0c-0305e82c3301 user.json",
+		"irisctl users patch --set probing_enabled=true --set probing_limit=10 3f2504e0-4f89-11d3-9a0c-0305e82c3301",
+	},
+	"users services": {
+		"irisctl users services a75482d1-8c5c-4d56-845e-fc3861047992",
+		"eval \"$(irisctl users services --export env a75482d1-8c5c-4d56-845e-fc3861047992)\"",
+		"irisctl users services --export aws-profile a75482d1-8c5c-4d56-845e-fc3861047992 >> ~/.aws/credentials",
+	},
+	"users groups": {
+		"irisctl users groups",
+		"irisctl users groups my-project",
+	},
+	"users search": {
+		"irisctl users search lip6.fr",
+		"irisctl users search 3f2504e0",
+	},
+	"users create": {
+		"irisctl users create user.json",
+	},
+	"users export": {
+		"irisctl users export users.json",
+		"irisctl users export users.csv",
+	},
+	"users import": {
+		"irisctl users import --dry-run users.json",
+		"irisctl --profile staging users import users.csv",
+	},
+	"users activity": {
+		"irisctl users activity",
+		"irisctl users activity --dormant 180 --sort last allmd",
+	},
+	"agents": {
+		"irisctl agents",
+		"irisctl agents --tag all",
+		"irisctl agents --state offline",
+		"irisctl agents --watch --interval 1m",
+		"irisctl agents --format table",
+		"irisctl agents --format csv --columns hostname,state,version,external_ipv4",
+		"irisctl agents iris-us-east4",
+	},
+	"agents history": {
+		"irisctl agents history --since 7d",
+		"irisctl agents history --since 24h iris-us-east4",
+	},
+	"agents history record": {
+		"irisctl agents history record",
+	},
+	"agents restart": {
+		"irisctl agents restart --dry-run iris-us-east4",
+		"irisctl agents restart --yes --wait 5m iris-us-east4 iris-europe-west1",
+	},
+	"agents versions": {
+		"irisctl agents versions",
+		"irisctl agents versions --expected 1.2.0",
+	},
+	"targets all": {
+		"irisctl targets all",
+	},
+	"targets key": {
+		"irisctl targets key prefixes.csv",
+		"irisctl targets key --with-content prefixes.csv",
+		"irisctl targets key --output prefixes-copy.csv prefixes.csv",
+		"irisctl targets key --output . prefixes.csv probes.csv",
+	},
+	"targets delete": {
+		"irisctl targets delete prefixes.csv",
+	},
+	"targets upload": {
+		"irisctl targets upload prefixes.csv",
+		"irisctl targets upload --probe probes.csv",
+		"irisctl targets upload --force prefixes.csv",
+		"irisctl --stdout targets upload --manifest targets.yaml",
+	},
+	"targets aggregate": {
+		"irisctl targets aggregate --dry-run prefixes.csv",
+		"irisctl targets aggregate --output prefixes-aggregated.csv prefixes.csv",
+	},
+	"targets split": {
+		"irisctl targets split --agents 4 prefixes.csv",
+		"irisctl targets split --agents 8 --by random --seed 42 --output-dir shards --upload prefixes.csv",
+		"irisctl targets split --agents 4 --by rtt --rtt-file min-rtts.csv prefixes.csv",
+	},
+	"targets diff": {
+		"irisctl targets diff zeph-2024-06-01.csv zeph-2024-06-02.csv",
+		"irisctl targets diff prefixes.csv ./prefixes.csv",
+	},
+	"targets validate": {
+		"irisctl targets validate prefixes.csv",
+		"irisctl targets validate prefixes-v4.csv prefixes-v6.csv && irisctl targets upload prefixes-v4.csv",
+	},
+	"targets generate": {
+		"irisctl targets generate prefixes.txt > prefixes.csv",
+		"irisctl targets generate --source rib --max-ttl 20 --flows 12 --output rib-2024-06-01.csv --upload rib.20240601.0000.txt",
+		"irisctl targets generate --source hitlist --protocol udp --prefix-len6 48 --output hitlist-v6.csv responsive-addresses.txt",
+	},
+	"apply": {
+		"irisctl apply --dry-run -f iris/",
+		"irisctl apply -f users.yaml -f measurements.yaml",
+	},
+	"export state": {
+		"irisctl --stdout export state > iris.yaml",
+		"irisctl export state --every 168h",
+	},
+	"serve ui": {
+		"irisctl serve ui",
+		"irisctl serve ui --all-users --addr 127.0.0.1:9000 --refresh 1m",
+	},
+	"meas": {
+		"irisctl meas",
+		"irisctl meas --state finished --tag zeph-gcp-daily.json",
+		"irisctl meas --since 7d",
+		"irisctl meas --all-users --fields uuid,state,tags",
+		"irisctl meas --uuid a75482d1-8c5c-4d56-845e-fc3861047992",
+	},
+	"meas clone": {
+		"irisctl meas clone --dry-run a75482d1-8c5c-4d56-845e-fc3861047992",
+		"irisctl meas clone --tag zeph-gcp-daily.json --tag rerun a75482d1-8c5c-4d56-845e-fc3861047992",
+	},
+	"meas delete": {
+		"irisctl meas delete a75482d1-8c5c-4d56-845e-fc3861047992",
+	},
+	"meas diff": {
+		"irisctl meas diff a75482d1-8c5c-4d56-845e-fc3861047992 b75482d1-8c5c-4d56-845e-fc3861047992",
+		"irisctl meas diff --threshold 20 a75482d1-8c5c-4d56-845e-fc3861047992 b75482d1-8c5c-4d56-845e-fc3861047992",
+	},
+	"meas download": {
+		"irisctl meas download a75482d1-8c5c-4d56-845e-fc3861047992",
+		"irisctl meas download --format native --output-dir results a75482d1-8c5c-4d56-845e-fc3861047992",
+	},
+	"meas edit": {
+		"irisctl meas edit a75482d1-8c5c-4d56-845e-fc3861047992 patch.json",
+	},
+	"meas estimate": {
+		"irisctl meas estimate meas.json",
+	},
+	"meas init": {
+		"irisctl meas init meas.json",
+		"irisctl meas init --tool ping --agent-tag ping meas-ping.json",
+	},
+	"meas manifest": {
+		"irisctl meas manifest a75482d1-8c5c-4d56-845e-fc3861047992",
+		"irisctl --stdout meas manifest a75482d1-8c5c-4d56-845e-fc3861047992",
+	},
+	"meas postmortem": {
+		"irisctl meas postmortem a75482d1-8c5c-4d56-845e-fc3861047992",
+		"irisctl --stdout meas postmortem --no-logs a75482d1-8c5c-4d56-845e-fc3861047992",
+	},
+	"meas publish": {
+		"irisctl meas publish a75482d1-8c5c-4d56-845e-fc3861047992",
+	},
+	"meas retry": {
+		"irisctl meas retry --dry-run a75482d1-8c5c-4d56-845e-fc3861047992",
+		"irisctl meas retry a75482d1-8c5c-4d56-845e-fc3861047992",
+	},
+	"meas summary": {
+		"irisctl meas summary a75482d1-8c5c-4d56-845e-fc3861047992",
+	},
+	"meas unpublish": {
+		"irisctl meas unpublish a75482d1-8c5c-4d56-845e-fc3861047992",
+	},
+	"meas progress": {
+		"irisctl meas progress a75482d1-8c5c-4d56-845e-fc3861047992",
+		"irisctl meas progress --follow --interval 30s a75482d1-8c5c-4d56-845e-fc3861047992",
+	},
+	"meas schedule": {
+		"irisctl meas schedule",
+	},
+	"meas schedule run": {
+		"irisctl meas schedule run",
+		"nohup irisctl meas schedule run >> schedule.log 2>&1 &",
+	},
+	"meas replay": {
+		"irisctl meas replay --to-profile staging --dry-run a75482d1-8c5c-4d56-845e-fc3861047992",
+		"irisctl meas replay --to-profile staging --agent-tag all a75482d1-8c5c-4d56-845e-fc3861047992",
+	},
+	"meas request": {
+		"irisctl meas request meas.json",
+	},
+	"meas retag": {
+		"irisctl meas retag --from test --to exhaustive --dry-run",
+		"irisctl meas retag --from test --to exhaustive --state finished --after 2024-01-01",
+	},
+	"meas validate": {
+		"irisctl meas validate meas.json",
+		"irisctl meas validate --offline meas.json",
+	},
+	"meas wait": {
+		"irisctl meas wait a75482d1-8c5c-4d56-845e-fc3861047992",
+		"irisctl meas wait --until finished --timeout 2h a75482d1-8c5c-4d56-845e-fc3861047992 && irisctl clickhouse export a75482d1-8c5c-4d56-845e-fc3861047992",
+	},
+	"meas watch": {
+		"irisctl meas watch a75482d1-8c5c-4d56-845e-fc3861047992",
+		"irisctl meas watch --interval 5m a75482d1-8c5c-4d56-845e-fc3861047992",
+	},
+	"status": {
+		"irisctl status",
+	},
+	"maint dq": {
+		"irisctl maint dq measurements",
+		"irisctl maint dq --post measurements",
+	},
+	"maint meas": {
+		"irisctl maint meas delete a75482d1-8c5c-4d56-845e-fc3861047992",
+		"irisctl maint meas delete --state agent_failure --before 2024-01-01",
+	},
+
+	// Extension (non-API) commands.
+	"analyze": {
+		"irisctl analyze --state finished",
+		"irisctl analyze --tag zeph-gcp-daily.json --after 2024-01-01 allmd",
+		"irisctl analyze --since yesterday --before today allmd",
+		"irisctl analyze --format parquet --output analysis.parquet allmd",
+		"irisctl analyze --format parquet --output gs://my-bucket/analysis.parquet allmd",
+	},
+	"analyze hours": {
+		"irisctl analyze hours allmd",
+		"irisctl analyze --after 2024-01-01 hours --html allmd",
+	},
+	"analyze tags": {
+		"irisctl analyze tags allmd",
+		"irisctl analyze --state finished tags allmd",
+	},
+	"analyze states": {
+		"irisctl analyze states allmd",
+		"irisctl analyze --tag zeph-gcp-daily.json states allmd",
+	},
+	"analyze projects": {
+		"irisctl analyze projects allmd",
+	},
+	"analyze tables": {
+		"irisctl analyze tables --sort rows --desc allmd",
+		"irisctl analyze tables --meas-uuid a75482d1-8c5c-4d56-845e-fc3861047992 allmd",
+	},
+	"analyze sql": {
+		"irisctl analyze sql 'SELECT state, count(*) FROM measurements GROUP BY state' allmd",
+		"irisctl analyze --after 2024-01-01 sql 'SELECT tool, avg(end_time - start_time) FROM measurements GROUP BY tool' allmd",
+	},
+	"analyze params": {
+		"irisctl analyze --tag zeph-gcp-daily.json params allmd",
+	},
+	"analyze seasonality": {
+		"irisctl analyze seasonality allmd",
+		"irisctl analyze --after 2024-01-01 seasonality --top 10 allmd",
+	},
+	"analyze trend": {
+		"irisctl analyze --all-users trend",
+		"irisctl analyze --tag zeph-gcp-daily.json trend --alert --days 14 --drop 30",
+	},
+	"analyze agent-readiness": {
+		"irisctl analyze agent-readiness",
+		"irisctl analyze agent-readiness --since 2h --slo 5m --alert",
+	},
+	"analyze failure-correlation": {
+		"irisctl analyze --all-users --after 2024-06-01 failure-correlation",
+		"irisctl analyze --tag zeph-gcp-daily.json failure-correlation --window 1h allmd",
+	},
+	"cache gc": {
+		"irisctl cache gc",
+		"irisctl cache gc --days 1 --dry-run",
+	},
+	"cache refresh": {
+		"irisctl cache refresh",
+		"irisctl --profile staging cache refresh",
+	},
+	"cache show": {
+		"irisctl cache show",
+	},
+	"check agents": {
+		"irisctl check agents",
+		"irisctl check agents --uptime --net",
+	},
+	"check collect": {
+		"irisctl check collect iris-us-east4",
+		"irisctl check collect --tail 1000 --output support.tar.gz iris-us-east4 iris-europe-west6",
+	},
+	"check containers": {
+		"irisctl check containers",
+		"irisctl check containers --errors iris-us-east4",
+		"irisctl check containers --format json --errors iris-us-east4",
+	},
+	"check ingestion": {
+		"irisctl check ingestion --meas-uuid a75482d1-8c5c-4d56-845e-fc3861047992",
+		"irisctl check ingestion --meas-uuid a75482d1-8c5c-4d56-845e-fc3861047992 --window 1h",
+	},
+	"check inventory": {
+		"irisctl check inventory",
+	},
+	"check uuids": {
+		"irisctl check uuids a75482d1-8c5c-4d56-845e-fc3861047992",
+	},
+	"check docker-restart": {
+		"irisctl check docker-restart",
+		"irisctl check docker-restart --policy unless-stopped --compose-file /opt/iris/docker-compose.yml iris-us-east4",
+	},
+	"check versions": {
+		"irisctl check versions",
+		"irisctl check versions --matrix https://raw.githubusercontent.com/dioptra-io/irisctl/main/internal/check/compat.json",
+	},
+	"check regression": {
+		"irisctl check regression --record iris-1.2.json",
+		"irisctl check regression iris-1.2.json",
+		"irisctl check regression --record --call /agents/ --call /users/me agents.json",
+	},
+	"clickhouse": {
+		"irisctl clickhouse --query 'SHOW TABLES'",
+		"irisctl clickhouse query.sql",
+		"irisctl clickhouse --clickhouse-credentials clickhouse.json --query 'SHOW TABLES'",
+	},
+	"clickhouse export": {
+		"irisctl clickhouse export a75482d1-8c5c-4d56-845e-fc3861047992",
+		"irisctl clickhouse export --jobs 4 --output-dir exports a75482d1-8c5c-4d56-845e-fc3861047992",
+		"irisctl clickhouse export --output-dir s3://my-bucket/exports a75482d1-8c5c-4d56-845e-fc3861047992",
+	},
+	"clickhouse tail": {
+		"irisctl clickhouse tail --meas-uuid a75482d1-8c5c-4d56-845e-fc3861047992",
+		"irisctl clickhouse tail --meas-uuid a75482d1-8c5c-4d56-845e-fc3861047992 --interval 1m --count 10",
+	},
+	"clickhouse annotate": {
+		"irisctl clickhouse annotate --meas-uuid a75482d1-8c5c-4d56-845e-fc3861047992 --dry-run",
+		"irisctl clickhouse --clickhouse-credentials admin.json annotate --meas-uuid a75482d1-8c5c-4d56-845e-fc3861047992",
+	},
+	"convert md": {
+		"irisctl convert md oldmd allmd",
+	},
+	"doctor": {
+		"irisctl doctor",
+		"irisctl --profile staging doctor",
+	},
+	"explain": {
+		"irisctl explain",
+		"irisctl explain too-long",
+		"irisctl explain expected-tables empty-table",
+	},
+	"list": {
+		"irisctl list",
+		"irisctl list --state finished --tag zeph-gcp-daily.json allmd",
+		"irisctl list --bq allmd",
+		"irisctl list --since 24h allmd",
+		"irisctl list --fields tags,start_time,end_time,agents.state",
+	},
+}
+
+// RegisterExamples sets the examples of all commands under root that
+// have examples in Examples.
+func RegisterExamples(root *cobra.Command) {
+	var walk func(cmd *cobra.Command)
+	walk = func(cmd *cobra.Command) {
+		path := strings.TrimPrefix(strings.TrimPrefix(cmd.CommandPath(), root.Name()), " ")
+		if examples, ok := Examples[path]; ok {
+			cmd.Example = strings.Join(examples, "\n")
+		}
+		for _, c := range cmd.Commands() {
+			walk(c)
+		}
+	}
+	walk(root)
+}
@@ -0,0 +1,151 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// httpClient sends the requests that do not need curl.  Its
+	// transport keeps connections alive and uses HTTP/2 when the
+	// server supports it, so a series of requests (e.g., batches of
+	// metadata) pays the TCP and TLS handshakes only once.
+	httpClient = &http.Client{
+		Transport: &http.Transport{
+			Proxy:               http.ProxyFromEnvironment,
+			ForceAttemptHTTP2:   true,
+			MaxIdleConnsPerHost: 8,
+			IdleConnTimeout:     90 * time.Second,
+			TLSHandshakeTimeout: 10 * time.Second,
+		},
+	}
+
+	// Connection statistics reported by --timing.
+	connStats   = make(map[string]int) // keyed by "new", "reused", and protocol
+	connSetup   time.Duration          // time spent setting up new connections
+	connStatsMu sync.Mutex
+)
+
+// parseCurlArgs returns the headers and the body of a request made
+// with the specified curl arguments.  It returns false if the
+// arguments use curl options (e.g., -F or --output) that the native
+// client does not support.
+func parseCurlArgs(curlArgs []string) (http.Header, string, bool) {
+	header := http.Header{}
+	body := ""
+	for i := 0; i < len(curlArgs); i++ {
+		switch curlArgs[i] {
+		case "-s":
+		case "-X":
+			i++ // the method is passed separately
+		case "-H", "-d":
+			if i+1 == len(curlArgs) {
+				return nil, "", false
+			}
+			i++
+			if curlArgs[i-1] == "-d" {
+				body = curlArgs[i]
+				continue
+			}
+			name, value, ok := strings.Cut(curlArgs[i], ":")
+			if !ok {
+				return nil, "", false
+			}
+			header.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+		default:
+			return nil, "", false
+		}
+	}
+	// Like curl -d.
+	if body != "" && header.Get("Content-Type") == "" {
+		header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	return header, body, true
+}
+
+// httpRateLimited sends the request with the native client and, like
+// curlRateLimited, retries it if it is rate limited.  It returns false
+// if the request needs curl.  As with curl (without --fail), HTTP
+// errors are not errors; the body and the status code of the response
+// are returned.
+func httpRateLimited(method, url string, curlArgs []string) ([]byte, int, bool, error) {
+	// The last argument is the URL.
+	header, body, ok := parseCurlArgs(curlArgs[:len(curlArgs)-1])
+	if !ok {
+		return nil, 0, false, nil
+	}
+	for attempt := 0; ; attempt++ {
+		start := time.Now()
+		req, err := http.NewRequestWithContext(traceConnections(context.Background()), method, url, strings.NewReader(body))
+		if err != nil {
+			return nil, 0, true, err
+		}
+		req.Header = header.Clone()
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			AddTiming(curlTimingCategory(url), start)
+			return []byte(err.Error()), 0, true, err
+		}
+		output, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		AddTiming(curlTimingCategory(url), start)
+		connStatsMu.Lock()
+		connStats[resp.Proto]++
+		connStatsMu.Unlock()
+		Debug("%s %s: %d bytes in %v (%s)\n", method, url, len(output), time.Since(start).Round(time.Millisecond), resp.Proto)
+		Trace("%s\n", string(output))
+		if err != nil {
+			return output, resp.StatusCode, true, err
+		}
+		if !rateLimitRetry(method, url, resp.StatusCode, resp.Header, attempt) {
+			return output, resp.StatusCode, true, nil
+		}
+	}
+}
+
+// traceConnections returns a context that records whether requests
+// reuse connections and how long new connections take to set up.
+func traceConnections(ctx context.Context) context.Context {
+	var getConn time.Time
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GetConn: func(string) { getConn = time.Now() },
+		GotConn: func(info httptrace.GotConnInfo) {
+			connStatsMu.Lock()
+			defer connStatsMu.Unlock()
+			if info.Reused {
+				connStats["reused"]++
+				return
+			}
+			connStats["new"]++
+			connSetup += time.Since(getConn)
+		},
+	})
+}
+
+// printConnStats prints the number of new and reused connections and
+// an estimate of the time saved by reusing connections (i.e., the
+// average setup time of a new connection times the number of reused
+// connections).
+func printConnStats() {
+	connStatsMu.Lock()
+	defer connStatsMu.Unlock()
+	nNew, nReused := connStats["new"], connStats["reused"]
+	if nNew == 0 {
+		return
+	}
+	var protos []string
+	for _, proto := range []string{"HTTP/2.0", "HTTP/1.1", "HTTP/1.0"} {
+		if n := connStats[proto]; n > 0 {
+			protos = append(protos, fmt.Sprintf("%d %s", n, proto))
+		}
+	}
+	saved := connSetup / time.Duration(nNew) * time.Duration(nReused)
+	fmt.Fprintf(os.Stderr, "    %-18s %d new, %d reused (%s requests), ~%v saved\n", "connections", nNew, nReused, strings.Join(protos, ", "), saved.Round(time.Millisecond))
+}
@@ -0,0 +1,101 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/itchyny/gojq"
+)
+
+// JqFile applies the jq filter to the contents of file.
+func JqFile(file string, filter []string) ([]byte, error) {
+	jsonData, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return JqBytes(jsonData, filter)
+}
+
+// JqBytes applies the jq filter to jsonData.  The filter is specified
+// as jq command line arguments: the -r (--raw-output) option followed
+// by the filter expression.  The filter is run by the embedded gojq
+// package; the external jq is only used, if installed, for options
+// or expressions that gojq does not support.
+func JqBytes(jsonData []byte, filter []string) ([]byte, error) {
+	defer AddTiming(TimingOutput, time.Now())
+	raw := false
+	expr := ""
+	for _, arg := range filter {
+		switch {
+		case arg == "-r" || arg == "--raw-output":
+			raw = true
+		case expr == "" && (len(arg) == 0 || arg[0] != '-'):
+			expr = arg
+		default:
+			return jqExternal(jsonData, filter, fmt.Errorf("%w: %v", ErrJqUnsupported, arg))
+		}
+	}
+	if expr == "" {
+		expr = "."
+	}
+	query, err := gojq.Parse(expr)
+	if err != nil {
+		return jqExternal(jsonData, filter, err)
+	}
+	code, err := gojq.Compile(query)
+	if err != nil {
+		return jqExternal(jsonData, filter, err)
+	}
+
+	var output bytes.Buffer
+	enc := json.NewEncoder(&output)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	dec := json.NewDecoder(bytes.NewReader(jsonData))
+	for {
+		var input interface{}
+		if err := dec.Decode(&input); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return output.Bytes(), err
+		}
+		iter := code.Run(input)
+		for {
+			v, ok := iter.Next()
+			if !ok {
+				break
+			}
+			if err, ok := v.(error); ok {
+				return output.Bytes(), err
+			}
+			if s, ok := v.(string); ok && raw {
+				fmt.Fprintln(&output, s)
+				continue
+			}
+			if err := enc.Encode(v); err != nil {
+				return output.Bytes(), err
+			}
+		}
+	}
+	return output.Bytes(), nil
+}
+
+// jqExternal runs the external jq with the specified filter.  If jq
+// is not installed, it returns embeddedErr, the reason the embedded
+// jq could not be used.
+func jqExternal(jsonData []byte, filter []string, embeddedErr error) ([]byte, error) {
+	if _, err := exec.LookPath("jq"); err != nil {
+		return nil, embeddedErr
+	}
+	Verbose("%v: falling back to external jq\n", embeddedErr)
+	cmd := exec.Command("jq", filter...)
+	cmd.Stdin = bytes.NewBuffer(jsonData)
+	return runCmd(cmd)
+}
@@ -0,0 +1,158 @@
+package common
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"golang.org/x/term"
+)
+
+const (
+	// DefaultPager is the pager used if PAGER is not set.
+	DefaultPager = "less"
+
+	// Output that has not filled the screen after pagerWait is not
+	// paged (e.g., progress of long-running commands).
+	pagerWait = 500 * time.Millisecond
+)
+
+var (
+	pagerStdout *os.File      // the original standard output
+	pagerWriter *os.File      // the write end of the pipe set as os.Stdout
+	pagerDone   chan struct{} // closed when all output is written
+	pagerOnce   sync.Once
+)
+
+// StartPager sends standard output to a pager ($PAGER or less) like
+// git does, if standard output is a terminal and --no-pager is not
+// set.  Output is only paged once it exceeds the height of the screen,
+// so short output and prompts for passwords are not affected.
+func StartPager() {
+	if RootFlagBool("no-pager") || !term.IsTerminal(int(os.Stdout.Fd())) {
+		return
+	}
+	pager, ok := os.LookupEnv("PAGER")
+	if !ok {
+		pager = DefaultPager
+	}
+	if pager == "" || pager == "cat" {
+		return
+	}
+	_, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || height <= 0 {
+		return
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		return
+	}
+	pagerStdout, pagerWriter = os.Stdout, w
+	pagerDone = make(chan struct{})
+	os.Stdout = w
+	// Fatal errors exit the process, so stop the pager before they
+	// are logged.
+	log.SetOutput(pagerLogWriter{})
+	go runPager(pager, height, r)
+}
+
+// StopPager waits for the pager to exit after all output was sent to
+// it.  It is a no-op if there is no pager.
+func StopPager() {
+	if pagerWriter == nil {
+		return
+	}
+	pagerOnce.Do(func() {
+		os.Stdout = pagerStdout
+		pagerWriter.Close()
+		<-pagerDone
+	})
+}
+
+// pagerLogWriter stops the pager before writing log messages so they
+// are not hidden by the pager.
+type pagerLogWriter struct{}
+
+func (pagerLogWriter) Write(p []byte) (int, error) {
+	StopPager()
+	return os.Stderr.Write(p)
+}
+
+// runPager copies output from r to the original standard output until
+// it exceeds height lines, at which point it starts the pager and
+// sends it all output.
+func runPager(pager string, height int, r *os.File) {
+	defer close(pagerDone)
+	defer r.Close()
+
+	// Read output until it exceeds the screen, pagerWait elapses, or
+	// there is no more output.
+	chunks := make(chan []byte)
+	go func() {
+		defer close(chunks)
+		for {
+			buf := make([]byte, 32*1024)
+			n, err := r.Read(buf)
+			if n > 0 {
+				chunks <- buf[:n]
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	var buffered bytes.Buffer
+	var timeout <-chan time.Time
+	page := false
+	for !page {
+		select {
+		case chunk, ok := <-chunks:
+			if !ok {
+				pagerStdout.Write(buffered.Bytes())
+				return
+			}
+			if timeout == nil {
+				timeout = time.After(pagerWait)
+			}
+			buffered.Write(chunk)
+			page = bytes.Count(buffered.Bytes(), []byte("\n")) >= height
+		case <-timeout:
+			pagerStdout.Write(buffered.Bytes())
+			for chunk := range chunks {
+				pagerStdout.Write(chunk)
+			}
+			return
+		}
+	}
+
+	cmd := exec.Command("sh", "-c", pager)
+	cmd.Stdout, cmd.Stderr = pagerStdout, os.Stderr
+	if _, ok := os.LookupEnv("LESS"); !ok {
+		cmd.Env = append(os.Environ(), "LESS=FRX")
+	}
+	stdin, err := cmd.StdinPipe()
+	if err == nil {
+		err = cmd.Start()
+	}
+	if err != nil {
+		// Do not lose output if the pager cannot be started.
+		pagerStdout.Write(buffered.Bytes())
+		for chunk := range chunks {
+			pagerStdout.Write(chunk)
+		}
+		return
+	}
+	io.Copy(stdin, &buffered)
+	for chunk := range chunks {
+		if _, err := stdin.Write(chunk); err != nil {
+			// The user quit the pager; discard the rest.
+			continue
+		}
+	}
+	stdin.Close()
+	cmd.Wait()
+}
@@ -0,0 +1,54 @@
+package common
+
+import (
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// MeasurementRow defines the columns of measurements exported in
+// Parquet format.
+type MeasurementRow struct {
+	UUID           string    `parquet:"uuid"`
+	UserID         string    `parquet:"user_id"`
+	Tool           string    `parquet:"tool"`
+	State          string    `parquet:"state"`
+	Tags           []string  `parquet:"tags,list"`
+	CreationTime   time.Time `parquet:"creation_time,timestamp(microsecond)"`
+	StartTime      time.Time `parquet:"start_time,timestamp(microsecond),optional"`
+	EndTime        time.Time `parquet:"end_time,timestamp(microsecond),optional"`
+	Agents         int32     `parquet:"agents"`
+	AgentsFinished int32     `parquet:"agents_finished"`
+	Issues         []string  `parquet:"issues,list"`
+}
+
+// NewMeasurementRow returns the Parquet row of the specified measurement.
+func NewMeasurementRow(measurement Measurement, issues []string) MeasurementRow {
+	row := MeasurementRow{
+		UUID:         measurement.UUID,
+		UserID:       measurement.UserID,
+		Tool:         measurement.Tool,
+		State:        measurement.State,
+		Tags:         measurement.Tags,
+		CreationTime: measurement.CreationTime.Time,
+		Agents:       int32(len(measurement.Agents)),
+		Issues:       issues,
+	}
+	// Measurements that have not started or ended have zero times,
+	// which the optional columns store as nulls.
+	row.StartTime = measurement.StartTime.Time
+	row.EndTime = measurement.EndTime.Time
+	for _, agent := range measurement.Agents {
+		if agent.State == "finished" {
+			row.AgentsFinished++
+		}
+	}
+	return row
+}
+
+// WriteParquet writes the specified rows to file in Parquet format.
+func WriteParquet[T any](file string, rows []T) error {
+	defer AddTiming(TimingOutput, time.Now())
+	Verbose("writing %d rows to %s\n", len(rows), file)
+	return parquet.WriteFile(file, rows)
+}
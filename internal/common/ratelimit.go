@@ -0,0 +1,166 @@
+package common
+
+import (
+	"bufio"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Maximum number of times a rate-limited request is retried.
+	maxRateLimitRetries = 5
+	// Remaining requests below which PacingDelay slows down.
+	lowQuota = 5
+)
+
+// rateLimit holds the rate-limit state reported by the server in the
+// headers of the last response.
+type rateLimit struct {
+	mu        sync.Mutex
+	remaining int // -1 if unknown
+	reset     time.Duration
+}
+
+var lastRateLimit = rateLimit{remaining: -1}
+
+// PacingDelay returns how long callers issuing a series of requests
+// (e.g., fetching batches) should wait before the next request to
+// spread the remaining quota over the rate-limit window.
+func PacingDelay() time.Duration {
+	lastRateLimit.mu.Lock()
+	defer lastRateLimit.mu.Unlock()
+	if lastRateLimit.remaining < 0 || lastRateLimit.remaining > lowQuota || lastRateLimit.reset <= 0 {
+		return 0
+	}
+	return lastRateLimit.reset / time.Duration(lastRateLimit.remaining+1)
+}
+
+// curlRateLimited runs curl and retries the request after the delay
+// specified by the server if it responds with 429 Too Many Requests
+// or 503 Service Unavailable with a Retry-After header.  It also
+// returns the status code of the last response (0 if unknown).
+func curlRateLimited(method, url string, curlArgs []string) ([]byte, int, error) {
+	headerFile, err := CreateTemp("irisctl-headers-")
+	if err != nil {
+		return nil, 0, err
+	}
+	headerFile.Close()
+	defer os.Remove(headerFile.Name())
+	curlArgs = append([]string{"-D", headerFile.Name()}, curlArgs...)
+
+	for attempt := 0; ; attempt++ {
+		start := time.Now()
+		cmd := exec.Command("curl", curlArgs...)
+		output, err := cmd.CombinedOutput()
+		AddTiming(curlTimingCategory(url), start)
+		Debug("%s %s: %d bytes in %v\n", method, url, len(output), time.Since(start).Round(time.Millisecond))
+		Trace("%s\n", string(output))
+		if err != nil {
+			return output, 0, err
+		}
+		status, header := parseHeaderFile(headerFile.Name())
+		if !rateLimitRetry(method, url, status, header, attempt) {
+			return output, status, nil
+		}
+	}
+}
+
+// rateLimitRetry updates the rate-limit state with the headers of the
+// response and, if the request was rate limited and can be retried,
+// waits before the next attempt and returns true.
+func rateLimitRetry(method, url string, status int, header http.Header, attempt int) bool {
+	updateRateLimit(header)
+	retryAfter, ok := parseRetryAfter(header.Get("Retry-After"))
+	if status != http.StatusTooManyRequests && !(status == http.StatusServiceUnavailable && ok) {
+		return false
+	}
+	if attempt == maxRateLimitRetries {
+		return false
+	}
+	if !ok {
+		retryAfter = time.Second << attempt
+	}
+	Verbose("%s %s: rate limited (%d), retrying in %v\n", method, url, status, retryAfter)
+	time.Sleep(retryAfter)
+	return true
+}
+
+// parseHeaderFile returns the status code and the headers of the last
+// response in the specified file written by curl -D.
+func parseHeaderFile(headerFile string) (int, http.Header) {
+	status := 0
+	header := http.Header{}
+	file, err := os.Open(headerFile)
+	if err != nil {
+		return status, header
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.HasPrefix(line, "HTTP/") {
+			// A new response (e.g., after a redirect) starts.
+			header = http.Header{}
+			if fields := strings.Fields(line); len(fields) > 1 {
+				status, _ = strconv.Atoi(fields[1])
+			}
+			continue
+		}
+		if name, value, ok := strings.Cut(line, ":"); ok {
+			header.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+		}
+	}
+	return status, header
+}
+
+func updateRateLimit(header http.Header) {
+	remaining := firstHeader(header, "X-RateLimit-Remaining", "RateLimit-Remaining")
+	if remaining == "" {
+		return
+	}
+	n, err := strconv.Atoi(remaining)
+	if err != nil {
+		return
+	}
+	lastRateLimit.mu.Lock()
+	defer lastRateLimit.mu.Unlock()
+	lastRateLimit.remaining = n
+	lastRateLimit.reset = 0
+	if reset, err := strconv.Atoi(firstHeader(header, "X-RateLimit-Reset", "RateLimit-Reset")); err == nil {
+		lastRateLimit.reset = time.Duration(reset) * time.Second
+		// Some servers send the reset time as a Unix timestamp.
+		if reset > 1000000000 {
+			lastRateLimit.reset = time.Until(time.Unix(int64(reset), 0))
+		}
+	}
+	Verbose("rate limit: %d requests remaining (reset in %v)\n", n, lastRateLimit.reset.Round(time.Second))
+}
+
+func firstHeader(header http.Header, names ...string) string {
+	for _, name := range names {
+		if value := header.Get(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// parseRetryAfter parses the value of a Retry-After header, which is
+// either a number of seconds or an HTTP date.
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return time.Until(t), true
+	}
+	return 0, false
+}
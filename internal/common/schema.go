@@ -0,0 +1,143 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrSchemaDrift = errors.New("API schema drift")
+
+	// Schema drift that has already been reported in this run.
+	reportedDrift   = make(map[string]bool)
+	reportedDriftMu sync.Mutex
+
+	jsonUnmarshaler = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+)
+
+// DecodeJSON decodes a response of Iris API into v.  Fields that are
+// not defined in v are silently dropped and critical fields (i.e.,
+// fields tagged with `schema:"required"`) may be missing unless:
+//
+//   - --strict-schema is set, in which case unknown or missing fields
+//     are an error, or
+//   - --schema-warnings is set, in which case each unknown or missing
+//     field is reported once per run.
+func DecodeJSON(data []byte, v interface{}) error {
+	if RootFlagBool("strict-schema") {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(v); err != nil {
+			return fmt.Errorf("%w: %v", ErrSchemaDrift, err)
+		}
+		if missing := SchemaDrift(data, v, false); len(missing) > 0 {
+			return fmt.Errorf("%w: %s", ErrSchemaDrift, strings.Join(missing, ", "))
+		}
+		return nil
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return err
+	}
+	if RootFlagBool("schema-warnings") {
+		reportDrift(SchemaDrift(data, v, true))
+	}
+	return nil
+}
+
+// SchemaDrift returns the critical fields of v that are missing in
+// data and, if unknown is true, the fields of data that are not
+// defined in v.  Fields are named by their JSON path (e.g.,
+// Measurement.agents[].state).
+func SchemaDrift(data []byte, v interface{}, unknown bool) []string {
+	var raw interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil
+	}
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	drift := make(map[string]bool)
+	schemaDrift(t, raw, t.Name(), unknown, drift)
+	var fields []string
+	for field := range drift {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+func schemaDrift(t reflect.Type, raw interface{}, path string, unknown bool, drift map[string]bool) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if reflect.PtrTo(t).Implements(jsonUnmarshaler) {
+		return
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		object, ok := raw.(map[string]interface{})
+		if !ok {
+			return
+		}
+		known := make(map[string]bool)
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name := strings.Split(field.Tag.Get("json"), ",")[0]
+			if name == "-" || !field.IsExported() {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			known[name] = true
+			value, present := object[name]
+			if (!present || value == nil) && field.Tag.Get("schema") == "required" {
+				drift["missing "+path+"."+name] = true
+			}
+			if present {
+				schemaDrift(field.Type, value, path+"."+name, unknown, drift)
+			}
+		}
+		if unknown {
+			for name := range object {
+				if !known[name] {
+					drift["unknown "+path+"."+name] = true
+				}
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		if array, ok := raw.([]interface{}); ok {
+			for _, value := range array {
+				schemaDrift(t.Elem(), value, path+"[]", unknown, drift)
+			}
+		}
+	case reflect.Map:
+		if object, ok := raw.(map[string]interface{}); ok {
+			for _, value := range object {
+				schemaDrift(t.Elem(), value, path+".*", unknown, drift)
+			}
+		}
+	}
+}
+
+// reportDrift prints a warning for each field of drift that has not
+// been reported yet.
+func reportDrift(drift []string) {
+	reportedDriftMu.Lock()
+	defer reportedDriftMu.Unlock()
+	for _, field := range drift {
+		if reportedDrift[field] {
+			continue
+		}
+		reportedDrift[field] = true
+		fmt.Fprintf(os.Stderr, "%s %v: %s\n", Warning(WarnSchemaDrift), ErrSchemaDrift, field)
+	}
+}
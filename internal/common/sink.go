@@ -0,0 +1,140 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrUnknownScheme = errors.New("unknown URL scheme (one of file, gs, s3)")
+)
+
+// Sink defines a destination of exported files.  Sinks are selected
+// by the URL scheme of the destination: local paths and file:// URLs
+// are local directories, gs:// URLs are GCS buckets (via gcloud
+// storage), and s3:// URLs are S3 buckets (via the aws CLI).
+type Sink interface {
+	// Size returns the size of the named file in the sink or an
+	// error that wraps os.ErrNotExist if it does not exist.
+	Size(name string) (int64, error)
+	// Put moves the local file to the named file in the sink.
+	Put(localFile, name string) error
+	// URL returns the path or URL of the named file in the sink.
+	URL(name string) string
+}
+
+// NewSink returns the sink of the specified path or URL.
+func NewSink(dest string) (Sink, error) {
+	if IsLocalSink(dest) {
+		return localSink{strings.TrimPrefix(dest, "file://")}, nil
+	}
+	u, err := url.Parse(dest)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "gs":
+		return bucketSink{dest: strings.TrimSuffix(dest, "/"), cp: []string{"gcloud", "storage", "cp"}, ls: []string{"gcloud", "storage", "ls", "-l"}}, nil
+	case "s3":
+		return bucketSink{dest: strings.TrimSuffix(dest, "/"), cp: []string{"aws", "s3", "cp"}, ls: []string{"aws", "s3", "ls"}}, nil
+	}
+	return nil, fmt.Errorf("%s: %w", dest, ErrUnknownScheme)
+}
+
+// IsLocalSink returns true if dest is a local path or a file:// URL.
+func IsLocalSink(dest string) bool {
+	return !strings.Contains(dest, "://") || strings.HasPrefix(dest, "file://")
+}
+
+// WriteToSink calls write to create the file at dest (a path or URL).
+// Files for remote sinks are written to a temporary file first.
+func WriteToSink(dest string, write func(file string) error) error {
+	if IsLocalSink(dest) {
+		return write(strings.TrimPrefix(dest, "file://"))
+	}
+	i := strings.LastIndex(dest, "/")
+	sink, err := NewSink(dest[:i])
+	if err != nil {
+		return err
+	}
+	f, err := CreateTemp("irisctl-sink-")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+	if err := write(f.Name()); err != nil {
+		return err
+	}
+	return sink.Put(f.Name(), dest[i+1:])
+}
+
+type localSink struct {
+	dir string
+}
+
+func (s localSink) Size(name string) (int64, error) {
+	fi, err := os.Stat(s.URL(name))
+	if err != nil {
+		return 0, err
+	}
+	return fi.Size(), nil
+}
+
+func (s localSink) Put(localFile, name string) error {
+	file := s.URL(name)
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	return os.Rename(localFile, file)
+}
+
+func (s localSink) URL(name string) string {
+	return filepath.Join(s.dir, name)
+}
+
+// bucketSink is a GCS or S3 bucket accessed with the command line
+// tools of the cloud provider.
+type bucketSink struct {
+	dest string
+	cp   []string
+	ls   []string
+}
+
+func (s bucketSink) Size(name string) (int64, error) {
+	cmd := exec.Command(s.ls[0], append(s.ls[1:], s.URL(name))...)
+	output, err := runCmd(cmd)
+	if err != nil {
+		// Both tools fail if the object does not exist.
+		return 0, fmt.Errorf("%s: %w", s.URL(name), os.ErrNotExist)
+	}
+	// The size is the first (gcloud) or third (aws) field.
+	fields := strings.Fields(string(output))
+	for _, i := range []int{0, 2} {
+		if i < len(fields) {
+			if size, err := strconv.ParseInt(fields[i], 10, 64); err == nil {
+				return size, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("%s: cannot parse size: %s", s.URL(name), output)
+}
+
+func (s bucketSink) Put(localFile, name string) error {
+	cmd := exec.Command(s.cp[0], append(s.cp[1:], localFile, s.URL(name))...)
+	if output, err := runCmd(cmd); err != nil {
+		return fmt.Errorf("%s: %v: %s", s.URL(name), err, output)
+	}
+	return os.Remove(localFile)
+}
+
+func (s bucketSink) URL(name string) string {
+	return s.dest + "/" + path.Clean(filepath.ToSlash(name))
+}
@@ -0,0 +1,160 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	// SSH backends of the ssh-backend key of the configuration file.
+	SSHBackendGcloud = "gcloud"
+	SSHBackendNative = "native"
+
+	// Port and timeout of connections of the native SSH backend.
+	sshPort        = "22"
+	sshDialTimeout = 15 * time.Second
+)
+
+var (
+	SSHBackends = []string{SSHBackendGcloud, SSHBackendNative}
+
+	// GetAccessToken returns the access token of the agents request
+	// of sshAddress.  main sets it to auth.GetAccessToken because
+	// auth imports common.
+	GetAccessToken = func() string { return "" }
+
+	ErrSSHBackend = errors.New("invalid ssh-backend")
+	ErrNativeSSH  = errors.New("native SSH is not available")
+)
+
+// SSHConfig defines how the native SSH backend connects to agents.
+// It is read from the configuration file:
+//
+//	ssh-backend: native
+//	ssh:
+//	  user: joe_blow_lip6_fr
+//	  key: /home/joe/.ssh/google_compute_engine
+//	  known-hosts: /home/joe/.ssh/known_hosts
+//	  forward-agent: false
+//	  hosts:
+//	    iris-us-east4: 203.0.113.7
+type SSHConfig struct {
+	User         string
+	Key          string
+	KnownHosts   string
+	ForwardAgent bool
+	Hosts        map[string]string
+}
+
+// SSHBackend returns the ssh-backend of the configuration file:
+// gcloud (the default) runs gcloud compute ssh and native connects
+// directly with the SSH client built into irisctl, so that commands
+// that run remote commands on agents work where the gcloud SDK is not
+// installed (e.g., in containers).
+func SSHBackend() (string, error) {
+	backend := viper.GetString("ssh-backend")
+	if backend == "" {
+		return SSHBackendGcloud, nil
+	}
+	if !Contains(SSHBackends, backend) {
+		return "", fmt.Errorf("%s: %w (one of %s)", backend, ErrSSHBackend, strings.Join(SSHBackends, " "))
+	}
+	return backend, nil
+}
+
+// SSH runs remoteCmd on the agent with the specified hostname with the
+// SSH backend of the configuration file and returns the hostname
+// followed by the non-empty lines of the output.
+func SSH(hostname, remoteCmd string) ([]string, error) {
+	backend, err := SSHBackend()
+	if err != nil {
+		return nil, err
+	}
+	if backend == SSHBackendGcloud {
+		return GcloudSSH(hostname, remoteCmd)
+	}
+	defer AddTiming(TimingSSH, time.Now())
+	config, err := sshConfig()
+	if err != nil {
+		return nil, err
+	}
+	address, err := sshAddress(hostname, config)
+	if err != nil {
+		return nil, err
+	}
+	Verbose("running %q on %s (%s) with native SSH\n", remoteCmd, hostname, address)
+	output, err := nativeSSH(address, remoteCmd, config)
+	if err != nil {
+		return nil, fmt.Errorf("%v\n%v\n", string(output), err)
+	}
+	return sshResults(hostname, output), nil
+}
+
+// sshResults returns the hostname followed by the non-empty lines of
+// the output of a remote command.
+func sshResults(hostname string, output []byte) []string {
+	results := []string{fmt.Sprintf("%s\n", hostname)}
+	for _, line := range strings.Split(string(output), "\n") {
+		if line != "" {
+			results = append(results, fmt.Sprintf("%s\n", line))
+		}
+	}
+	return results
+}
+
+// sshConfig returns the configuration of the native SSH backend with
+// the defaults of the files that gcloud compute ssh creates.
+func sshConfig() (SSHConfig, error) {
+	config := SSHConfig{
+		User:         viper.GetString("ssh.user"),
+		Key:          viper.GetString("ssh.key"),
+		KnownHosts:   viper.GetString("ssh.known-hosts"),
+		ForwardAgent: viper.GetBool("ssh.forward-agent"),
+		Hosts:        viper.GetStringMapString("ssh.hosts"),
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return config, err
+	}
+	if config.User == "" {
+		config.User = os.Getenv("USER")
+	}
+	if config.Key == "" {
+		config.Key = filepath.Join(home, ".ssh", "google_compute_engine")
+	}
+	if config.KnownHosts == "" {
+		config.KnownHosts = filepath.Join(home, ".ssh", "known_hosts")
+	}
+	return config, nil
+}
+
+// sshAddress returns the address of the agent with the specified
+// hostname: its address in the hosts of the SSH configuration or else
+// the external IPv4 address that the agent reports to Iris.
+func sshAddress(hostname string, config SSHConfig) (string, error) {
+	// Viper lowercases keys.
+	if address, ok := config.Hosts[strings.ToLower(hostname)]; ok {
+		return address, nil
+	}
+	url := fmt.Sprintf("%s/?&offset=0&limit=200", APIEndpoint(AgentsAPISuffix))
+	jsonData, err := Curl(GetAccessToken(), false, "GET", url)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", err, jsonData)
+	}
+	var data AgentsData
+	if err := DecodeJSON(jsonData, &data); err != nil {
+		return "", err
+	}
+	for _, agent := range data.Results {
+		if agent.Parameters.Hostname == hostname && agent.Parameters.ExternalIPv4Address != "" {
+			return agent.Parameters.ExternalIPv4Address, nil
+		}
+	}
+	return "", fmt.Errorf("%s: no address (add it to ssh.hosts in the configuration file)", hostname)
+}
@@ -0,0 +1,78 @@
+package common
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// nativeSSH runs remoteCmd on the host at address with the SSH client
+// of golang.org/x/crypto/ssh and returns its combined output.  It
+// authenticates with the keys of the SSH agent (SSH_AUTH_SOCK) and with
+// the key file of the configuration, and verifies the host key against
+// the known hosts file.
+func nativeSSH(address, remoteCmd string, config SSHConfig) ([]byte, error) {
+	var signers []ssh.Signer
+	var agentClient agent.ExtendedAgent
+	if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+		conn, err := net.Dial("unix", sock)
+		if err != nil {
+			return nil, fmt.Errorf("ssh agent: %v", err)
+		}
+		defer conn.Close()
+		agentClient = agent.NewClient(conn)
+		if s, err := agentClient.Signers(); err == nil {
+			signers = append(signers, s...)
+		}
+	}
+	if key, err := os.ReadFile(config.Key); err == nil {
+		signer, err := ssh.ParsePrivateKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", config.Key, err)
+		}
+		signers = append(signers, signer)
+	}
+	if len(signers) == 0 {
+		return nil, fmt.Errorf("%w: no SSH agent and no key in %s", ErrNativeSSH, config.Key)
+	}
+	hostKeyCallback, err := knownhosts.New(config.KnownHosts)
+	if err != nil {
+		return nil, err
+	}
+	client, err := ssh.Dial("tcp", net.JoinHostPort(address, sshPort), &ssh.ClientConfig{
+		User:            config.User,
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signers...)},
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         sshDialTimeout,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+	session, err := client.NewSession()
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+	if config.ForwardAgent {
+		if agentClient == nil {
+			return nil, fmt.Errorf("%w: forward-agent requires an SSH agent (SSH_AUTH_SOCK)", ErrNativeSSH)
+		}
+		if err := agent.ForwardToAgent(client, agentClient); err != nil {
+			return nil, err
+		}
+		if err := agent.RequestAgentForwarding(session); err != nil {
+			return nil, err
+		}
+	}
+	var output bytes.Buffer
+	session.Stdout = &output
+	session.Stderr = &output
+	err = session.Run(remoteCmd)
+	return output.Bytes(), err
+}
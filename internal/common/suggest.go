@@ -0,0 +1,136 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+const (
+	// maxSuggestions is the maximum number of values that Suggest
+	// returns.
+	maxSuggestions = 3
+)
+
+// Levenshtein returns the edit distance between a and b: the minimum
+// number of single-character insertions, deletions, and substitutions
+// that change a into b.
+func Levenshtein(a, b string) int {
+	s, t := []rune(a), []rune(b)
+	prev := make([]int, len(t)+1)
+	curr := make([]int, len(t)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(s); i++ {
+		curr[0] = i
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(t)]
+}
+
+// Suggest returns the known values closest to value (at most
+// maxSuggestions of them) that are close enough to be typos of value:
+// at most one edit away for every three characters of value.
+func Suggest(value string, known []string) []string {
+	maxDistance := max(1, len(value)/3)
+	distances := make(map[string]int)
+	for _, k := range known {
+		if _, ok := distances[k]; ok || k == value {
+			continue
+		}
+		if d := Levenshtein(strings.ToLower(value), strings.ToLower(k)); d <= maxDistance {
+			distances[k] = d
+		}
+	}
+	var suggestions []string
+	for k := range distances {
+		suggestions = append(suggestions, k)
+	}
+	sort.Slice(suggestions, func(i, j int) bool {
+		if distances[suggestions[i]] != distances[suggestions[j]] {
+			return distances[suggestions[i]] < distances[suggestions[j]]
+		}
+		return suggestions[i] < suggestions[j]
+	})
+	if len(suggestions) > maxSuggestions {
+		suggestions = suggestions[:maxSuggestions]
+	}
+	return suggestions
+}
+
+// DidYouMean returns " (did you mean a or b?)" with the suggestions of
+// Suggest for value, or an empty string if there are none.
+func DidYouMean(value string, known []string) string {
+	suggestions := Suggest(value, known)
+	if len(suggestions) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (did you mean %s?)", strings.Join(suggestions, " or "))
+}
+
+// WarnUnmatchedTags prints a warning for each tag of --tag that matches
+// no tag of the measurements, with the closest tags of the
+// measurements and of cachedTags as suggestions.  With
+// --strict-filters, it returns ErrNoMatch instead.
+func WarnUnmatchedTags(tags []string, measurements []Measurement, cachedTags []string) error {
+	known := append([]string{}, cachedTags...)
+	for _, measurement := range measurements {
+		known = append(known, measurement.Tags...)
+	}
+	var unmatched []string
+	for _, tag := range tags {
+		found := false
+		for _, measurement := range measurements {
+			if MatchTag(measurement.Tags, []string{tag}, false) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			unmatched = append(unmatched, fmt.Sprintf("--tag %s matches no measurements%s", tag, DidYouMean(tag, known)))
+		}
+	}
+	return warnUnmatched(unmatched)
+}
+
+// WarnUnmatchedAgents prints a warning for each hostname of --agent
+// that is not the hostname of an agent of the measurements, with the
+// closest hostnames of the measurements and of cachedHostnames as
+// suggestions.  With --strict-filters, it returns ErrNoMatch instead.
+func WarnUnmatchedAgents(hostnames []string, measurements []Measurement, cachedHostnames []string) error {
+	known := append([]string{}, cachedHostnames...)
+	for _, measurement := range measurements {
+		for _, agent := range measurement.Agents {
+			known = append(known, agent.AgentParameters.Hostname)
+		}
+	}
+	var unmatched []string
+	for _, hostname := range hostnames {
+		if !Contains(known, hostname) {
+			unmatched = append(unmatched, fmt.Sprintf("--agent %s matches no agents%s", hostname, DidYouMean(hostname, known)))
+		}
+	}
+	return warnUnmatched(unmatched)
+}
+
+func warnUnmatched(unmatched []string) error {
+	if len(unmatched) == 0 {
+		return nil
+	}
+	if RootFlagBool("strict-filters") {
+		return fmt.Errorf("%w: %s", ErrNoMatch, strings.Join(unmatched, "; "))
+	}
+	for _, u := range unmatched {
+		fmt.Fprintf(os.Stderr, "%s %s\n", Warning(WarnNoMatch), u)
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Summary defines the JSON object that --summary-json prints to stderr
+// when the command ends, successfully or not.
+type Summary struct {
+	ExitCode   int      `json:"exit_code"`
+	DurationMs int64    `json:"duration_ms"`
+	Items      int      `json:"items"`
+	Warnings   int      `json:"warnings"`
+	Errors     []string `json:"errors"`
+	Outputs    []string `json:"outputs"`
+}
+
+var (
+	summary     = Summary{Errors: []string{}, Outputs: []string{}}
+	summaryMu   sync.Mutex
+	summaryOnce sync.Once
+
+	// Date and time that log.Fatal prefixes messages with.
+	logTimeRegexp = regexp.MustCompile(`^\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2}(\.\d+)? `)
+)
+
+// summaryLogWriter records fatal errors in the summary and prints it
+// before log.Fatal exits.  irisctl only logs fatal errors, so every
+// write is the message of a fatal error.
+type summaryLogWriter struct {
+	next io.Writer
+}
+
+func (w summaryLogWriter) Write(p []byte) (int, error) {
+	n, err := w.next.Write(p)
+	summaryMu.Lock()
+	summary.Errors = append(summary.Errors, logTimeRegexp.ReplaceAllString(strings.TrimSpace(string(p)), ""))
+	summaryMu.Unlock()
+	printSummary(1)
+	return n, err
+}
+
+// StartSummary starts recording the summary of --summary-json.  It
+// must be called after StartPager so that fatal errors stop the pager
+// before they are printed.
+func StartSummary() {
+	if !RootFlagBool("summary-json") {
+		return
+	}
+	log.SetOutput(summaryLogWriter{next: log.Writer()})
+}
+
+// AddSummaryItems adds n to the number of items (e.g., measurement
+// records) that the command processed.
+func AddSummaryItems(n int) {
+	summaryMu.Lock()
+	summary.Items += n
+	summaryMu.Unlock()
+}
+
+// AddSummaryOutput adds a file or directory that the command saved its
+// output in.
+func AddSummaryOutput(path string) {
+	summaryMu.Lock()
+	summary.Outputs = append(summary.Outputs, path)
+	summaryMu.Unlock()
+}
+
+// SavingIn prints where the output of the command is saved and adds it
+// to the summary.
+func SavingIn(path string) {
+	fmt.Fprintf(os.Stderr, "saving in %s\n", path)
+	AddSummaryOutput(path)
+}
+
+func addSummaryWarning() {
+	summaryMu.Lock()
+	summary.Warnings++
+	summaryMu.Unlock()
+}
+
+// PrintSummary prints the summary of a successful command if
+// --summary-json is set.
+func PrintSummary() {
+	printSummary(0)
+}
+
+// Exit stops the pager, prints the timing and the summary of the
+// command with the specified exit code, and exits.  Commands whose
+// exit code reports an outcome (e.g., meas wait) use it instead of
+// os.Exit.
+func Exit(code int) {
+	StopPager()
+	PrintTiming()
+	printSummary(code)
+	os.Exit(code)
+}
+
+// printSummary prints the summary as a single line of JSON to stderr
+// once, so that scripts can parse the outcome of the command from the
+// last line of stderr.
+func printSummary(exitCode int) {
+	if !RootFlagBool("summary-json") {
+		return
+	}
+	summaryOnce.Do(func() {
+		summaryMu.Lock()
+		defer summaryMu.Unlock()
+		summary.ExitCode = exitCode
+		summary.DurationMs = time.Since(timingStart).Milliseconds()
+		jsonData, err := json.Marshal(summary)
+		if err != nil {
+			return
+		}
+		fmt.Fprintf(os.Stderr, "%s\n", jsonData)
+	})
+}
@@ -0,0 +1,70 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	// TableTypes are the types of the ClickHouse tables that Iris
+	// creates for each agent of a measurement.
+	TableTypes = []string{"results", "prefixes", "links", "probes"}
+
+	// Iris names tables <type>__<meas-uuid>__<agent-uuid> with the
+	// dashes of the UUIDs replaced by underscores.  The pattern also
+	// matches variants of these names: the internal tables of
+	// materialized views (.inner.), single underscores between the
+	// fields, UUIDs with dashes, and suffixes after the agent UUID
+	// (e.g., probes tables of a round).
+	tableUUIDPattern = `[0-9a-f]{8}[_-][0-9a-f]{4}[_-][0-9a-f]{4}[_-][0-9a-f]{4}[_-][0-9a-f]{12}`
+	tableNameRegexp  = regexp.MustCompile(`^(\.inner(?:_id)?\.)?([a-z]+)_{1,2}(` + tableUUIDPattern + `)_{1,2}(` + tableUUIDPattern + `)(.*)$`)
+
+	// Errors.
+	ErrInvalidTableName = errors.New("invalid table name")
+	ErrUnknownTableType = errors.New("unknown table type")
+)
+
+// TableClass defines what the name of a table of a measurement tells:
+// its type, the UUIDs of its measurement and agent, and its variant
+// (what follows the agent UUID, if anything).
+type TableClass struct {
+	Type      string
+	MeasUUID  string
+	AgentUUID string
+	Variant   string
+	Inner     bool
+}
+
+// TableName returns the name of the table of the specified type of the
+// specified measurement and agent.
+func TableName(tableType, measUUID, agentUUID string) string {
+	return tableType + "__" + strings.ReplaceAll(measUUID, "-", "_") + "__" + strings.ReplaceAll(agentUUID, "-", "_")
+}
+
+// ClassifyTable returns the class of the table with the specified
+// name.  The UUIDs of the class have dashes.
+func ClassifyTable(name string) (TableClass, error) {
+	m := tableNameRegexp.FindStringSubmatch(strings.ToLower(name))
+	if m == nil {
+		return TableClass{}, fmt.Errorf("%s: %w", name, ErrInvalidTableName)
+	}
+	c := TableClass{
+		Type:      m[2],
+		MeasUUID:  strings.ReplaceAll(m[3], "_", "-"),
+		AgentUUID: strings.ReplaceAll(m[4], "_", "-"),
+		Variant:   strings.TrimLeft(m[5], "_"),
+		Inner:     m[1] != "",
+	}
+	if !Contains(TableTypes, c.Type) {
+		return c, fmt.Errorf("%s: %w: %s", name, ErrUnknownTableType, c.Type)
+	}
+	return c, nil
+}
+
+// Canonical returns true if name is the name that Iris gives to the
+// table of the class.
+func (c TableClass) Canonical(name string) bool {
+	return name == TableName(c.Type, c.MeasUUID, c.AgentUUID)
+}
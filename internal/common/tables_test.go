@@ -0,0 +1,98 @@
+package common
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	testMeasUUID  = "a75482d1-8c5c-4d56-845e-fc3861047992"
+	testAgentUUID = "3f2504e0-4f89-11d3-9a0c-0305e82c3301"
+	testMeasID    = "a75482d1_8c5c_4d56_845e_fc3861047992"
+	testAgentID   = "3f2504e0_4f89_11d3_9a0c_0305e82c3301"
+)
+
+func TestClassifyTable(t *testing.T) {
+	tests := []struct {
+		name      string
+		table     string
+		want      TableClass
+		err       error
+		canonical bool
+	}{
+		{"results", "results__" + testMeasID + "__" + testAgentID, TableClass{Type: "results", MeasUUID: testMeasUUID, AgentUUID: testAgentUUID}, nil, true},
+		{"prefixes", "prefixes__" + testMeasID + "__" + testAgentID, TableClass{Type: "prefixes", MeasUUID: testMeasUUID, AgentUUID: testAgentUUID}, nil, true},
+		{"links", "links__" + testMeasID + "__" + testAgentID, TableClass{Type: "links", MeasUUID: testMeasUUID, AgentUUID: testAgentUUID}, nil, true},
+		{"probes", "probes__" + testMeasID + "__" + testAgentID, TableClass{Type: "probes", MeasUUID: testMeasUUID, AgentUUID: testAgentUUID}, nil, true},
+		{"probes of a round", "probes__" + testMeasID + "__" + testAgentID + "__round_2", TableClass{Type: "probes", MeasUUID: testMeasUUID, AgentUUID: testAgentUUID, Variant: "round_2"}, nil, false},
+		{"single underscores", "probes_" + testMeasID + "_" + testAgentID, TableClass{Type: "probes", MeasUUID: testMeasUUID, AgentUUID: testAgentUUID}, nil, false},
+		{"uuids with dashes", "results__" + testMeasUUID + "__" + testAgentUUID, TableClass{Type: "results", MeasUUID: testMeasUUID, AgentUUID: testAgentUUID}, nil, false},
+		{"upper case", "RESULTS__" + testMeasID + "__" + testAgentID, TableClass{Type: "results", MeasUUID: testMeasUUID, AgentUUID: testAgentUUID}, nil, false},
+		{"materialized view", ".inner.links__" + testMeasID + "__" + testAgentID, TableClass{Type: "links", MeasUUID: testMeasUUID, AgentUUID: testAgentUUID, Inner: true}, nil, false},
+		{"materialized view id", ".inner_id.links__" + testMeasID + "__" + testAgentID, TableClass{Type: "links", MeasUUID: testMeasUUID, AgentUUID: testAgentUUID, Inner: true}, nil, false},
+		{"unknown type", "traces__" + testMeasID + "__" + testAgentID, TableClass{Type: "traces", MeasUUID: testMeasUUID, AgentUUID: testAgentUUID}, ErrUnknownTableType, false},
+		{"missing agent", "results__" + testMeasID, TableClass{}, ErrInvalidTableName, false},
+		{"truncated uuid", "results__a75482d1_8c5c__" + testAgentID, TableClass{}, ErrInvalidTableName, false},
+		{"no uuids", "query_log", TableClass{}, ErrInvalidTableName, false},
+		{"empty", "", TableClass{}, ErrInvalidTableName, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ClassifyTable(tt.table)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("ClassifyTable(%q) error = %v, want %v", tt.table, err, tt.err)
+			}
+			if got != tt.want {
+				t.Errorf("ClassifyTable(%q) = %+v, want %+v", tt.table, got, tt.want)
+			}
+			if err == nil && got.Canonical(tt.table) != tt.canonical {
+				t.Errorf("Canonical(%q) = %v, want %v", tt.table, !tt.canonical, tt.canonical)
+			}
+		})
+	}
+}
+
+func TestTableNameRoundTrip(t *testing.T) {
+	for _, tableType := range TableTypes {
+		name := TableName(tableType, testMeasUUID, testAgentUUID)
+		c, err := ClassifyTable(name)
+		if err != nil {
+			t.Fatalf("ClassifyTable(%q): %v", name, err)
+		}
+		if c.Type != tableType || !c.Canonical(name) {
+			t.Errorf("ClassifyTable(%q) = %+v, want canonical %s table", name, c, tableType)
+		}
+	}
+}
+
+func TestExpectedTables(t *testing.T) {
+	tests := []struct {
+		name     string
+		tool     string
+		override map[string]int
+		want     int
+	}{
+		{"diamond-miner", "diamond-miner", nil, 4},
+		{"ping", "ping", nil, 2},
+		{"yarrp", "yarrp", nil, 2},
+		{"unknown tool", "new-tool", nil, len(TableTypes)},
+		{"overridden", "ping", map[string]int{"ping": 1}, 1},
+		{"overridden unknown tool", "new-tool", map[string]int{"new-tool": 3}, 3},
+		{"other tool overridden", "yarrp", map[string]int{"ping": 1}, 2},
+		{"negative override", "ping", map[string]int{"ping": -1}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viper.Reset()
+			defer viper.Reset()
+			for tool, n := range tt.override {
+				viper.Set("expected-tables."+tool, n)
+			}
+			if got := ExpectedTables(tt.tool); got != tt.want {
+				t.Errorf("ExpectedTables(%q) = %d, want %d", tt.tool, got, tt.want)
+			}
+		})
+	}
+}
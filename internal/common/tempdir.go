@@ -0,0 +1,144 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	// DefaultTempMaxAgeDays is the age in days after which temporary
+	// files are removed if temp-gc-days is not set in the configuration
+	// file.
+	DefaultTempMaxAgeDays = 7
+
+	// The automatic removal of old temporary files runs at most once
+	// per tempGCInterval and records when it ran in tempGCStampFile.
+	tempGCInterval  = 24 * time.Hour
+	tempGCStampFile = ".last-gc"
+)
+
+var (
+	ErrUnsafeTempDir = errors.New("unsafe temporary directory")
+)
+
+// TempDir returns the temporary directory of the user, creating it if
+// it does not exist.  Each user has their own directory (irisctl-<uid>
+// in the temporary directory of the system) so that the temporary
+// files of users of shared hosts are neither mixed nor readable by
+// other users.
+func TempDir() (string, error) {
+	dir := filepath.Join(os.TempDir(), "irisctl-"+userID())
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	// Another user could have created the directory first.
+	fi, err := os.Lstat(dir)
+	if err != nil {
+		return "", err
+	}
+	if !fi.IsDir() || !privateDir(fi) {
+		return "", fmt.Errorf("%s: %w (it must be a directory of the user with mode 0700)", dir, ErrUnsafeTempDir)
+	}
+	return dir, nil
+}
+
+// CreateTemp creates a temporary file in the temporary directory of the
+// user (see TempDir and os.CreateTemp for pattern).
+func CreateTemp(pattern string) (*os.File, error) {
+	dir, err := TempDir()
+	if err != nil {
+		return nil, err
+	}
+	return os.CreateTemp(dir, pattern)
+}
+
+// MkdirTemp creates a directory in the temporary directory of the user
+// (see TempDir and os.MkdirTemp for pattern).
+func MkdirTemp(pattern string) (string, error) {
+	dir, err := TempDir()
+	if err != nil {
+		return "", err
+	}
+	return os.MkdirTemp(dir, pattern)
+}
+
+// TempMaxAge returns the age after which temporary files are removed:
+// temp-gc-days of the configuration file or DefaultTempMaxAgeDays.
+func TempMaxAge() time.Duration {
+	days := DefaultTempMaxAgeDays
+	if viper.IsSet("temp-gc-days") {
+		days = viper.GetInt("temp-gc-days")
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// CleanTempDir removes the files and directories of the temporary
+// directory of the user that were last modified more than maxAge ago
+// and returns their names.  If dryRun is true, nothing is removed.
+func CleanTempDir(maxAge time.Duration, dryRun bool) ([]string, error) {
+	dir, err := TempDir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var removed []string
+	var errs []error
+	for _, entry := range entries {
+		if entry.Name() == tempGCStampFile {
+			continue
+		}
+		fi, err := entry.Info()
+		if err != nil {
+			continue // removed since ReadDir
+		}
+		if time.Since(fi.ModTime()) <= maxAge {
+			continue
+		}
+		name := filepath.Join(dir, entry.Name())
+		if !dryRun {
+			if err := os.RemoveAll(name); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+		}
+		removed = append(removed, name)
+	}
+	sort.Strings(removed)
+	return removed, errors.Join(errs...)
+}
+
+// AutoCleanTempDir removes old temporary files (see CleanTempDir) at
+// most once per day unless temp-gc is false in the configuration file.
+// It is called on startup, so errors are only reported with --verbose.
+func AutoCleanTempDir() {
+	if viper.IsSet("temp-gc") && !viper.GetBool("temp-gc") {
+		return
+	}
+	dir, err := TempDir()
+	if err != nil {
+		Verbose("not removing old temporary files: %v\n", err)
+		return
+	}
+	stamp := filepath.Join(dir, tempGCStampFile)
+	if fi, err := os.Stat(stamp); err == nil && time.Since(fi.ModTime()) < tempGCInterval {
+		return
+	}
+	if err := os.WriteFile(stamp, nil, 0600); err != nil {
+		Verbose("not removing old temporary files: %v\n", err)
+		return
+	}
+	removed, err := CleanTempDir(TempMaxAge(), false)
+	if err != nil {
+		Verbose("removing old temporary files: %v\n", err)
+	}
+	Verbose("removed %d temporary files older than %v from %s\n", len(removed), TempMaxAge(), dir)
+}
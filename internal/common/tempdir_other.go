@@ -0,0 +1,23 @@
+//go:build !windows
+
+package common
+
+import (
+	"os"
+	"strconv"
+	"syscall"
+)
+
+func userID() string {
+	return strconv.Itoa(os.Getuid())
+}
+
+// privateDir returns true if the directory is owned by the user and
+// only accessible by the user.
+func privateDir(fi os.FileInfo) bool {
+	if fi.Mode().Perm()&0077 != 0 {
+		return false
+	}
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	return !ok || int(st.Uid) == os.Getuid()
+}
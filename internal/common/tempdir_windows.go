@@ -0,0 +1,24 @@
+//go:build windows
+
+package common
+
+import (
+	"os"
+	"os/user"
+	"path/filepath"
+)
+
+// userID returns the name of the user because Windows has no UIDs.
+func userID() string {
+	u, err := user.Current()
+	if err != nil {
+		return "unknown"
+	}
+	return filepath.Base(u.Username)
+}
+
+// privateDir returns true because the temporary directory of the
+// system is already per user on Windows.
+func privateDir(fi os.FileInfo) bool {
+	return true
+}
@@ -0,0 +1,70 @@
+package common
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestCustomTimeLayouts(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  time.Time
+	}{
+		{"iris api", "2024-03-05T06:07:08.123456", time.Date(2024, 3, 5, 6, 7, 8, 123456000, time.UTC)},
+		{"iris api without fraction", "2024-03-05T06:07:08", time.Date(2024, 3, 5, 6, 7, 8, 0, time.UTC)},
+		{"rfc 3339", "2024-03-05T06:07:08Z", time.Date(2024, 3, 5, 6, 7, 8, 0, time.UTC)},
+		{"rfc 3339 with offset", "2024-03-05T06:07:08.5+02:00", time.Date(2024, 3, 5, 4, 7, 8, 500000000, time.UTC)},
+		{"offset without colon", "2024-03-05T06:07:08-0100", time.Date(2024, 3, 5, 7, 7, 8, 0, time.UTC)},
+		{"space separator", "2024-03-05 06:07:08.25", time.Date(2024, 3, 5, 6, 7, 8, 250000000, time.UTC)},
+		{"date", "2024-03-05", time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c CustomTime
+			if err := c.Set(tt.value); err != nil {
+				t.Fatalf("Set(%q): %v", tt.value, err)
+			}
+			if !c.Time.Equal(tt.want) {
+				t.Errorf("Set(%q) = %v, want %v", tt.value, c.Time, tt.want)
+			}
+			var u CustomTime
+			if err := json.Unmarshal([]byte(`"`+tt.value+`"`), &u); err != nil {
+				t.Fatalf("UnmarshalJSON(%q): %v", tt.value, err)
+			}
+			if !u.Time.Equal(tt.want) {
+				t.Errorf("UnmarshalJSON(%q) = %v, want %v", tt.value, u.Time, tt.want)
+			}
+		})
+	}
+}
+
+func TestCustomTimeNull(t *testing.T) {
+	c := CustomTime{Time: time.Now()}
+	if err := json.Unmarshal([]byte("null"), &c); err != nil {
+		t.Fatalf("UnmarshalJSON(null): %v", err)
+	}
+	if !c.Time.IsZero() {
+		t.Errorf("UnmarshalJSON(null) = %v, want zero time", c.Time)
+	}
+}
+
+func TestCustomTimeRejected(t *testing.T) {
+	tests := []string{
+		"",
+		"05/03/2024",
+		"2024-13-05",
+		"2024-03-05T25:00:00",
+		"yesterday at noon",
+	}
+	for _, value := range tests {
+		var c CustomTime
+		if err := c.Set(value); err == nil {
+			t.Errorf("Set(%q) = %v, want error", value, c.Time)
+		}
+		if err := json.Unmarshal([]byte(`"`+value+`"`), &c); err == nil {
+			t.Errorf("UnmarshalJSON(%q) = %v, want error", value, c.Time)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Categories of time reported by --timing.
+	TimingAPI        = "api calls"
+	TimingClickHouse = "clickhouse"
+	TimingSSH        = "ssh"
+	TimingOutput     = "output rendering"
+	TimingLocal      = "local processing"
+)
+
+var (
+	timingCategories = []string{TimingAPI, TimingClickHouse, TimingSSH, TimingOutput}
+	timingStart      = time.Now()
+	timings          = make(map[string]time.Duration)
+	timingCounts     = make(map[string]int)
+	timingsMu        sync.Mutex
+)
+
+// AddTiming adds the time elapsed since start to the specified
+// category of --timing.  It is meant to be deferred:
+//
+//	defer AddTiming(TimingAPI, time.Now())
+func AddTiming(category string, start time.Time) {
+	elapsed := time.Since(start)
+	timingsMu.Lock()
+	timings[category] += elapsed
+	timingCounts[category]++
+	timingsMu.Unlock()
+}
+
+// curlTimingCategory returns the --timing category of a curl request
+// to the specified URL.
+func curlTimingCategory(url string) string {
+	for _, chproxy := range ClickHouseProxyURLs() {
+		if chproxy != "" && strings.HasPrefix(url, chproxy) {
+			return TimingClickHouse
+		}
+	}
+	return TimingAPI
+}
+
+// PrintTiming prints the breakdown of the time spent by the command
+// if --timing is set.  Local processing is the time not spent in any
+// other category.
+func PrintTiming() {
+	if !RootFlagBool("timing") {
+		return
+	}
+	total := time.Since(timingStart)
+	timingsMu.Lock()
+	defer timingsMu.Unlock()
+	local := total
+	fmt.Fprintf(os.Stderr, "\ntiming:\n")
+	for _, category := range timingCategories {
+		d := timings[category]
+		local -= d
+		fmt.Fprintf(os.Stderr, "    %-18s %10v %5.1f%% (%d)\n", category, d.Round(time.Millisecond), percent(d, total), timingCounts[category])
+	}
+	// Concurrent requests can add up to more than the total time.
+	if local < 0 {
+		local = 0
+	}
+	fmt.Fprintf(os.Stderr, "    %-18s %10v %5.1f%%\n", TimingLocal, local.Round(time.Millisecond), percent(local, total))
+	fmt.Fprintf(os.Stderr, "    %-18s %10v\n", "total", total.Round(time.Millisecond))
+	printConnStats()
+}
+
+func percent(d, total time.Duration) float64 {
+	if total == 0 {
+		return 0
+	}
+	return 100 * float64(d) / float64(total)
+}
@@ -0,0 +1,312 @@
+package common
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Codes of warnings.  Warnings are printed as "WARNING [<code>]: ..."
+// and irisctl explain <code> shows their explanation in Warnings.
+const (
+	WarnTooLong          = "too-long"
+	WarnNoAgents         = "no-agents"
+	WarnNoCreationTime   = "no-creation-time"
+	WarnNoStartTime      = "no-start-time"
+	WarnNoEndTime        = "no-end-time"
+	WarnNoTables         = "no-tables"
+	WarnExpectedTables   = "expected-tables"
+	WarnEmptyTable       = "empty-table"
+	WarnAllUsersIgnored  = "all-users-ignored"
+	WarnMalformedRecord  = "malformed-record"
+	WarnSchemaDrift      = "schema-drift"
+	WarnMdIntegrity      = "md-integrity"
+	WarnAgentDisconnect  = "agent-not-connected"
+	WarnAgentTags        = "agent-tags"
+	WarnNoMatchingAgent  = "no-matching-agent"
+	WarnNoMatch          = "no-match"
+	WarnTargetList       = "target-list"
+	WarnAgentCapability  = "agent-capability"
+	WarnNoRowCounts      = "no-row-counts"
+	WarnCronNeverMatches = "cron-never"
+	WarnExposedUI        = "exposed-ui"
+)
+
+// WarningInfo defines the explanation of a warning: why it is printed,
+// what typically causes it, and commands to run next.
+type WarningInfo struct {
+	Summary   string
+	Rationale string
+	Causes    []string
+	Next      []string
+}
+
+// Warnings are the explanations of warnings keyed by code.
+var Warnings = map[string]WarningInfo{
+	WarnTooLong: {
+		Summary:   "measurement took longer than expected",
+		Rationale: "Daily zeph measurements (zeph-gcp-daily.json) should end within 5 hours and exhaustive collections (collection:exhaustive) within 24 hours; longer ones delay the next measurements.",
+		Causes: []string{
+			"an agent probed slowly or was restarted during the measurement",
+			"the workers or ClickHouse inserts were backlogged",
+			"the target list grew",
+		},
+		Next: []string{
+			"irisctl meas summary <meas-uuid>",
+			"irisctl meas postmortem <meas-uuid>",
+			"irisctl check agents --uptime",
+		},
+	},
+	WarnNoAgents: {
+		Summary:   "measurement has no agents",
+		Rationale: "A measurement without agents has no results, so it is usually the result of a failed request.",
+		Causes: []string{
+			"no agent had the tag of the measurement request",
+			"the agents were deleted before the metadata was fetched",
+		},
+		Next: []string{
+			"irisctl meas --uuid <meas-uuid>",
+			"irisctl agents",
+		},
+	},
+	WarnNoCreationTime: {
+		Summary:   "measurement has no creation time",
+		Rationale: "Iris sets the creation time when a measurement is requested, so a measurement without one is skipped because its durations cannot be computed.",
+		Causes: []string{
+			"an internal error of the Iris API",
+			"a corrupted measurement metadata file",
+		},
+		Next: []string{
+			"irisctl meas --uuid <meas-uuid>",
+			"irisctl --strict analyze <meas-md-file>",
+		},
+	},
+	WarnNoStartTime: {
+		Summary:   "measurement has not started",
+		Rationale: "A measurement without a start time is still waiting in the queue and is skipped because its durations cannot be computed.",
+		Causes: []string{
+			"the workers are busy with other measurements",
+			"the agents of the measurement are not connected",
+		},
+		Next: []string{
+			"irisctl status",
+			"irisctl check agents",
+		},
+	},
+	WarnNoEndTime: {
+		Summary:   "measurement has not ended",
+		Rationale: "A measurement without an end time is ongoing and is skipped because its duration cannot be computed.",
+		Causes: []string{
+			"the measurement is still running",
+			"an agent is stuck and the measurement will never end",
+		},
+		Next: []string{
+			"irisctl meas progress <meas-uuid>",
+			"irisctl check ingestion --meas-uuid <meas-uuid>",
+		},
+	},
+	WarnNoTables: {
+		Summary:   "measurement has no ClickHouse tables",
+		Rationale: "Each agent of a measurement should have results, prefixes, links, and probes tables; without tables, the results of the measurement are lost.",
+		Causes: []string{
+			"the measurement was canceled or failed before any results were inserted",
+			"the tables were dropped (e.g., by maint)",
+		},
+		Next: []string{
+			"irisctl meas postmortem <meas-uuid>",
+			"irisctl analyze tables --meas-uuid <meas-uuid>",
+		},
+	},
+	WarnExpectedTables: {
+		Summary:   "measurement does not have the expected number of tables per agent",
+		Rationale: "Each agent of a diamond-miner measurement should have results, prefixes, links, and probes tables (other tools create fewer, see expected-tables in the configuration file); missing tables mean that the results of some agents are incomplete.",
+		Causes: []string{
+			"the expected-tables configuration of the tool of the measurement is wrong",
+			"an agent failed before its results were inserted",
+			"the measurement is still ongoing",
+			"some tables were dropped",
+		},
+		Next: []string{
+			"irisctl meas summary <meas-uuid>",
+			"irisctl meas retry --dry-run <meas-uuid>",
+		},
+	},
+	WarnEmptyTable: {
+		Summary:   "table has no rows or bytes",
+		Rationale: "Tables of finished measurements should not be empty; an empty results table means that an agent did not receive any replies.",
+		Causes: []string{
+			"the agent could not send or receive probes (e.g., firewall rules)",
+			"the insertion into ClickHouse failed",
+		},
+		Next: []string{
+			"irisctl check containers --errors <hostname>",
+			"irisctl meas postmortem <meas-uuid>",
+		},
+	},
+	WarnAllUsersIgnored: {
+		Summary:   "--all-users is ignored",
+		Rationale: "--all-users selects which measurements are fetched from the API, but a measurement metadata file was specified so nothing is fetched.",
+		Causes: []string{
+			"both --all-users and a measurement metadata file were specified",
+		},
+		Next: []string{
+			"irisctl meas --all-users",
+		},
+	},
+	WarnMalformedRecord: {
+		Summary:   "measurement records were skipped",
+		Rationale: "Records of the measurement metadata file that cannot be decoded are skipped so that the other measurements can still be analyzed.",
+		Causes: []string{
+			"the API returned a measurement in an older or newer format",
+			"the metadata file was truncated or edited",
+		},
+		Next: []string{
+			"irisctl --strict analyze <meas-md-file>",
+			"irisctl check versions",
+		},
+	},
+	WarnSchemaDrift: {
+		Summary:   "API response has unexpected fields",
+		Rationale: "irisctl decodes the responses of the API with the schema of the API version it was written for; unknown or missing fields mean that some values may be ignored or zero.",
+		Causes: []string{
+			"the API was upgraded",
+			"irisctl is older than the API",
+		},
+		Next: []string{
+			"irisctl check versions",
+			"irisctl --strict-schema meas --uuid <meas-uuid>",
+		},
+	},
+	WarnMdIntegrity: {
+		Summary:   "measurement metadata file is inconsistent",
+		Rationale: "Analyses of an inconsistent metadata file (e.g., with duplicate or missing measurements) give wrong counts.",
+		Causes: []string{
+			"measurements were requested or deleted while the metadata was fetched",
+			"the metadata file was concatenated or edited",
+		},
+		Next: []string{
+			"irisctl meas --all-users",
+			"irisctl --strict analyze <meas-md-file>",
+		},
+	},
+	WarnAgentDisconnect: {
+		Summary:   "agent of the desired state is not connected",
+		Rationale: "apply can only check agents that are connected to Iris; measurements will not run on disconnected agents.",
+		Causes: []string{
+			"the agent VM is stopped",
+			"the iris-agent container is not running",
+		},
+		Next: []string{
+			"irisctl check containers <hostname>",
+			"irisctl check docker-restart <hostname>",
+			"irisctl check inventory",
+		},
+	},
+	WarnAgentTags: {
+		Summary:   "agent tags differ from the desired state",
+		Rationale: "Tags of agents are set in their configuration, not with the API, so apply cannot change them; measurements requested by tag will not run on the agent.",
+		Causes: []string{
+			"the agent configuration was not updated",
+			"the agent was not restarted after its configuration was updated",
+		},
+		Next: []string{
+			"irisctl agents --tag <tag>",
+			"irisctl check collect <hostname>",
+		},
+	},
+	WarnNoMatchingAgent: {
+		Summary:   "no agent matches an agent of the measurement definition",
+		Rationale: "The estimate only includes agents that exist, so an agent UUID or tag that matches no agent is not estimated and would not run.",
+		Causes: []string{
+			"a typo in the agent UUID or tag",
+			"the agent is not connected",
+		},
+		Next: []string{
+			"irisctl agents",
+			"irisctl meas validate <meas-file>",
+		},
+	},
+	WarnNoMatch: {
+		Summary:   "a --tag or --agent value matches nothing",
+		Rationale: "Filters that match nothing silently produce empty results, so values of --tag and --agent that match no measurement or agent are reported with the closest known values (with --strict-filters, they are errors).",
+		Causes: []string{
+			"a typo in the tag or hostname",
+			"the measurements of the tag are older than those of the metadata file or belong to another user",
+		},
+		Next: []string{
+			"irisctl cache refresh",
+			"irisctl cache show",
+		},
+	},
+	WarnTargetList: {
+		Summary:   "targets of a target-list overlap or have host bits set",
+		Rationale: "Iris probes each line of a target-list, so duplicate targets and prefixes contained in other prefixes of the same protocol are probed more than once, and the host bits of a prefix are ignored.",
+		Causes: []string{
+			"target-lists concatenated without deduplication",
+			"an address written with a prefix length instead of as a prefix",
+		},
+		Next: []string{
+			"irisctl targets validate <target-list-file>",
+		},
+	},
+	WarnAgentCapability: {
+		Summary:   "a measurement definition exceeds the limits of an agent",
+		Rationale: "Agents advertise their maximum probing rate and minimum TTL; a measurement that asks for more probes too fast or only probes TTLs that the agent filters can end in agent_failure or without results.",
+		Causes: []string{
+			"the definition was written for other agents",
+			"the agent was reconfigured with a lower max_probing_rate or a higher min_ttl",
+		},
+		Next: []string{
+			"irisctl agents --format table --columns hostname,max_probing_rate,tags",
+			"irisctl meas estimate <meas-file>",
+		},
+	},
+	WarnNoRowCounts: {
+		Summary:   "table row counts are not available",
+		Rationale: "Row counts come from ClickHouse; without them, the comparison only includes the metadata of the measurements.",
+		Causes: []string{
+			"ClickHouse or its proxy is not reachable",
+			"the tables of the measurement were dropped",
+		},
+		Next: []string{
+			"irisctl doctor",
+			"irisctl analyze tables --meas-uuid <meas-uuid>",
+		},
+	},
+	WarnCronNeverMatches: {
+		Summary:   "cron expression of a schedule never matches",
+		Rationale: "The schedule will never request its measurement, so it is ignored.",
+		Causes: []string{
+			"an impossible date such as day 30 of February (0 0 30 2 *)",
+		},
+		Next: []string{
+			"irisctl meas schedule",
+		},
+	},
+	WarnExposedUI: {
+		Summary:   "web UI is reachable from other hosts",
+		Rationale: "serve ui uses your credentials, so anyone who can reach its address can see your measurements (or those of all users with --all-users).",
+		Causes: []string{
+			"--addr is not a loopback address",
+		},
+		Next: []string{
+			"irisctl serve ui --addr 127.0.0.1:8080",
+		},
+	},
+}
+
+// Warning returns the prefix of a warning with the specified code and
+// counts the warning in the summary of --summary-json.
+func Warning(code string) string {
+	addSummaryWarning()
+	return fmt.Sprintf("WARNING [%s]:", code)
+}
+
+// WarningCodes returns the codes of Warnings in alphabetical order.
+func WarningCodes() []string {
+	var codes []string
+	for code := range Warnings {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
@@ -0,0 +1,221 @@
+// Package convert implements commands for converting files produced
+// by older versions of irisctl and Iris (not in the Iris API).
+package convert
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/dioptra-io/irisctl/internal/common"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Command, its flags, subcommands, and their flags.
+	//	convert <subcommand>
+	//	convert md <in-meas-md-file> <out-meas-md-file>
+	cmdName     = "convert"
+	subcmdNames = []string{"md"}
+
+	// Errors.
+	ErrUnknownFormat = errors.New("unknown metadata format")
+
+	// Test code changes Fatal to Panic so a fatal error won't exit
+	// the process and can be recovered.
+	fatal    = log.Fatal
+	cliFatal = common.CliFatal
+	verbose  = common.Verbose
+
+	// Keys of the measurement list in the pagination wrappers used
+	// by different versions of Iris.
+	wrapperKeys = []string{"results", "measurements", "items", "data"}
+
+	// Old versions of Iris reported rounds as strings such as
+	// "Round(number=1, limit=10, offset=0)".
+	roundRe = regexp.MustCompile(`(number|limit|offset)=(\d+)`)
+)
+
+// ConvertCmd returns the command structure for convert.
+func ConvertCmd() *cobra.Command {
+	convertCmd := &cobra.Command{
+		Use:       cmdName,
+		ValidArgs: subcmdNames,
+		Short:     "convert commands",
+		Long:      "convert files produced by older versions of irisctl and Iris",
+		Args:      convertArgs,
+		Run:       convert,
+	}
+	convertCmd.SetUsageFunc(common.Usage)
+	convertCmd.SetHelpFunc(common.Help)
+
+	// convert md (has no flags)
+	mdSubcmd := &cobra.Command{
+		Use:   "md",
+		Short: "convert a measurements metadata file",
+		Long:  "convert a measurements metadata file in any historical format to the current format",
+		Args:  convertMdArgs,
+		Run:   convertMd,
+	}
+	convertCmd.AddCommand(mdSubcmd)
+
+	return convertCmd
+}
+
+func convertArgs(cmd *cobra.Command, args []string) error {
+	if _, ok := common.IsUsage(args); ok {
+		return nil
+	}
+	if len(args) == 0 {
+		cliFatal("convert requires one of these subcommands: ", strings.Join(subcmdNames, " "))
+	}
+	cliFatal("unknown subcommand: ", args[0])
+	return nil
+}
+
+func convert(cmd *cobra.Command, args []string) {
+	fatal("convert()")
+}
+
+func convertMdArgs(cmd *cobra.Command, args []string) error {
+	if format, ok := common.IsUsage(args); ok {
+		fmt.Printf(format, "<in> <out>", "input and output measurements metadata files")
+		return nil
+	}
+	if len(args) != 2 {
+		cliFatal("convert md requires two arguments: <in> <out>")
+	}
+	if _, err := common.CheckFile("measurements metadata", args[0]); err != nil {
+		cliFatal(err)
+	}
+	return nil
+}
+
+func convertMd(cmd *cobra.Command, args []string) {
+	n, err := ConvertMdFile(args[0], args[1])
+	if err != nil {
+		fatal(err)
+	}
+	fmt.Printf("converted %d measurement(s) into %s\n", n, args[1])
+}
+
+// ConvertMdFile reads the measurements in inFile, which may be a stream
+// of batches, a list of measurements, or one measurement per line, and
+// writes them to outFile as one batch in the current format.
+func ConvertMdFile(inFile, outFile string) (int, error) {
+	f, err := os.Open(inFile)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	var records []json.RawMessage
+	decoder := json.NewDecoder(f)
+	for {
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return 0, err
+		}
+		r, err := unwrap(value)
+		if err != nil {
+			return 0, err
+		}
+		records = append(records, r...)
+	}
+
+	var results []map[string]interface{}
+	for i, record := range records {
+		normalized, err := normalize(record)
+		if err != nil {
+			return 0, fmt.Errorf("record %d: %v", i+1, err)
+		}
+		results = append(results, normalized)
+	}
+	batch := map[string]interface{}{
+		"count":    len(results),
+		"next":     nil,
+		"previous": nil,
+		"results":  results,
+	}
+	jsonData, err := json.Marshal(batch)
+	if err != nil {
+		return 0, err
+	}
+	return len(results), os.WriteFile(outFile, append(jsonData, '\n'), 0644)
+}
+
+// unwrap returns the measurement records in a JSON value.
+func unwrap(value json.RawMessage) ([]json.RawMessage, error) {
+	value = bytes.TrimSpace(value)
+	if len(value) > 0 && value[0] == '[' {
+		var records []json.RawMessage
+		err := json.Unmarshal(value, &records)
+		return records, err
+	}
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(value, &object); err != nil {
+		return nil, err
+	}
+	for _, key := range wrapperKeys {
+		if list, ok := object[key]; ok {
+			verbose("found measurements in %q\n", key)
+			return unwrap(list)
+		}
+	}
+	if _, ok := object["uuid"]; ok {
+		return []json.RawMessage{value}, nil
+	}
+	return nil, ErrUnknownFormat
+}
+
+// normalize converts a measurement record in any historical format to
+// the current format and verifies that it can be parsed.
+func normalize(record json.RawMessage) (map[string]interface{}, error) {
+	var m map[string]interface{}
+	if err := json.Unmarshal(record, &m); err != nil {
+		return nil, err
+	}
+	agents, _ := m["agents"].([]interface{})
+	for _, a := range agents {
+		agent, ok := a.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		stats, _ := agent["probing_statistics"].(map[string]interface{})
+		for _, s := range stats {
+			stat, ok := s.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if round, ok := stat["round"].(string); ok {
+				stat["round"] = parseRound(round)
+			}
+		}
+	}
+	jsonData, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	var measurement common.Measurement
+	if err := json.Unmarshal(jsonData, &measurement); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func parseRound(round string) map[string]int {
+	r := map[string]int{"number": 0, "limit": 0, "offset": 0}
+	for _, match := range roundRe.FindAllStringSubmatch(round, -1) {
+		r[match[1]], _ = strconv.Atoi(match[2])
+	}
+	return r
+}
@@ -0,0 +1,319 @@
+// Package doctor implements a command for diagnosing the irisctl
+// environment (not in the Iris API).
+package doctor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dioptra-io/irisctl/internal/auth"
+	"github.com/dioptra-io/irisctl/internal/clickhouse"
+	"github.com/dioptra-io/irisctl/internal/common"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// Row returned by SELECT 1 in the JSONEachRow format.
+	clickhouseExpectedRow = `{"1":1}`
+)
+
+// result defines the outcome of one diagnostic check.
+type result struct {
+	name    string
+	ok      bool
+	skipped bool
+	warning bool
+	detail  string
+	hint    string
+}
+
+var (
+	// Command, its flags, subcommands, and their flags.
+	//      doctor (has no flags)
+	cmdName     = "doctor"
+	subcmdNames = []string{}
+
+	// Errors.
+	ErrChecksFailed     = errors.New("one or more checks failed")
+	ErrUnexpectedResult = errors.New("unexpected query result")
+
+	// Test code changes Fatal to Panic so a fatal error won't exit
+	// the process and can be recovered.
+	fatal    = log.Fatal
+	cliFatal = common.CliFatal
+	verbose  = common.Verbose
+)
+
+// DoctorCmd returns the command structure for doctor.
+func DoctorCmd() *cobra.Command {
+	doctorCmd := &cobra.Command{
+		Use:       cmdName,
+		ValidArgs: subcmdNames,
+		Short:     "diagnose the environment",
+		Long:      "check external tools, configuration, credentials, Iris API, ClickHouse, and temporary directory",
+		Args:      doctorArgs,
+		Run:       doctor,
+	}
+	doctorCmd.SetUsageFunc(common.Usage)
+	doctorCmd.SetHelpFunc(common.Help)
+
+	return doctorCmd
+}
+
+func doctorArgs(cmd *cobra.Command, args []string) error {
+	if _, ok := common.IsUsage(args); ok {
+		return nil
+	}
+	if len(args) != 0 {
+		cliFatal("doctor does not take any arguments")
+	}
+	return nil
+}
+
+func doctor(cmd *cobra.Command, args []string) {
+	var results []result
+	results = append(results, checkTool("curl"))
+	results = append(results, checkSSH())
+	credentials, user := checkCredentials()
+	results = append(results, credentials)
+	results = append(results, checkCredentialHelper(user))
+	results = append(results, checkProjects())
+	jwt := checkAccessToken()
+	results = append(results, jwt)
+	results = append(results, checkAPI())
+	results = append(results, checkClickHouse(jwt.ok && !jwt.warning))
+	results = append(results, checkTempDir())
+
+	failed := false
+	for _, r := range results {
+		status := "PASS"
+		switch {
+		case r.skipped:
+			status = "SKIP"
+		case r.warning:
+			status = "WARN"
+		case !r.ok:
+			status = "FAIL"
+			failed = true
+		}
+		fmt.Printf("%-4s  %-20s %s\n", status, r.name, r.detail)
+		if (!r.ok || r.warning) && r.hint != "" {
+			fmt.Printf("      %-20s hint: %s\n", "", r.hint)
+		}
+	}
+	if failed {
+		fatal(ErrChecksFailed)
+	}
+}
+
+func checkTool(tool string) result {
+	r := result{name: tool}
+	path, err := exec.LookPath(tool)
+	if err != nil {
+		r.detail = "not found in PATH"
+		r.hint = fmt.Sprintf("install %s", tool)
+		if tool == "gcloud" {
+			r.hint += " (only needed by the check command)"
+		}
+		return r
+	}
+	r.ok = true
+	r.detail = path
+	return r
+}
+
+// checkSSH checks that the SSH backend of the configuration file can
+// run commands on agents.
+func checkSSH() result {
+	backend, err := common.SSHBackend()
+	if err != nil {
+		return result{name: "ssh", detail: err.Error(), hint: "set ssh-backend to gcloud or native in the configuration file"}
+	}
+	if backend == common.SSHBackendGcloud {
+		return checkTool("gcloud")
+	}
+	return result{name: "ssh", ok: true, detail: "native SSH backend"}
+}
+
+// checkCredentials returns the result of the check and the user name
+// if it was found.
+func checkCredentials() (result, string) {
+	r := result{name: "credentials"}
+	if user := common.RootFlagString("username"); user != "" {
+		r.ok = true
+		r.detail = fmt.Sprintf("user %s (configuration file)", user)
+		return r, user
+	}
+	irisHome, err := common.IrisDir()
+	if err != nil {
+		r.detail = err.Error()
+		return r, ""
+	}
+	credentialsFile := fmt.Sprintf("%s/credentials", irisHome)
+	contents, err := os.ReadFile(credentialsFile)
+	if err != nil {
+		r.detail = err.Error()
+		r.hint = fmt.Sprintf("write your Iris user name (e.g., joe.blow@lip6.fr) in %s", credentialsFile)
+		return r, ""
+	}
+	for _, line := range strings.Split(string(contents), "\n") {
+		if line != "" && !strings.HasPrefix(line, "#") {
+			r.ok = true
+			r.detail = fmt.Sprintf("user %s", line)
+			return r, line
+		}
+	}
+	r.detail = fmt.Sprintf("no user name in %s", credentialsFile)
+	r.hint = fmt.Sprintf("write your Iris user name (e.g., joe.blow@lip6.fr) in %s", credentialsFile)
+	return r, ""
+}
+
+func checkCredentialHelper(user string) result {
+	r := result{name: "credential helper"}
+	store, err := common.NewCredentialStore()
+	if err != nil {
+		r.detail = err.Error()
+		r.hint = "check --credential-helper"
+		return r
+	}
+	r.ok = true
+	if user == "" {
+		r.skipped = true
+		r.detail = fmt.Sprintf("%s (skipped because there is no user name)", store.Name())
+		return r
+	}
+	if _, err := store.Get(user); err != nil {
+		r.detail = fmt.Sprintf("%s (no stored password, run irisctl auth credentials set to store one)", store.Name())
+		return r
+	}
+	r.detail = fmt.Sprintf("%s (password stored)", store.Name())
+	return r
+}
+
+func checkProjects() result {
+	r := result{name: "projects"}
+	projects, err := common.ReadProjects()
+	if errors.Is(err, os.ErrNotExist) {
+		r.ok = true
+		r.skipped = true
+		r.detail = "no projects file"
+		return r
+	}
+	if err != nil {
+		r.detail = err.Error()
+		r.hint = "fix the projects file; its format is:" + strings.ReplaceAll(common.ProjectsFile, "\n", " ")
+		return r
+	}
+	r.ok = true
+	r.detail = fmt.Sprintf("%d project(s)", len(projects))
+	return r
+}
+
+// checkAccessToken checks that the access token has not expired.  An
+// expired access token is only a warning if there is a refresh token
+// because the next command will refresh it.
+func checkAccessToken() result {
+	r := result{name: "access token"}
+	accessTokenFile, err := common.AccessTokenFile()
+	if err != nil {
+		r.detail = err.Error()
+		return r
+	}
+	accessToken, err := os.ReadFile(accessTokenFile)
+	if err != nil {
+		r.detail = err.Error()
+		r.hint = "run irisctl auth login"
+		return r
+	}
+	expiry, err := auth.AccessTokenExpiry(string(accessToken))
+	if err != nil {
+		r.detail = err.Error()
+		r.hint = "run irisctl auth login"
+		return r
+	}
+	if left := time.Until(expiry); left > 0 {
+		r.ok = true
+		r.detail = fmt.Sprintf("valid for %v", left.Round(time.Second))
+		return r
+	}
+	r.detail = fmt.Sprintf("expired %v ago", time.Since(expiry).Round(time.Second))
+	r.hint = "run irisctl auth login"
+	if _, err := os.Stat(auth.RefreshTokenFile(accessTokenFile)); err == nil {
+		r.ok = true
+		r.warning = true
+		r.detail += ", will refresh"
+		r.hint = "the next command will refresh it with the refresh token"
+	}
+	return r
+}
+
+func checkAPI() result {
+	r := result{name: "iris api"}
+	url := common.APIEndpoint(common.StatusAPISuffix) + "/"
+	jsonData, err := common.Curl("", false, "GET", url)
+	if err != nil {
+		r.detail = fmt.Sprintf("%s: %v", url, err)
+		r.hint = "check your network connection and --iris-api-url"
+		return r
+	}
+	var status map[string]interface{}
+	if err := json.Unmarshal(jsonData, &status); err != nil {
+		r.detail = fmt.Sprintf("%s: unexpected response", url)
+		r.hint = "check --iris-api-url"
+		return r
+	}
+	r.ok = true
+	r.detail = common.RootFlagString("iris-api-url")
+	return r
+}
+
+func checkClickHouse(haveToken bool) result {
+	r := result{name: "clickhouse"}
+	if !haveToken && os.Getenv("IRIS_PASSWORD") == "" {
+		// Do not prompt for a password.
+		r.ok = true
+		r.skipped = true
+		r.detail = "skipped because there is no valid access token"
+		return r
+	}
+	filename, output, err := clickhouse.RunQueryString("SELECT 1 FORMAT JSONEachRow")
+	if filename != "" {
+		defer os.Remove(filename)
+	}
+	if err == nil {
+		var contents []byte
+		if contents, err = os.ReadFile(filename); err == nil && strings.TrimSpace(string(contents)) != clickhouseExpectedRow {
+			err = fmt.Errorf("%w: %q", ErrUnexpectedResult, strings.TrimSpace(string(contents)))
+		}
+	}
+	if err != nil {
+		r.detail = fmt.Sprintf("%v %s", err, strings.TrimSpace(output))
+		r.hint = "check --meas-uuid, which is used to get ClickHouse credentials"
+		return r
+	}
+	r.ok = true
+	r.detail = "query succeeded"
+	return r
+}
+
+func checkTempDir() result {
+	r := result{name: "temp directory"}
+	f, err := common.CreateTemp("irisctl-doctor-")
+	if err != nil {
+		r.detail = err.Error()
+		r.hint = fmt.Sprintf("make %s writable and remove irisctl directories of other users from it", os.TempDir())
+		return r
+	}
+	f.Close()
+	os.Remove(f.Name())
+	r.ok = true
+	r.detail = fmt.Sprintf("%s is writable", filepath.Dir(f.Name()))
+	return r
+}
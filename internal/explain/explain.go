@@ -0,0 +1,92 @@
+// Package explain implements a command for explaining the warnings of
+// irisctl (not in the Iris API).
+package explain
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/dioptra-io/irisctl/internal/common"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Command, its flags, subcommands, and their flags.
+	//	explain [<warning-code>...]
+	cmdName     = "explain"
+	subcmdNames = []string{}
+
+	// Errors.
+	ErrUnknownWarning = errors.New("unknown warning code")
+
+	// Test code changes Fatal to Panic so a fatal error won't exit
+	// the process and can be recovered.
+	fatal    = log.Fatal
+	cliFatal = common.CliFatal
+)
+
+// ExplainCmd returns the command structure for explain.
+func ExplainCmd() *cobra.Command {
+	explainCmd := &cobra.Command{
+		Use:       cmdName,
+		ValidArgs: subcmdNames,
+		Short:     "explain warnings",
+		Long:      "explain the rationale, typical causes, and suggested next commands of the warnings with the specified codes (e.g., WARNING [too-long]), or list all warning codes",
+		Args:      explainArgs,
+		Run:       explain,
+	}
+	explainCmd.SetUsageFunc(common.Usage)
+	explainCmd.SetHelpFunc(common.Help)
+
+	return explainCmd
+}
+
+func explainArgs(cmd *cobra.Command, args []string) error {
+	if format, ok := common.IsUsage(args); ok {
+		fmt.Printf(format, "<warning-code>...", "zero or more warning codes (default: list all codes)")
+		return nil
+	}
+	for _, arg := range args {
+		if _, ok := common.Warnings[warningCode(arg)]; !ok {
+			cliFatal(fmt.Sprintf("%s: %v (see irisctl explain)", arg, ErrUnknownWarning))
+		}
+	}
+	return nil
+}
+
+func explain(cmd *cobra.Command, args []string) {
+	if len(args) == 0 {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, code := range common.WarningCodes() {
+			fmt.Fprintf(w, "%s\t%s\n", code, common.Warnings[code].Summary)
+		}
+		w.Flush()
+		return
+	}
+	for i, arg := range args {
+		if i > 0 {
+			fmt.Println()
+		}
+		code := warningCode(arg)
+		info := common.Warnings[code]
+		fmt.Printf("%s: %s\n\n%s\n", code, info.Summary, info.Rationale)
+		fmt.Printf("\ntypical causes:\n")
+		for _, cause := range info.Causes {
+			fmt.Printf("  - %s\n", cause)
+		}
+		fmt.Printf("\nnext commands:\n")
+		for _, next := range info.Next {
+			fmt.Printf("  %s\n", next)
+		}
+	}
+}
+
+// warningCode returns the code of arg, which may be copied from a
+// warning with its brackets (e.g., [too-long]).
+func warningCode(arg string) string {
+	return strings.ToLower(strings.Trim(arg, "[]:"))
+}
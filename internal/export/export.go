@@ -0,0 +1,165 @@
+// Package export implements commands for exporting the current state
+// of an Iris instance (not in the Iris API).
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dioptra-io/irisctl/internal/agents"
+	"github.com/dioptra-io/irisctl/internal/apply"
+	"github.com/dioptra-io/irisctl/internal/common"
+	"github.com/dioptra-io/irisctl/internal/meas"
+	"github.com/dioptra-io/irisctl/internal/users"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	// Command, its flags, subcommands, and their flags.
+	//	export <subcommand>
+	//	export state [--every <duration>]
+	cmdName     = "export"
+	subcmdNames = []string{"state"}
+	fStateEvery time.Duration
+
+	// Test code changes Fatal to Panic so a fatal error won't exit
+	// the process and can be recovered.
+	fatal    = log.Fatal
+	cliFatal = common.CliFatal
+	verbose  = common.Verbose
+)
+
+// ExportCmd returns the command structure for export.
+func ExportCmd() *cobra.Command {
+	exportCmd := &cobra.Command{
+		Use:       cmdName,
+		ValidArgs: subcmdNames,
+		Short:     "export commands",
+		Long:      "commands for exporting the current state of the Iris instance",
+		Args:      exportArgs,
+		Run:       export,
+	}
+	exportCmd.SetUsageFunc(common.Usage)
+	exportCmd.SetHelpFunc(common.Help)
+
+	// export state and its flags
+	stateSubcmd := &cobra.Command{
+		Use:   "state",
+		Short: "export the current state in the format of apply",
+		Long:  "export the current users, agent tags, and recurring measurements in the YAML format of the desired state of apply",
+		Args:  exportStateArgs,
+		Run:   exportState,
+	}
+	stateSubcmd.Flags().DurationVar(&fStateEvery, "every", 24*time.Hour, "period of recurring measurements that have been requested only once")
+	exportCmd.AddCommand(stateSubcmd)
+
+	return exportCmd
+}
+
+func exportArgs(cmd *cobra.Command, args []string) error {
+	if _, ok := common.IsUsage(args); ok {
+		return nil
+	}
+	if len(args) == 0 {
+		cliFatal("export requires one of these subcommands: ", strings.Join(subcmdNames, " "))
+	}
+	cliFatal("unknown subcommand: ", args[0])
+	return nil
+}
+
+func export(cmd *cobra.Command, args []string) {
+	fatal("export()")
+}
+
+func exportStateArgs(cmd *cobra.Command, args []string) error {
+	if _, ok := common.IsUsage(args); ok {
+		return nil
+	}
+	if len(args) != 0 {
+		cliFatal("export state does not take any arguments")
+	}
+	if fStateEvery <= 0 {
+		cliFatal("--every must be positive")
+	}
+	return nil
+}
+
+func exportState(cmd *cobra.Command, args []string) {
+	state, err := currentState()
+	if err != nil {
+		fatal(err)
+	}
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(state); err != nil {
+		fatal(err)
+	}
+	data := buf.Bytes()
+	if common.RootFlagBool("stdout") {
+		fmt.Print(string(data))
+		return
+	}
+	f, err := common.CreateTemp("irisctl-export-state-*.yaml")
+	if err != nil {
+		fatal(err)
+	}
+	defer f.Close()
+	common.SavingIn(f.Name())
+	if _, err := f.Write(data); err != nil {
+		fatal(err)
+	}
+}
+
+// currentState returns the current state of the Iris instance in the
+// format of the desired state of apply.  Only the tags of agents are
+// exported because their other parameters cannot be managed by apply.
+func currentState() (apply.DesiredState, error) {
+	var state apply.DesiredState
+	var err error
+	verbose("exporting users\n")
+	if state.Users, err = users.DesiredUsers(); err != nil {
+		return state, err
+	}
+
+	verbose("exporting agents\n")
+	jsonData, err := agents.GetAgents("", false)
+	if err != nil {
+		return state, err
+	}
+	var agentsData common.AgentsData
+	if err := common.DecodeJSON(jsonData, &agentsData); err != nil {
+		return state, err
+	}
+	for _, result := range agentsData.Results {
+		state.Agents = append(state.Agents, apply.DesiredAgent{
+			Hostname: result.Parameters.Hostname,
+			Tags:     result.Parameters.Tags,
+		})
+	}
+	sort.Slice(state.Agents, func(i, j int) bool { return state.Agents[i].Hostname < state.Agents[j].Hostname })
+
+	verbose("exporting recurring measurements\n")
+	recurring, err := meas.GetRecurringMeasurements(fStateEvery)
+	if err != nil {
+		return state, err
+	}
+	for _, r := range recurring {
+		var request map[string]interface{}
+		if err := json.Unmarshal(r.Request, &request); err != nil {
+			return state, err
+		}
+		state.Measurements = append(state.Measurements, apply.DesiredMeasurement{
+			Name:    r.Name,
+			Every:   r.Every.String(),
+			Request: request,
+		})
+	}
+	return state, nil
+}
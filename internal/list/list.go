@@ -9,6 +9,7 @@ import (
 	"time"
 
 	//"github.com/dioptra-io/irisctl/internal/auth"
+	"github.com/dioptra-io/irisctl/internal/cache"
 	"github.com/dioptra-io/irisctl/internal/common"
 	"github.com/dioptra-io/irisctl/internal/meas"
 	"github.com/spf13/cobra"
@@ -21,8 +22,8 @@ const (
 
 var (
 	// Command, its flags, subcommands, and their flags.
-	//      list [--bq] [--all-users] [--before <yyyy-mm-ddThh:mm:ss>] [--after <yyyy-mm-ddThh:mm:ss>] [--state <state>]... [--tag <tag>]... [--tags-and] \
-	//		[--agent <agent-hostname>...] [<meas-md-file>]
+	//      list [--bq] [--all-users] [--before <yyyy-mm-ddThh:mm:ss>] [--after <yyyy-mm-ddThh:mm:ss>] [--since <time>] [--state <state>]... [--tag <tag>]... [--tags-and] \
+	//		[--agent <agent-hostname>...] [--project <project>]... [--format text|parquet] [--output <file>] [--fields <field>,...] [<meas-md-file>]
 	//      list [--bq] --uuid <meas_uuid>...
 	cmdName       = "list"
 	subcmdNames   = []string{}
@@ -35,6 +36,12 @@ var (
 	fListTagsAnd  bool
 	fListAgents   []string
 	fListUUID     bool
+	fListProject  []string
+	fListFormat   string
+	fListOutput   string
+	fListFields   []string
+
+	listProjectUserIDs []string
 
 	// Errors.
 	ErrInvalidTableName = errors.New("invalid table name")
@@ -73,13 +80,18 @@ func ListCmd() *cobra.Command {
 	}
 	listCmd.Flags().BoolVar(&fListAllUsers, "all-users", false, "match all measurements of all users (admin only)")
 	listCmd.Flags().BoolVar(&fListBQFormat, "bq", false, "generate output suitable for inserting into BigQuery table")
-	listCmd.Flags().Var(&fListBefore, "before", "match measurements before the specified date (exclusive)")
-	listCmd.Flags().Var(&fListAfter, "after", "match measurements after the specified date (inclusive)")
+	listCmd.Flags().Var(&fListBefore, "before", "match measurements before the specified date or relative time such as 24h, 7d, today, or yesterday (exclusive)")
+	listCmd.Flags().Var(&fListAfter, "after", "match measurements after the specified date or relative time such as 24h, 7d, today, or yesterday (inclusive)")
+	listCmd.Flags().Var(&fListAfter, "since", "same as --after (e.g., --since 24h)")
 	listCmd.Flags().StringArrayVarP(&fListState, "state", "s", []string{}, "repeatable: match measurements with the specified state (agent_failure, canceled, finished, ongoing)")
 	listCmd.Flags().StringArrayVarP(&fListTag, "tag", "t", []string{}, "repeatable: match measurements with the specified tag (also see --tags-and)")
 	listCmd.Flags().BoolVar(&fListTagsAnd, "tags-and", false, "match measurements that have all specified tags")
 	listCmd.Flags().StringArrayVarP(&fListAgents, "agent", "a", []string{}, "repeatable: match measurements that ran on the specified agent")
 	listCmd.Flags().BoolVarP(&fListUUID, "uuid", "", false, "list measurements with the specified UUIDs")
+	listCmd.Flags().StringVar(&fListFormat, "format", "text", "output format: text or parquet")
+	listCmd.Flags().StringVar(&fListOutput, "output", "measurements.parquet", "output file or gs:// or s3:// URL for --format parquet")
+	listCmd.Flags().StringSliceVar(&fListFields, "fields", []string{}, "comma-separated fields of measurements to get from the API (e.g., uuid,state,tags,start_time,end_time); other fields are listed as empty")
+	listCmd.Flags().StringArrayVar(&fListProject, "project", []string{}, "repeatable: match measurements of users in the specified local project")
 	listCmd.SetUsageFunc(common.Usage)
 	listCmd.SetHelpFunc(common.Help)
 
@@ -98,8 +110,11 @@ func listArgs(cmd *cobra.Command, args []string) error {
 		cliFatal("list --uuid requires at least one argument: <meas-uuid>...")
 	}
 	validateFlags()
+	if len(fListFields) > 0 && (fListUUID || len(args) > 0) {
+		cliFatal("--fields cannot be used with --uuid or a measurement metadata file")
+	}
 	if fListAllUsers && len(args) > 0 {
-		fmt.Printf("WARNING: ignoring --all-users because a measurement metadata file is specidfied\n")
+		fmt.Printf("%s ignoring --all-users because a measurement metadata file is specidfied\n", common.Warning(common.WarnAllUsersIgnored))
 		fListAllUsers = false
 	}
 	return nil
@@ -124,6 +139,12 @@ func list(cmd *cobra.Command, args []string) {
 		if err != nil {
 			fatal(err)
 		}
+		if fListFormat == "parquet" {
+			if err := writeParquet(measurements); err != nil {
+				fatal(err)
+			}
+			return
+		}
 		for _, measurement := range measurements {
 			if measSkip(measurement) {
 				continue
@@ -147,12 +168,19 @@ func getMeasurements(args []string) ([]common.Measurement, error) {
 		measMdFile = args[0]
 	} else {
 		var err error
-		measMdFile, err = meas.GetMeasMdFile(fListAllUsers)
+		measMdFile, err = meas.GetMeasMdFileFields(fListAllUsers, fListFields)
 		if err != nil {
 			return nil, err
 		}
 	}
-	return common.GetMeasurementsSorted(measMdFile)
+	measurements, err := common.GetMeasurementsSorted(measMdFile)
+	if err != nil {
+		return nil, err
+	}
+	if err := common.WarnUnmatchedTags(fListTag, measurements, cache.Tags()); err != nil {
+		return nil, err
+	}
+	return measurements, nil
 }
 
 func measSkip(measurement common.Measurement) bool {
@@ -162,6 +190,9 @@ func measSkip(measurement common.Measurement) bool {
 	if len(fListState) > 0 && !common.MatchState(measurement.State, fListState) {
 		return true
 	}
+	if len(fListProject) > 0 && !common.Contains(listProjectUserIDs, measurement.UserID) {
+		return true
+	}
 	if !measurement.CreationTime.After(fListAfter.Time) ||
 		!measurement.CreationTime.Before(fListBefore.Time) {
 		return true
@@ -210,10 +241,33 @@ func printMeasDetailsBQ(measurement common.Measurement) {
 	fmt.Printf("%d\n", agents_finished) // agents_finished
 }
 
+func writeParquet(measurements []common.Measurement) error {
+	rows := []common.MeasurementRow{}
+	for _, measurement := range measurements {
+		if !measSkip(measurement) {
+			rows = append(rows, common.NewMeasurementRow(measurement, nil))
+		}
+	}
+	common.SavingIn(fListOutput)
+	return common.WriteToSink(fListOutput, func(file string) error { return common.WriteParquet(file, rows) })
+}
+
 func validateFlags() {
+	if fListFormat != "text" && fListFormat != "parquet" {
+		cliFatal("invalid --format: ", fListFormat, " (one of these: text parquet)")
+	}
+	if fListFormat == "parquet" && (fListBQFormat || fListUUID) {
+		cliFatal("--format parquet cannot be used with --bq or --uuid")
+	}
 	if len(fListState) > 0 {
 		if s, err := common.ValidateState(fListState); err != nil {
 			cliFatal(fmt.Sprintf("%v: %v", s, err))
 		}
 	}
+	if len(fListProject) > 0 {
+		var err error
+		if listProjectUserIDs, err = common.ProjectUserIDs(fListProject); err != nil {
+			cliFatal(err)
+		}
+	}
 }
@@ -4,11 +4,13 @@ package maint
 import (
 	"fmt"
 	"log"
-	"os"
 	"strings"
+	"time"
 
 	"github.com/dioptra-io/irisctl/internal/auth"
+	"github.com/dioptra-io/irisctl/internal/cache"
 	"github.com/dioptra-io/irisctl/internal/common"
+	"github.com/dioptra-io/irisctl/internal/meas"
 	"github.com/spf13/cobra"
 )
 
@@ -17,11 +19,19 @@ var (
 	//      maint dq <queue-name>...
 	//      maint dq --post <queue-name> [<actor-string>]  (actor-string: watch_measurement_agent)
 	//      maint dq --delete <queue-name> <redis-message-id>
-	//      maint meas delete <meas-uuid>
-	cmdName     = "maint"
-	subcmdNames = []string{"dq", "meas"}
-	fDqPost     bool
-	fDqDelete   bool
+	//      maint meas delete <meas-uuid>...
+	//      maint meas [--all-users] [--before <yyyy-mm-ddThh:mm:ss>] [--after <yyyy-mm-ddThh:mm:ss>] [--state <state>]... [--tag <tag>]... [--tags-and] [--yes] delete
+	cmdName       = "maint"
+	subcmdNames   = []string{"dq", "meas"}
+	fDqPost       bool
+	fDqDelete     bool
+	fMeasAllUsers bool
+	fMeasBefore   common.CustomTime
+	fMeasAfter    common.CustomTime
+	fMeasState    []string
+	fMeasTag      []string
+	fMeasTagsAnd  bool
+	fMeasYes      bool
 
 	// Test code changes Fatal to Panic so a fatal error won't exit
 	// the process and can be recovered.
@@ -55,14 +65,21 @@ func MaintCmd() *cobra.Command {
 	dqSubcmd.Flags().BoolVar(&fDqDelete, "delete", false, "delete dramatiq queue")
 	maintCmd.AddCommand(dqSubcmd)
 
-	// maint meas delete
+	// maint meas delete and its flags
 	measSubcmd := &cobra.Command{
 		Use:   "meas",
 		Short: "delete measurement(s)",
-		Long:  "delete measurement(s) specified by measurement UUID(s)",
+		Long:  "delete measurement(s) specified by measurement UUID(s) or matching the specified filters",
 		Args:  maintMeasArgs,
 		Run:   maintMeas,
 	}
+	measSubcmd.Flags().BoolVar(&fMeasAllUsers, "all-users", false, "match all measurements of all users (admin only)")
+	measSubcmd.Flags().Var(&fMeasBefore, "before", "match measurements before the specified date or relative time such as 24h, 7d, today, or yesterday (exclusive)")
+	measSubcmd.Flags().Var(&fMeasAfter, "after", "match measurements after the specified date or relative time such as 24h, 7d, today, or yesterday (inclusive)")
+	measSubcmd.Flags().StringArrayVarP(&fMeasState, "state", "s", []string{}, "repeatable: match measurements with the specified state (agent_failure, canceled, finished, ongoing)")
+	measSubcmd.Flags().StringArrayVarP(&fMeasTag, "tag", "t", []string{}, "repeatable: match measurements with the specified tag (also see --tags-and)")
+	measSubcmd.Flags().BoolVar(&fMeasTagsAnd, "tags-and", false, "match measurements that have all specified tags")
+	measSubcmd.Flags().BoolVarP(&fMeasYes, "yes", "y", false, "do not ask for confirmation before deleting matching measurements")
 	maintCmd.AddCommand(measSubcmd)
 
 	return maintCmd
@@ -130,21 +147,51 @@ func maintDq(cmd *cobra.Command, args []string) {
 
 func maintMeasArgs(cmd *cobra.Command, args []string) error {
 	if format, ok := common.IsUsage(args); ok {
-		fmt.Printf(format, "<meas-uuid>...", "measurement UUID")
+		fmt.Printf(format, "<meas-uuid>...", "measurement UUID (not allowed with filter flags)")
 		return nil
 	}
-	if len(args) < 2 || args[0] != "delete" {
-		cliFatal("maint meas requires an explicit \"delete\" and at least one argument: <meas-uuid>...")
+	if len(args) < 1 || args[0] != "delete" {
+		cliFatal("maint meas requires an explicit \"delete\"")
 	}
-	if err := common.ValidateFormat(args[1:], common.MeasurementUUID); err != nil {
-		cliFatal(err)
+	if !hasMeasFilters() {
+		if len(args) < 2 {
+			cliFatal("maint meas delete requires at least one argument or a filter flag: <meas-uuid>...")
+		}
+		if err := common.ValidateFormat(args[1:], common.MeasurementUUID); err != nil {
+			cliFatal(err)
+		}
+		return nil
+	}
+	if len(args) > 1 {
+		cliFatal("cannot use filter flags and also specify measurement UUIDs")
+	}
+	if len(fMeasState) > 0 {
+		if s, err := common.ValidateState(fMeasState); err != nil {
+			cliFatal(fmt.Sprintf("%v: %v", s, err))
+		}
 	}
 	return nil
 }
 
 func maintMeas(cmd *cobra.Command, args []string) {
-	for _, arg := range args[1:] {
-		if err := deleteMaintenanceMeas(arg); err != nil {
+	measUUIDs := args[1:]
+	if hasMeasFilters() {
+		var err error
+		measUUIDs, err = matchingMeasurements()
+		if err != nil {
+			fatal(err)
+		}
+		if len(measUUIDs) == 0 {
+			fmt.Println("no measurements matched the specified filters")
+			return
+		}
+		if !fMeasYes && !common.Confirm(fmt.Sprintf("delete %d measurement(s)?", len(measUUIDs))) {
+			fmt.Println("aborted")
+			return
+		}
+	}
+	for _, measUUID := range measUUIDs {
+		if err := deleteMaintenanceMeas(measUUID); err != nil {
 			fatal(err)
 		}
 	}
@@ -165,13 +212,52 @@ func deleteMaintenanceDq(queue, redisMsgId string) error {
 	return nil
 }
 
+func hasMeasFilters() bool {
+	return len(fMeasState) > 0 || len(fMeasTag) > 0 || !fMeasBefore.IsZero() || !fMeasAfter.IsZero()
+}
+
+// matchingMeasurements returns the UUIDs of measurements that match
+// the filter flags after printing them as the deletion plan.
+func matchingMeasurements() ([]string, error) {
+	measMdFile, err := meas.GetMeasMdFile(fMeasAllUsers)
+	if err != nil {
+		return nil, err
+	}
+	measurements, err := common.GetMeasurementsSorted(measMdFile)
+	if err != nil {
+		return nil, err
+	}
+	if err := common.WarnUnmatchedTags(fMeasTag, measurements, cache.Tags()); err != nil {
+		return nil, err
+	}
+	var measUUIDs []string
+	for _, measurement := range measurements {
+		if len(fMeasTag) > 0 && !common.MatchTag(measurement.Tags, fMeasTag, fMeasTagsAnd) {
+			continue
+		}
+		if len(fMeasState) > 0 && !common.MatchState(measurement.State, fMeasState) {
+			continue
+		}
+		if !fMeasAfter.IsZero() && measurement.CreationTime.Before(fMeasAfter.Time) {
+			continue
+		}
+		if !fMeasBefore.IsZero() && !measurement.CreationTime.Before(fMeasBefore.Time) {
+			continue
+		}
+		c := time.Time(measurement.CreationTime.Time)
+		fmt.Printf("%s %-13s %s %q\n", measurement.UUID, measurement.State, c.Format("06-01-02.15:04:05"), measurement.Tags)
+		measUUIDs = append(measUUIDs, measurement.UUID)
+	}
+	return measUUIDs, nil
+}
+
 func deleteMaintenanceMeas(measUUID string) error {
-	f, err := os.CreateTemp("/tmp", "irisctl-maint-meas-delete-")
+	f, err := common.CreateTemp("irisctl-maint-meas-delete-")
 	if err != nil {
 		return err
 	}
 	defer f.Close()
-	fmt.Fprintf(os.Stderr, "saving in %s\n", f.Name())
+	common.SavingIn(f.Name())
 
 	url := fmt.Sprintf("%s/measurements/%s", common.APIEndpoint((common.MaintenanceAPISuffix)), measUUID)
 	jsonData, err := common.Curl(auth.GetAccessToken(), false, "DELETE", url)
@@ -0,0 +1,98 @@
+package meas
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/dioptra-io/irisctl/internal/auth"
+	"github.com/dioptra-io/irisctl/internal/common"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// CloneTagPrefix is the prefix of the tag that records the UUID
+	// of the original measurement of a cloned measurement.
+	CloneTagPrefix = "clone:"
+)
+
+var (
+	ErrNoAgents = errors.New("measurement has no agents")
+)
+
+func measCloneArgs(cmd *cobra.Command, args []string) error {
+	if format, ok := common.IsUsage(args); ok {
+		fmt.Printf(format, "<meas-uuid>", "measurement UUID")
+		return nil
+	}
+	if len(args) != 1 {
+		cliFatal("meas clone requires exactly one argument: <meas-uuid>")
+	}
+	if err := common.ValidateFormat(args, common.MeasurementUUID); err != nil {
+		cliFatal(err)
+	}
+	return nil
+}
+
+func measClone(cmd *cobra.Command, args []string) {
+	measurement, err := GetMeasurementAllDetails(args[0])
+	if err != nil {
+		fatal(err)
+	}
+	request, err := cloneRequest(measurement, fCloneTags)
+	if err != nil {
+		fatal(err)
+	}
+	data, err := json.MarshalIndent(request, "", "  ")
+	if err != nil {
+		fatal(err)
+	}
+	if fCloneDryRun {
+		fmt.Printf("dry-run: would request:\n%s\n", data)
+		return
+	}
+	jsonData, err := postMeasurement(data)
+	if err != nil {
+		fmt.Println(string(jsonData))
+		fatal(err)
+	}
+	if err := common.SaveOrPrint(jsonData, "irisctl-meas-clone-"); err != nil {
+		fatal(err)
+	}
+}
+
+// cloneRequest returns a request for the same measurement on the same
+// agents.  If tags is not empty, it replaces the tags of the original
+// measurement.  The tag CloneTagPrefix<uuid> is always added.
+func cloneRequest(measurement common.Measurement, tags []string) (MeasurementRequest, error) {
+	if len(tags) == 0 {
+		tags = measurement.Tags
+	}
+	request := MeasurementRequest{
+		Tool: measurement.Tool,
+		Tags: append(append([]string{}, tags...), CloneTagPrefix+measurement.UUID),
+	}
+	if len(measurement.Agents) == 0 {
+		return request, fmt.Errorf("%s: %w", measurement.UUID, ErrNoAgents)
+	}
+	for _, a := range measurement.Agents {
+		request.Agents = append(request.Agents, MeasurementRequestAgent{
+			UUID:           a.AgentUUID,
+			TargetFile:     a.TargetFile,
+			BatchSize:      a.BatchSize,
+			ProbingRate:    a.ProbingRate,
+			ToolParameters: a.ToolParameters,
+		})
+	}
+	return request, nil
+}
+
+// postMeasurement requests the measurement defined by data (a JSON
+// MeasurementRequest) and returns the response.
+func postMeasurement(data []byte) ([]byte, error) {
+	url := fmt.Sprintf("%s/", common.APIEndpoint(common.MeasurementsAPISuffix))
+	return common.Curl(auth.GetAccessToken(), false, "POST", url,
+		"-H", "Content-Type: application/json",
+		"-d", string(data),
+	)
+}
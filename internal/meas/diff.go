@@ -0,0 +1,196 @@
+package meas
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dioptra-io/irisctl/internal/common"
+	"github.com/spf13/cobra"
+)
+
+// diffMetric defines a per-agent metric compared by meas diff.
+type diffMetric struct {
+	name string
+	// higherIsBetter is true if a decrease is a regression and false
+	// if an increase is a regression.
+	higherIsBetter bool
+	value          func(agent common.Agent, rows map[string]int, measUUID string) (int, bool)
+}
+
+var (
+	diffMetrics = []diffMetric{
+		{"rounds", true, func(a common.Agent, _ map[string]int, _ string) (int, bool) { return getAgentTotals(a).rounds, true }},
+		{"packets sent", true, func(a common.Agent, _ map[string]int, _ string) (int, bool) { return getAgentTotals(a).sent, true }},
+		{"packets received", true, func(a common.Agent, _ map[string]int, _ string) (int, bool) { return getAgentTotals(a).received, true }},
+		{"pcap dropped", false, func(a common.Agent, _ map[string]int, _ string) (int, bool) { return getAgentTotals(a).dropped, true }},
+		{"results rows", true, tableRows(0)},
+		{"prefixes rows", true, tableRows(1)},
+		{"links rows", true, tableRows(2)},
+		{"probes rows", true, tableRows(3)},
+	}
+)
+
+// tableRows returns a metric function for the row count of the i-th
+// table of TableNames.
+func tableRows(i int) func(common.Agent, map[string]int, string) (int, bool) {
+	return func(a common.Agent, rows map[string]int, measUUID string) (int, bool) {
+		if rows == nil {
+			return 0, false
+		}
+		n, ok := rows[TableNames(measUUID, a.AgentUUID)[i]]
+		return n, ok
+	}
+}
+
+func measDiffArgs(cmd *cobra.Command, args []string) error {
+	if format, ok := common.IsUsage(args); ok {
+		fmt.Printf(format, "<meas-uuid-a> <meas-uuid-b>", "measurement UUIDs to compare (b is compared to a)")
+		return nil
+	}
+	if len(args) != 2 {
+		cliFatal("meas diff requires exactly two arguments: <meas-uuid-a> <meas-uuid-b>")
+	}
+	if err := common.ValidateFormat(args, common.MeasurementUUID); err != nil {
+		cliFatal(err)
+	}
+	if fDiffThreshold <= 0 || fDiffThreshold > 100 {
+		cliFatal("--threshold must be between 0 and 100")
+	}
+	return nil
+}
+
+func measDiff(cmd *cobra.Command, args []string) {
+	var measurements [2]common.Measurement
+	var rows [2]map[string]int
+	for i, uuid := range args {
+		measurement, err := GetMeasurementAllDetails(uuid)
+		if err != nil {
+			fatal(err)
+		}
+		measurements[i] = measurement
+		if rows[i], err = TableRowCounts(uuid); err != nil {
+			fmt.Fprintf(os.Stderr, "%s %s: table row counts not available: %v\n", common.Warning(common.WarnNoRowCounts), uuid, err)
+			rows[i] = nil
+		}
+	}
+	n := printDiff(os.Stdout, measurements[0], measurements[1], rows[0], rows[1], fDiffThreshold)
+	if n > 0 {
+		fmt.Printf("\n%d regression(s) of at least %.0f%%\n", n, fDiffThreshold)
+	}
+}
+
+// printDiff prints the differences between measurements a and b and
+// returns the number of regressions (i.e., metrics of b that are worse
+// than those of a by at least threshold percent).
+func printDiff(out io.Writer, a, b common.Measurement, rowsA, rowsB map[string]int, threshold float64) int {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "\ta\tb\n")
+	fmt.Fprintf(w, "measurement\t%s\t%s\n", a.UUID, b.UUID)
+	fmt.Fprintf(w, "tool\t%s\t%s\n", a.Tool, b.Tool)
+	fmt.Fprintf(w, "state\t%s\t%s\n", a.State, b.State)
+	fmt.Fprintf(w, "created\t%s\t%s\n", summaryTime(a.CreationTime.Time), summaryTime(b.CreationTime.Time))
+	fmt.Fprintf(w, "duration\t%s\t%s\n", measDuration(a), measDuration(b))
+	w.Flush()
+	if added, removed := diffStrings(a.Tags, b.Tags); len(added)+len(removed) > 0 {
+		fmt.Fprintf(out, "tags: added %q, removed %q\n", added, removed)
+	}
+
+	agentsA := make(map[string]common.Agent)
+	for _, agent := range a.Agents {
+		agentsA[agentName(agent)] = agent
+	}
+	agentsB := make(map[string]common.Agent)
+	var namesA, namesB []string
+	for _, agent := range a.Agents {
+		namesA = append(namesA, agentName(agent))
+	}
+	for _, agent := range b.Agents {
+		agentsB[agentName(agent)] = agent
+		namesB = append(namesB, agentName(agent))
+	}
+	added, removed := diffStrings(namesA, namesB)
+	for _, name := range removed {
+		fmt.Fprintf(out, "agent %s: only in a\n", name)
+	}
+	for _, name := range added {
+		fmt.Fprintf(out, "agent %s: only in b\n", name)
+	}
+
+	fmt.Fprintf(out, "\n")
+	nRegressions := 0
+	w = tabwriter.NewWriter(out, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(w, "agent\tmetric\ta\tb\tchange\t\t\n")
+	for _, name := range namesA {
+		agentB, ok := agentsB[name]
+		if !ok {
+			continue
+		}
+		agentA := agentsA[name]
+		if agentA.State != agentB.State {
+			fmt.Fprintf(w, "%s\tstate\t%s\t%s\t\t\t\n", name, agentA.State, agentB.State)
+		}
+		for _, m := range diffMetrics {
+			va, okA := m.value(agentA, rowsA, a.UUID)
+			vb, okB := m.value(agentB, rowsB, b.UUID)
+			if !okA || !okB {
+				continue
+			}
+			change, regression := compareMetric(va, vb, m.higherIsBetter, threshold)
+			flag := ""
+			if regression {
+				flag = "REGRESSION"
+				nRegressions++
+			}
+			fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%s\t%s\t\n", name, m.name, va, vb, change, flag)
+		}
+	}
+	w.Flush()
+	return nRegressions
+}
+
+// compareMetric returns the relative change from a to b and whether it
+// is a regression of at least threshold percent.
+func compareMetric(a, b int, higherIsBetter bool, threshold float64) (string, bool) {
+	if a == b {
+		return "=", false
+	}
+	worse := (b < a) == higherIsBetter
+	if a == 0 {
+		return "new", worse
+	}
+	pct := 100 * float64(b-a) / float64(a)
+	abs := pct
+	if abs < 0 {
+		abs = -abs
+	}
+	return fmt.Sprintf("%+.1f%%", pct), worse && abs >= threshold
+}
+
+// diffStrings returns the strings of b that are not in a and the
+// strings of a that are not in b.
+func diffStrings(a, b []string) ([]string, []string) {
+	var added, removed []string
+	for _, s := range b {
+		if !common.Contains(a, s) {
+			added = append(added, s)
+		}
+	}
+	for _, s := range a {
+		if !common.Contains(b, s) {
+			removed = append(removed, s)
+		}
+	}
+	return added, removed
+}
+
+// measDuration returns the duration of the measurement or "-" if it
+// has not started or ended.
+func measDuration(measurement common.Measurement) string {
+	if measurement.StartTime.IsZero() || measurement.EndTime.IsZero() {
+		return "-"
+	}
+	return measurement.EndTime.Sub(measurement.StartTime.Time).Round(time.Second).String()
+}
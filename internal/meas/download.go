@@ -0,0 +1,135 @@
+package meas
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/dioptra-io/irisctl/internal/common"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// Interval between progress reports of a table download.
+	downloadProgressInterval = 5 * time.Second
+)
+
+var (
+	// DownloadTable downloads a ClickHouse table to a file in the
+	// specified ClickHouse format.  It is set by the main package to
+	// avoid an import cycle with the clickhouse package.
+	DownloadTable func(table, format, file string) error
+
+	// Download formats and the corresponding ClickHouse formats and
+	// file extensions.
+	downloadFormats = map[string]struct{ clickhouse, ext string }{
+		"csv":    {"CSVWithNames", ".csv"},
+		"native": {"Native", ".native"},
+	}
+	downloadTableKinds = []string{"results", "links", "prefixes", "probes"}
+
+	// Errors.
+	ErrNoTables = errors.New("no tables found")
+)
+
+func measDownloadArgs(cmd *cobra.Command, args []string) error {
+	if format, ok := common.IsUsage(args); ok {
+		fmt.Printf(format, "<meas-uuid>", "measurement UUID")
+		return nil
+	}
+	if len(args) != 1 {
+		cliFatal("meas download requires exactly one argument: <meas-uuid>")
+	}
+	if err := common.ValidateFormat(args, common.MeasurementUUID); err != nil {
+		cliFatal(err)
+	}
+	if _, ok := downloadFormats[fDownloadFormat]; !ok {
+		cliFatal("--format must be csv or native")
+	}
+	return nil
+}
+
+func measDownload(cmd *cobra.Command, args []string) {
+	if err := downloadMeasurement(args[0], fDownloadFormat, fDownloadOutputDir); err != nil {
+		fatal(err)
+	}
+}
+
+// downloadMeasurement downloads the results, links, prefixes, and
+// probes tables of the measurement to outputDir/<meas-uuid>.  Tables
+// that were already downloaded are skipped, so an interrupted download
+// can be resumed by running it again.
+func downloadMeasurement(measUUID, format, outputDir string) error {
+	rows, err := TableRowCounts(measUUID)
+	if err != nil {
+		return err
+	}
+	var tables []string
+	for table := range rows {
+		c, err := common.ClassifyTable(table)
+		if err == nil && c.Canonical(table) && common.Contains(downloadTableKinds, c.Type) {
+			tables = append(tables, table)
+		}
+	}
+	if len(tables) == 0 {
+		return fmt.Errorf("%s: %w", measUUID, ErrNoTables)
+	}
+	sort.Strings(tables)
+
+	measDir := filepath.Join(outputDir, measUUID)
+	if err := os.MkdirAll(measDir, 0755); err != nil {
+		return err
+	}
+	f := downloadFormats[format]
+	for i, table := range tables {
+		file := filepath.Join(measDir, table+f.ext)
+		if _, err := os.Stat(file); err == nil {
+			fmt.Fprintf(os.Stderr, "[%d/%d] %s: already downloaded\n", i+1, len(tables), table)
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "[%d/%d] %s: downloading %d rows\n", i+1, len(tables), table, rows[table])
+		start := time.Now()
+		// Download to a temporary file first so a partial download
+		// is not mistaken for a complete one when resuming.
+		partFile := file + ".part"
+		if err := downloadWithProgress(table, f.clickhouse, partFile, fmt.Sprintf("[%d/%d] %s", i+1, len(tables), table)); err != nil {
+			os.Remove(partFile)
+			return err
+		}
+		if err := os.Rename(partFile, file); err != nil {
+			return err
+		}
+		fi, err := os.Stat(file)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "[%d/%d] %s: %d bytes in %v\n", i+1, len(tables), table, fi.Size(), time.Since(start).Round(time.Second))
+	}
+	common.SavingIn(measDir)
+	return nil
+}
+
+// downloadWithProgress downloads the table and reports the size of the
+// file every downloadProgressInterval until the download completes.
+func downloadWithProgress(table, format, file, prefix string) error {
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(downloadProgressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if fi, err := os.Stat(file); err == nil {
+					fmt.Fprintf(os.Stderr, "%s: %d bytes so far\n", prefix, fi.Size())
+				}
+			}
+		}
+	}()
+	return DownloadTable(table, format, file)
+}
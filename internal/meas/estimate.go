@@ -0,0 +1,217 @@
+package meas
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dioptra-io/irisctl/internal/agents"
+	"github.com/dioptra-io/irisctl/internal/auth"
+	"github.com/dioptra-io/irisctl/internal/common"
+	"github.com/spf13/cobra"
+)
+
+// estimate defines the estimated probes and duration of a measurement
+// on one agent.
+type estimate struct {
+	agent      string
+	targetFile string
+	targets    int
+	probes     int
+	rate       int
+	duration   time.Duration
+}
+
+func measEstimateArgs(cmd *cobra.Command, args []string) error {
+	if format, ok := common.IsUsage(args); ok {
+		fmt.Printf(format, "<meas-file>", "measurement definition file")
+		return nil
+	}
+	if len(args) != 1 {
+		cliFatal("meas estimate requires exactly one argument: <meas-file>", common.MeasurementFile)
+	}
+	if _, err := common.CheckFile("measurement", args[0]); err != nil {
+		cliFatal(err)
+	}
+	return nil
+}
+
+func measEstimate(cmd *cobra.Command, args []string) {
+	contents, err := os.ReadFile(args[0])
+	if err != nil {
+		fatal(err)
+	}
+	def, problems := parseMeasDefinition(contents)
+	if len(problems) > 0 {
+		fatal(fmt.Errorf("%s: %w: %s", args[0], ErrInvalidMeasFile, strings.Join(problems, "; ")))
+	}
+	estimates, err := estimateMeasurement(def)
+	if err != nil {
+		fatal(err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(w, "agent\ttarget file\ttargets\tprobes\tprobing rate\tduration\t\n")
+	for _, e := range estimates {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%s\t\n", e.agent, e.targetFile, e.targets, e.probes, e.rate, e.duration)
+	}
+	w.Flush()
+	if *def.Tool == "diamond-miner" {
+		fmt.Printf("\nprobes and durations are for the first round; the probes of later rounds depend on the topology\n")
+	}
+}
+
+// estimateMeasurement returns the estimated probes and duration of the
+// measurement defined by def on each of its agents.  Missing tool
+// parameters have the defaults of Iris and missing probing rates are
+// the maximum probing rates of the agents.
+func estimateMeasurement(def measDefinition) ([]estimate, error) {
+	jsonData, err := agents.GetAgents("", false)
+	if err != nil {
+		return nil, err
+	}
+	var agentsData common.AgentsData
+	if err := common.DecodeJSON(jsonData, &agentsData); err != nil {
+		return nil, err
+	}
+
+	var estimates []estimate
+	targetLists := make(map[string][]string)
+	for i, a := range def.Agents {
+		var matched []common.AgentsResult
+		for _, result := range agentsData.Results {
+			if matchesAgent(a, result) {
+				matched = append(matched, result)
+				for _, warning := range checkAgentCapabilities(fmt.Sprintf("agents[%d]", i), a, result) {
+					fmt.Fprintf(os.Stderr, "%s %s\n", common.Warning(common.WarnAgentCapability), warning)
+				}
+			}
+		}
+		if len(matched) == 0 {
+			fmt.Fprintf(os.Stderr, "%s agents[%d]: no matching agent\n", common.Warning(common.WarnNoMatchingAgent), i)
+			continue
+		}
+		lines, ok := targetLists[*a.TargetFile]
+		if !ok {
+			if lines, err = getTargetListContent(*a.TargetFile); err != nil {
+				return nil, err
+			}
+			targetLists[*a.TargetFile] = lines
+		}
+		params := estimateParams(*def.Tool, a.ToolParameters)
+		probes, err := estimateProbes(lines, params)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", *a.TargetFile, err)
+		}
+		for _, result := range matched {
+			e := estimate{
+				agent:      result.Parameters.Hostname,
+				targetFile: *a.TargetFile,
+				targets:    len(lines),
+				probes:     probes,
+				rate:       result.Parameters.MaxProbingRate,
+			}
+			if a.ProbingRate != nil && *a.ProbingRate < e.rate {
+				e.rate = *a.ProbingRate
+			}
+			if e.rate > 0 {
+				e.duration = time.Duration(float64(probes) / float64(e.rate) * float64(time.Second)).Round(time.Second)
+			}
+			estimates = append(estimates, e)
+		}
+	}
+	return estimates, nil
+}
+
+// estimateParams returns the tool parameters of p with the defaults of
+// Iris for the missing ones.
+func estimateParams(tool string, p *measDefinitionParams) common.ToolParameters {
+	params := initRequest(tool, "").Agents[0].ToolParameters
+	if p == nil {
+		return params
+	}
+	if p.PrefixLenV4 != nil {
+		params.PrefixLenV4 = *p.PrefixLenV4
+	}
+	if p.PrefixLenV6 != nil {
+		params.PrefixLenV6 = *p.PrefixLenV6
+	}
+	if p.GlobalMinTTL != nil {
+		params.GlobalMinTTL = *p.GlobalMinTTL
+	}
+	if p.GlobalMaxTTL != nil {
+		params.GlobalMaxTTL = *p.GlobalMaxTTL
+	}
+	return params
+}
+
+// estimateProbes returns the number of probes of the first round for
+// the lines of a target list (target,protocol,min_ttl,max_ttl,
+// n_initial_flows) or a probe list (one probe per line).
+func estimateProbes(lines []string, params common.ToolParameters) (int, error) {
+	probes := 0
+	for i, line := range lines {
+		fields := strings.Split(strings.TrimSpace(line), ",")
+		if len(fields) != 5 {
+			return 0, fmt.Errorf("line %d: %w: %q", i+1, common.ErrInvalidLine, line)
+		}
+		// The second field of probe lists is a source port.
+		if _, err := strconv.Atoi(fields[1]); err == nil {
+			probes++
+			continue
+		}
+		prefix, err := common.ParseTarget(fields[0])
+		if err != nil {
+			return 0, fmt.Errorf("line %d: %w: %v", i+1, common.ErrInvalidLine, err)
+		}
+		minTTL, err1 := strconv.Atoi(fields[2])
+		maxTTL, err2 := strconv.Atoi(fields[3])
+		flows, err3 := strconv.Atoi(fields[4])
+		if err1 != nil || err2 != nil || err3 != nil {
+			return 0, fmt.Errorf("line %d: %w: %q", i+1, common.ErrInvalidLine, line)
+		}
+		minTTL = max(minTTL, params.GlobalMinTTL)
+		maxTTL = min(maxTTL, params.GlobalMaxTTL)
+		if maxTTL < minTTL {
+			continue
+		}
+		prefixLen := params.PrefixLenV4
+		if prefix.Addr().Is6() {
+			prefixLen = params.PrefixLenV6
+		}
+		// Each target is probed as prefixes of length prefixLen.
+		subprefixes := 1
+		if prefix.Bits() < prefixLen {
+			subprefixes = int(math.Pow(2, float64(prefixLen-prefix.Bits())))
+		}
+		probes += subprefixes * (maxTTL - minTTL + 1) * flows
+	}
+	return probes, nil
+}
+
+// getTargetListContent returns the lines of the specified target list.
+func getTargetListContent(key string) ([]string, error) {
+	url := fmt.Sprintf("%s/%s?with_content=true", common.APIEndpoint(common.TargetsAPISuffix), key)
+	jsonData, err := common.Curl(auth.GetAccessToken(), false, "GET", url)
+	if err != nil {
+		return nil, err
+	}
+	var target struct {
+		Content []string `json:"content"`
+	}
+	if err := json.Unmarshal(jsonData, &target); err != nil {
+		return nil, fmt.Errorf("%s: %v: %s", key, err, jsonData)
+	}
+	var lines []string
+	for _, line := range target.Content {
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, nil
+}
@@ -0,0 +1,123 @@
+package meas
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/dioptra-io/irisctl/internal/common"
+)
+
+var (
+	// Fields of measurements and of their agents that --fields always
+	// keeps because irisctl needs them to parse metadata files (see
+	// the fields tagged with `schema:"required"`).
+	measRequiredFields  = []string{"uuid", "creation_time", "state"}
+	agentRequiredFields = []string{"agent_uuid"}
+
+	ErrInvalidField = errors.New("invalid field")
+)
+
+// validateFields checks that each field of --fields is a field of
+// measurements or, prefixed with agents., a field of their agents.
+func validateFields(fields []string) error {
+	measFields := jsonFieldNames(reflect.TypeOf(common.Measurement{}))
+	agentFields := jsonFieldNames(reflect.TypeOf(common.Agent{}))
+	for _, field := range fields {
+		name, subfield, ok := strings.Cut(field, ".")
+		switch {
+		case ok && name == "agents" && common.Contains(agentFields, subfield):
+		case !ok && common.Contains(measFields, name):
+		default:
+			return fmt.Errorf("%s: %w (one of %s or agents.<field> where <field> is one of %s)", field, ErrInvalidField, strings.Join(measFields, " "), strings.Join(agentFields, " "))
+		}
+	}
+	return nil
+}
+
+// jsonFieldNames returns the JSON names of the fields of the struct t
+// in alphabetical order.
+func jsonFieldNames(t reflect.Type) []string {
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// projectBatch returns the batch of measurements returned by the API
+// with only the specified fields (and the required fields) of each
+// measurement.  Fields are decoded as raw JSON, so large fields that
+// are dropped (e.g., probing_statistics) are not unmarshaled.
+func projectBatch(jsonData []byte, fields []string) ([]byte, error) {
+	var batch map[string]json.RawMessage
+	if err := json.Unmarshal(jsonData, &batch); err != nil {
+		return nil, err
+	}
+	var results []map[string]json.RawMessage
+	if err := json.Unmarshal(batch["results"], &results); err != nil {
+		return nil, err
+	}
+	projected, err := projectMeasurements(results, fields)
+	if err != nil {
+		return nil, err
+	}
+	if batch["results"], err = json.Marshal(projected); err != nil {
+		return nil, err
+	}
+	return json.Marshal(batch)
+}
+
+// projectMeasurements returns the measurements with only the specified
+// fields (and the required fields).
+func projectMeasurements(measurements []map[string]json.RawMessage, fields []string) ([]map[string]json.RawMessage, error) {
+	keep := append([]string{}, measRequiredFields...)
+	agentKeep := append([]string{}, agentRequiredFields...)
+	allAgentFields := false
+	for _, field := range fields {
+		if name, subfield, ok := strings.Cut(field, "."); ok {
+			agentKeep = append(agentKeep, subfield)
+			keep = append(keep, name)
+		} else {
+			keep = append(keep, field)
+			allAgentFields = allAgentFields || field == "agents"
+		}
+	}
+	projected := make([]map[string]json.RawMessage, 0, len(measurements))
+	for _, m := range measurements {
+		p := make(map[string]json.RawMessage)
+		for _, field := range keep {
+			if value, ok := m[field]; ok {
+				p[field] = value
+			}
+		}
+		if value, ok := p["agents"]; ok && !allAgentFields {
+			var agents []map[string]json.RawMessage
+			if err := json.Unmarshal(value, &agents); err != nil {
+				return nil, err
+			}
+			for i, agent := range agents {
+				a := make(map[string]json.RawMessage)
+				for _, field := range agentKeep {
+					if value, ok := agent[field]; ok {
+						a[field] = value
+					}
+				}
+				agents[i] = a
+			}
+			var err error
+			if p["agents"], err = json.Marshal(agents); err != nil {
+				return nil, err
+			}
+		}
+		projected = append(projected, p)
+	}
+	return projected, nil
+}
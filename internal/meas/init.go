@@ -0,0 +1,112 @@
+package meas
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/dioptra-io/irisctl/internal/common"
+	"github.com/spf13/cobra"
+)
+
+// initParam describes a tool parameter of a starter measurement
+// definition.  JSON has no comments, so the descriptions are printed
+// when the definition is written.
+type initParam struct {
+	name        string
+	description string
+}
+
+var (
+	initParams = []initParam{
+		{"initial_source_port", "source port of the first flow of each prefix"},
+		{"destination_port", "destination port of UDP probes"},
+		{"max_round", "maximum number of probing rounds"},
+		{"failure_probability", "probability of missing a link (diamond-miner only)"},
+		{"flow_mapper", "mapping of flow IDs to addresses and ports (" + strings.Join(flowMappers, ", ") + ")"},
+		{"flow_mapper_kwargs.seed", "seed of RandomFlowMapper"},
+		{"prefix_len_v4", "length of the IPv4 prefixes probed as a unit"},
+		{"prefix_len_v6", "length of the IPv6 prefixes probed as a unit"},
+		{"global_min_ttl", "minimum TTL of probes"},
+		{"global_max_ttl", "maximum TTL of probes"},
+	}
+)
+
+func measInitArgs(cmd *cobra.Command, args []string) error {
+	if format, ok := common.IsUsage(args); ok {
+		fmt.Printf(format, "<meas-file>", "measurement definition file to create")
+		return nil
+	}
+	if len(args) != 1 {
+		cliFatal("meas init requires exactly one argument: <meas-file>")
+	}
+	if !common.Contains(measTools, fInitTool) {
+		cliFatal("--tool must be one of: ", strings.Join(measTools, " "))
+	}
+	if fInitAgentTag == "" {
+		cliFatal("--agent-tag cannot be empty")
+	}
+	return nil
+}
+
+func measInit(cmd *cobra.Command, args []string) {
+	measFile := args[0]
+	data, err := json.MarshalIndent(initRequest(fInitTool, fInitAgentTag), "", "  ")
+	if err != nil {
+		fatal(err)
+	}
+	if _, problems := parseMeasDefinition(data); len(problems) > 0 {
+		fatal(fmt.Errorf("%w: %s", ErrInvalidMeasFile, strings.Join(problems, "; ")))
+	}
+	// Existing files are never overwritten.
+	f, err := os.OpenFile(measFile, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		fatal(err)
+	}
+	fmt.Printf("created %s for %s on agents tagged %q\n", measFile, fInitTool, fInitAgentTag)
+	fmt.Printf("edit target_file and tags, then check it with: irisctl meas validate %s\n", measFile)
+	fmt.Printf("tool_parameters (defaults of Iris):\n")
+	for _, p := range initParams {
+		fmt.Printf("  %-24s %s\n", p.name, p.description)
+	}
+}
+
+// initRequest returns a starter measurement request for tool on the
+// agents tagged agentTag with the default tool parameters of Iris.
+func initRequest(tool, agentTag string) MeasurementRequest {
+	params := common.ToolParameters{
+		InitialSourcePort:  24000,
+		DestinationPort:    33434,
+		MaxRound:           10,
+		FailureProbability: 0.05,
+		FlowMapper:         "RandomFlowMapper",
+		PrefixLenV4:        24,
+		PrefixLenV6:        64,
+		GlobalMinTTL:       0,
+		GlobalMaxTTL:       255,
+	}
+	params.FlowMapperKwargs.Seed = 42
+	// Ping and yarrp probe each target once, so they use a single
+	// round and probe addresses rather than prefixes.
+	if tool != "diamond-miner" {
+		params.MaxRound = 1
+		params.PrefixLenV4 = 32
+		params.PrefixLenV6 = 128
+	}
+	return MeasurementRequest{
+		Tool: tool,
+		Agents: []MeasurementRequestAgent{
+			{
+				Tag:            agentTag,
+				TargetFile:     "prefixes.csv",
+				ToolParameters: params,
+			},
+		},
+		Tags: []string{"test"},
+	}
+}
@@ -0,0 +1,87 @@
+package meas
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/dioptra-io/irisctl/internal/common"
+)
+
+var (
+	// Errors.
+	ErrMdIntegrity = errors.New("measurements metadata file failed integrity check")
+)
+
+// verifyMeasMdFile checks that the batches of measMdFile parse, that
+// the number of measurements matches the count reported by the API in
+// the last batch, and that there are no duplicate measurement UUIDs
+// (e.g., because measurements were created during the download and
+// shifted the pages).  It returns a description of each problem.
+func verifyMeasMdFile(measMdFile string) ([]string, error) {
+	file, err := os.Open(measMdFile)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var problems []string
+	decoder := json.NewDecoder(file)
+	seen := make(map[string]int)
+	count, total, nBatches := -1, 0, 0
+	for {
+		var batch struct {
+			Count   int `json:"count"`
+			Results []struct {
+				UUID string `json:"uuid"`
+			} `json:"results"`
+		}
+		if err := decoder.Decode(&batch); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			problems = append(problems, fmt.Sprintf("batch %d does not parse: %v", nBatches+1, err))
+			break
+		}
+		nBatches++
+		count = batch.Count
+		for _, m := range batch.Results {
+			total++
+			seen[m.UUID]++
+		}
+	}
+	if count >= 0 && total != count {
+		problems = append(problems, fmt.Sprintf("%d measurements but the API reported %d", total, count))
+	}
+	nDuplicates := 0
+	for uuid, n := range seen {
+		if n > 1 {
+			nDuplicates++
+			verbose("measurement %s appears %d times\n", uuid, n)
+		}
+	}
+	if nDuplicates > 0 {
+		problems = append(problems, fmt.Sprintf("%d duplicate measurement UUIDs", nDuplicates))
+	}
+	verbose("verified %s: %d batches, %d measurements\n", measMdFile, nBatches, total)
+	return problems, nil
+}
+
+// checkMeasMdFile warns about the problems of measMdFile found by
+// verifyMeasMdFile before analysis proceeds on it.  With --strict, the
+// problems are an error.
+func checkMeasMdFile(measMdFile string) error {
+	problems, err := verifyMeasMdFile(measMdFile)
+	if err != nil || len(problems) == 0 {
+		return err
+	}
+	for _, problem := range problems {
+		fmt.Fprintf(os.Stderr, "%s %s: %s\n", common.Warning(common.WarnMdIntegrity), measMdFile, problem)
+	}
+	if common.RootFlagBool("strict") {
+		return fmt.Errorf("%s: %w", measMdFile, ErrMdIntegrity)
+	}
+	return nil
+}
@@ -2,33 +2,93 @@
 package meas
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"regexp"
+	"strings"
+	"time"
 
 	"github.com/dioptra-io/irisctl/internal/auth"
 	"github.com/dioptra-io/irisctl/internal/common"
+	"github.com/dioptra-io/irisctl/internal/users"
 	"github.com/spf13/cobra"
 )
 
 var (
 	// Command, its flags, subcommands, and their flags.
-	//	meas [--state <state>] [--tag <tag>] [--all-users] [--public]
+	//	meas [--state <state>] [--tag <tag>] [--all-users] [--public] [--before <time>] [--after|--since <time>] [--fields <field>,...]
 	//	meas --uuid <meas-uuid>...
-	//	meas --target-list <meas-uuid> <agent-uuid>
+	//	meas --target-list [--checksum-file <file>] <meas-uuid> <agent-uuid>
 	//	meas request <meas-file>...
 	//	meas delete <meas-uuid>...
 	//	meas edit <meas-uuid> <patch-file>
-	cmdName         = "meas"
-	subcmdNames     = []string{"request", "delete", "edit"}
-	fMeasState      string
-	fMeasTag        string
-	fMeasAllUsers   bool
-	fMeasPublic     bool
-	fMeasUUID       bool
-	fMeasTargetList bool
+	//	meas manifest <meas-uuid>
+	//	meas publish <meas-uuid>...
+	//	meas unpublish <meas-uuid>...
+	//	meas retag --from <old-tag> --to <new-tag> [--all-users] [--before <yyyy-mm-ddThh:mm:ss>] [--after <yyyy-mm-ddThh:mm:ss>] [--state <state>]... [--dry-run]
+	//	meas replay --to-profile <profile> [--agent-tag <tag>] [--dry-run] <meas-uuid>
+	//	meas wait [--until finished|canceled|any-terminal] [--timeout <duration>] [--interval <duration>] <meas-uuid>
+	//	meas watch [--interval <duration>] <meas-uuid>
+	//	meas clone [--tag <tag>]... [--dry-run] <meas-uuid>
+	//	meas validate [--offline] <meas-file>...
+	//	meas postmortem [--log-lines <n>] [--no-logs] [--queue <queue>]... <meas-uuid>
+	//	meas download [--format csv|native] [--output-dir <dir>] <meas-uuid>
+	//	meas summary <meas-uuid>
+	//	meas diff [--threshold <percent>] <meas-uuid-a> <meas-uuid-b>
+	//	meas init [--tool <tool>] [--agent-tag <tag>] <meas-file>
+	//	meas estimate <meas-file>
+	//	meas retry [--dry-run] <meas-uuid>
+	//	meas progress [--follow [--interval <duration>]] <meas-uuid>
+	//	meas schedule
+	//	meas schedule run
+	cmdName            = "meas"
+	subcmdNames        = []string{"request", "delete", "edit", "manifest", "publish", "unpublish", "retag", "replay", "wait", "watch", "clone", "validate", "postmortem", "download", "summary", "diff", "init", "estimate", "retry", "progress", "schedule"}
+	fMeasState         string
+	fMeasTag           string
+	fMeasAllUsers      bool
+	fMeasPublic        bool
+	fMeasUUID          bool
+	fMeasTargetList    bool
+	fMeasChecksum      string
+	fMeasBefore        common.CustomTime
+	fMeasAfter         common.CustomTime
+	fMeasFields        []string
+	fRetagFrom         string
+	fRetagTo           string
+	fRetagAllUsers     bool
+	fRetagBefore       common.CustomTime
+	fRetagAfter        common.CustomTime
+	fRetagState        []string
+	fRetagDryRun       bool
+	fReplayProfile     string
+	fReplayAgentTag    string
+	fReplayDryRun      bool
+	fWaitUntil         string
+	fWaitTimeout       time.Duration
+	fWaitInterval      time.Duration
+	fWatchInterval     time.Duration
+	fCloneTags         []string
+	fCloneDryRun       bool
+	fValidateOffline   bool
+	fPostmortemLines   int
+	fPostmortemNoLogs  bool
+	fPostmortemQueues  []string
+	fDownloadFormat    string
+	fDownloadOutputDir string
+	fDiffThreshold     float64
+	fInitTool          string
+	fInitAgentTag      string
+	fRetryDryRun       bool
+	fProgressFollow    bool
+	fProgressInterval  time.Duration
+
+	// Errors.
+	ErrPatchRejected = errors.New("patch rejected")
 
 	// Test code changes Fatal to Panic so a fatal error won't exit
 	// the process and can be recovered.
@@ -53,6 +113,11 @@ func MeasCmd() *cobra.Command {
 	measCmd.Flags().BoolVarP(&fMeasPublic, "public", "", false, "get measurements tagged as visibility:public")
 	measCmd.Flags().BoolVarP(&fMeasUUID, "uuid", "", false, "get measurements with the specified UUIDs")
 	measCmd.Flags().BoolVarP(&fMeasTargetList, "target-list", "", false, "get the target-list of the specified measurement and agent")
+	measCmd.Flags().StringVar(&fMeasChecksum, "checksum-file", "", "verify or record the checksum of the target-list in the specified file")
+	measCmd.Flags().Var(&fMeasBefore, "before", "get measurements before the specified date or relative time such as 24h, 7d, today, or yesterday (exclusive)")
+	measCmd.Flags().Var(&fMeasAfter, "after", "get measurements after the specified date or relative time such as 24h, 7d, today, or yesterday (inclusive)")
+	measCmd.Flags().Var(&fMeasAfter, "since", "same as --after (e.g., --since 24h)")
+	measCmd.Flags().StringSliceVar(&fMeasFields, "fields", []string{}, "comma-separated fields of measurements to get (e.g., uuid,state,tags or agents.state); uuid, creation_time, and state are always included")
 	measCmd.SetUsageFunc(common.Usage)
 	measCmd.SetHelpFunc(common.Help)
 
@@ -86,11 +151,256 @@ func MeasCmd() *cobra.Command {
 	}
 	measCmd.AddCommand(editSubcmd)
 
+	// meas manifest (has no flags)
+	manifestSubcmd := &cobra.Command{
+		Use:   "manifest",
+		Short: "export a measurement manifest",
+		Long:  "export the definition and provenance of the specified measurement as a reproducibility record",
+		Args:  measManifestArgs,
+		Run:   measManifest,
+	}
+	measCmd.AddCommand(manifestSubcmd)
+
+	// meas publish (has no flags)
+	publishSubcmd := &cobra.Command{
+		Use:   "publish",
+		Short: "make measurement(s) public",
+		Long:  "make the specified measurement(s) public by adding the " + common.PublicTag + " tag",
+		Args:  measPublishArgs,
+		Run:   measPublish,
+	}
+	measCmd.AddCommand(publishSubcmd)
+
+	// meas unpublish (has no flags)
+	unpublishSubcmd := &cobra.Command{
+		Use:   "unpublish",
+		Short: "make measurement(s) private",
+		Long:  "make the specified measurement(s) private by removing the " + common.PublicTag + " tag",
+		Args:  measPublishArgs,
+		Run:   measUnpublish,
+	}
+	measCmd.AddCommand(unpublishSubcmd)
+
+	// meas retag and its flags
+	retagSubcmd := &cobra.Command{
+		Use:   "retag",
+		Short: "rename a tag",
+		Long:  "replace a tag with another tag in all matching measurements",
+		Args:  measRetagArgs,
+		Run:   measRetag,
+	}
+	retagSubcmd.Flags().StringVar(&fRetagFrom, "from", "", "tag to replace")
+	retagSubcmd.Flags().StringVar(&fRetagTo, "to", "", "replacement tag")
+	retagSubcmd.Flags().BoolVar(&fRetagAllUsers, "all-users", false, "match all measurements of all users (admin only)")
+	retagSubcmd.Flags().Var(&fRetagBefore, "before", "match measurements before the specified date or relative time such as 24h, 7d, today, or yesterday (exclusive)")
+	retagSubcmd.Flags().Var(&fRetagAfter, "after", "match measurements after the specified date or relative time such as 24h, 7d, today, or yesterday (inclusive)")
+	retagSubcmd.Flags().StringArrayVarP(&fRetagState, "state", "s", []string{}, "repeatable: match measurements with the specified state (agent_failure, canceled, finished, ongoing)")
+	retagSubcmd.Flags().BoolVar(&fRetagDryRun, "dry-run", false, "enable dry-run mode (i.e., only print the plan)")
+	measCmd.AddCommand(retagSubcmd)
+
+	// meas replay and its flags
+	replaySubcmd := &cobra.Command{
+		Use:   "replay",
+		Short: "replay a measurement on another Iris instance",
+		Long:  "request the specified measurement again on the Iris instance of another profile of the configuration file",
+		Args:  measReplayArgs,
+		Run:   measReplay,
+	}
+	replaySubcmd.Flags().StringVar(&fReplayProfile, "to-profile", "", "profile of the Iris instance to replay the measurement on")
+	replaySubcmd.Flags().StringVar(&fReplayAgentTag, "agent-tag", "", "run on agents with this tag instead of agents with the same hostnames")
+	replaySubcmd.Flags().BoolVar(&fReplayDryRun, "dry-run", false, "enable dry-run mode (i.e., only print the request)")
+	measCmd.AddCommand(replaySubcmd)
+
+	// meas wait and its flags
+	waitSubcmd := &cobra.Command{
+		Use:   "wait",
+		Short: "wait for a measurement to reach a state",
+		Long:  fmt.Sprintf("block until the specified measurement reaches the state of --until and exit with %d, or with %d if it reaches another terminal state, or with %d if --timeout expires", ExitWaitMet, ExitWaitFailed, ExitWaitTimeout),
+		Args:  measWaitArgs,
+		Run:   measWait,
+	}
+	waitSubcmd.Flags().StringVar(&fWaitUntil, "until", UntilAnyTerminal, "state to wait for: finished, canceled, or any-terminal")
+	waitSubcmd.Flags().DurationVar(&fWaitTimeout, "timeout", 12*time.Hour, "maximum time to wait (0 means no timeout)")
+	waitSubcmd.Flags().DurationVar(&fWaitInterval, "interval", 30*time.Second, "interval between polls of the measurement state")
+	measCmd.AddCommand(waitSubcmd)
+
+	// meas watch and its flags
+	watchSubcmd := &cobra.Command{
+		Use:   "watch",
+		Short: "watch a measurement until it completes",
+		Long:  fmt.Sprintf("print the state transitions of the specified measurement and the progress of its agents until it completes and exit with %d if it finished or %d if it was canceled or an agent failed", ExitWaitMet, ExitWaitFailed),
+		Args:  measWatchArgs,
+		Run:   measWatch,
+	}
+	watchSubcmd.Flags().DurationVar(&fWatchInterval, "interval", 30*time.Second, "interval between polls of the measurement")
+	measCmd.AddCommand(watchSubcmd)
+
+	// meas clone and its flags
+	cloneSubcmd := &cobra.Command{
+		Use:   "clone",
+		Short: "re-run a measurement",
+		Long:  "request the specified measurement again with the same tool, agents, target files, and (unless --tag is specified) tags",
+		Args:  measCloneArgs,
+		Run:   measClone,
+	}
+	cloneSubcmd.Flags().StringArrayVar(&fCloneTags, "tag", []string{}, "repeatable: tag of the new measurement (replaces the tags of the original measurement)")
+	cloneSubcmd.Flags().BoolVar(&fCloneDryRun, "dry-run", false, "enable dry-run mode (i.e., only print the request)")
+	measCmd.AddCommand(cloneSubcmd)
+
+	// meas validate and its flags
+	validateSubcmd := &cobra.Command{
+		Use:   "validate",
+		Short: "validate measurement definition file(s)",
+		Long:  "check the structure, tool parameters, agents, and target files of the specified measurement definition file(s) before requesting them, and warn about probing rates and TTLs that exceed the limits of the agents",
+		Args:  measValidateArgs,
+		Run:   measValidate,
+	}
+	validateSubcmd.Flags().BoolVar(&fValidateOffline, "offline", false, "only check the structure (i.e., do not check agents and target files with the API)")
+	measCmd.AddCommand(validateSubcmd)
+
+	// meas postmortem and its flags
+	postmortemSubcmd := &cobra.Command{
+		Use:   "postmortem",
+		Short: "report what happened to a measurement",
+		Long:  "assemble the timeline, agent states, relevant container log lines, dramatiq messages, and table row counts of the specified measurement into a markdown report",
+		Args:  measPostmortemArgs,
+		Run:   measPostmortem,
+	}
+	postmortemSubcmd.Flags().IntVar(&fPostmortemLines, "log-lines", 20, "maximum number of relevant container log lines per agent")
+	postmortemSubcmd.Flags().BoolVar(&fPostmortemNoLogs, "no-logs", false, "do not collect container logs (e.g., without gcloud access)")
+	postmortemSubcmd.Flags().StringArrayVar(&fPostmortemQueues, "queue", []string{"default"}, "repeatable: dramatiq queue to search for messages of the measurement")
+	measCmd.AddCommand(postmortemSubcmd)
+
+	// meas download and its flags
+	downloadSubcmd := &cobra.Command{
+		Use:   "download",
+		Short: "download the results of a measurement",
+		Long:  "download the results, links, prefixes, and probes tables of the specified measurement from ClickHouse to local files",
+		Args:  measDownloadArgs,
+		Run:   measDownload,
+	}
+	downloadSubcmd.Flags().StringVar(&fDownloadFormat, "format", "csv", "format of the downloaded files: csv or native (ClickHouse native format)")
+	downloadSubcmd.Flags().StringVar(&fDownloadOutputDir, "output-dir", ".", "directory to download the tables to")
+	measCmd.AddCommand(downloadSubcmd)
+
+	// meas summary (has no flags)
+	summarySubcmd := &cobra.Command{
+		Use:   "summary",
+		Short: "summarize a measurement",
+		Long:  "show the metadata, the probing statistics of the agents, and the table row counts of the specified measurement",
+		Args:  measSummaryArgs,
+		Run:   measSummary,
+	}
+	measCmd.AddCommand(summarySubcmd)
+
+	// meas diff and its flags
+	diffSubcmd := &cobra.Command{
+		Use:   "diff",
+		Short: "compare two measurements",
+		Long:  "compare the agents, tags, durations, probing statistics, and table row counts of two measurements and highlight regressions",
+		Args:  measDiffArgs,
+		Run:   measDiff,
+	}
+	diffSubcmd.Flags().Float64Var(&fDiffThreshold, "threshold", 50, "percent change of a metric of the second measurement that is highlighted as a regression")
+	measCmd.AddCommand(diffSubcmd)
+
+	// meas init and its flags
+	initSubcmd := &cobra.Command{
+		Use:   "init",
+		Short: "create a starter measurement definition file",
+		Long:  "create a valid measurement definition file with the default tool parameters of Iris",
+		Args:  measInitArgs,
+		Run:   measInit,
+	}
+	initSubcmd.Flags().StringVar(&fInitTool, "tool", "diamond-miner", "measurement tool (diamond-miner, yarrp, or ping)")
+	initSubcmd.Flags().StringVar(&fInitAgentTag, "agent-tag", "all", "tag of the agents of the measurement")
+	measCmd.AddCommand(initSubcmd)
+
+	// meas estimate (has no flags)
+	estimateSubcmd := &cobra.Command{
+		Use:   "estimate",
+		Short: "estimate the probes and duration of a measurement",
+		Long:  "estimate the number of probes and the duration of a measurement on each agent before requesting it",
+		Args:  measEstimateArgs,
+		Run:   measEstimate,
+	}
+	measCmd.AddCommand(estimateSubcmd)
+
+	// meas retry and its flags
+	retrySubcmd := &cobra.Command{
+		Use:   "retry",
+		Short: "re-run a measurement on its failed agents",
+		Long:  "request a measurement in agent_failure state again on the agents that failed with the same target files and tags",
+		Args:  measRetryArgs,
+		Run:   measRetry,
+	}
+	retrySubcmd.Flags().BoolVar(&fRetryDryRun, "dry-run", false, "enable dry-run mode (i.e., only print the request)")
+	measCmd.AddCommand(retrySubcmd)
+
+	// meas progress and its flags
+	progressSubcmd := &cobra.Command{
+		Use:   "progress",
+		Short: "show the round progress of a measurement",
+		Long:  "show the current round, packets sent, and probes read of each agent of a measurement",
+		Args:  measProgressArgs,
+		Run:   measProgress,
+	}
+	progressSubcmd.Flags().BoolVarP(&fProgressFollow, "follow", "f", false, "refresh the progress until the measurement ends")
+	progressSubcmd.Flags().DurationVar(&fProgressInterval, "interval", 10*time.Second, "interval between refreshes with --follow")
+	measCmd.AddCommand(progressSubcmd)
+
+	// meas schedule and its subcommand (have no flags)
+	scheduleSubcmd := &cobra.Command{
+		Use:       "schedule",
+		ValidArgs: []string{"run"},
+		Short:     "show scheduled measurements",
+		Long:      "show the schedules of the configuration file with their last and next requests",
+		Args:      measScheduleArgs,
+		Run:       measSchedule,
+	}
+	scheduleSubcmd.AddCommand(&cobra.Command{
+		Use:   "run",
+		Short: "request scheduled measurements",
+		Long:  "run in the foreground and request the measurement of each schedule of the configuration file at the times of its cron expression, recording the requested measurements in " + ScheduleRecordsFile + " of the irisctl directory",
+		Args:  measScheduleRunArgs,
+		Run:   measScheduleRun,
+	})
+	measCmd.AddCommand(scheduleSubcmd)
+
 	return measCmd
 }
 
+// Manifest defines a self-contained reproducibility record of a
+// measurement.
+type Manifest struct {
+	GenerationTime time.Time          `json:"generation_time"`
+	IrisAPIURL     string             `json:"iris_api_url"`
+	APIVersion     string             `json:"api_version"`
+	Measurement    common.Measurement `json:"measurement"`
+	Agents         []ManifestAgent    `json:"agents"`
+}
+
+// ManifestAgent defines the provenance of one agent of a measurement.
+type ManifestAgent struct {
+	AgentUUID        string   `json:"agent_uuid"`
+	Hostname         string   `json:"hostname"`
+	Version          string   `json:"version"`
+	TargetFile       string   `json:"target_file"`
+	TargetListSHA256 string   `json:"target_list_sha256"`
+	Tables           []string `json:"tables"`
+}
+
 func GetMeasMdFile(allUsers bool) (string, error) {
-	fMeasAllUsers = allUsers
+	return GetMeasMdFileFields(allUsers, nil)
+}
+
+// GetMeasMdFileFields is like GetMeasMdFile but gets only the specified
+// fields of measurements (see --fields) if fields is not empty.
+func GetMeasMdFileFields(allUsers bool, fields []string) (string, error) {
+	if err := validateFields(fields); err != nil {
+		return "", err
+	}
+	fMeasAllUsers, fMeasFields = allUsers, fields
 	return getMeasMdFile()
 }
 
@@ -103,7 +413,7 @@ func GetMeasurementAllDetails(uuid string) (common.Measurement, error) {
 		return measurement, err
 	}
 
-	err = json.Unmarshal(jsonData, &measurement)
+	err = common.DecodeJSON(jsonData, &measurement)
 	if err == nil {
 		return measurement, err
 	}
@@ -150,6 +460,18 @@ func measArgs(cmd *cobra.Command, args []string) error {
 	if fMeasTargetList && len(args) != 2 {
 		cliFatal("meas --target-list requires two arguments: <meas-uuid> <agent-uuid>")
 	}
+	if fMeasChecksum != "" && !fMeasTargetList {
+		cliFatal("--checksum-file requires --target-list")
+	}
+	if (fMeasUUID || fMeasTargetList) && (!fMeasBefore.IsZero() || !fMeasAfter.IsZero()) {
+		cliFatal("--before and --after cannot be used with --uuid or --target-list")
+	}
+	if (fMeasUUID || fMeasTargetList) && len(fMeasFields) > 0 {
+		cliFatal("--fields cannot be used with --uuid or --target-list")
+	}
+	if err := validateFields(fMeasFields); err != nil {
+		cliFatal(err)
+	}
 	return nil
 }
 
@@ -169,9 +491,15 @@ func meas(cmd *cobra.Command, args []string) {
 		}
 		return
 	}
-	if _, err := getMeasMdFile(); err != nil {
+	measMdFile, err := getMeasMdFile()
+	if err != nil {
 		fatal(err)
 	}
+	if !fMeasBefore.IsZero() || !fMeasAfter.IsZero() {
+		if err := filterMeasMdFile(measMdFile); err != nil {
+			fatal(err)
+		}
+	}
 }
 
 func measRequestArgs(cmd *cobra.Command, args []string) error {
@@ -224,26 +552,293 @@ func measEditArgs(cmd *cobra.Command, args []string) error {
 		return nil
 	}
 	if len(args) != 2 {
-		cliFatal("meas edit requires two arguments: <meas-uuid> <patch-file>")
+		cliFatal("meas edit requires two arguments: <meas-uuid> <patch-file>", common.MeasurementPatchFile)
 	}
-	if err := common.ValidateFormat(args, common.MeasurementUUID); err != nil {
+	if err := common.ValidateFormat(args[:1], common.MeasurementUUID); err != nil {
+		cliFatal(err)
+	}
+	if _, err := common.CheckFile("patch", args[1]); err != nil {
 		cliFatal(err)
 	}
 	return nil
 }
 
 func measEdit(cmd *cobra.Command, args []string) {
-	if err := patchMeasurement(); err != nil {
+	if err := patchMeasurement(args[0], args[1]); err != nil {
 		fatal(err)
 	}
 }
 
+func measManifestArgs(cmd *cobra.Command, args []string) error {
+	if format, ok := common.IsUsage(args); ok {
+		fmt.Printf(format, "<meas-uuid>", "measurement UUID")
+		return nil
+	}
+	if len(args) != 1 {
+		cliFatal("meas manifest requires exactly one argument: <meas-uuid>")
+	}
+	if err := common.ValidateFormat(args, common.MeasurementUUID); err != nil {
+		cliFatal(err)
+	}
+	return nil
+}
+
+func measManifest(cmd *cobra.Command, args []string) {
+	manifest, err := getManifest(args[0])
+	if err != nil {
+		fatal(err)
+	}
+	jsonData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		fatal(err)
+	}
+	if err := common.SaveOrPrint(jsonData, "irisctl-meas-manifest-"); err != nil {
+		fatal(err)
+	}
+}
+
+func getManifest(measUUID string) (Manifest, error) {
+	manifest := Manifest{
+		GenerationTime: time.Now().UTC(),
+		IrisAPIURL:     common.RootFlagString("iris-api-url"),
+	}
+	measurement, err := GetMeasurementAllDetails(measUUID)
+	if err != nil {
+		return manifest, err
+	}
+	manifest.Measurement = measurement
+	if manifest.APIVersion, err = GetAPIVersion(); err != nil {
+		return manifest, err
+	}
+	for _, agent := range measurement.Agents {
+		verbose("getting target list of agent %s\n", agent.AgentUUID)
+		url := fmt.Sprintf("%s/%s/%s/target", common.APIEndpoint(common.MeasurementsAPISuffix), measUUID, agent.AgentUUID)
+		jsonData, err := common.Curl(auth.GetAccessToken(), false, "GET", url)
+		if err != nil {
+			return manifest, err
+		}
+		// Like meas --target-list --checksum-file, the checksum is
+		// of the content of the target-list (i.e., of the file).
+		targetList, err := common.DecodeTargetListContent(jsonData)
+		if err != nil {
+			return manifest, fmt.Errorf("%s/%s/target: %v: %s", measUUID, agent.AgentUUID, err, jsonData)
+		}
+		sum := sha256.Sum256(targetList)
+		manifest.Agents = append(manifest.Agents, ManifestAgent{
+			AgentUUID:        agent.AgentUUID,
+			Hostname:         agent.AgentParameters.Hostname,
+			Version:          agent.AgentParameters.Version,
+			TargetFile:       agent.TargetFile,
+			TargetListSHA256: hex.EncodeToString(sum[:]),
+			Tables:           TableNames(measUUID, agent.AgentUUID),
+		})
+	}
+	return manifest, nil
+}
+
+// TableNames returns the names of the ClickHouse tables of the
+// specified measurement and agent.
+func TableNames(measUUID, agentUUID string) []string {
+	var names []string
+	for _, tableType := range common.TableTypes {
+		names = append(names, common.TableName(tableType, measUUID, agentUUID))
+	}
+	return names
+}
+
+// GetAPIVersion returns the version of Iris API reported by its status
+// endpoint or "?" if it does not report one.
+func GetAPIVersion() (string, error) {
+	jsonData, err := common.Curl(auth.GetAccessToken(), false, "GET", common.APIEndpoint(common.StatusAPISuffix)+"/")
+	if err != nil {
+		return "", err
+	}
+	var status map[string]interface{}
+	if err := json.Unmarshal(jsonData, &status); err != nil {
+		return "", err
+	}
+	version, ok := status["version"].(string)
+	if !ok {
+		return "?", nil
+	}
+	return version, nil
+}
+
+func measPublishArgs(cmd *cobra.Command, args []string) error {
+	if format, ok := common.IsUsage(args); ok {
+		fmt.Printf(format, "<meas-uuid>...", "measurement UUID")
+		return nil
+	}
+	if len(args) < 1 {
+		cliFatal("meas ", cmd.Name(), " requires at least one argument: <meas-uuid>...")
+	}
+	if err := common.ValidateFormat(args, common.MeasurementUUID); err != nil {
+		cliFatal(err)
+	}
+	return nil
+}
+
+func measPublish(cmd *cobra.Command, args []string) {
+	user, err := users.GetMe()
+	if err != nil {
+		fatal(err)
+	}
+	if !user.AllowTagPublic {
+		cliFatal(user.Email, " is not allowed to use the ", common.PublicTag, " tag")
+	}
+	for _, measUUID := range args {
+		if err := setVisibility(measUUID, true); err != nil {
+			fatal(err)
+		}
+	}
+}
+
+func measUnpublish(cmd *cobra.Command, args []string) {
+	for _, measUUID := range args {
+		if err := setVisibility(measUUID, false); err != nil {
+			fatal(err)
+		}
+	}
+}
+
+func setVisibility(measUUID string, public bool) error {
+	measurement, err := GetMeasurementAllDetails(measUUID)
+	if err != nil {
+		return err
+	}
+	if common.Contains(measurement.Tags, common.PublicTag) == public {
+		fmt.Printf("%s: nothing to do, visibility is already as requested\n", measUUID)
+		return nil
+	}
+	tags := []string{}
+	for _, tag := range measurement.Tags {
+		if tag != common.PublicTag {
+			tags = append(tags, tag)
+		}
+	}
+	if public {
+		tags = append(tags, common.PublicTag)
+	}
+	return patchMeasurementTags(measUUID, tags)
+}
+
+func patchMeasurementTags(measUUID string, tags []string) error {
+	data, err := json.Marshal(struct {
+		Tags []string `json:"tags"`
+	}{tags})
+	if err != nil {
+		return err
+	}
+	url := fmt.Sprintf("%s/%s", common.APIEndpoint(common.MeasurementsAPISuffix), measUUID)
+	jsonData, status, err := common.CurlStatus(auth.GetAccessToken(), false, "PATCH", url,
+		"-H", "Content-Type: application/json",
+		"-d", string(data),
+	)
+	if err != nil {
+		fmt.Println(string(jsonData))
+		return err
+	}
+	if common.RootFlagBool("curl") {
+		return nil
+	}
+	if status < 200 || status >= 300 {
+		return fmt.Errorf("%s: %w: %d: %s", measUUID, ErrPatchRejected, status, jsonData)
+	}
+	return common.SaveOrPrint(jsonData, "irisctl-meas-patch-")
+}
+
+func measRetagArgs(cmd *cobra.Command, args []string) error {
+	if _, ok := common.IsUsage(args); ok {
+		return nil
+	}
+	if len(args) != 0 {
+		cliFatal("meas retag does not take any arguments")
+	}
+	if fRetagFrom == "" || fRetagTo == "" {
+		cliFatal("meas retag requires both --from <old-tag> and --to <new-tag>")
+	}
+	if fRetagFrom == fRetagTo {
+		cliFatal("--from and --to must be different tags")
+	}
+	if len(fRetagState) > 0 {
+		if s, err := common.ValidateState(fRetagState); err != nil {
+			cliFatal(fmt.Sprintf("%v: %v", s, err))
+		}
+	}
+	return nil
+}
+
+func measRetag(cmd *cobra.Command, args []string) {
+	// Let the API do the tag filtering.
+	fMeasTag = fRetagFrom
+	measMdFile, err := GetMeasMdFile(fRetagAllUsers)
+	if err != nil {
+		fatal(err)
+	}
+	measurements, err := common.GetMeasurementsSorted(measMdFile)
+	if err != nil {
+		fatal(err)
+	}
+	plan := make(map[string][]string)
+	var measUUIDs []string
+	for _, measurement := range measurements {
+		if !common.Contains(measurement.Tags, fRetagFrom) {
+			continue
+		}
+		if len(fRetagState) > 0 && !common.MatchState(measurement.State, fRetagState) {
+			continue
+		}
+		if !fRetagAfter.IsZero() && measurement.CreationTime.Before(fRetagAfter.Time) {
+			continue
+		}
+		if !fRetagBefore.IsZero() && !measurement.CreationTime.Before(fRetagBefore.Time) {
+			continue
+		}
+		tags := []string{}
+		for _, tag := range measurement.Tags {
+			if tag == fRetagFrom {
+				tag = fRetagTo
+			}
+			if !common.Contains(tags, tag) {
+				tags = append(tags, tag)
+			}
+		}
+		fmt.Printf("%s %q -> %q\n", measurement.UUID, measurement.Tags, tags)
+		plan[measurement.UUID] = tags
+		measUUIDs = append(measUUIDs, measurement.UUID)
+	}
+	if len(measUUIDs) == 0 {
+		fmt.Printf("no measurements have the tag %q\n", fRetagFrom)
+		return
+	}
+	if fRetagDryRun {
+		fmt.Printf("dry-run: would retag %d measurement(s)\n", len(measUUIDs))
+		return
+	}
+	for _, measUUID := range measUUIDs {
+		if err := patchMeasurementTags(measUUID, plan[measUUID]); err != nil {
+			fatal(err)
+		}
+	}
+}
+
 func getTargetList(measUUID, agentUUID string) error {
 	url := fmt.Sprintf("%s/%s/%s/target", common.APIEndpoint(common.MeasurementsAPISuffix), measUUID, agentUUID)
 	jsonData, err := common.Curl(auth.GetAccessToken(), false, "GET", url)
 	if err != nil {
 		return err
 	}
+	if fMeasChecksum != "" {
+		// The checksum is of the content of the target-list and is
+		// verified before the target-list is saved or printed.
+		content, err := common.DecodeTargetListContent(jsonData)
+		if err != nil {
+			return fmt.Errorf("%s/%s/target: %v: %s", measUUID, agentUUID, err, jsonData)
+		}
+		if err := common.VerifyChecksum(fMeasChecksum, measUUID+"/"+agentUUID+"/target", content); err != nil {
+			return err
+		}
+	}
 	return common.SaveOrPrint(jsonData, "irisctl-meas-target-")
 }
 
@@ -265,12 +860,12 @@ func getMeasMdFile() (string, error) {
 		verbose("getting metadata of my measurements\n")
 		prefix = "irisctl-meas-me-"
 	}
-	f, err := os.CreateTemp("/tmp", prefix)
+	f, err := common.CreateTemp(prefix)
 	if err != nil {
 		return "", err
 	}
 	defer f.Close()
-	fmt.Fprintf(os.Stderr, "saving in %s\n", f.Name())
+	common.SavingIn(f.Name())
 
 	limit := 200
 	defer fmt.Println()
@@ -288,11 +883,21 @@ func getMeasMdFile() (string, error) {
 		if fMeasTag != "" {
 			url = fmt.Sprintf("%stag=%v&", url, fMeasTag)
 		}
+		if len(fMeasFields) > 0 {
+			url = fmt.Sprintf("%sfields=%v&", url, strings.Join(fMeasFields, ","))
+		}
 		url = fmt.Sprintf("%soffset=%d&limit=%d", url, offset, limit)
 		jsonData, err := common.Curl(auth.GetAccessToken(), false, "GET", url)
 		if err != nil {
 			return f.Name(), err
 		}
+		// Iris API ignores query parameters that it does not support,
+		// so the fields are also selected here.
+		if len(fMeasFields) > 0 {
+			if jsonData, err = projectBatch(jsonData, fMeasFields); err != nil {
+				return f.Name(), err
+			}
+		}
 		if _, err := f.Write(jsonData); err != nil {
 			return f.Name(), err
 		}
@@ -303,8 +908,52 @@ func getMeasMdFile() (string, error) {
 		if batch.Next == nil || *batch.Next == "" {
 			break
 		}
+		if delay := common.PacingDelay(); delay > 0 {
+			verbose("pausing %v before the next batch to stay within the rate limit\n", delay)
+			time.Sleep(delay)
+		}
 	}
-	return f.Name(), nil
+	return f.Name(), checkMeasMdFile(f.Name())
+}
+
+// filterMeasMdFile saves or prints the measurements of the metadata
+// file that were created in the time range of --after and --before.
+// The Iris API cannot filter measurements by time.
+func filterMeasMdFile(measMdFile string) error {
+	measurements, err := common.GetMeasurementsSorted(measMdFile)
+	if err != nil {
+		return err
+	}
+	matched := []common.Measurement{}
+	for _, m := range measurements {
+		if !fMeasAfter.IsZero() && m.CreationTime.Before(fMeasAfter.Time) {
+			continue
+		}
+		if !fMeasBefore.IsZero() && !m.CreationTime.Before(fMeasBefore.Time) {
+			continue
+		}
+		matched = append(matched, m)
+	}
+	verbose("%d of %d measurements match the time range\n", len(matched), len(measurements))
+	jsonData, err := json.MarshalIndent(matched, "", "  ")
+	if err != nil {
+		return err
+	}
+	if len(fMeasFields) > 0 {
+		// Decoding the measurements added the fields that were not
+		// selected.
+		var raw []map[string]json.RawMessage
+		if err := json.Unmarshal(jsonData, &raw); err != nil {
+			return err
+		}
+		if raw, err = projectMeasurements(raw, fMeasFields); err != nil {
+			return err
+		}
+		if jsonData, err = json.MarshalIndent(raw, "", "  "); err != nil {
+			return err
+		}
+	}
+	return common.SaveOrPrint(jsonData, "irisctl-meas-filtered-")
 }
 
 func postMeasurementRequst(measFile string) error {
@@ -321,7 +970,43 @@ func deleteMeasurement(measUUID string) error {
 	return common.SaveOrPrint(jsonData, "irisctl-meas-delete-")
 }
 
-func patchMeasurement() error {
-	fmt.Println("patchMeasurement() not implemented yet")
+// patchMeasurement patches the measurement with the contents of
+// patchFile and prints the updated measurement metadata.
+func patchMeasurement(measUUID, patchFile string) error {
+	contents, err := os.ReadFile(patchFile)
+	if err != nil {
+		return err
+	}
+	var patch map[string]interface{}
+	if err := json.Unmarshal(contents, &patch); err != nil {
+		return fmt.Errorf("%s: %w, patch file format:%s", patchFile, err, common.MeasurementPatchFile)
+	}
+	if len(patch) == 0 {
+		return fmt.Errorf("%s: %w, patch file format:%s", patchFile, common.ErrZeroLength, common.MeasurementPatchFile)
+	}
+	data, err := json.Marshal(patch)
+	if err != nil {
+		return err
+	}
+	url := fmt.Sprintf("%s/%s", common.APIEndpoint(common.MeasurementsAPISuffix), measUUID)
+	jsonData, status, err := common.CurlStatus(auth.GetAccessToken(), false, "PATCH", url,
+		"-H", "Content-Type: application/json",
+		"-d", string(data),
+	)
+	if err != nil {
+		fmt.Println(string(jsonData))
+		return err
+	}
+	if common.RootFlagBool("curl") {
+		return nil
+	}
+	if status < 200 || status >= 300 {
+		return fmt.Errorf("%s: %w: %d: %s", measUUID, ErrPatchRejected, status, jsonData)
+	}
+	jqOutput, err := common.JqBytes(jsonData, []string{common.RootFlagString("jq-filter")})
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(jqOutput))
 	return nil
 }
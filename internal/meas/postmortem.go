@@ -0,0 +1,257 @@
+package meas
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dioptra-io/irisctl/internal/auth"
+	"github.com/dioptra-io/irisctl/internal/common"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// Number of lines of container logs searched for lines relevant
+	// to the measurement.
+	postmortemLogTail = 10000
+)
+
+var (
+	// TableRowCounts returns the number of rows of each table of a
+	// measurement.  It is set by the main package to avoid an import
+	// cycle with the clickhouse package.
+	TableRowCounts func(measUUID string) (map[string]int, error)
+)
+
+// DramatiqMessage defines a message of a dramatiq queue returned by
+// the maintenance API.
+type DramatiqMessage struct {
+	QueueName        string                 `json:"queue_name"`
+	ActorName        string                 `json:"actor_name"`
+	Args             []interface{}          `json:"args"`
+	Kwargs           map[string]interface{} `json:"kwargs"`
+	MessageID        string                 `json:"message_id"`
+	MessageTimestamp int64                  `json:"message_timestamp"`
+	RedisMessageID   string                 `json:"redis_message_id"`
+}
+
+// timelineEvent defines an event of the timeline of a postmortem.
+type timelineEvent struct {
+	time  string
+	event string
+}
+
+func measPostmortemArgs(cmd *cobra.Command, args []string) error {
+	if format, ok := common.IsUsage(args); ok {
+		fmt.Printf(format, "<meas-uuid>", "measurement UUID")
+		return nil
+	}
+	if len(args) != 1 {
+		cliFatal("meas postmortem requires exactly one argument: <meas-uuid>")
+	}
+	if err := common.ValidateFormat(args, common.MeasurementUUID); err != nil {
+		cliFatal(err)
+	}
+	if fPostmortemLines <= 0 {
+		cliFatal("--log-lines must be positive")
+	}
+	return nil
+}
+
+func measPostmortem(cmd *cobra.Command, args []string) {
+	measurement, err := GetMeasurementAllDetails(args[0])
+	if err != nil {
+		fatal(err)
+	}
+	report := postmortemReport(measurement)
+	if common.RootFlagBool("stdout") {
+		fmt.Print(report)
+		return
+	}
+	f, err := common.CreateTemp("irisctl-meas-postmortem-*.md")
+	if err != nil {
+		fatal(err)
+	}
+	defer f.Close()
+	common.SavingIn(f.Name())
+	if _, err := f.WriteString(report); err != nil {
+		fatal(err)
+	}
+}
+
+// postmortemReport returns a markdown report of the measurement for
+// incident tickets.  Sections whose data cannot be retrieved record
+// the error instead of failing the whole report.
+func postmortemReport(measurement common.Measurement) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Postmortem of measurement %s\n\n", measurement.UUID)
+	fmt.Fprintf(&b, "Generated on %s from %s.\n\n", time.Now().UTC().Format(time.RFC3339), common.RootFlagString("iris-api-url"))
+	fmt.Fprintf(&b, "| | |\n|---|---|\n")
+	fmt.Fprintf(&b, "| tool | %s |\n", measurement.Tool)
+	fmt.Fprintf(&b, "| state | %s |\n", measurement.State)
+	fmt.Fprintf(&b, "| user | %s |\n", measurement.UserID)
+	fmt.Fprintf(&b, "| tags | %s |\n", strings.Join(measurement.Tags, ", "))
+
+	fmt.Fprintf(&b, "\n## Timeline\n\n| time | event |\n|---|---|\n")
+	for _, e := range measTimeline(measurement) {
+		fmt.Fprintf(&b, "| %s | %s |\n", e.time, e.event)
+	}
+
+	fmt.Fprintf(&b, "\n## Agents\n\n| agent | hostname | state | target file | rounds | packets sent | packets received |\n|---|---|---|---|---|---|---|\n")
+	for _, a := range measurement.Agents {
+		sent, received := 0, 0
+		for _, stats := range a.ProbingStatistics {
+			sent += stats.PacketsSent
+			received += stats.PacketsReceived
+		}
+		fmt.Fprintf(&b, "| %s | %s | %s | %s | %d | %d | %d |\n", a.AgentUUID, a.AgentParameters.Hostname, a.State, a.TargetFile, len(a.ProbingStatistics), sent, received)
+	}
+
+	fmt.Fprintf(&b, "\n## Container logs\n\n")
+	if fPostmortemNoLogs {
+		fmt.Fprintf(&b, "_Not collected (--no-logs)._\n")
+	}
+	for _, a := range measurement.Agents {
+		if fPostmortemNoLogs {
+			break
+		}
+		hostname := a.AgentParameters.Hostname
+		fmt.Fprintf(&b, "### %s\n\n", hostname)
+		lines, err := relevantLogLines(hostname, measurement.UUID, fPostmortemLines)
+		switch {
+		case err != nil:
+			fmt.Fprintf(&b, "_Not collected: %s._\n\n", oneLine(err.Error()))
+		case len(lines) == 0:
+			fmt.Fprintf(&b, "_No relevant lines in the last %d lines._\n\n", postmortemLogTail)
+		default:
+			fmt.Fprintf(&b, "```\n%s\n```\n\n", strings.Join(lines, "\n"))
+		}
+	}
+
+	fmt.Fprintf(&b, "\n## Dramatiq messages\n\n")
+	messages, err := measDramatiqMessages(measurement.UUID, fPostmortemQueues)
+	switch {
+	case err != nil:
+		fmt.Fprintf(&b, "_Not collected: %s._\n", oneLine(err.Error()))
+	case len(messages) == 0:
+		fmt.Fprintf(&b, "_No messages for this measurement in queue(s) %s._\n", strings.Join(fPostmortemQueues, ", "))
+	default:
+		fmt.Fprintf(&b, "| time | queue | actor | message ID | args |\n|---|---|---|---|---|\n")
+		for _, m := range messages {
+			args, _ := json.Marshal(m.Args)
+			fmt.Fprintf(&b, "| %s | %s | %s | %s | `%s` |\n", time.UnixMilli(m.MessageTimestamp).UTC().Format(time.RFC3339), m.QueueName, m.ActorName, m.MessageID, args)
+		}
+	}
+
+	fmt.Fprintf(&b, "\n## Tables\n\n")
+	rows, err := TableRowCounts(measurement.UUID)
+	switch {
+	case err != nil:
+		fmt.Fprintf(&b, "_Not collected: %s._\n", oneLine(err.Error()))
+	case len(rows) == 0:
+		fmt.Fprintf(&b, "_No tables._\n")
+	default:
+		var tables []string
+		for table := range rows {
+			tables = append(tables, table)
+		}
+		sort.Strings(tables)
+		fmt.Fprintf(&b, "| table | rows |\n|---|---|\n")
+		for _, table := range tables {
+			fmt.Fprintf(&b, "| %s | %d |\n", table, rows[table])
+		}
+	}
+	return b.String()
+}
+
+// measTimeline returns the creation, start, and end of the measurement
+// and the start and end of each round of each agent in time order.
+func measTimeline(measurement common.Measurement) []timelineEvent {
+	var events []timelineEvent
+	add := func(t time.Time, event string) {
+		if !t.IsZero() {
+			events = append(events, timelineEvent{t.UTC().Format(time.RFC3339), event})
+		}
+	}
+	add(measurement.CreationTime.Time, "measurement created")
+	add(measurement.StartTime.Time, "measurement started")
+	for _, a := range measurement.Agents {
+		hostname := a.AgentParameters.Hostname
+		for round, stats := range a.ProbingStatistics {
+			for _, e := range []struct{ t, what string }{{stats.StartTime, "started"}, {stats.EndTime, "ended"}} {
+				var t common.CustomTime
+				if err := t.UnmarshalJSON([]byte(strconv.Quote(e.t))); err != nil {
+					continue
+				}
+				add(t.Time, fmt.Sprintf("%s round %s %s", hostname, round, e.what))
+			}
+		}
+	}
+	add(measurement.EndTime.Time, fmt.Sprintf("measurement ended (%s)", measurement.State))
+	sort.SliceStable(events, func(i, j int) bool { return events[i].time < events[j].time })
+	return events
+}
+
+// relevantLogLines returns the last n lines of the container logs of
+// the agent that mention the measurement or an error.
+func relevantLogLines(hostname, measUUID string, n int) ([]string, error) {
+	output, err := common.SSH(hostname, fmt.Sprintf("docker logs --timestamps --tail %d iris-agent", postmortemLogTail))
+	if err != nil {
+		return nil, err
+	}
+	var lines []string
+	for _, line := range output[1:] { // the first line is the hostname
+		line = strings.TrimRight(line, "\r\n")
+		if strings.Contains(line, measUUID) || strings.Contains(strings.ToLower(line), "error") {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return lines, nil
+}
+
+// measDramatiqMessages returns the messages of the specified dramatiq
+// queues whose arguments mention the measurement.
+func measDramatiqMessages(measUUID string, queues []string) ([]DramatiqMessage, error) {
+	var messages []DramatiqMessage
+	for _, queue := range queues {
+		queueMessages, err := GetDramatiqMessages(queue)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range queueMessages {
+			args, _ := json.Marshal([]interface{}{m.Args, m.Kwargs})
+			if bytes.Contains(args, []byte(measUUID)) {
+				messages = append(messages, m)
+			}
+		}
+	}
+	sort.SliceStable(messages, func(i, j int) bool { return messages[i].MessageTimestamp < messages[j].MessageTimestamp })
+	return messages, nil
+}
+
+// GetDramatiqMessages returns the messages of the specified dramatiq
+// queue.
+func GetDramatiqMessages(queue string) ([]DramatiqMessage, error) {
+	url := fmt.Sprintf("%s/dq/%s/messages", common.APIEndpoint(common.MaintenanceAPISuffix), queue)
+	jsonData, err := common.Curl(auth.GetAccessToken(), false, "GET", url)
+	if err != nil {
+		return nil, err
+	}
+	var messages []DramatiqMessage
+	if err := json.Unmarshal(jsonData, &messages); err != nil {
+		return nil, fmt.Errorf("%s: %v: %s", queue, err, bytes.TrimSpace(jsonData))
+	}
+	return messages, nil
+}
+
+// oneLine returns s on one line so that it fits in a markdown line.
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
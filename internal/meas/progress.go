@@ -0,0 +1,121 @@
+package meas
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dioptra-io/irisctl/internal/common"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// roundProgress defines the progress of an agent in its current round.
+type roundProgress struct {
+	round      int
+	sent       int
+	probesRead int
+	// rate is the number of probes read per second in the current
+	// round or zero if it is not known.
+	rate float64
+}
+
+func measProgressArgs(cmd *cobra.Command, args []string) error {
+	if format, ok := common.IsUsage(args); ok {
+		fmt.Printf(format, "<meas-uuid>", "measurement UUID")
+		return nil
+	}
+	if len(args) != 1 {
+		cliFatal("meas progress requires exactly one argument: <meas-uuid>")
+	}
+	if err := common.ValidateFormat(args, common.MeasurementUUID); err != nil {
+		cliFatal(err)
+	}
+	if fProgressInterval <= 0 {
+		cliFatal("--interval must be positive")
+	}
+	return nil
+}
+
+func measProgress(cmd *cobra.Command, args []string) {
+	if fProgressFollow {
+		// Live output is not paged.
+		common.StopPager()
+	}
+	clear := fProgressFollow && term.IsTerminal(int(os.Stdout.Fd()))
+	for i := 0; ; i++ {
+		jsonData, err := GetMeasurementUncached(args[0])
+		if err != nil {
+			fatal(err)
+		}
+		var measurement common.Measurement
+		if err := common.DecodeJSON(jsonData, &measurement); err != nil {
+			fatal(err)
+		}
+		if clear {
+			fmt.Print("\033[H\033[2J")
+		} else if i > 0 {
+			fmt.Println()
+		}
+		printProgress(os.Stdout, measurement, time.Now())
+		if !fProgressFollow || common.Contains(terminalStates, measurement.State) {
+			return
+		}
+		time.Sleep(fProgressInterval)
+	}
+}
+
+// printProgress prints the current round of each agent of the
+// measurement with its packets sent and probes read.
+func printProgress(out io.Writer, measurement common.Measurement, now time.Time) {
+	fmt.Fprintf(out, "%s %s %s\n\n", now.Format("2006-01-02 15:04:05"), measurement.UUID, measurement.State)
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(w, "agent\tstate\tround\tpackets sent\tprobes read\tprobes read/s\t\n")
+	for _, agent := range measurement.Agents {
+		p := getRoundProgress(agent, now)
+		maxRound, rate := "-", "-"
+		if agent.ToolParameters.MaxRound > 0 {
+			maxRound = strconv.Itoa(agent.ToolParameters.MaxRound)
+		}
+		if p.rate > 0 {
+			rate = fmt.Sprintf("%.0f", p.rate)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d/%s\t%d\t%d\t%s\t\n", agentName(agent), agent.State, p.round, maxRound, p.sent, p.probesRead, rate)
+	}
+	w.Flush()
+}
+
+// getRoundProgress returns the progress of the agent in its current
+// (i.e., highest) round.  Rounds that have not ended are timed until
+// now.
+func getRoundProgress(agent common.Agent, now time.Time) roundProgress {
+	var p roundProgress
+	for _, stats := range agent.ProbingStatistics {
+		p.sent += stats.PacketsSent
+		if stats.Round.Number > p.round {
+			p.round = stats.Round.Number
+		}
+	}
+	var elapsed time.Duration
+	for _, stats := range agent.ProbingStatistics {
+		if stats.Round.Number != p.round {
+			continue
+		}
+		p.probesRead += stats.ProbesRead
+		var start, end common.CustomTime
+		if err := start.UnmarshalJSON([]byte(strconv.Quote(stats.StartTime))); err != nil || start.IsZero() {
+			continue
+		}
+		if err := end.UnmarshalJSON([]byte(strconv.Quote(stats.EndTime))); err != nil || end.IsZero() {
+			end.Time = now
+		}
+		elapsed += end.Sub(start.Time)
+	}
+	if elapsed > 0 {
+		p.rate = float64(p.probesRead) / elapsed.Seconds()
+	}
+	return p
+}
@@ -0,0 +1,191 @@
+package meas
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dioptra-io/irisctl/internal/common"
+)
+
+const (
+	// RecurringTagPrefix is the prefix of the tag that identifies the
+	// measurements requested for a recurring measurement definition.
+	RecurringTagPrefix = "apply:"
+)
+
+var (
+	ErrInvalidRecurring = errors.New("invalid recurring measurement")
+)
+
+// RecurringMeasurement defines a measurement that should be requested
+// again every Every.  Request is a JSON measurement definition in the
+// format of meas request.
+type RecurringMeasurement struct {
+	Name    string
+	Every   time.Duration
+	Request []byte
+}
+
+// ApplyMeasurements requests the recurring measurements that are due
+// (i.e., that have never been requested or whose last request is older
+// than their period and is not ongoing) and returns the number of
+// requested measurements.  If dryRun is true, the due measurements are
+// printed but not requested.
+func ApplyMeasurements(recurring []RecurringMeasurement, dryRun bool) (int, error) {
+	// Requests are kept as generic JSON objects so that fields that
+	// are not specified (e.g., tool_parameters) keep their defaults.
+	requests := make([]map[string]interface{}, len(recurring))
+	for i, r := range recurring {
+		if r.Name == "" || r.Every <= 0 {
+			return 0, fmt.Errorf("%q: %w: name and a positive period are required", r.Name, ErrInvalidRecurring)
+		}
+		if _, problems := parseMeasDefinition(r.Request); len(problems) > 0 {
+			return 0, fmt.Errorf("%s: %w: %s", r.Name, ErrInvalidRecurring, strings.Join(problems, "; "))
+		}
+		if err := json.Unmarshal(r.Request, &requests[i]); err != nil {
+			return 0, fmt.Errorf("%s: %w: %v", r.Name, ErrInvalidRecurring, err)
+		}
+	}
+	measMdFile, err := GetMeasMdFile(false)
+	if err != nil {
+		return 0, err
+	}
+	measurements, err := common.GetMeasurementsSorted(measMdFile)
+	if err != nil {
+		return 0, err
+	}
+
+	nRequested := 0
+	for i, r := range recurring {
+		tag := RecurringTagPrefix + r.Name
+		var last *common.Measurement
+		for j := range measurements {
+			m := &measurements[j]
+			if common.Contains(m.Tags, tag) && (last == nil || m.CreationTime.After(last.CreationTime.Time)) {
+				last = m
+			}
+		}
+		switch {
+		case last == nil:
+			verbose("recurring measurement %s has never been requested\n", r.Name)
+		case last.State == "ongoing":
+			verbose("recurring measurement %s is ongoing (%s)\n", r.Name, last.UUID)
+			continue
+		case time.Since(last.CreationTime.Time) < r.Every:
+			verbose("recurring measurement %s is not due (last requested %s)\n", r.Name, last.CreationTime.Format("2006-01-02 15:04:05"))
+			continue
+		}
+		nRequested++
+		if dryRun {
+			fmt.Printf("dry-run: would request recurring measurement %s\n", r.Name)
+			continue
+		}
+		measUUID, err := postTaggedMeasurement(requests[i], tag)
+		if err != nil {
+			return nRequested, fmt.Errorf("%s: %w", r.Name, err)
+		}
+		fmt.Printf("requested recurring measurement %s (%s)\n", r.Name, measUUID)
+	}
+	return nRequested, nil
+}
+
+// postTaggedMeasurement requests the measurement defined by request (a
+// generic JSON measurement definition) with tag added to its tags and
+// returns the UUID of the requested measurement.
+func postTaggedMeasurement(request map[string]interface{}, tag string) (string, error) {
+	tags, _ := request["tags"].([]interface{})
+	request["tags"] = append(append([]interface{}{}, tags...), tag)
+	data, err := json.Marshal(request)
+	if err != nil {
+		return "", err
+	}
+	jsonData, err := postMeasurement(data)
+	if err != nil {
+		fmt.Println(string(jsonData))
+		return "", err
+	}
+	var measurement common.Measurement
+	if err := json.Unmarshal(jsonData, &measurement); err != nil {
+		return "", fmt.Errorf("%v: %s", err, jsonData)
+	}
+	if measurement.UUID == "" {
+		return "", fmt.Errorf("request failed: %s", jsonData)
+	}
+	return measurement.UUID, nil
+}
+
+// GetRecurringMeasurements returns the recurring measurements that
+// were requested by ApplyMeasurements.  The request of each one is
+// that of its most recent measurement and its period is the interval
+// between its two most recent measurements (or defaultEvery if it has
+// only one).
+func GetRecurringMeasurements(defaultEvery time.Duration) ([]RecurringMeasurement, error) {
+	measMdFile, err := GetMeasMdFile(false)
+	if err != nil {
+		return nil, err
+	}
+	measurements, err := common.GetMeasurementsSorted(measMdFile)
+	if err != nil {
+		return nil, err
+	}
+	// Creation times of the measurements of each recurring measurement
+	// and its most recent measurement.
+	creations := make(map[string][]time.Time)
+	last := make(map[string]common.Measurement)
+	for _, m := range measurements {
+		for _, tag := range m.Tags {
+			if !strings.HasPrefix(tag, RecurringTagPrefix) {
+				continue
+			}
+			name := strings.TrimPrefix(tag, RecurringTagPrefix)
+			creations[name] = append(creations[name], m.CreationTime.Time)
+			if l, ok := last[name]; !ok || m.CreationTime.After(l.CreationTime.Time) {
+				last[name] = m
+			}
+		}
+	}
+	var names []string
+	for name := range last {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var recurring []RecurringMeasurement
+	for _, name := range names {
+		// The metadata file does not have all the details of agents.
+		measurement, err := GetMeasurementAllDetails(last[name].UUID)
+		if err != nil {
+			return nil, err
+		}
+		request, err := cloneRequest(measurement, nil)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "WARNING: recurring measurement %s: %v\n", name, err)
+			continue
+		}
+		var tags []string
+		for _, tag := range request.Tags {
+			if !strings.HasPrefix(tag, RecurringTagPrefix) && !strings.HasPrefix(tag, CloneTagPrefix) {
+				tags = append(tags, tag)
+			}
+		}
+		request.Tags = tags
+		data, err := json.Marshal(request)
+		if err != nil {
+			return nil, err
+		}
+		every := defaultEvery
+		if times := creations[name]; len(times) > 1 {
+			sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+			if d := times[len(times)-1].Sub(times[len(times)-2]).Round(time.Minute); d > 0 {
+				every = d
+			}
+		}
+		recurring = append(recurring, RecurringMeasurement{Name: name, Every: every, Request: data})
+	}
+	return recurring, nil
+}
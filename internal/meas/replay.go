@@ -0,0 +1,152 @@
+package meas
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/dioptra-io/irisctl/internal/agents"
+	"github.com/dioptra-io/irisctl/internal/common"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// ReplayTagPrefix is the prefix of the tag that records the UUID
+	// of the original measurement of a replayed measurement.
+	ReplayTagPrefix = "replay:"
+)
+
+var (
+	ErrSameInstance    = errors.New("source and destination are the same Iris instance")
+	ErrUnmatchedAgents = errors.New("no matching agents in the destination Iris instance")
+)
+
+// MeasurementRequest defines the body of a measurement request.
+type MeasurementRequest struct {
+	Tool   string                    `json:"tool"`
+	Agents []MeasurementRequestAgent `json:"agents"`
+	Tags   []string                  `json:"tags"`
+}
+
+// MeasurementRequestAgent defines the agent(s) of a measurement
+// request, which are specified by either their UUID or a tag.
+type MeasurementRequestAgent struct {
+	UUID           string                `json:"uuid,omitempty"`
+	Tag            string                `json:"tag,omitempty"`
+	TargetFile     string                `json:"target_file"`
+	BatchSize      interface{}           `json:"batch_size,omitempty"`
+	ProbingRate    interface{}           `json:"probing_rate,omitempty"`
+	ToolParameters common.ToolParameters `json:"tool_parameters"`
+}
+
+func measReplayArgs(cmd *cobra.Command, args []string) error {
+	if format, ok := common.IsUsage(args); ok {
+		fmt.Printf(format, "<meas-uuid>", "measurement UUID")
+		return nil
+	}
+	if len(args) != 1 {
+		cliFatal("meas replay requires exactly one argument: <meas-uuid>")
+	}
+	if err := common.ValidateFormat(args, common.MeasurementUUID); err != nil {
+		cliFatal(err)
+	}
+	if fReplayProfile == "" {
+		cliFatal("meas replay requires --to-profile <profile>")
+	}
+	return nil
+}
+
+func measReplay(cmd *cobra.Command, args []string) {
+	measurement, err := GetMeasurementAllDetails(args[0])
+	if err != nil {
+		fatal(err)
+	}
+	srcURL := common.RootFlagString("iris-api-url")
+	if err := common.UseProfile(fReplayProfile); err != nil {
+		fatal(err)
+	}
+	if common.RootFlagString("iris-api-url") == srcURL {
+		fatal(fmt.Errorf("%w: %s", ErrSameInstance, srcURL))
+	}
+	request, err := replayRequest(measurement, fReplayAgentTag)
+	if err != nil {
+		fatal(err)
+	}
+	data, err := json.MarshalIndent(request, "", "  ")
+	if err != nil {
+		fatal(err)
+	}
+	if fReplayDryRun {
+		fmt.Printf("dry-run: would request on %s:\n%s\n", common.RootFlagString("iris-api-url"), data)
+		return
+	}
+	jsonData, err := postMeasurement(data)
+	if err != nil {
+		fmt.Println(string(jsonData))
+		fatal(err)
+	}
+	if err := common.SaveOrPrint(jsonData, "irisctl-meas-replay-"); err != nil {
+		fatal(err)
+	}
+}
+
+// replayRequest returns a request for the same measurement in the
+// current Iris instance.  If agentTag is specified, the measurement
+// runs on all agents with that tag (with the parameters of the first
+// agent of the original measurement).  Otherwise, each agent of the
+// original measurement is mapped to the agent with the same hostname.
+func replayRequest(measurement common.Measurement, agentTag string) (MeasurementRequest, error) {
+	request := MeasurementRequest{
+		Tool: measurement.Tool,
+		Tags: append(append([]string{}, measurement.Tags...), ReplayTagPrefix+measurement.UUID),
+	}
+	if len(measurement.Agents) == 0 {
+		return request, fmt.Errorf("%s: %w", measurement.UUID, ErrUnmatchedAgents)
+	}
+	if agentTag != "" {
+		a := measurement.Agents[0]
+		request.Agents = append(request.Agents, MeasurementRequestAgent{
+			Tag:            agentTag,
+			TargetFile:     a.TargetFile,
+			BatchSize:      a.BatchSize,
+			ProbingRate:    a.ProbingRate,
+			ToolParameters: a.ToolParameters,
+		})
+		return request, nil
+	}
+
+	jsonData, err := agents.GetAgents("", false)
+	if err != nil {
+		return request, err
+	}
+	var agentsData common.AgentsData
+	if err := common.DecodeJSON(jsonData, &agentsData); err != nil {
+		return request, err
+	}
+	dstAgents := make(map[string]string)
+	for _, result := range agentsData.Results {
+		dstAgents[result.Parameters.Hostname] = result.UUID
+	}
+	var unmatched []string
+	for _, a := range measurement.Agents {
+		hostname := a.AgentParameters.Hostname
+		uuid, ok := dstAgents[hostname]
+		if !ok {
+			unmatched = append(unmatched, hostname)
+			continue
+		}
+		verbose("mapping agent %s (%s) to %s\n", hostname, a.AgentUUID, uuid)
+		request.Agents = append(request.Agents, MeasurementRequestAgent{
+			UUID:           uuid,
+			TargetFile:     a.TargetFile,
+			BatchSize:      a.BatchSize,
+			ProbingRate:    a.ProbingRate,
+			ToolParameters: a.ToolParameters,
+		})
+	}
+	if len(unmatched) > 0 {
+		return request, fmt.Errorf("%w: %s (use --agent-tag to select agents by tag)", ErrUnmatchedAgents, strings.Join(unmatched, " "))
+	}
+	return request, nil
+}
@@ -0,0 +1,93 @@
+package meas
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/dioptra-io/irisctl/internal/common"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// RetryTagPrefix is the prefix of the tag that records the UUID
+	// of the original measurement of a retried measurement.
+	RetryTagPrefix = "retry:"
+)
+
+var (
+	ErrNotAgentFailure = errors.New("measurement is not in agent_failure state")
+	ErrNoFailedAgents  = errors.New("measurement has no failed agents")
+)
+
+func measRetryArgs(cmd *cobra.Command, args []string) error {
+	if format, ok := common.IsUsage(args); ok {
+		fmt.Printf(format, "<meas-uuid>", "measurement UUID")
+		return nil
+	}
+	if len(args) != 1 {
+		cliFatal("meas retry requires exactly one argument: <meas-uuid>")
+	}
+	if err := common.ValidateFormat(args, common.MeasurementUUID); err != nil {
+		cliFatal(err)
+	}
+	return nil
+}
+
+func measRetry(cmd *cobra.Command, args []string) {
+	measurement, err := GetMeasurementAllDetails(args[0])
+	if err != nil {
+		fatal(err)
+	}
+	request, err := retryRequest(measurement)
+	if err != nil {
+		fatal(err)
+	}
+	data, err := json.MarshalIndent(request, "", "  ")
+	if err != nil {
+		fatal(err)
+	}
+	if fRetryDryRun {
+		fmt.Printf("dry-run: would request:\n%s\n", data)
+		return
+	}
+	jsonData, err := postMeasurement(data)
+	if err != nil {
+		fmt.Println(string(jsonData))
+		fatal(err)
+	}
+	if err := common.SaveOrPrint(jsonData, "irisctl-meas-retry-"); err != nil {
+		fatal(err)
+	}
+}
+
+// retryRequest returns a request for the same measurement on the
+// agents of the measurement that failed.  The tag RetryTagPrefix<uuid>
+// is added to the tags of the original measurement.
+func retryRequest(measurement common.Measurement) (MeasurementRequest, error) {
+	request := MeasurementRequest{
+		Tool: measurement.Tool,
+		Tags: append(append([]string{}, measurement.Tags...), RetryTagPrefix+measurement.UUID),
+	}
+	if measurement.State != "agent_failure" {
+		return request, fmt.Errorf("%s: %w (%s)", measurement.UUID, ErrNotAgentFailure, measurement.State)
+	}
+	for _, a := range measurement.Agents {
+		if a.State != "agent_failure" {
+			verbose("skipping agent %s in %s state\n", agentName(a), a.State)
+			continue
+		}
+		verbose("retrying agent %s\n", agentName(a))
+		request.Agents = append(request.Agents, MeasurementRequestAgent{
+			UUID:           a.AgentUUID,
+			TargetFile:     a.TargetFile,
+			BatchSize:      a.BatchSize,
+			ProbingRate:    a.ProbingRate,
+			ToolParameters: a.ToolParameters,
+		})
+	}
+	if len(request.Agents) == 0 {
+		return request, fmt.Errorf("%s: %w", measurement.UUID, ErrNoFailedAgents)
+	}
+	return request, nil
+}
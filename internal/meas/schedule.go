@@ -0,0 +1,335 @@
+package meas
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dioptra-io/irisctl/internal/common"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// ScheduleTagPrefix is the prefix of the tag that identifies the
+	// measurements requested by meas schedule run.
+	ScheduleTagPrefix = "schedule:"
+	// ScheduleRecordsFile is the file (in the irisctl directory) that
+	// records the measurements requested by meas schedule run.
+	ScheduleRecordsFile = "schedule.jsonl"
+)
+
+var (
+	ErrNoSchedules     = errors.New("no schedules in the configuration file")
+	ErrInvalidSchedule = errors.New("invalid schedule")
+	ErrInvalidCron     = errors.New("invalid cron expression")
+
+	// Minimum and maximum values of the fields of cron expressions.
+	cronRanges = [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+)
+
+// schedule is a schedule of the configuration file with its parsed
+// cron expression.
+type schedule struct {
+	common.Schedule
+	spec cronSpec
+}
+
+// cronSpec defines the values allowed by each field of a cron
+// expression.
+type cronSpec struct {
+	fields [5][]bool
+	// Like cron, if both the day of month and the day of week are
+	// restricted, a time matches if either of them matches.
+	domStar bool
+	dowStar bool
+}
+
+// scheduleRecord defines a line of the schedule records file.
+type scheduleRecord struct {
+	Name     string    `json:"name"`
+	Time     time.Time `json:"time"`
+	MeasFile string    `json:"meas_file"`
+	UUID     string    `json:"uuid"`
+}
+
+func measScheduleArgs(cmd *cobra.Command, args []string) error {
+	if _, ok := common.IsUsage(args); ok {
+		return nil
+	}
+	if len(args) != 0 {
+		cliFatal("unknown subcommand: ", args[0])
+	}
+	return nil
+}
+
+func measSchedule(cmd *cobra.Command, args []string) {
+	schedules, err := loadSchedules()
+	if err != nil {
+		fatal(err)
+	}
+	records, err := lastScheduleRecords()
+	if err != nil {
+		fatal(err)
+	}
+	now := time.Now()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "name\tcron\tmeas file\tlast request\tlast UUID\tnext request\n")
+	for _, s := range schedules {
+		last, uuid := "-", "-"
+		if r, ok := records[s.Name]; ok {
+			last, uuid = r.Time.Format("2006-01-02 15:04"), r.UUID
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", s.Name, s.Cron, s.MeasFile, last, uuid, formatNext(s.spec.next(now)))
+	}
+	w.Flush()
+}
+
+func measScheduleRunArgs(cmd *cobra.Command, args []string) error {
+	if _, ok := common.IsUsage(args); ok {
+		return nil
+	}
+	if len(args) != 0 {
+		cliFatal("meas schedule run does not take any arguments")
+	}
+	return nil
+}
+
+func measScheduleRun(cmd *cobra.Command, args []string) {
+	schedules, err := loadSchedules()
+	if err != nil {
+		fatal(err)
+	}
+	// Like cron, missed times (e.g., while irisctl was not running)
+	// are not caught up.
+	next := make([]time.Time, len(schedules))
+	now := time.Now()
+	for i, s := range schedules {
+		if next[i] = s.spec.next(now); next[i].IsZero() {
+			fmt.Fprintf(os.Stderr, "%s schedule %s: %q never matches\n", common.Warning(common.WarnCronNeverMatches), s.Name, s.Cron)
+		}
+		fmt.Printf("schedule %s: next request at %s\n", s.Name, formatNext(next[i]))
+	}
+	for {
+		var wake time.Time
+		for _, t := range next {
+			if !t.IsZero() && (wake.IsZero() || t.Before(wake)) {
+				wake = t
+			}
+		}
+		if wake.IsZero() {
+			fatal(ErrNoSchedules)
+		}
+		verbose("sleeping until %s\n", formatNext(wake))
+		time.Sleep(time.Until(wake))
+		for i, s := range schedules {
+			if next[i].IsZero() || next[i].After(wake) {
+				continue
+			}
+			if err := runSchedule(s, wake); err != nil {
+				fmt.Fprintf(os.Stderr, "WARNING: schedule %s: %v\n", s.Name, err)
+			}
+			next[i] = s.spec.next(wake)
+		}
+	}
+}
+
+// loadSchedules returns the schedules of the configuration file after
+// validating them.
+func loadSchedules() ([]schedule, error) {
+	entries, err := common.Schedules()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSchedule, err)
+	}
+	if len(entries) == 0 {
+		return nil, ErrNoSchedules
+	}
+	var schedules []schedule
+	names := make(map[string]bool)
+	for i, entry := range entries {
+		if entry.Name == "" || entry.Cron == "" || entry.MeasFile == "" {
+			return nil, fmt.Errorf("schedules[%d]: %w: name, cron, and meas-file are required", i, ErrInvalidSchedule)
+		}
+		if names[entry.Name] {
+			return nil, fmt.Errorf("%s: %w: duplicate name", entry.Name, ErrInvalidSchedule)
+		}
+		names[entry.Name] = true
+		spec, err := parseCron(entry.Cron)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Name, err)
+		}
+		if _, err := common.CheckFile("measurement", entry.MeasFile); err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Name, err)
+		}
+		schedules = append(schedules, schedule{entry, spec})
+	}
+	return schedules, nil
+}
+
+// runSchedule requests the measurement of the schedule and records its
+// UUID.  The measurement file is read on every request so that it can
+// be edited without restarting meas schedule run.
+func runSchedule(s schedule, t time.Time) error {
+	contents, err := os.ReadFile(s.MeasFile)
+	if err != nil {
+		return err
+	}
+	if _, problems := parseMeasDefinition(contents); len(problems) > 0 {
+		return fmt.Errorf("%s: %w: %s", s.MeasFile, ErrInvalidMeasFile, strings.Join(problems, "; "))
+	}
+	var request map[string]interface{}
+	if err := json.Unmarshal(contents, &request); err != nil {
+		return fmt.Errorf("%s: %w: %v", s.MeasFile, ErrInvalidMeasFile, err)
+	}
+	measUUID, err := postTaggedMeasurement(request, ScheduleTagPrefix+s.Name)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s: requested %s (%s)\n", t.Format("2006-01-02 15:04"), s.Name, measUUID)
+	return appendScheduleRecord(scheduleRecord{Name: s.Name, Time: t, MeasFile: s.MeasFile, UUID: measUUID})
+}
+
+// scheduleRecordsFile returns the path of the schedule records file.
+func scheduleRecordsFile() (string, error) {
+	irisDir, err := common.IrisDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(irisDir, ScheduleRecordsFile), nil
+}
+
+func appendScheduleRecord(r scheduleRecord) error {
+	file, err := scheduleRecordsFile()
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = fmt.Fprintf(f, "%s\n", data)
+	return err
+}
+
+// lastScheduleRecords returns the most recent record of each schedule.
+func lastScheduleRecords() (map[string]scheduleRecord, error) {
+	records := make(map[string]scheduleRecord)
+	file, err := scheduleRecordsFile()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return records, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var r scheduleRecord
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			fmt.Fprintf(os.Stderr, "WARNING: %s: %v\n", file, err)
+			continue
+		}
+		if last, ok := records[r.Name]; !ok || r.Time.After(last.Time) {
+			records[r.Name] = r
+		}
+	}
+	return records, scanner.Err()
+}
+
+// parseCron parses a cron expression with five fields (minute, hour,
+// day of month, month, day of week).  Each field is a comma-separated
+// list of *, values, or ranges, each optionally followed by /step.
+func parseCron(expr string) (cronSpec, error) {
+	var spec cronSpec
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return spec, fmt.Errorf("%q: %w: expected 5 fields", expr, ErrInvalidCron)
+	}
+	for i, field := range fields {
+		lo, hi := cronRanges[i][0], cronRanges[i][1]
+		spec.fields[i] = make([]bool, hi+1)
+		for _, part := range strings.Split(field, ",") {
+			values, step := part, 1
+			if j := strings.Index(part, "/"); j >= 0 {
+				n, err := strconv.Atoi(part[j+1:])
+				if err != nil || n <= 0 {
+					return spec, fmt.Errorf("%q: %w: invalid step %q", expr, ErrInvalidCron, part)
+				}
+				values, step = part[:j], n
+			}
+			first, last := lo, hi
+			if values != "*" {
+				bounds := strings.SplitN(values, "-", 2)
+				var err1, err2 error
+				first, err1 = strconv.Atoi(bounds[0])
+				last = first
+				if len(bounds) == 2 {
+					last, err2 = strconv.Atoi(bounds[1])
+				} else if step > 1 {
+					last = hi
+				}
+				if err1 != nil || err2 != nil {
+					return spec, fmt.Errorf("%q: %w: invalid value %q", expr, ErrInvalidCron, part)
+				}
+			}
+			if first < lo || last > hi || first > last {
+				return spec, fmt.Errorf("%q: %w: %q is not in %d-%d", expr, ErrInvalidCron, part, lo, hi)
+			}
+			for v := first; v <= last; v += step {
+				spec.fields[i][v] = true
+			}
+		}
+	}
+	// Both 0 and 7 are Sunday.
+	spec.fields[4][0] = spec.fields[4][0] || spec.fields[4][7]
+	spec.domStar = fields[2] == "*"
+	spec.dowStar = fields[4] == "*"
+	return spec, nil
+}
+
+// matches returns true if the minute of t is allowed by the spec.
+func (c cronSpec) matches(t time.Time) bool {
+	if !c.fields[0][t.Minute()] || !c.fields[1][t.Hour()] || !c.fields[3][int(t.Month())] {
+		return false
+	}
+	dom, dow := c.fields[2][t.Day()], c.fields[4][int(t.Weekday())]
+	if c.domStar || c.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// next returns the first minute after t that matches the spec or the
+// zero time if none does in the next five years (e.g., February 30).
+func (c cronSpec) next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	for end := t.AddDate(5, 0, 0); t.Before(end); t = t.Add(time.Minute) {
+		if c.matches(t) {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// formatNext returns t in local time or "never" if t is zero.
+func formatNext(t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	return t.Format("2006-01-02 15:04")
+}
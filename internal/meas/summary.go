@@ -0,0 +1,117 @@
+package meas
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dioptra-io/irisctl/internal/common"
+	"github.com/spf13/cobra"
+)
+
+func measSummaryArgs(cmd *cobra.Command, args []string) error {
+	if format, ok := common.IsUsage(args); ok {
+		fmt.Printf(format, "<meas-uuid>", "measurement UUID")
+		return nil
+	}
+	if len(args) != 1 {
+		cliFatal("meas summary requires exactly one argument: <meas-uuid>")
+	}
+	if err := common.ValidateFormat(args, common.MeasurementUUID); err != nil {
+		cliFatal(err)
+	}
+	return nil
+}
+
+func measSummary(cmd *cobra.Command, args []string) {
+	measurement, err := GetMeasurementAllDetails(args[0])
+	if err != nil {
+		fatal(err)
+	}
+	printSummary(os.Stdout, measurement)
+}
+
+// printSummary prints the metadata of the measurement, the probing
+// statistics of its agents, and the row counts of its tables.
+func printSummary(out io.Writer, measurement common.Measurement) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "measurement\t%s\n", measurement.UUID)
+	fmt.Fprintf(w, "tool\t%s\n", measurement.Tool)
+	fmt.Fprintf(w, "state\t%s\n", measurement.State)
+	fmt.Fprintf(w, "tags\t%s\n", strings.Join(measurement.Tags, ", "))
+	fmt.Fprintf(w, "created\t%s\n", summaryTime(measurement.CreationTime.Time))
+	fmt.Fprintf(w, "started\t%s\n", summaryTime(measurement.StartTime.Time))
+	fmt.Fprintf(w, "ended\t%s\n", summaryTime(measurement.EndTime.Time))
+	if !measurement.StartTime.IsZero() && !measurement.EndTime.IsZero() {
+		fmt.Fprintf(w, "duration\t%v\n", measurement.EndTime.Sub(measurement.StartTime.Time).Round(time.Second))
+	}
+	w.Flush()
+
+	fmt.Fprintf(out, "\nagents\n")
+	w = tabwriter.NewWriter(out, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(w, "agent\tstate\trounds\tsent\treceived\treceived%%\tpcap dropped\tinterface dropped\t\n")
+	for _, agent := range measurement.Agents {
+		t := getAgentTotals(agent)
+		ratio := "-"
+		if t.sent > 0 {
+			ratio = fmt.Sprintf("%.1f", 100*float64(t.received)/float64(t.sent))
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%s\t%d\t%d\t\n", agentName(agent), agent.State, t.rounds, t.sent, t.received, ratio, t.dropped, t.ifDropped)
+	}
+	w.Flush()
+
+	fmt.Fprintf(out, "\ntables (rows)\n")
+	rows, err := TableRowCounts(measurement.UUID)
+	if err != nil {
+		fmt.Fprintf(out, "not available: %v\n", err)
+		return
+	}
+	w = tabwriter.NewWriter(out, 0, 0, 2, ' ', tabwriter.AlignRight)
+	// The columns are in the order of TableNames.
+	fmt.Fprintf(w, "agent\tresults\tprefixes\tlinks\tprobes\t\n")
+	for _, agent := range measurement.Agents {
+		var counts []string
+		for _, table := range TableNames(measurement.UUID, agent.AgentUUID) {
+			count, ok := rows[table]
+			if !ok {
+				counts = append(counts, "-")
+				continue
+			}
+			counts = append(counts, fmt.Sprint(count))
+		}
+		fmt.Fprintf(w, "%s\t%s\t\n", agentName(agent), strings.Join(counts, "\t"))
+	}
+	w.Flush()
+}
+
+// agentTotals defines the probing statistics of an agent summed over
+// all rounds.
+type agentTotals struct {
+	rounds    int
+	sent      int
+	received  int
+	dropped   int
+	ifDropped int
+}
+
+func getAgentTotals(agent common.Agent) agentTotals {
+	t := agentTotals{rounds: len(agent.ProbingStatistics)}
+	for _, stats := range agent.ProbingStatistics {
+		t.sent += stats.PacketsSent
+		t.received += stats.PacketsReceived
+		t.dropped += stats.PcapDropped
+		t.ifDropped += stats.PcapInterfaceDropped
+	}
+	return t
+}
+
+// summaryTime returns t in UTC or "-" if t is zero.
+func summaryTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.UTC().Format("2006-01-02 15:04:05")
+}
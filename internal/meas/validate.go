@@ -0,0 +1,309 @@
+package meas
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/dioptra-io/irisctl/internal/agents"
+	"github.com/dioptra-io/irisctl/internal/auth"
+	"github.com/dioptra-io/irisctl/internal/common"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Tools and flow mappers supported by Iris.
+	measTools   = []string{"diamond-miner", "yarrp", "ping"}
+	flowMappers = []string{"SequentialFlowMapper", "IntervalFlowMapper", "ReverseByteFlowMapper", "RandomFlowMapper"}
+
+	// Errors.
+	ErrInvalidMeasFile = errors.New("invalid measurement file")
+)
+
+// measDefinition mirrors MeasurementRequest with pointers so that
+// missing fields can be told apart from zero values.
+type measDefinition struct {
+	Tool   *string               `json:"tool"`
+	Agents []measDefinitionAgent `json:"agents"`
+	Tags   []string              `json:"tags"`
+}
+
+type measDefinitionAgent struct {
+	UUID           *string               `json:"uuid"`
+	Tag            *string               `json:"tag"`
+	TargetFile     *string               `json:"target_file"`
+	BatchSize      *int                  `json:"batch_size"`
+	ProbingRate    *int                  `json:"probing_rate"`
+	ToolParameters *measDefinitionParams `json:"tool_parameters"`
+}
+
+type measDefinitionParams struct {
+	InitialSourcePort  *int     `json:"initial_source_port"`
+	DestinationPort    *int     `json:"destination_port"`
+	MaxRound           *int     `json:"max_round"`
+	FailureProbability *float64 `json:"failure_probability"`
+	FlowMapper         *string  `json:"flow_mapper"`
+	FlowMapperKwargs   *struct {
+		Seed *int `json:"seed"`
+	} `json:"flow_mapper_kwargs"`
+	PrefixLenV4  *int `json:"prefix_len_v4"`
+	PrefixLenV6  *int `json:"prefix_len_v6"`
+	GlobalMinTTL *int `json:"global_min_ttl"`
+	GlobalMaxTTL *int `json:"global_max_ttl"`
+}
+
+func measValidateArgs(cmd *cobra.Command, args []string) error {
+	if format, ok := common.IsUsage(args); ok {
+		fmt.Printf(format, "<meas-file>", "measurement definition file")
+		return nil
+	}
+	if len(args) < 1 {
+		cliFatal("meas validate requires at least one argument: <meas-file>...", common.MeasurementFile)
+	}
+	for _, arg := range args {
+		if _, err := common.CheckFile("measurement file", arg); err != nil {
+			fatal(err)
+		}
+	}
+	return nil
+}
+
+func measValidate(cmd *cobra.Command, args []string) {
+	n := 0
+	for _, measFile := range args {
+		problems, warnings, err := validateMeasFile(measFile, fValidateOffline)
+		if err != nil {
+			fatal(err)
+		}
+		for _, warning := range warnings {
+			fmt.Fprintf(os.Stderr, "%s %s: %s\n", common.Warning(common.WarnAgentCapability), measFile, warning)
+		}
+		for _, problem := range problems {
+			fmt.Printf("%s: %s\n", measFile, problem)
+		}
+		if len(problems) == 0 {
+			fmt.Printf("%s: ok\n", measFile)
+			continue
+		}
+		n++
+	}
+	if n > 0 {
+		fatal(fmt.Errorf("%w: %d of %d file(s)", ErrInvalidMeasFile, n, len(args)))
+	}
+}
+
+// validateMeasFile returns the problems of the specified measurement
+// definition file.  Unless offline is true, agent UUIDs, agent tags,
+// and target files are also checked against the Iris instance, and
+// the warnings about parameters that exceed the limits of the agents
+// are returned.
+func validateMeasFile(measFile string, offline bool) ([]string, []string, error) {
+	contents, err := os.ReadFile(measFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	def, problems := parseMeasDefinition(contents)
+	if offline || len(problems) > 0 {
+		return problems, nil, nil
+	}
+	return checkMeasReferences(def)
+}
+
+// parseMeasDefinition parses the JSON measurement definition in
+// contents and returns it with its structural problems.
+func parseMeasDefinition(contents []byte) (measDefinition, []string) {
+	var def measDefinition
+	dec := json.NewDecoder(bytes.NewReader(contents))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&def); err != nil {
+		var syntaxErr *json.SyntaxError
+		var typeErr *json.UnmarshalTypeError
+		switch {
+		case errors.As(err, &syntaxErr):
+			return def, []string{fmt.Sprintf("line %d: %v", lineOf(contents, syntaxErr.Offset), err)}
+		case errors.As(err, &typeErr):
+			return def, []string{fmt.Sprintf("line %d: %s: expected %v, got %s", lineOf(contents, typeErr.Offset), typeErr.Field, typeErr.Type, typeErr.Value)}
+		}
+		// Unknown fields (e.g., misspelled names) end up here.
+		return def, []string{fmt.Sprintf("%v (see the format below)%s", err, common.MeasurementFile)}
+	}
+	return def, checkMeasDefinition(def)
+}
+
+// checkMeasDefinition returns the structural problems of def.
+func checkMeasDefinition(def measDefinition) []string {
+	var problems []string
+	add := func(format string, a ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, a...))
+	}
+	switch {
+	case def.Tool == nil:
+		add("tool: missing (one of %s)", strings.Join(measTools, ", "))
+	case !common.Contains(measTools, *def.Tool):
+		add("tool: unknown tool %q (one of %s)", *def.Tool, strings.Join(measTools, ", "))
+	}
+	if len(def.Agents) == 0 {
+		add("agents: at least one agent is required")
+	}
+	for i, a := range def.Agents {
+		path := fmt.Sprintf("agents[%d]", i)
+		switch {
+		case a.UUID == nil && a.Tag == nil:
+			add("%s: either uuid or tag is required", path)
+		case a.UUID != nil && a.Tag != nil:
+			add("%s: uuid and tag are mutually exclusive", path)
+		case a.UUID != nil && common.ValidateFormat([]string{*a.UUID}, common.MeasurementUUID) != nil:
+			add("%s.uuid: invalid UUID %q", path, *a.UUID)
+		case a.Tag != nil && *a.Tag == "":
+			add("%s.tag: empty tag", path)
+		}
+		if a.TargetFile == nil || *a.TargetFile == "" {
+			add("%s.target_file: missing (upload the target list with irisctl targets upload)", path)
+		}
+		if a.BatchSize != nil && *a.BatchSize <= 0 {
+			add("%s.batch_size: %d must be positive", path, *a.BatchSize)
+		}
+		if a.ProbingRate != nil && *a.ProbingRate <= 0 {
+			add("%s.probing_rate: %d must be positive", path, *a.ProbingRate)
+		}
+		if a.ToolParameters != nil {
+			for _, problem := range checkToolParameters(a.ToolParameters) {
+				add("%s.tool_parameters.%s", path, problem)
+			}
+		}
+	}
+	return problems
+}
+
+// checkToolParameters returns the out-of-range tool parameters.
+func checkToolParameters(p *measDefinitionParams) []string {
+	var problems []string
+	checkRange := func(name string, v *int, min, max int) {
+		if v != nil && (*v < min || *v > max) {
+			problems = append(problems, fmt.Sprintf("%s: %d is out of range [%d, %d]", name, *v, min, max))
+		}
+	}
+	checkRange("initial_source_port", p.InitialSourcePort, 0, 65535)
+	checkRange("destination_port", p.DestinationPort, 0, 65535)
+	checkRange("max_round", p.MaxRound, 1, 255)
+	checkRange("prefix_len_v4", p.PrefixLenV4, 0, 32)
+	checkRange("prefix_len_v6", p.PrefixLenV6, 0, 128)
+	checkRange("global_min_ttl", p.GlobalMinTTL, 0, 255)
+	checkRange("global_max_ttl", p.GlobalMaxTTL, 0, 255)
+	if p.FailureProbability != nil && (*p.FailureProbability < 0 || *p.FailureProbability > 1) {
+		problems = append(problems, fmt.Sprintf("failure_probability: %v is out of range [0, 1]", *p.FailureProbability))
+	}
+	if p.FlowMapper != nil && !common.Contains(flowMappers, *p.FlowMapper) {
+		problems = append(problems, fmt.Sprintf("flow_mapper: unknown flow mapper %q (one of %s)", *p.FlowMapper, strings.Join(flowMappers, ", ")))
+	}
+	if p.GlobalMinTTL != nil && p.GlobalMaxTTL != nil && *p.GlobalMinTTL > *p.GlobalMaxTTL {
+		problems = append(problems, fmt.Sprintf("global_min_ttl: %d is greater than global_max_ttl %d", *p.GlobalMinTTL, *p.GlobalMaxTTL))
+	}
+	return problems
+}
+
+// checkMeasReferences returns the agent UUIDs, agent tags, and target
+// files of def that do not exist in the Iris instance, and warnings
+// about the parameters of def that exceed the limits of the agents.
+func checkMeasReferences(def measDefinition) ([]string, []string, error) {
+	jsonData, err := agents.GetAgents("", false)
+	if err != nil {
+		return nil, nil, err
+	}
+	var agentsData common.AgentsData
+	if err := common.DecodeJSON(jsonData, &agentsData); err != nil {
+		return nil, nil, err
+	}
+	agentUUIDs := make(map[string]bool)
+	agentTags := make(map[string]bool)
+	for _, result := range agentsData.Results {
+		agentUUIDs[result.UUID] = true
+		for _, tag := range result.Parameters.Tags {
+			agentTags[tag] = true
+		}
+	}
+	targetFiles, err := getTargetFiles()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var problems, warnings []string
+	for i, a := range def.Agents {
+		path := fmt.Sprintf("agents[%d]", i)
+		if a.UUID != nil && !agentUUIDs[*a.UUID] {
+			problems = append(problems, fmt.Sprintf("%s.uuid: no agent %s (see irisctl agents)", path, *a.UUID))
+		}
+		if a.Tag != nil && !agentTags[*a.Tag] {
+			problems = append(problems, fmt.Sprintf("%s.tag: no agent with tag %q (see irisctl agents --tag %s)", path, *a.Tag, *a.Tag))
+		}
+		if !targetFiles[*a.TargetFile] {
+			problems = append(problems, fmt.Sprintf("%s.target_file: no target list %q (upload it with irisctl targets upload %s)", path, *a.TargetFile, *a.TargetFile))
+		}
+		for _, result := range agentsData.Results {
+			if matchesAgent(a, result) {
+				warnings = append(warnings, checkAgentCapabilities(path, a, result)...)
+			}
+		}
+	}
+	return problems, warnings, nil
+}
+
+// matchesAgent returns true if the agent of a measurement definition
+// selects the specified agent by UUID or by tag.
+func matchesAgent(a measDefinitionAgent, agent common.AgentsResult) bool {
+	return (a.UUID != nil && *a.UUID == agent.UUID) || (a.Tag != nil && common.Contains(agent.Parameters.Tags, *a.Tag))
+}
+
+// checkAgentCapabilities returns the probing rate and TTLs of a that
+// exceed the limits that the specified agent advertises: its maximum
+// probing rate and its minimum TTL.  Agents do not probe below their
+// minimum TTL, so a measurement whose TTLs are all below it probes
+// nothing.
+func checkAgentCapabilities(path string, a measDefinitionAgent, agent common.AgentsResult) []string {
+	var warnings []string
+	p := agent.Parameters
+	if a.ProbingRate != nil && p.MaxProbingRate > 0 && *a.ProbingRate > p.MaxProbingRate {
+		warnings = append(warnings, fmt.Sprintf("%s.probing_rate: %d exceeds the max_probing_rate %d of %s", path, *a.ProbingRate, p.MaxProbingRate, p.Hostname))
+	}
+	if tp := a.ToolParameters; tp != nil {
+		if tp.GlobalMaxTTL != nil && *tp.GlobalMaxTTL < p.MinTTL {
+			warnings = append(warnings, fmt.Sprintf("%s.tool_parameters.global_max_ttl: %d is below the min_ttl %d of %s (nothing would be probed)", path, *tp.GlobalMaxTTL, p.MinTTL, p.Hostname))
+		} else if tp.GlobalMinTTL != nil && *tp.GlobalMinTTL < p.MinTTL {
+			warnings = append(warnings, fmt.Sprintf("%s.tool_parameters.global_min_ttl: %d is below the min_ttl %d of %s (lower TTLs would not be probed)", path, *tp.GlobalMinTTL, p.MinTTL, p.Hostname))
+		}
+	}
+	return warnings
+}
+
+// getTargetFiles returns the keys of the target lists of the user.
+func getTargetFiles() (map[string]bool, error) {
+	url := fmt.Sprintf("%s/?offset=0&limit=200", common.APIEndpoint(common.TargetsAPISuffix))
+	jsonData, err := common.Curl(auth.GetAccessToken(), false, "GET", url)
+	if err != nil {
+		return nil, err
+	}
+	var targets struct {
+		Results []struct {
+			Key string `json:"key"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal(jsonData, &targets); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]bool)
+	for _, result := range targets.Results {
+		keys[result.Key] = true
+	}
+	return keys, nil
+}
+
+// lineOf returns the line number of the specified offset in contents.
+func lineOf(contents []byte, offset int64) int {
+	if offset > int64(len(contents)) {
+		offset = int64(len(contents))
+	}
+	return bytes.Count(contents[:offset], []byte("\n")) + 1
+}
@@ -0,0 +1,117 @@
+package meas
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/dioptra-io/irisctl/internal/auth"
+	"github.com/dioptra-io/irisctl/internal/common"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// Exit codes of meas wait.  Other errors exit with 1.
+	ExitWaitMet     = 0 // the measurement reached the awaited state
+	ExitWaitFailed  = 2 // the measurement reached another terminal state
+	ExitWaitTimeout = 3 // the timeout expired
+
+	// Conditions of meas wait --until.
+	UntilFinished    = "finished"
+	UntilCanceled    = "canceled"
+	UntilAnyTerminal = "any-terminal"
+)
+
+var (
+	waitConditions = []string{UntilFinished, UntilCanceled, UntilAnyTerminal}
+	terminalStates = []string{"finished", "canceled", "agent_failure"}
+)
+
+func measWaitArgs(cmd *cobra.Command, args []string) error {
+	if format, ok := common.IsUsage(args); ok {
+		fmt.Printf(format, "<meas-uuid>", "measurement UUID")
+		return nil
+	}
+	if len(args) != 1 {
+		cliFatal("meas wait requires exactly one argument: <meas-uuid>")
+	}
+	if err := common.ValidateFormat(args, common.MeasurementUUID); err != nil {
+		cliFatal(err)
+	}
+	if !common.Contains(waitConditions, fWaitUntil) {
+		cliFatal("invalid --until (must be finished, canceled, or any-terminal): ", fWaitUntil)
+	}
+	if fWaitInterval <= 0 {
+		cliFatal("--interval must be positive")
+	}
+	return nil
+}
+
+func measWait(cmd *cobra.Command, args []string) {
+	code, err := waitMeasurement(args[0], fWaitUntil, fWaitTimeout, fWaitInterval)
+	if err != nil {
+		fatal(err)
+	}
+	common.Exit(code)
+}
+
+// waitMeasurement polls the state of the measurement every interval
+// until the condition is met, the measurement reaches a terminal state
+// that does not meet the condition, or the timeout (if not zero)
+// expires, and returns the corresponding exit code.
+func waitMeasurement(uuid, until string, timeout, interval time.Duration) (int, error) {
+	start := time.Now()
+	prevState := ""
+	for {
+		state, err := getMeasState(uuid)
+		if err != nil {
+			return 1, err
+		}
+		if state != prevState {
+			fmt.Fprintf(os.Stderr, "%s %s %s\n", time.Now().Format("2006-01-02 15:04:05"), uuid, state)
+			prevState = state
+		}
+		if common.Contains(terminalStates, state) {
+			if until == UntilAnyTerminal || until == state {
+				return ExitWaitMet, nil
+			}
+			fmt.Fprintf(os.Stderr, "measurement %s is %s, not %s\n", uuid, state, until)
+			return ExitWaitFailed, nil
+		}
+		if timeout > 0 && time.Since(start)+interval > timeout {
+			fmt.Fprintf(os.Stderr, "timed out after %v waiting for measurement %s to be %s\n", time.Since(start).Round(time.Second), uuid, until)
+			return ExitWaitTimeout, nil
+		}
+		time.Sleep(interval)
+	}
+}
+
+// GetMeasurementUncached returns the current details of the
+// measurement, bypassing the response cache of Curl.
+func GetMeasurementUncached(uuid string) ([]byte, error) {
+	url := fmt.Sprintf("%s/%s", common.APIEndpoint(common.MeasurementsAPISuffix), uuid)
+	jsonData, err := common.CurlNoCache(auth.GetAccessToken(), false, "GET", url)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", err, jsonData)
+	}
+	return jsonData, nil
+}
+
+// getMeasState returns the current state of the measurement.
+func getMeasState(uuid string) (string, error) {
+	jsonData, err := GetMeasurementUncached(uuid)
+	if err != nil {
+		return "", err
+	}
+	var m struct {
+		State string `json:"state"`
+	}
+	if err := json.Unmarshal(jsonData, &m); err != nil {
+		return "", err
+	}
+	if m.State == "" {
+		return "", fmt.Errorf("%s: %w: no state in %s", uuid, common.ErrInvalidState, jsonData)
+	}
+	return m.State, nil
+}
@@ -0,0 +1,95 @@
+package meas
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/dioptra-io/irisctl/internal/common"
+	"github.com/spf13/cobra"
+)
+
+func measWatchArgs(cmd *cobra.Command, args []string) error {
+	if format, ok := common.IsUsage(args); ok {
+		fmt.Printf(format, "<meas-uuid>", "measurement UUID")
+		return nil
+	}
+	if len(args) != 1 {
+		cliFatal("meas watch requires exactly one argument: <meas-uuid>")
+	}
+	if err := common.ValidateFormat(args, common.MeasurementUUID); err != nil {
+		cliFatal(err)
+	}
+	if fWatchInterval <= 0 {
+		cliFatal("--interval must be positive")
+	}
+	return nil
+}
+
+func measWatch(cmd *cobra.Command, args []string) {
+	code, err := watchMeasurement(args[0], fWatchInterval)
+	if err != nil {
+		fatal(err)
+	}
+	common.Exit(code)
+}
+
+// watchMeasurement polls the measurement every interval and prints its
+// state transitions and the progress of each agent until it reaches a
+// terminal state.  It returns ExitWaitMet if the measurement finished
+// and ExitWaitFailed if it was canceled or an agent failed.
+func watchMeasurement(uuid string, interval time.Duration) (int, error) {
+	prevState := ""
+	prevProgress := make(map[string]string)
+	for {
+		jsonData, err := GetMeasurementUncached(uuid)
+		if err != nil {
+			return 1, err
+		}
+		var measurement common.Measurement
+		if err := common.DecodeJSON(jsonData, &measurement); err != nil {
+			return 1, err
+		}
+		now := time.Now().Format("2006-01-02 15:04:05")
+		if measurement.State != prevState {
+			fmt.Printf("%s %s %s\n", now, uuid, measurement.State)
+			prevState = measurement.State
+		}
+		for _, agent := range measurement.Agents {
+			progress := agentProgress(agent)
+			if progress != prevProgress[agent.AgentUUID] {
+				fmt.Printf("%s     %-24s %s\n", now, agentName(agent), progress)
+				prevProgress[agent.AgentUUID] = progress
+			}
+		}
+		if common.Contains(terminalStates, measurement.State) {
+			if measurement.State == "finished" {
+				return ExitWaitMet, nil
+			}
+			return ExitWaitFailed, nil
+		}
+		time.Sleep(interval)
+	}
+}
+
+// agentName returns the hostname of the agent or its UUID if the
+// hostname is not known.
+func agentName(agent common.Agent) string {
+	if agent.AgentParameters.Hostname != "" {
+		return agent.AgentParameters.Hostname
+	}
+	return agent.AgentUUID
+}
+
+// agentProgress returns the state of the agent, the last round it
+// probed, and the number of packets it sent and received.
+func agentProgress(agent common.Agent) string {
+	round, sent, received := 0, 0, 0
+	for _, stats := range agent.ProbingStatistics {
+		if stats.Round.Number > round {
+			round = stats.Round.Number
+		}
+		sent += stats.PacketsSent
+		received += stats.PacketsReceived
+	}
+	return fmt.Sprintf("%-14s round %d, %d packets sent, %d received", agent.State, round, sent, received)
+}
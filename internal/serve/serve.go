@@ -0,0 +1,286 @@
+// Package serve implements commands for serving Iris measurement
+// metadata over HTTP (not in the Iris API).
+package serve
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dioptra-io/irisctl/internal/common"
+	"github.com/dioptra-io/irisctl/internal/meas"
+	"github.com/spf13/cobra"
+)
+
+// The templates of the pages are embedded in the binary so the UI can
+// be served without any external files.
+//
+//go:embed templates/*.html
+var templates embed.FS
+
+var (
+	// Command, its flags, subcommands, and their flags.
+	//	serve <subcommand>
+	//	serve ui [--addr <host:port>] [--all-users] [--refresh <duration>]
+	cmdName       = "serve"
+	subcmdNames   = []string{"ui"}
+	fUIAddr       string
+	fUIAllUsers   bool
+	fUIRefresh    time.Duration
+	measStates    = []string{"agent_failure", "canceled", "finished", "ongoing"}
+	pageTemplates *template.Template
+
+	// Test code changes Fatal to Panic so a fatal error won't exit
+	// the process and can be recovered.
+	fatal    = log.Fatal
+	cliFatal = common.CliFatal
+	verbose  = common.Verbose
+)
+
+type listPage struct {
+	States       []string
+	State        string
+	Tags         string
+	TagsAnd      bool
+	After        string
+	Before       string
+	Total        int
+	Loaded       string
+	Measurements []common.Measurement
+}
+
+type tablesPage struct {
+	UUID   string
+	Error  error
+	Tables []tableRows
+}
+
+type tableRows struct {
+	Name string
+	Rows int
+}
+
+// agentTotals defines the probing statistics of an agent summed over
+// all rounds.
+type agentTotals struct {
+	Rounds   int
+	Sent     int
+	Received int
+}
+
+// ServeCmd returns the command structure for serve.
+func ServeCmd() *cobra.Command {
+	serveCmd := &cobra.Command{
+		Use:       cmdName,
+		ValidArgs: subcmdNames,
+		Short:     "serve commands",
+		Long:      "commands for serving measurement metadata over HTTP",
+		Args:      serveArgs,
+		Run:       serve,
+	}
+	serveCmd.SetUsageFunc(common.Usage)
+	serveCmd.SetHelpFunc(common.Help)
+
+	// serve ui and its flags
+	uiSubcmd := &cobra.Command{
+		Use:   "ui",
+		Short: "serve a web UI for browsing measurements",
+		Long:  "serve a minimal web UI for listing, filtering, and inspecting measurements with the credentials of the current user",
+		Args:  serveUIArgs,
+		Run:   serveUI,
+	}
+	uiSubcmd.Flags().StringVar(&fUIAddr, "addr", "127.0.0.1:8080", "address to listen on")
+	uiSubcmd.Flags().BoolVar(&fUIAllUsers, "all-users", false, "list the measurements of all users (admin only)")
+	uiSubcmd.Flags().DurationVar(&fUIRefresh, "refresh", 5*time.Minute, "age after which the measurements metadata is refreshed")
+	serveCmd.AddCommand(uiSubcmd)
+
+	return serveCmd
+}
+
+func serveArgs(cmd *cobra.Command, args []string) error {
+	if _, ok := common.IsUsage(args); ok {
+		return nil
+	}
+	if len(args) == 0 {
+		cliFatal("serve requires one of these subcommands: ", strings.Join(subcmdNames, " "))
+	}
+	cliFatal("unknown subcommand: ", args[0])
+	return nil
+}
+
+func serve(cmd *cobra.Command, args []string) {
+	fatal("serve()")
+}
+
+func serveUIArgs(cmd *cobra.Command, args []string) error {
+	if _, ok := common.IsUsage(args); ok {
+		return nil
+	}
+	if len(args) != 0 {
+		cliFatal("serve ui does not take any arguments")
+	}
+	host, _, err := net.SplitHostPort(fUIAddr)
+	if err != nil {
+		cliFatal(err)
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		fmt.Fprintf(os.Stderr, "%s %s is reachable from other hosts and serves data with your credentials\n", common.Warning(common.WarnExposedUI), fUIAddr)
+	}
+	if fUIRefresh <= 0 {
+		cliFatal("--refresh must be positive")
+	}
+	return nil
+}
+
+func serveUI(cmd *cobra.Command, args []string) {
+	if err := parseTemplates(); err != nil {
+		fatal(err)
+	}
+	service := NewService(fUIAllUsers, fUIRefresh)
+	if _, _, err := service.metadata(); err != nil {
+		fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) { listHandler(service, w, r) })
+	mux.HandleFunc("/meas/", func(w http.ResponseWriter, r *http.Request) { measHandler(service, w, r) })
+	fmt.Printf("serving on http://%s/\n", fUIAddr)
+	fatal(http.ListenAndServe(fUIAddr, mux))
+}
+
+// parseTemplates parses the templates of the pages.
+func parseTemplates() error {
+	var err error
+	pageTemplates, err = template.New("").Funcs(template.FuncMap{
+		"join":       strings.Join,
+		"formatTime": formatTime,
+		"duration":   duration,
+		"totals":     getAgentTotals,
+	}).ParseFS(templates, "templates/*.html")
+	return err
+}
+
+func listHandler(service *Service, w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	measurements, loadTime, err := service.metadata()
+	if err != nil {
+		httpError(w, err)
+		return
+	}
+	q := r.URL.Query()
+	page := listPage{
+		States:  measStates,
+		State:   q.Get("state"),
+		Tags:    q.Get("tag"),
+		TagsAnd: q.Get("tags-and") != "",
+		After:   q.Get("after"),
+		Before:  q.Get("before"),
+		Total:   len(measurements),
+		Loaded:  loadTime.UTC().Format("2006-01-02 15:04:05 UTC"),
+	}
+	filter := MeasurementFilter{State: page.State, TagsAnd: page.TagsAnd}
+	for _, tag := range strings.Split(page.Tags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			filter.Tags = append(filter.Tags, tag)
+		}
+	}
+	var err1, err2 error
+	filter.After, err1 = parseDate(page.After)
+	filter.Before, err2 = parseDate(page.Before)
+	if err := errors.Join(err1, err2); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if page.Measurements, err = service.ListMeasurements(filter); err != nil {
+		httpError(w, err)
+		return
+	}
+	render(w, "list.html", page)
+}
+
+func measHandler(service *Service, w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/meas/"), "/")
+	measUUID := parts[0]
+	if common.ValidateFormat([]string{measUUID}, common.MeasurementUUID) != nil || len(parts) > 2 || (len(parts) == 2 && parts[1] != "tables") {
+		http.NotFound(w, r)
+		return
+	}
+	if len(parts) == 2 {
+		page := tablesPage{UUID: measUUID}
+		rows, err := meas.TableRowCounts(measUUID)
+		if err != nil {
+			page.Error = err
+		}
+		for name, n := range rows {
+			page.Tables = append(page.Tables, tableRows{name, n})
+		}
+		sort.Slice(page.Tables, func(i, j int) bool { return page.Tables[i].Name < page.Tables[j].Name })
+		render(w, "tables.html", page)
+		return
+	}
+	measurement, err := service.GetMeasurement(measUUID)
+	if err != nil {
+		httpError(w, err)
+		return
+	}
+	render(w, "meas.html", measurement)
+}
+
+// render executes the named template with data and writes the page.
+func render(w http.ResponseWriter, name string, data interface{}) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := pageTemplates.ExecuteTemplate(w, name, data); err != nil {
+		fmt.Fprintf(os.Stderr, "WARNING: %s: %v\n", name, err)
+	}
+}
+
+func httpError(w http.ResponseWriter, err error) {
+	fmt.Fprintf(os.Stderr, "WARNING: %v\n", err)
+	http.Error(w, err.Error(), http.StatusBadGateway)
+}
+
+// parseDate parses a yyyy-mm-dd date and returns the zero time if
+// date is empty.
+func parseDate(date string) (time.Time, error) {
+	if date == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse("2006-01-02", date)
+}
+
+// formatTime returns t in UTC or "-" if t is zero.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.UTC().Format("2006-01-02 15:04:05")
+}
+
+// duration returns the duration of the measurement or "-" if it has
+// not started or ended.
+func duration(measurement common.Measurement) string {
+	if measurement.StartTime.IsZero() || measurement.EndTime.IsZero() {
+		return "-"
+	}
+	return measurement.EndTime.Sub(measurement.StartTime.Time).Round(time.Second).String()
+}
+
+func getAgentTotals(agent common.Agent) agentTotals {
+	t := agentTotals{Rounds: len(agent.ProbingStatistics)}
+	for _, stats := range agent.ProbingStatistics {
+		t.Sent += stats.PacketsSent
+		t.Received += stats.PacketsReceived
+	}
+	return t
+}
@@ -0,0 +1,75 @@
+package serve
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dioptra-io/irisctl/internal/clickhouse"
+	"github.com/dioptra-io/irisctl/internal/common"
+	"github.com/dioptra-io/irisctl/internal/meas"
+	"github.com/spf13/viper"
+)
+
+// TestMeasHandlerTablesConcurrent requests the tables of a measurement
+// concurrently, as browsers do, and checks that the ClickHouse
+// credentials are fetched once.  Run it with -race.
+func TestMeasHandlerTablesConcurrent(t *testing.T) {
+	const (
+		measUUID  = "a75482d1-8c5c-4d56-845e-fc3861047992"
+		agentUUID = "3f2504e0-4f89-11d3-9a0c-0305e82c3301"
+		requests  = 8
+	)
+	table := common.TableName("results", measUUID, agentUUID)
+	var servicesRequests atomic.Int32
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case common.UsersAPISuffix + "/me/services":
+			servicesRequests.Add(1)
+			fmt.Fprintf(w, `{"clickhouse": {"username": "user", "password": "secret"}, "clickhouse_expiration_time": %q}`,
+				time.Now().Add(time.Hour).UTC().Format(time.RFC3339))
+		case "/":
+			fmt.Fprintf(w, "{\"name\": %q, \"total_rows\": 42}\n", table)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer api.Close()
+
+	viper.Reset()
+	defer viper.Reset()
+	viper.Set("no-auto-login", true)
+	viper.Set("iris-api-url", api.URL)
+	viper.Set("clickhouse-proxy-url", api.URL)
+	meas.TableRowCounts = clickhouse.TableRowCounts
+	if err := parseTemplates(); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	bodies := make([]string, requests)
+	codes := make([]int, requests)
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			measHandler(nil, w, httptest.NewRequest("GET", "/meas/"+measUUID+"/tables", nil))
+			codes[i], bodies[i] = w.Code, w.Body.String()
+		}(i)
+	}
+	wg.Wait()
+	for i := range bodies {
+		if codes[i] != http.StatusOK || !strings.Contains(bodies[i], table) || !strings.Contains(bodies[i], "42") {
+			t.Errorf("request %d: got %d %q, want the row count of %s", i, codes[i], bodies[i], table)
+		}
+	}
+	if n := servicesRequests.Load(); n != 1 {
+		t.Errorf("fetched the clickhouse credentials %d times, want 1", n)
+	}
+}
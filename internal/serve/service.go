@@ -0,0 +1,150 @@
+package serve
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dioptra-io/irisctl/internal/agents"
+	"github.com/dioptra-io/irisctl/internal/common"
+	"github.com/dioptra-io/irisctl/internal/meas"
+)
+
+// Service implements the queries of the serve subsystem with the same
+// client as the other commands.  It is used by the web UI.
+type Service struct {
+	allUsers bool
+	refresh  time.Duration
+
+	// Cache of the metadata of measurements.
+	sync.Mutex
+	measurements []common.Measurement
+	loadTime     time.Time
+}
+
+// MeasurementFilter defines the measurements returned by
+// ListMeasurements.  Zero values match all measurements.
+type MeasurementFilter struct {
+	State   string
+	Tags    []string
+	TagsAnd bool
+	After   time.Time
+	Before  time.Time
+	Limit   int
+}
+
+// AgentHealth defines the state of an agent.
+type AgentHealth struct {
+	UUID     string
+	Hostname string
+	State    string
+	Version  string
+	Tags     []string
+}
+
+// NewService returns a service for the measurements of the current
+// user (or of all users if allUsers is true) whose metadata is
+// refreshed when it is older than refresh.
+func NewService(allUsers bool, refresh time.Duration) *Service {
+	return &Service{allUsers: allUsers, refresh: refresh}
+}
+
+// metadata returns the cached metadata of measurements (most recent
+// first) and its load time after refreshing it if it is too old.
+func (s *Service) metadata() ([]common.Measurement, time.Time, error) {
+	s.Lock()
+	defer s.Unlock()
+	if s.measurements != nil && time.Since(s.loadTime) < s.refresh {
+		return s.measurements, s.loadTime, nil
+	}
+	verbose("refreshing measurements metadata\n")
+	measMdFile, err := meas.GetMeasMdFile(s.allUsers)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	measurements, err := common.GetMeasurementsSorted(measMdFile)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	sort.SliceStable(measurements, func(i, j int) bool {
+		return measurements[i].CreationTime.After(measurements[j].CreationTime.Time)
+	})
+	s.measurements, s.loadTime = measurements, time.Now()
+	return s.measurements, s.loadTime, nil
+}
+
+// ListMeasurements returns the measurements that match the filter,
+// most recent first.
+func (s *Service) ListMeasurements(f MeasurementFilter) ([]common.Measurement, error) {
+	measurements, _, err := s.metadata()
+	if err != nil {
+		return nil, err
+	}
+	var matched []common.Measurement
+	for _, m := range measurements {
+		if f.State != "" && m.State != f.State {
+			continue
+		}
+		if len(f.Tags) > 0 && !common.MatchTag(m.Tags, f.Tags, f.TagsAnd) {
+			continue
+		}
+		if (!f.After.IsZero() && m.CreationTime.Before(f.After)) || (!f.Before.IsZero() && !m.CreationTime.Before(f.Before)) {
+			continue
+		}
+		matched = append(matched, m)
+		if f.Limit > 0 && len(matched) == f.Limit {
+			break
+		}
+	}
+	return matched, nil
+}
+
+// GetMeasurement returns the current details of the measurement.  The
+// response cache of Curl is bypassed because serve is long running.
+func (s *Service) GetMeasurement(measUUID string) (common.Measurement, error) {
+	var measurement common.Measurement
+	jsonData, err := meas.GetMeasurementUncached(measUUID)
+	if err != nil {
+		return measurement, err
+	}
+	err = common.DecodeJSON(jsonData, &measurement)
+	return measurement, err
+}
+
+// AgentHealth returns the current state of the agents sorted by
+// hostname.
+func (s *Service) AgentHealth() ([]AgentHealth, error) {
+	jsonData, err := agents.GetAgentsUncached()
+	if err != nil {
+		return nil, err
+	}
+	var agentsData common.AgentsData
+	if err := common.DecodeJSON(jsonData, &agentsData); err != nil {
+		return nil, err
+	}
+	var health []AgentHealth
+	for _, result := range agentsData.Results {
+		health = append(health, AgentHealth{
+			UUID:     result.UUID,
+			Hostname: result.Parameters.Hostname,
+			State:    result.State,
+			Version:  result.Parameters.Version,
+			Tags:     result.Parameters.Tags,
+		})
+	}
+	sort.Slice(health, func(i, j int) bool { return health[i].Hostname < health[j].Hostname })
+	return health, nil
+}
+
+// QueueDepth returns the number of messages of each dramatiq queue.
+func (s *Service) QueueDepth(queues []string) (map[string]int, error) {
+	depths := make(map[string]int)
+	for _, queue := range queues {
+		messages, err := meas.GetDramatiqMessages(queue)
+		if err != nil {
+			return nil, err
+		}
+		depths[queue] = len(messages)
+	}
+	return depths, nil
+}
@@ -0,0 +1,191 @@
+package targets
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/dioptra-io/irisctl/internal/common"
+	"github.com/spf13/cobra"
+)
+
+// aggregateReport defines what targets aggregate did to a target-list.
+type aggregateReport struct {
+	nDuplicate int // same prefix, protocol, TTLs, and flows
+	nCovered   int // covered by a prefix with the same or more TTLs and flows
+	nKept      int // covered but kept because they have more TTLs or flows
+	nMerged    int
+	warnings   []string
+}
+
+// summary returns what targets aggregate did in one line.
+func (r aggregateReport) summary() string {
+	return fmt.Sprintf("%d duplicate, %d covered, %d merged; %d covered but kept because they have more TTLs or flows", r.nDuplicate, r.nCovered, r.nMerged, r.nKept)
+}
+
+func targetsAggregateArgs(cmd *cobra.Command, args []string) error {
+	if format, ok := common.IsUsage(args); ok {
+		fmt.Printf(format, "<file>", "target-list file")
+		return nil
+	}
+	if len(args) != 1 {
+		cliFatal("targets aggregate requires exactly one argument: <target-list-file>", common.TargetListFile)
+	}
+	return nil
+}
+
+func targetsAggregate(cmd *cobra.Command, args []string) {
+	file := args[0]
+	if _, err := common.CheckFile("target-list", file); err != nil {
+		fatal(err)
+	}
+	contents, err := os.ReadFile(file)
+	if err != nil {
+		fatal(err)
+	}
+	var entries []targetEntry
+	var invalid []string
+	for i, line := range strings.Split(string(contents), "\n") {
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+		entry, err := parseTargetLine(line)
+		if err != nil {
+			invalid = append(invalid, fmt.Sprintf("line %d: %v: %q", i+1, err, line))
+			continue
+		}
+		entry.line = i + 1
+		entry.prefix = entry.prefix.Masked()
+		entries = append(entries, entry)
+	}
+	if len(invalid) > 0 {
+		for _, line := range invalid {
+			fmt.Printf("%s: %s\n", file, line)
+		}
+		fatal(fmt.Errorf("%s: %w: %d invalid lines (see targets validate)", file, ErrInvalidTargetList, len(invalid)))
+	}
+	aggregated, report := aggregateTargets(entries)
+	for _, warning := range report.warnings {
+		fmt.Printf("%s %s: %s\n", common.Warning(common.WarnTargetList), file, warning)
+	}
+	removed := len(entries) - len(aggregated)
+	if fAggregateDryRun {
+		fmt.Printf("dry-run: would remove %d of %d lines of %s (%s)\n", removed, len(entries), file, report.summary())
+		return
+	}
+	output := fAggregateOutput
+	if output == "" {
+		output = file
+	}
+	var b strings.Builder
+	for _, e := range aggregated {
+		fmt.Fprintf(&b, "%s,%s,%d,%d,%d\n", e.prefix, e.protocol, e.minTTL, e.maxTTL, e.flows)
+	}
+	if err := os.WriteFile(output, []byte(b.String()), 0644); err != nil {
+		fatal(err)
+	}
+	common.SavingIn(output)
+	fmt.Printf("%s: removed %d of %d lines (%s)\n", file, removed, len(entries), report.summary())
+}
+
+// aggregateTargets returns the targets sorted by protocol and address
+// without the targets that duplicate or are covered by a prefix of the
+// same protocol with the same or more TTLs and flows, and with sibling
+// prefixes of the same protocol, TTLs, and flows merged into their
+// parent prefix.  Covered targets with more TTLs or flows than the
+// prefixes that cover them are kept because removing them would probe
+// their prefix less.  IPv4 and IPv6 prefixes never cover each other, so
+// they are aggregated separately.
+func aggregateTargets(entries []targetEntry) ([]targetEntry, aggregateReport) {
+	var report aggregateReport
+	sorted := append([]targetEntry{}, entries...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].protocol != sorted[j].protocol {
+			return sorted[i].protocol < sorted[j].protocol
+		}
+		if c := sorted[i].prefix.Addr().Compare(sorted[j].prefix.Addr()); c != 0 {
+			return c < 0
+		}
+		return sorted[i].prefix.Bits() < sorted[j].prefix.Bits()
+	})
+	var aggregated []targetEntry
+	// Kept targets that cover the current target, from the widest
+	// prefix to the narrowest.  Targets that are not covered are
+	// disjoint, so they are always at the bottom.
+	var covering []targetEntry
+	// Index of the first target of aggregated that can be merged.
+	mergeable := 0
+	for _, e := range sorted {
+		for len(covering) > 0 && !covers(covering[len(covering)-1], e) {
+			covering = covering[:len(covering)-1]
+		}
+		if cover, ok := subsumedBy(covering, e); ok {
+			if cover.prefix == e.prefix && cover.minTTL == e.minTTL && cover.maxTTL == e.maxTTL && cover.flows == e.flows {
+				report.nDuplicate++
+			} else {
+				report.nCovered++
+			}
+			continue
+		}
+		if len(covering) > 0 {
+			last := covering[len(covering)-1]
+			report.nKept++
+			report.warnings = append(report.warnings, fmt.Sprintf("line %d: %s,%s,%d,%d,%d is covered by line %d but kept because it has more TTLs or flows",
+				e.line, e.prefix, e.protocol, e.minTTL, e.maxTTL, e.flows, last.line))
+			aggregated = append(aggregated, e)
+			covering = append(covering, e)
+			mergeable = len(aggregated)
+			continue
+		}
+		aggregated = append(aggregated, e)
+		// Only targets that are not covered are merged: a kept
+		// covered target between two siblings prevents the merge.
+		for len(aggregated)-2 >= mergeable {
+			n := len(aggregated)
+			parent, ok := mergeSiblings(aggregated[n-2], aggregated[n-1])
+			if !ok {
+				break
+			}
+			report.nMerged++
+			aggregated = append(aggregated[:n-2], parent)
+		}
+		covering = []targetEntry{aggregated[len(aggregated)-1]}
+	}
+	return aggregated, report
+}
+
+// covers returns true if the prefix of a contains the prefix of b and
+// they have the same protocol.
+func covers(a, b targetEntry) bool {
+	return a.protocol == b.protocol && a.prefix.Bits() <= b.prefix.Bits() && a.prefix.Contains(b.prefix.Addr())
+}
+
+// subsumedBy returns the first target of covering that probes e with
+// the same or more TTLs and flows.
+func subsumedBy(covering []targetEntry, e targetEntry) (targetEntry, bool) {
+	for _, c := range covering {
+		if c.minTTL <= e.minTTL && c.maxTTL >= e.maxTTL && c.flows >= e.flows {
+			return c, true
+		}
+	}
+	return targetEntry{}, false
+}
+
+// mergeSiblings returns the parent prefix of a and b if they are the
+// two halves of it and have the same protocol, TTLs, and flows.
+func mergeSiblings(a, b targetEntry) (targetEntry, bool) {
+	if a.protocol != b.protocol || a.minTTL != b.minTTL || a.maxTTL != b.maxTTL || a.flows != b.flows {
+		return a, false
+	}
+	bits := a.prefix.Bits()
+	if bits == 0 || bits != b.prefix.Bits() || a.prefix == b.prefix {
+		return a, false
+	}
+	parent, err := a.prefix.Addr().Prefix(bits - 1)
+	if err != nil || !parent.Contains(b.prefix.Addr()) {
+		return a, false
+	}
+	a.prefix = parent
+	return a, true
+}
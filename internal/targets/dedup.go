@@ -0,0 +1,95 @@
+package targets
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/dioptra-io/irisctl/internal/auth"
+	"github.com/dioptra-io/irisctl/internal/common"
+)
+
+// listChecksum returns the SHA-256 checksum of the non-empty lines of
+// a target-list or probe-list.  Lines are trimmed so that the checksum
+// of a local file matches the checksum of its uploaded content.
+func listChecksum(lines []string) string {
+	h := sha256.New()
+	for _, line := range lines {
+		if line = strings.TrimSpace(line); line != "" {
+			fmt.Fprintf(h, "%s\n", line)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// fileChecksum returns the checksum of the specified list file.
+func fileChecksum(file string) (string, error) {
+	contents, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	return listChecksum(strings.Split(string(contents), "\n")), nil
+}
+
+// findUploadedList returns the key of an already uploaded list whose
+// content is identical to file or an empty string if there is none.
+// Iris does not store checksums, so the content of each existing list
+// is fetched and hashed; the key name is checked first because it is
+// the most likely match.
+func findUploadedList(file, name string) (string, error) {
+	checksum, err := fileChecksum(file)
+	if err != nil {
+		return "", err
+	}
+	url := fmt.Sprintf("%s/?&offset=0&limit=200", common.APIEndpoint(common.TargetsAPISuffix))
+	jsonData, err := common.Curl(auth.GetAccessToken(), false, "GET", url)
+	if err != nil {
+		return "", fmt.Errorf("%v: %s", err, jsonData)
+	}
+	var all struct {
+		Results []struct {
+			Key string `json:"key"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal(jsonData, &all); err != nil {
+		return "", fmt.Errorf("%v: %s", err, jsonData)
+	}
+	keys := []string{}
+	for _, result := range all.Results {
+		if result.Key == name {
+			keys = append([]string{name}, keys...)
+		} else {
+			keys = append(keys, result.Key)
+		}
+	}
+	for _, key := range keys {
+		verbose("comparing %s with %s\n", file, key)
+		lines, err := getContent(key)
+		if err != nil {
+			return "", err
+		}
+		if listChecksum(lines) == checksum {
+			return key, nil
+		}
+	}
+	return "", nil
+}
+
+// getContent returns the lines of the specified list.
+func getContent(key string) ([]string, error) {
+	url := fmt.Sprintf("%s/%s?with_content=true", common.APIEndpoint(common.TargetsAPISuffix), key)
+	jsonData, err := common.Curl(auth.GetAccessToken(), false, "GET", url)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v: %s", key, err, jsonData)
+	}
+	var target struct {
+		Content []string `json:"content"`
+	}
+	if err := json.Unmarshal(jsonData, &target); err != nil {
+		return nil, fmt.Errorf("%s: %v: %s", key, err, jsonData)
+	}
+	return target.Content, nil
+}
@@ -0,0 +1,141 @@
+package targets
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/dioptra-io/irisctl/internal/common"
+	"github.com/spf13/cobra"
+)
+
+// targetKey identifies a target of a target-list: Iris probes a
+// prefix once per protocol.
+type targetKey struct {
+	prefix   string
+	protocol string
+}
+
+func targetsDiffArgs(cmd *cobra.Command, args []string) error {
+	if format, ok := common.IsUsage(args); ok {
+		fmt.Printf(format, "<key-or-file-a> <key-or-file-b>", "target-list keys or local files to compare (b is compared to a)")
+		return nil
+	}
+	if len(args) != 2 {
+		cliFatal("targets diff requires exactly two arguments: <key-or-file-a> <key-or-file-b>")
+	}
+	return nil
+}
+
+func targetsDiff(cmd *cobra.Command, args []string) {
+	var lists [2]map[targetKey]targetEntry
+	for i, arg := range args {
+		lines, err := readTargetList(arg)
+		if err != nil {
+			fatal(err)
+		}
+		lists[i] = indexTargetList(arg, lines)
+	}
+	added, removed, changed, unchanged := diffTargetLists(lists[0], lists[1])
+	for _, line := range removed {
+		fmt.Printf("- %s\n", line)
+	}
+	for _, line := range added {
+		fmt.Printf("+ %s\n", line)
+	}
+	for _, line := range changed {
+		fmt.Printf("~ %s\n", line)
+	}
+	fmt.Printf("%d added, %d removed, %d changed, %d unchanged\n", len(added), len(removed), len(changed), unchanged)
+}
+
+// readTargetList returns the lines of the local file arg if it exists
+// and of the uploaded target-list with key arg otherwise.
+func readTargetList(arg string) ([]string, error) {
+	if fi, err := os.Stat(arg); err == nil && fi.Mode().IsRegular() {
+		verbose("reading local file %s\n", arg)
+		contents, err := os.ReadFile(arg)
+		if err != nil {
+			return nil, err
+		}
+		return strings.Split(string(contents), "\n"), nil
+	}
+	return getContent(arg)
+}
+
+// indexTargetList returns the valid targets of a target-list by prefix
+// and protocol.  Invalid lines are skipped with a warning (see
+// targets validate).
+func indexTargetList(name string, lines []string) map[targetKey]targetEntry {
+	entries := make(map[targetKey]targetEntry)
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		entry, err := parseTargetLine(line)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s %s: line %d: %v: %q\n", common.Warning(common.WarnTargetList), name, i+1, err, line)
+			continue
+		}
+		entry.line = i + 1
+		entry.prefix = entry.prefix.Masked()
+		entries[targetKey{entry.prefix.String(), entry.protocol}] = entry
+	}
+	return entries
+}
+
+// diffTargetLists returns the targets of b that are not in a, the
+// targets of a that are not in b, the targets whose TTL range or
+// number of flows changed, and the number of unchanged targets.
+func diffTargetLists(a, b map[targetKey]targetEntry) ([]string, []string, []string, int) {
+	var added, removed, changed []string
+	unchanged := 0
+	for _, key := range sortedTargetKeys(a, b) {
+		ea, inA := a[key]
+		eb, inB := b[key]
+		switch {
+		case !inA:
+			added = append(added, formatTarget(eb))
+		case !inB:
+			removed = append(removed, formatTarget(ea))
+		case ea.minTTL != eb.minTTL || ea.maxTTL != eb.maxTTL || ea.flows != eb.flows:
+			changed = append(changed, fmt.Sprintf("%s %s: ttl %d-%d -> %d-%d, flows %d -> %d", key.prefix, key.protocol, ea.minTTL, ea.maxTTL, eb.minTTL, eb.maxTTL, ea.flows, eb.flows))
+		default:
+			unchanged++
+		}
+	}
+	return added, removed, changed, unchanged
+}
+
+// sortedTargetKeys returns the keys of a and b sorted by prefix and
+// protocol.
+func sortedTargetKeys(a, b map[targetKey]targetEntry) []targetKey {
+	prefixes := make(map[targetKey]targetEntry)
+	for key, entry := range a {
+		prefixes[key] = entry
+	}
+	for key, entry := range b {
+		prefixes[key] = entry
+	}
+	keys := make([]targetKey, 0, len(prefixes))
+	for key := range prefixes {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		pi, pj := prefixes[keys[i]].prefix, prefixes[keys[j]].prefix
+		if c := pi.Addr().Compare(pj.Addr()); c != 0 {
+			return c < 0
+		}
+		if pi.Bits() != pj.Bits() {
+			return pi.Bits() < pj.Bits()
+		}
+		return keys[i].protocol < keys[j].protocol
+	})
+	return keys
+}
+
+func formatTarget(e targetEntry) string {
+	return fmt.Sprintf("%s %s ttl %d-%d flows %d", e.prefix, e.protocol, e.minTTL, e.maxTTL, e.flows)
+}
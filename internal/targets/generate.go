@@ -0,0 +1,192 @@
+package targets
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/dioptra-io/irisctl/internal/common"
+	"github.com/spf13/cobra"
+)
+
+var (
+	generateSources = []string{"prefixes", "rib", "hitlist"}
+
+	// Errors.
+	ErrNoTargets = errors.New("no targets")
+)
+
+func targetsGenerateArgs(cmd *cobra.Command, args []string) error {
+	if format, ok := common.IsUsage(args); ok {
+		fmt.Printf(format, "<file>...", "one or more prefix files, RIB dumps, or address hitlists")
+		return nil
+	}
+	if len(args) < 1 {
+		cliFatal("targets generate requires at least one argument: <file>...")
+	}
+	if !common.Contains(generateSources, fGenerateSource) {
+		cliFatal("--source must be one of: ", strings.Join(generateSources, " "))
+	}
+	if fGenerateProtocol != "icmp" && fGenerateProtocol != "udp" {
+		cliFatal("--protocol must be icmp or udp")
+	}
+	if fGenerateMinTTL < minTargetTTL || fGenerateMaxTTL > maxTargetTTL || fGenerateMinTTL > fGenerateMaxTTL {
+		cliFatal(fmt.Sprintf("--min-ttl and --max-ttl must be between %d and %d and --min-ttl cannot be greater than --max-ttl", minTargetTTL, maxTargetTTL))
+	}
+	if fGenerateFlows < 1 {
+		cliFatal("--flows must be at least 1")
+	}
+	if fGeneratePrefixLen4 < 1 || fGeneratePrefixLen4 > 32 || fGeneratePrefixLen6 < 1 || fGeneratePrefixLen6 > 128 {
+		cliFatal("--prefix-len4 must be between 1 and 32 and --prefix-len6 between 1 and 128")
+	}
+	if fGenerateUpload && fGenerateOutput == "" {
+		cliFatal("targets generate --upload requires --output (the name of the file is the key of the target-list)")
+	}
+	return nil
+}
+
+func targetsGenerate(cmd *cobra.Command, args []string) {
+	var prefixes []netip.Prefix
+	for _, arg := range args {
+		if _, err := common.CheckFile(fGenerateSource, arg); err != nil {
+			fatal(err)
+		}
+		p, nSkipped, err := readPrefixes(arg, fGenerateSource)
+		if err != nil {
+			fatal(err)
+		}
+		if nSkipped > 0 {
+			fmt.Fprintf(os.Stderr, "%s %s: skipped %d lines without a valid prefix or address\n", common.Warning(common.WarnTargetList), arg, nSkipped)
+		}
+		verbose("%s: %d prefixes\n", arg, len(p))
+		prefixes = append(prefixes, p...)
+	}
+	prefixes = aggregatePrefixes(prefixes)
+	if len(prefixes) == 0 {
+		fatal(ErrNoTargets)
+	}
+	lines := targetLines(prefixes, fGenerateProtocol, fGenerateMinTTL, fGenerateMaxTTL, fGenerateFlows)
+	if fGenerateOutput == "" {
+		for _, line := range lines {
+			fmt.Println(line)
+		}
+		return
+	}
+	if err := os.WriteFile(fGenerateOutput, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		fatal(err)
+	}
+	common.SavingIn(fGenerateOutput)
+	fmt.Fprintf(os.Stderr, "%d targets\n", len(lines))
+	if fGenerateUpload {
+		if err := postList(fGenerateOutput); err != nil {
+			fatal(err)
+		}
+	}
+}
+
+// readPrefixes returns the prefixes of the specified file and the
+// number of lines that were skipped.  The format depends on source:
+//
+//	prefixes: a prefix or an address per line
+//	rib:      bgpdump -m lines (the prefix is the sixth field) or
+//	          pfx2as lines (address, prefix length, and origin AS)
+//	hitlist:  an address per line, aggregated to --prefix-len4 or
+//	          --prefix-len6
+//
+// Empty lines and lines starting with # are ignored.
+func readPrefixes(file, source string) ([]netip.Prefix, int, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+	var prefixes []netip.Prefix
+	nSkipped := 0
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		prefix, err := parsePrefixLine(line, source)
+		if err != nil {
+			verbose("%s: %v: %q\n", file, err, line)
+			nSkipped++
+			continue
+		}
+		if prefix.Bits() == 0 {
+			// Default routes of RIB dumps are not targets.
+			continue
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nSkipped, scanner.Err()
+}
+
+// parsePrefixLine parses a line of a prefix source (see readPrefixes).
+func parsePrefixLine(line, source string) (netip.Prefix, error) {
+	fields := strings.Fields(line)
+	switch source {
+	case "rib":
+		if strings.Contains(line, "|") {
+			fields = strings.Split(line, "|")
+			if len(fields) < 6 {
+				return netip.Prefix{}, fmt.Errorf("%d fields instead of at least 6", len(fields))
+			}
+			return netip.ParsePrefix(fields[5])
+		}
+		if len(fields) >= 2 && !strings.Contains(fields[0], "/") {
+			return netip.ParsePrefix(fields[0] + "/" + fields[1])
+		}
+		return common.ParseTarget(fields[0])
+	case "hitlist":
+		addr, err := netip.ParseAddr(fields[0])
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		if addr.Is4() {
+			return addr.Prefix(fGeneratePrefixLen4)
+		}
+		return addr.Prefix(fGeneratePrefixLen6)
+	default:
+		return common.ParseTarget(strings.TrimSuffix(fields[0], ","))
+	}
+}
+
+// aggregatePrefixes sorts the prefixes and removes duplicates and
+// prefixes contained in other prefixes, which would be probed twice.
+func aggregatePrefixes(prefixes []netip.Prefix) []netip.Prefix {
+	sort.Slice(prefixes, func(i, j int) bool {
+		if c := prefixes[i].Addr().Compare(prefixes[j].Addr()); c != 0 {
+			return c < 0
+		}
+		return prefixes[i].Bits() < prefixes[j].Bits()
+	})
+	var aggregated []netip.Prefix
+	for _, p := range prefixes {
+		if n := len(aggregated); n > 0 && aggregated[n-1].Contains(p.Addr()) {
+			continue
+		}
+		aggregated = append(aggregated, p)
+	}
+	return aggregated
+}
+
+// targetLines returns the target-list lines of the prefixes.  The icmp
+// protocol is icmp6 for IPv6 prefixes.
+func targetLines(prefixes []netip.Prefix, protocol string, minTTL, maxTTL, flows int) []string {
+	lines := make([]string, 0, len(prefixes))
+	for _, p := range prefixes {
+		proto := protocol
+		if proto == "icmp" && p.Addr().Is6() {
+			proto = "icmp6"
+		}
+		lines = append(lines, fmt.Sprintf("%s,%s,%d,%d,%d", p, proto, minTTL, maxTTL, flows))
+	}
+	return lines
+}
@@ -0,0 +1,143 @@
+package targets
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/dioptra-io/irisctl/internal/common"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	// Errors.
+	ErrInvalidManifest = errors.New("invalid upload manifest")
+)
+
+// UploadManifest defines the YAML manifest of targets upload --manifest:
+//
+//	targets:
+//	  - file: prefixes/daily.csv
+//	    name: zeph-daily.csv
+//	    tags: [zeph, daily]
+//	  - file: probes.csv
+//	    probe: true
+type UploadManifest struct {
+	Targets []UploadEntry `yaml:"targets"`
+}
+
+// UploadEntry defines one file of an upload manifest.  Name is the
+// key of the uploaded list (the base name of File by default).  Iris
+// does not store tags; they are copied to the output mapping so that
+// measurement templates can select lists by tag.
+type UploadEntry struct {
+	File  string   `yaml:"file"`
+	Name  string   `yaml:"name"`
+	Probe bool     `yaml:"probe"`
+	Tags  []string `yaml:"tags"`
+}
+
+// UploadMapping defines the output of targets upload --manifest for
+// one file.
+type UploadMapping struct {
+	File  string   `json:"file"`
+	Key   string   `json:"key"`
+	Probe bool     `json:"probe"`
+	Tags  []string `json:"tags"`
+}
+
+// readManifest reads and validates an upload manifest.  Relative file
+// paths are relative to the directory of the manifest.
+func readManifest(manifestFile string) (UploadManifest, error) {
+	var manifest UploadManifest
+	data, err := os.ReadFile(manifestFile)
+	if err != nil {
+		return manifest, err
+	}
+	if err := yaml.Unmarshal(data, &manifest); err != nil {
+		return manifest, fmt.Errorf("%s: %w: %v", manifestFile, ErrInvalidManifest, err)
+	}
+	if len(manifest.Targets) == 0 {
+		return manifest, fmt.Errorf("%s: %w: no targets", manifestFile, ErrInvalidManifest)
+	}
+	names := make(map[string]string)
+	for i := range manifest.Targets {
+		entry := &manifest.Targets[i]
+		if entry.File == "" {
+			return manifest, fmt.Errorf("%s: %w: entry %d has no file", manifestFile, ErrInvalidManifest, i+1)
+		}
+		if !filepath.IsAbs(entry.File) {
+			entry.File = filepath.Join(filepath.Dir(manifestFile), entry.File)
+		}
+		if _, err := common.CheckFile("target-list", entry.File); err != nil {
+			return manifest, fmt.Errorf("%s: %w", entry.File, err)
+		}
+		if entry.Name == "" {
+			entry.Name = filepath.Base(entry.File)
+		}
+		if prev, ok := names[entry.Name]; ok {
+			return manifest, fmt.Errorf("%s: %w: %s and %s have the same name %s", manifestFile, ErrInvalidManifest, prev, entry.File, entry.Name)
+		}
+		names[entry.Name] = entry.File
+		if entry.Tags == nil {
+			entry.Tags = []string{}
+		}
+	}
+	return manifest, nil
+}
+
+// uploadManifest uploads all files of the manifest and saves or prints
+// the mapping of each file to its key.  The manifest is validated
+// before any file is uploaded.  Files whose lists are already uploaded
+// are mapped to the existing keys unless --force is specified.
+func uploadManifest(manifestFile string) error {
+	manifest, err := readManifest(manifestFile)
+	if err != nil {
+		return err
+	}
+	var mappings []UploadMapping
+	for _, entry := range manifest.Targets {
+		if !fUploadForce {
+			key, err := findUploadedList(entry.File, entry.Name)
+			if err != nil {
+				return err
+			}
+			if key != "" {
+				mappings = append(mappings, UploadMapping{
+					File:  entry.File,
+					Key:   key,
+					Probe: entry.Probe,
+					Tags:  entry.Tags,
+				})
+				fmt.Fprintf(os.Stderr, "%s -> %s (already uploaded)\n", entry.File, key)
+				continue
+			}
+		}
+		verbose("uploading %s as %s\n", entry.File, entry.Name)
+		jsonData, err := uploadList(entry.File, entry.Name, entry.Probe)
+		if err != nil {
+			return fmt.Errorf("%s: %w: %s", entry.File, err, jsonData)
+		}
+		var resp struct {
+			Key string `json:"key"`
+		}
+		if err := json.Unmarshal(jsonData, &resp); err != nil || resp.Key == "" {
+			// Iris uses the uploaded file name as the key.
+			resp.Key = entry.Name
+		}
+		mappings = append(mappings, UploadMapping{
+			File:  entry.File,
+			Key:   resp.Key,
+			Probe: entry.Probe,
+			Tags:  entry.Tags,
+		})
+		fmt.Fprintf(os.Stderr, "%s -> %s\n", entry.File, resp.Key)
+	}
+	jsonData, err := json.MarshalIndent(mappings, "", "  ")
+	if err != nil {
+		return err
+	}
+	return common.SaveOrPrint(jsonData, "irisctl-targets-manifest-")
+}
@@ -0,0 +1,191 @@
+package targets
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dioptra-io/irisctl/internal/common"
+	"github.com/spf13/cobra"
+)
+
+var (
+	splitMethods = []string{"prefix", "random", "rtt"}
+)
+
+func targetsSplitArgs(cmd *cobra.Command, args []string) error {
+	if format, ok := common.IsUsage(args); ok {
+		fmt.Printf(format, "<file>", "target-list file")
+		return nil
+	}
+	if len(args) != 1 {
+		cliFatal("targets split requires exactly one argument: <target-list-file>", common.TargetListFile)
+	}
+	if fSplitAgents < 2 {
+		cliFatal("--agents must be at least 2")
+	}
+	if !common.Contains(splitMethods, fSplitBy) {
+		cliFatal("--by must be one of: ", strings.Join(splitMethods, " "))
+	}
+	if (fSplitBy == "rtt") != (fSplitRTTFile != "") {
+		cliFatal("targets split --by rtt requires --rtt-file and --rtt-file requires --by rtt")
+	}
+	return nil
+}
+
+func targetsSplit(cmd *cobra.Command, args []string) {
+	file := args[0]
+	if _, err := common.CheckFile("target-list", file); err != nil {
+		fatal(err)
+	}
+	contents, err := os.ReadFile(file)
+	if err != nil {
+		fatal(err)
+	}
+	var lines []string
+	var entries []targetEntry
+	for i, line := range strings.Split(string(contents), "\n") {
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+		entry, err := parseTargetLine(line)
+		if err != nil {
+			fatal(fmt.Errorf("%s: %w: line %d: %v", file, ErrInvalidTargetList, i+1, err))
+		}
+		entry.line = len(lines)
+		lines = append(lines, line)
+		entries = append(entries, entry)
+	}
+	if len(entries) < fSplitAgents {
+		fatal(fmt.Errorf("%s: %d targets cannot be split across %d agents", file, len(entries), fSplitAgents))
+	}
+	var shards [][]targetEntry
+	switch fSplitBy {
+	case "prefix":
+		shards = splitByPrefix(entries, fSplitAgents)
+	case "random":
+		shards = splitRandom(entries, fSplitAgents, fSplitSeed)
+	case "rtt":
+		rtts, err := readRTTs(fSplitRTTFile)
+		if err != nil {
+			fatal(err)
+		}
+		shards = splitByRTT(entries, fSplitAgents, rtts)
+	}
+	outputDir := fSplitOutputDir
+	if outputDir == "" {
+		outputDir = filepath.Dir(file)
+	}
+	base := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	for i, shard := range shards {
+		shardFile := filepath.Join(outputDir, fmt.Sprintf("%s-%d-of-%d%s", base, i+1, len(shards), filepath.Ext(file)))
+		var b strings.Builder
+		for _, e := range shard {
+			fmt.Fprintf(&b, "%s\n", lines[e.line])
+		}
+		if err := os.WriteFile(shardFile, []byte(b.String()), 0644); err != nil {
+			fatal(err)
+		}
+		common.SavingIn(shardFile)
+		fmt.Printf("%s: %d targets\n", shardFile, len(shard))
+		if fSplitUpload {
+			if err := postList(shardFile); err != nil {
+				fatal(err)
+			}
+		}
+	}
+}
+
+// splitByPrefix sorts the targets by address and splits them into n
+// contiguous shards of (almost) the same number of targets, so each
+// agent probes its own part of the address space.
+func splitByPrefix(entries []targetEntry, n int) [][]targetEntry {
+	sorted := append([]targetEntry{}, entries...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if c := sorted[i].prefix.Addr().Compare(sorted[j].prefix.Addr()); c != 0 {
+			return c < 0
+		}
+		return sorted[i].prefix.Bits() < sorted[j].prefix.Bits()
+	})
+	shards := make([][]targetEntry, n)
+	for i := range shards {
+		shards[i] = sorted[i*len(sorted)/n : (i+1)*len(sorted)/n]
+	}
+	return shards
+}
+
+// splitRandom shuffles the targets with the specified seed, so that
+// splits are reproducible, and deals them to n shards.
+func splitRandom(entries []targetEntry, n int, seed int64) [][]targetEntry {
+	shuffled := append([]targetEntry{}, entries...)
+	r := rand.New(rand.NewSource(seed))
+	r.Shuffle(len(shuffled), func(i, j int) { shuffled[i], shuffled[j] = shuffled[j], shuffled[i] })
+	shards := make([][]targetEntry, n)
+	for i, e := range shuffled {
+		shards[i%n] = append(shards[i%n], e)
+	}
+	return shards
+}
+
+// splitByRTT sorts the targets by RTT and deals them to n shards in
+// snake order (0..n-1, n-1..0, ...) so that each shard gets the same
+// mix of near and far targets and agents finish at about the same
+// time.  Targets without an RTT are dealt last.
+func splitByRTT(entries []targetEntry, n int, rtts map[string]float64) [][]targetEntry {
+	sorted := append([]targetEntry{}, entries...)
+	rtt := func(e targetEntry) (float64, bool) {
+		v, ok := rtts[e.prefix.Masked().String()]
+		return v, ok
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		ri, oki := rtt(sorted[i])
+		rj, okj := rtt(sorted[j])
+		if oki != okj {
+			return oki
+		}
+		return ri < rj
+	})
+	shards := make([][]targetEntry, n)
+	for i, e := range sorted {
+		shard := i % n
+		if (i/n)%2 == 1 {
+			shard = n - 1 - shard
+		}
+		shards[shard] = append(shards[shard], e)
+	}
+	return shards
+}
+
+// readRTTs returns the RTTs (in milliseconds) of the prefixes in the
+// specified file of prefix,rtt lines (e.g., the minimum RTT of each
+// prefix in the results of a previous measurement).
+func readRTTs(file string) (map[string]float64, error) {
+	contents, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	rtts := map[string]float64{}
+	for i, line := range strings.Split(string(contents), "\n") {
+		if line = strings.TrimSpace(line); line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, ",")
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s: line %d: %d fields instead of 2 (prefix,rtt)", file, i+1, len(fields))
+		}
+		prefix, err := common.ParseTarget(strings.TrimSpace(fields[0]))
+		if err != nil {
+			return nil, fmt.Errorf("%s: line %d: %v", file, i+1, err)
+		}
+		rtt, err := strconv.ParseFloat(strings.TrimSpace(fields[1]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: line %d: %v", file, i+1, err)
+		}
+		rtts[prefix.Masked().String()] = rtt
+	}
+	return rtts, nil
+}
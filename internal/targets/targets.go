@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/dioptra-io/irisctl/internal/auth"
@@ -16,13 +17,45 @@ var (
 	// Command, its flags, subcommands, and their flags.
 	//	targets <subcommand>
 	//	targets all
-	//	targets [--with-conent] key <key>...
-	//	targets upload [--probe] <file>
+	//	targets key [--with-content] [--checksum-file <file>] <key>...
+	//	targets key --output <path> <key>...
+	//	targets upload [--probe] [--force] <file>
+	//	targets upload [--force] --manifest <manifest-file>
+	//	targets validate <file>...
+	//	targets diff <key-or-file-a> <key-or-file-b>
+	//	targets aggregate [--output <file>] [--dry-run] <file>
+	//	targets split --agents <n> [--by prefix|random|rtt] [--seed <n>] [--rtt-file <file>] [--output-dir <dir>] [--upload [--force]] <file>
+	//	targets generate [--source prefixes|rib|hitlist] [--protocol icmp|udp] [--min-ttl <ttl>] [--max-ttl <ttl>] [--flows <n>]
+	//		[--prefix-len4 <bits>] [--prefix-len6 <bits>] [--output <file> [--upload [--force]]] <file>...
 	//	targets delete <key>
 	cmdName         = "targets"
-	subcmdNames     = []string{"all", "key", "upload", "delete"}
+	subcmdNames     = []string{"all", "key", "upload", "validate", "diff", "aggregate", "split", "generate", "delete"}
 	fKeyWithContent bool
+	fKeyChecksum    string
+	fKeyOutput      string
 	fUploadProbe    bool
+	fUploadManifest string
+	fUploadForce    bool
+
+	fAggregateOutput string
+	fAggregateDryRun bool
+
+	fSplitAgents    int
+	fSplitBy        string
+	fSplitSeed      int64
+	fSplitRTTFile   string
+	fSplitOutputDir string
+	fSplitUpload    bool
+
+	fGenerateSource     string
+	fGenerateProtocol   string
+	fGenerateMinTTL     int
+	fGenerateMaxTTL     int
+	fGenerateFlows      int
+	fGeneratePrefixLen4 int
+	fGeneratePrefixLen6 int
+	fGenerateOutput     string
+	fGenerateUpload     bool
 
 	// Test code can change Fatal to Panic, allowing recovery
 	// from a fatal error without causing the process to exit.
@@ -63,6 +96,8 @@ func TargetsCmd() *cobra.Command {
 		Run:   targetsKey,
 	}
 	keySubcmd.Flags().BoolVar(&fKeyWithContent, "with-content", false, "with target-list content")
+	keySubcmd.Flags().StringVar(&fKeyChecksum, "checksum-file", "", "verify or record the checksum of each target-list in the specified file")
+	keySubcmd.Flags().StringVar(&fKeyOutput, "output", "", "save the content of the target-list in the specified file, or in a file named after its key in the specified directory")
 	targetsCmd.AddCommand(keySubcmd)
 
 	// targets upload and its flags.
@@ -74,8 +109,79 @@ func TargetsCmd() *cobra.Command {
 		Run:   targetsUpload,
 	}
 	uploadSubcmd.Flags().BoolVar(&fUploadProbe, "probe", false, "upload a probes-list file")
+	uploadSubcmd.Flags().StringVar(&fUploadManifest, "manifest", "", "upload all files of the specified YAML manifest and output the key of each")
+	uploadSubcmd.Flags().BoolVar(&fUploadForce, "force", false, "upload even if an identical list is already uploaded")
 	targetsCmd.AddCommand(uploadSubcmd)
 
+	// targets validate (has no flags)
+	validateSubcmd := &cobra.Command{
+		Use:   "validate",
+		Short: "validate target-list file(s)",
+		Long:  "check the format of each line of target-list file(s) before uploading them and warn about duplicate and overlapping targets",
+		Args:  targetsValidateArgs,
+		Run:   targetsValidate,
+	}
+	targetsCmd.AddCommand(validateSubcmd)
+
+	// targets diff (has no flags)
+	diffSubcmd := &cobra.Command{
+		Use:   "diff",
+		Short: "compare two target-lists",
+		Long:  "compare two target-lists (local files if they exist, uploaded keys otherwise) and print the targets that were added, removed, or whose TTL range or number of flows changed",
+		Args:  targetsDiffArgs,
+		Run:   targetsDiff,
+	}
+	targetsCmd.AddCommand(diffSubcmd)
+
+	// targets aggregate and its flags
+	aggregateSubcmd := &cobra.Command{
+		Use:   "aggregate",
+		Short: "aggregate the prefixes of a target-list",
+		Long:  "remove duplicate and covered prefixes of a target-list (keeping covered prefixes with more TTLs or flows) and merge sibling prefixes with the same protocol, TTLs, and flows (separately for IPv4 and IPv6), rewriting the file",
+		Args:  targetsAggregateArgs,
+		Run:   targetsAggregate,
+	}
+	aggregateSubcmd.Flags().StringVar(&fAggregateOutput, "output", "", "save the aggregated target-list in the specified file instead of rewriting the file")
+	aggregateSubcmd.Flags().BoolVar(&fAggregateDryRun, "dry-run", false, "only report how many lines would be removed")
+	targetsCmd.AddCommand(aggregateSubcmd)
+
+	// targets split and its flags
+	splitSubcmd := &cobra.Command{
+		Use:   "split",
+		Short: "split a target-list across agents",
+		Long:  "partition a target-list into one shard per agent, by address ranges, randomly, or by RTT so that agents finish at about the same time, and optionally upload the shards",
+		Args:  targetsSplitArgs,
+		Run:   targetsSplit,
+	}
+	splitSubcmd.Flags().IntVar(&fSplitAgents, "agents", 0, "number of agents (i.e., shards)")
+	splitSubcmd.Flags().StringVar(&fSplitBy, "by", "prefix", "how to split: "+strings.Join(splitMethods, ", "))
+	splitSubcmd.Flags().Int64Var(&fSplitSeed, "seed", 1, "seed of --by random")
+	splitSubcmd.Flags().StringVar(&fSplitRTTFile, "rtt-file", "", "file of prefix,rtt lines used by --by rtt")
+	splitSubcmd.Flags().StringVar(&fSplitOutputDir, "output-dir", "", "directory of the shard files (default: the directory of the target-list)")
+	splitSubcmd.Flags().BoolVar(&fSplitUpload, "upload", false, "upload each shard after saving it")
+	splitSubcmd.Flags().BoolVar(&fUploadForce, "force", false, "upload even if an identical list is already uploaded")
+	targetsCmd.AddCommand(splitSubcmd)
+
+	// targets generate and its flags
+	generateSubcmd := &cobra.Command{
+		Use:   "generate",
+		Short: "generate a target-list from prefix sources",
+		Long:  "generate a target-list from prefix files, RIB dumps (bgpdump -m or pfx2as), or address hitlists, removing duplicate and overlapping prefixes, and optionally upload it",
+		Args:  targetsGenerateArgs,
+		Run:   targetsGenerate,
+	}
+	generateSubcmd.Flags().StringVar(&fGenerateSource, "source", "prefixes", "format of the input files: "+strings.Join(generateSources, ", "))
+	generateSubcmd.Flags().StringVar(&fGenerateProtocol, "protocol", "icmp", "probing protocol: icmp (icmp6 for IPv6 prefixes) or udp")
+	generateSubcmd.Flags().IntVar(&fGenerateMinTTL, "min-ttl", 2, "minimum TTL of each target")
+	generateSubcmd.Flags().IntVar(&fGenerateMaxTTL, "max-ttl", 32, "maximum TTL of each target")
+	generateSubcmd.Flags().IntVar(&fGenerateFlows, "flows", 6, "number of initial flows per prefix")
+	generateSubcmd.Flags().IntVar(&fGeneratePrefixLen4, "prefix-len4", 24, "length of the prefixes that IPv4 hitlist addresses are aggregated to")
+	generateSubcmd.Flags().IntVar(&fGeneratePrefixLen6, "prefix-len6", 64, "length of the prefixes that IPv6 hitlist addresses are aggregated to")
+	generateSubcmd.Flags().StringVar(&fGenerateOutput, "output", "", "save the target-list in the specified file instead of printing it")
+	generateSubcmd.Flags().BoolVar(&fGenerateUpload, "upload", false, "upload the target-list after saving it (its key is the name of the --output file)")
+	generateSubcmd.Flags().BoolVar(&fUploadForce, "force", false, "upload even if an identical list is already uploaded")
+	targetsCmd.AddCommand(generateSubcmd)
+
 	// targets delete and its flags
 	deleteSubcmd := &cobra.Command{
 		Use:   "delete",
@@ -128,10 +234,26 @@ func targetsKeyArgs(cmd *cobra.Command, args []string) error {
 	if len(args) < 1 {
 		cliFatal("targets key requires at least one argument: <key>...")
 	}
+	if fKeyOutput != "" {
+		if fKeyWithContent || fKeyChecksum != "" {
+			cliFatal("targets key --output cannot be used with --with-content or --checksum-file")
+		}
+		if fi, err := os.Stat(fKeyOutput); len(args) > 1 && (err != nil || !fi.IsDir()) {
+			cliFatal("targets key --output must be an existing directory with more than one key")
+		}
+	}
 	return nil
 }
 
 func targetsKey(cmd *cobra.Command, args []string) {
+	if fKeyOutput != "" {
+		for _, arg := range args {
+			if err := saveContent(arg, fKeyOutput); err != nil {
+				fatal(err)
+			}
+		}
+		return
+	}
 	for _, arg := range args {
 		if _, err := getByKey(arg); err != nil {
 			fatal(err)
@@ -144,6 +266,12 @@ func targetsUploadArgs(cmd *cobra.Command, args []string) error {
 		fmt.Printf(format, "<file>", "probe-list file or taget-list file")
 		return nil
 	}
+	if fUploadManifest != "" {
+		if len(args) != 0 || fUploadProbe {
+			cliFatal("targets upload --manifest does not take any arguments or --probe")
+		}
+		return nil
+	}
 	if len(args) != 1 {
 		if fUploadProbe {
 			cliFatal("targets upload --probe requires exactly one argument: <probe-list-file>", common.ProbeListFile)
@@ -154,6 +282,12 @@ func targetsUploadArgs(cmd *cobra.Command, args []string) error {
 }
 
 func targetsUpload(cmd *cobra.Command, args []string) {
+	if fUploadManifest != "" {
+		if err := uploadManifest(fUploadManifest); err != nil {
+			fatal(err)
+		}
+		return
+	}
 	for _, arg := range args {
 		if _, err := common.CheckFile("target-list", arg); err != nil {
 			fatal(err)
@@ -190,18 +324,53 @@ func getAll() ([]byte, error) {
 
 func getByKey(key string) ([]byte, error) {
 	url := fmt.Sprintf("%s/%s?with_content=%v", common.APIEndpoint(common.TargetsAPISuffix), key, fKeyWithContent)
+	if fKeyChecksum != "" {
+		// The checksum is of the content of the target-list, so
+		// that it matches the checksum of the uploaded file, and
+		// is verified before the target-list is printed.
+		lines, err := getContent(key)
+		if err != nil {
+			return nil, err
+		}
+		if err := common.VerifyChecksum(fKeyChecksum, key, common.TargetListContent(lines)); err != nil {
+			return nil, err
+		}
+	}
 	return getResults(url, true)
 }
 
+// saveContent saves the content of the target-list with the specified
+// key in output or, if output is a directory, in a file of output
+// named after the key (i.e., the name of the uploaded file).
+func saveContent(key, output string) error {
+	lines, err := getContent(key)
+	if err != nil {
+		return err
+	}
+	file := output
+	if fi, err := os.Stat(output); err == nil && fi.IsDir() {
+		file = filepath.Join(output, filepath.Base(key))
+	}
+	if err := os.WriteFile(file, common.TargetListContent(lines), 0644); err != nil {
+		return err
+	}
+	common.SavingIn(file)
+	return nil
+}
+
 func postList(file string) error {
-	url := fmt.Sprintf("%v/", common.APIEndpoint(common.TargetsAPISuffix))
-	if fUploadProbe {
-		url = url + "/probes/"
+	if !fUploadForce {
+		key, err := findUploadedList(file, filepath.Base(file))
+		if err != nil {
+			return err
+		}
+		if key != "" {
+			fmt.Fprintf(os.Stderr, "%s is already uploaded as %s (use --force to upload it again)\n", file, key)
+			fmt.Printf("key: %s\n", key)
+			return nil
+		}
 	}
-	jsonData, err := common.Curl(auth.GetAccessToken(), false, "POST", url,
-		"-H", "Content-Type: multipart/form-data",
-		"-F", fmt.Sprintf("target_file=@%v;type=text/csv", file),
-	)
+	jsonData, err := uploadList(file, filepath.Base(file), fUploadProbe)
 	if err != nil {
 		return err
 	}
@@ -209,6 +378,19 @@ func postList(file string) error {
 	return nil
 }
 
+// uploadList uploads the specified target-list or probe-list file
+// under the specified name (i.e., key) and returns the response.
+func uploadList(file, name string, probe bool) ([]byte, error) {
+	url := fmt.Sprintf("%v/", common.APIEndpoint(common.TargetsAPISuffix))
+	if probe {
+		url = url + "/probes/"
+	}
+	return common.Curl(auth.GetAccessToken(), false, "POST", url,
+		"-H", "Content-Type: multipart/form-data",
+		"-F", fmt.Sprintf("target_file=@%v;filename=%v;type=text/csv", file, name),
+	)
+}
+
 func deleteByKey(key string) error {
 	fmt.Println("targets delete not implemented yet")
 	return nil
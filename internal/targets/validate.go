@@ -0,0 +1,204 @@
+package targets
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dioptra-io/irisctl/internal/common"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// Bounds of the TTLs of a target-list line.
+	minTargetTTL = 1
+	maxTargetTTL = 255
+)
+
+var (
+	targetProtocols = []string{"icmp", "icmp6", "udp"}
+
+	// Errors.
+	ErrInvalidTargetList = errors.New("invalid target-list")
+)
+
+// targetEntry defines a valid line of a target-list file.
+type targetEntry struct {
+	line     int
+	prefix   netip.Prefix
+	protocol string
+	minTTL   int
+	maxTTL   int
+	flows    int
+}
+
+// targetListReport defines what targets validate reports for a file.
+type targetListReport struct {
+	nLines   int
+	invalid  []string
+	warnings []string
+}
+
+func targetsValidateArgs(cmd *cobra.Command, args []string) error {
+	if format, ok := common.IsUsage(args); ok {
+		fmt.Printf(format, "<file>...", "one or more target-list files")
+		return nil
+	}
+	if len(args) < 1 {
+		cliFatal("targets validate requires at least one argument: <target-list-file>...", common.TargetListFile)
+	}
+	return nil
+}
+
+func targetsValidate(cmd *cobra.Command, args []string) {
+	var errs []error
+	for _, arg := range args {
+		if _, err := common.CheckFile("target-list", arg); err != nil {
+			fatal(err)
+		}
+		contents, err := os.ReadFile(arg)
+		if err != nil {
+			fatal(err)
+		}
+		report := validateTargetList(strings.Split(string(contents), "\n"))
+		for _, invalid := range report.invalid {
+			fmt.Printf("%s: %s\n", arg, invalid)
+		}
+		for _, warning := range report.warnings {
+			fmt.Printf("%s %s: %s\n", common.Warning(common.WarnTargetList), arg, warning)
+		}
+		if len(report.invalid) > 0 {
+			errs = append(errs, fmt.Errorf("%s: %w: %d of %d lines are invalid", arg, ErrInvalidTargetList, len(report.invalid), report.nLines))
+			continue
+		}
+		fmt.Printf("%s: %d targets ok, %d warning(s)\n", arg, report.nLines, len(report.warnings))
+	}
+	if len(errs) > 0 {
+		fatal(errors.Join(errs...))
+	}
+}
+
+// validateTargetList checks each non-empty line of a target-list
+// against the format of common.TargetListFile and looks for duplicate
+// targets and for prefixes that overlap prefixes of the same protocol.
+func validateTargetList(lines []string) targetListReport {
+	var report targetListReport
+	var entries []targetEntry
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		report.nLines++
+		entry, err := parseTargetLine(line)
+		if err != nil {
+			report.invalid = append(report.invalid, fmt.Sprintf("line %d: %v: %q", i+1, err, line))
+			continue
+		}
+		entry.line = i + 1
+		if entry.prefix != entry.prefix.Masked() {
+			report.warnings = append(report.warnings, fmt.Sprintf("line %d: %s has host bits set and is probed as %s", entry.line, entry.prefix, entry.prefix.Masked()))
+			entry.prefix = entry.prefix.Masked()
+		}
+		entries = append(entries, entry)
+	}
+	report.warnings = append(report.warnings, overlappingTargets(entries)...)
+	return report
+}
+
+// parseTargetLine parses a line of a target-list:
+// target,protocol,min_ttl,max_ttl,n_initial_flows.
+func parseTargetLine(line string) (targetEntry, error) {
+	var entry targetEntry
+	fields := strings.Split(line, ",")
+	if len(fields) != 5 {
+		return entry, fmt.Errorf("%d fields instead of 5", len(fields))
+	}
+	for i := range fields {
+		fields[i] = strings.TrimSpace(fields[i])
+	}
+	prefix, err := common.ParseTarget(fields[0])
+	if err != nil {
+		return entry, fmt.Errorf("invalid target: %v", err)
+	}
+	protocol := fields[1]
+	if !common.Contains(targetProtocols, protocol) {
+		return entry, fmt.Errorf("invalid protocol: %s (one of %s)", protocol, strings.Join(targetProtocols, " "))
+	}
+	if protocol == "icmp" && prefix.Addr().Is6() {
+		return entry, fmt.Errorf("icmp protocol for an IPv6 target (use icmp6)")
+	}
+	if protocol == "icmp6" && prefix.Addr().Is4() {
+		return entry, fmt.Errorf("icmp6 protocol for an IPv4 target (use icmp)")
+	}
+	minTTL, err := strconv.Atoi(fields[2])
+	if err != nil || minTTL < minTargetTTL || minTTL > maxTargetTTL {
+		return entry, fmt.Errorf("invalid min_ttl: %s (an integer between %d and %d)", fields[2], minTargetTTL, maxTargetTTL)
+	}
+	maxTTL, err := strconv.Atoi(fields[3])
+	if err != nil || maxTTL < minTargetTTL || maxTTL > maxTargetTTL {
+		return entry, fmt.Errorf("invalid max_ttl: %s (an integer between %d and %d)", fields[3], minTargetTTL, maxTargetTTL)
+	}
+	if minTTL > maxTTL {
+		return entry, fmt.Errorf("min_ttl %d is greater than max_ttl %d", minTTL, maxTTL)
+	}
+	flows, err := strconv.Atoi(fields[4])
+	if err != nil || flows < 1 {
+		return entry, fmt.Errorf("invalid n_initial_flows: %s (a positive integer)", fields[4])
+	}
+	entry.prefix, entry.protocol = prefix, protocol
+	entry.minTTL, entry.maxTTL, entry.flows = minTTL, maxTTL, flows
+	return entry, nil
+}
+
+// overlappingTargets returns a warning for each target that is a
+// duplicate of a target of the same protocol or that is contained in a
+// prefix of the same protocol.  Overlapping targets are probed twice.
+func overlappingTargets(entries []targetEntry) []string {
+	// Sorting by address and then by prefix length puts each prefix
+	// right after the prefixes that contain it.
+	sorted := append([]targetEntry{}, entries...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].protocol != sorted[j].protocol {
+			return sorted[i].protocol < sorted[j].protocol
+		}
+		if c := sorted[i].prefix.Addr().Compare(sorted[j].prefix.Addr()); c != 0 {
+			return c < 0
+		}
+		return sorted[i].prefix.Bits() < sorted[j].prefix.Bits()
+	})
+	type overlap struct {
+		line    int
+		warning string
+	}
+	var overlaps []overlap
+	var containing []targetEntry // stack of prefixes containing the current one
+	for _, e := range sorted {
+		for len(containing) > 0 {
+			top := containing[len(containing)-1]
+			if top.protocol == e.protocol && top.prefix.Contains(e.prefix.Addr()) {
+				break
+			}
+			containing = containing[:len(containing)-1]
+		}
+		if len(containing) > 0 {
+			top := containing[len(containing)-1]
+			if top.prefix == e.prefix {
+				overlaps = append(overlaps, overlap{e.line, fmt.Sprintf("line %d: %s %s is a duplicate of line %d", e.line, e.prefix, e.protocol, top.line)})
+				continue
+			}
+			overlaps = append(overlaps, overlap{e.line, fmt.Sprintf("line %d: %s %s overlaps %s of line %d", e.line, e.prefix, e.protocol, top.prefix, top.line)})
+		}
+		containing = append(containing, e)
+	}
+	sort.SliceStable(overlaps, func(i, j int) bool { return overlaps[i].line < overlaps[j].line })
+	var warnings []string
+	for _, o := range overlaps {
+		warnings = append(warnings, o.warning)
+	}
+	return warnings
+}
@@ -0,0 +1,129 @@
+package users
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dioptra-io/irisctl/internal/common"
+)
+
+var (
+	// GetMeasMdFile returns the name of a file containing the metadata
+	// of all measurements of the current user or of all users.  It is
+	// set by the main package to meas.GetMeasMdFile because the meas
+	// package imports this package.
+	GetMeasMdFile func(allUsers bool) (string, error)
+
+	// Sort orders of users activity.
+	activitySorts = []string{"measurements", "last", "email"}
+	// States counted by users activity in the order they are printed.
+	activityStates = []string{"finished", "ongoing", "canceled", "agent_failure"}
+)
+
+// userActivity defines the measurement activity of a user.
+type userActivity struct {
+	user         common.User
+	measurements int
+	last         time.Time
+	states       map[string]int
+}
+
+// getUsersActivity returns the measurement activity of all users in
+// users.  Measurements of users that are not in users (e.g., deleted
+// users) are ignored.
+func getUsersActivity(users []common.User, measurements []common.Measurement) []*userActivity {
+	var activities []*userActivity
+	byID := make(map[string]*userActivity)
+	for _, user := range users {
+		a := &userActivity{user: user, states: make(map[string]int)}
+		activities = append(activities, a)
+		byID[user.UUID] = a
+	}
+	for _, measurement := range measurements {
+		a, ok := byID[measurement.UserID]
+		if !ok {
+			verbose("measurement %s: unknown user %s\n", measurement.UUID, measurement.UserID)
+			continue
+		}
+		a.measurements++
+		a.states[measurement.State]++
+		for _, t := range []time.Time{measurement.CreationTime.Time, measurement.EndTime.Time} {
+			if t.After(a.last) {
+				a.last = t
+			}
+		}
+	}
+	return activities
+}
+
+// sortActivities sorts activities by the specified order.  Ties are
+// broken by email.
+func sortActivities(activities []*userActivity, order string) {
+	sort.SliceStable(activities, func(i, j int) bool {
+		ai, aj := activities[i], activities[j]
+		switch order {
+		case "measurements":
+			if ai.measurements != aj.measurements {
+				return ai.measurements > aj.measurements
+			}
+		case "last":
+			if !ai.last.Equal(aj.last) {
+				return ai.last.After(aj.last)
+			}
+		}
+		return ai.user.Email < aj.user.Email
+	})
+}
+
+// printActivity prints the measurement activity of users that have
+// been dormant for at least dormant days if dormant is not zero.
+// Users without measurements are always dormant.
+func printActivity(activities []*userActivity, dormant int) error {
+	cutoff := time.Now().AddDate(0, 0, -dormant)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "email\tuuid\tmeasurements\tlast activity\t%s\n", strings.Join(activityStates, "\t"))
+	n := 0
+	for _, a := range activities {
+		if dormant > 0 && a.last.After(cutoff) {
+			continue
+		}
+		last := "never"
+		if !a.last.IsZero() {
+			last = a.last.Format("2006-01-02")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s", a.user.Email, a.user.UUID, a.measurements, last)
+		for _, state := range activityStates {
+			fmt.Fprintf(w, "\t%d", a.states[state])
+		}
+		fmt.Fprintln(w)
+		n++
+	}
+	verbose("%d of %d users\n", n, len(activities))
+	return w.Flush()
+}
+
+// usersActivityReport prints the measurement activity of all users.
+// If measMdFile is empty, the metadata of all measurements of all
+// users is fetched.
+func usersActivityReport(measMdFile, order string, dormant int) error {
+	users, err := getAllUsers()
+	if err != nil {
+		return err
+	}
+	if measMdFile == "" {
+		if measMdFile, err = GetMeasMdFile(true); err != nil {
+			return err
+		}
+	}
+	measurements, err := common.GetMeasurementsSorted(measMdFile)
+	if err != nil {
+		return err
+	}
+	activities := getUsersActivity(users, measurements)
+	sortActivities(activities, order)
+	return printActivity(activities, dormant)
+}
@@ -0,0 +1,136 @@
+package users
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var (
+	ErrInvalidDesiredUser = errors.New("invalid desired user")
+)
+
+// ApplyUsers makes the users of the current Iris instance match the
+// desired users and returns the number of changes.  Each desired user
+// is a map of user fields (as decoded from JSON) matched by email.
+// Only the specified fields are managed; users that are not desired
+// are left alone.  Users that do not exist are created with a random
+// password unless one is specified.  If dryRun is true, the changes
+// are printed but not applied.
+func ApplyUsers(desired []map[string]interface{}, dryRun bool) (int, error) {
+	for _, user := range desired {
+		problems := checkUserFields(user)
+		if email, _ := user["email"].(string); email == "" {
+			problems = append(problems, "missing email")
+		}
+		if len(problems) > 0 {
+			sort.Strings(problems)
+			return 0, fmt.Errorf("%v: %w: %s", user["email"], ErrInvalidDesiredUser, strings.Join(problems, ", "))
+		}
+	}
+	existing, err := getAllUsers()
+	if err != nil {
+		return 0, err
+	}
+	current := make(map[string]map[string]interface{})
+	for _, user := range existing {
+		jsonData, err := json.Marshal(user)
+		if err != nil {
+			return 0, err
+		}
+		var fields map[string]interface{}
+		if err := json.Unmarshal(jsonData, &fields); err != nil {
+			return 0, err
+		}
+		current[strings.ToLower(user.Email)] = fields
+	}
+
+	nChanges := 0
+	for _, user := range desired {
+		email := user["email"].(string)
+		fields, ok := current[strings.ToLower(email)]
+		if !ok {
+			nChanges++
+			if dryRun {
+				fmt.Printf("dry-run: would create user %s\n", email)
+				continue
+			}
+			if _, ok := user["password"]; !ok {
+				password, err := randomPassword()
+				if err != nil {
+					return nChanges, err
+				}
+				user["password"] = password
+				fmt.Printf("user %s has a random password and must reset it\n", email)
+			}
+			id, err := registerAndPatchUser(user)
+			if err != nil {
+				return nChanges, fmt.Errorf("%s: %w", email, err)
+			}
+			fmt.Printf("created user %s (%s)\n", email, id)
+			continue
+		}
+
+		patch := make(map[string]interface{})
+		var diffs []string
+		for key, value := range user {
+			// Passwords cannot be compared and are only set when
+			// users are created.
+			if key == "email" || key == "password" {
+				continue
+			}
+			from, _ := json.Marshal(fields[key])
+			to, _ := json.Marshal(value)
+			if string(from) != string(to) {
+				patch[key] = value
+				diffs = append(diffs, fmt.Sprintf("%s %s -> %s", key, from, to))
+			}
+		}
+		if len(patch) == 0 {
+			verbose("user %s is up to date\n", email)
+			continue
+		}
+		sort.Strings(diffs)
+		nChanges++
+		if dryRun {
+			fmt.Printf("dry-run: would patch user %s: %s\n", email, strings.Join(diffs, ", "))
+			continue
+		}
+		data, err := json.Marshal(patch)
+		if err != nil {
+			return nChanges, err
+		}
+		if _, err := patchUser(fields["id"].(string), data); err != nil {
+			return nChanges, fmt.Errorf("%s: %w", email, err)
+		}
+		fmt.Printf("patched user %s: %s\n", email, strings.Join(diffs, ", "))
+	}
+	return nChanges, nil
+}
+
+// DesiredUsers returns the current users in the format of the desired
+// users of ApplyUsers.
+func DesiredUsers() ([]map[string]interface{}, error) {
+	existing, err := getAllUsers()
+	if err != nil {
+		return nil, err
+	}
+	var desired []map[string]interface{}
+	for _, user := range existing {
+		jsonData, err := json.Marshal(newUserPatch(user))
+		if err != nil {
+			return nil, err
+		}
+		var fields map[string]interface{}
+		if err := json.Unmarshal(jsonData, &fields); err != nil {
+			return nil, err
+		}
+		desired = append(desired, fields)
+	}
+	sort.Slice(desired, func(i, j int) bool {
+		return desired[i]["email"].(string) < desired[j]["email"].(string)
+	})
+	return desired, nil
+}
@@ -0,0 +1,88 @@
+package users
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/dioptra-io/irisctl/internal/auth"
+	"github.com/dioptra-io/irisctl/internal/common"
+)
+
+var (
+	ErrCascadeAborted = errors.New("deletion of measurements aborted")
+)
+
+// userMeasurements returns the measurements of the specified user.
+func userMeasurements(userId string) ([]common.Measurement, error) {
+	measMdFile, err := GetMeasMdFile(true)
+	if err != nil {
+		return nil, err
+	}
+	measurements, err := common.GetMeasurementsSorted(measMdFile)
+	if err != nil {
+		return nil, err
+	}
+	var userMeasurements []common.Measurement
+	for _, measurement := range measurements {
+		if measurement.UserID == userId {
+			userMeasurements = append(userMeasurements, measurement)
+		}
+	}
+	return userMeasurements, nil
+}
+
+// deleteUserMeasurements lists the measurements of the specified user
+// and, after confirmation (unless yes is true), archives their metadata
+// in a file and deletes them with the maintenance API.  It returns
+// ErrCascadeAborted if the user does not confirm and ErrRequestRejected
+// if a measurement is not deleted, so the user is not deleted while
+// still owning measurements.
+func deleteUserMeasurements(userId string, dryRun, yes bool) error {
+	measurements, err := userMeasurements(userId)
+	if err != nil {
+		return err
+	}
+	if len(measurements) == 0 {
+		fmt.Printf("user %s has no measurements\n", userId)
+		return nil
+	}
+	for _, measurement := range measurements {
+		fmt.Printf("%s %-13s %s %q\n", measurement.UUID, measurement.State, measurement.CreationTime.Format("06-01-02.15:04:05"), measurement.Tags)
+	}
+	if dryRun {
+		fmt.Printf("dry-run: would delete %d measurement(s) of user %s\n", len(measurements), userId)
+		return nil
+	}
+	if !yes && !common.Confirm(fmt.Sprintf("delete %d measurement(s) of user %s?", len(measurements), userId)) {
+		return fmt.Errorf("%s: %w", userId, ErrCascadeAborted)
+	}
+
+	jsonData, err := json.MarshalIndent(measurements, "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := common.CreateTemp("irisctl-users-delete-" + userId + "-")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	common.SavingIn(f.Name())
+	if _, err := f.Write(jsonData); err != nil {
+		return err
+	}
+
+	for i, measurement := range measurements {
+		verbose("[%d/%d] deleting measurement %s\n", i+1, len(measurements), measurement.UUID)
+		url := fmt.Sprintf("%s/measurements/%s", common.APIEndpoint(common.MaintenanceAPISuffix), measurement.UUID)
+		jsonData, status, err := common.CurlStatus(auth.GetAccessToken(), false, "DELETE", url)
+		if err != nil {
+			fmt.Println(string(jsonData))
+			return fmt.Errorf("%s: %w", measurement.UUID, err)
+		}
+		if status < 200 || status >= 300 {
+			return fmt.Errorf("%s: delete: %w: %d: %s", measurement.UUID, ErrRequestRejected, status, jsonData)
+		}
+	}
+	return nil
+}
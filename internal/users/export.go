@@ -0,0 +1,351 @@
+package users
+
+import (
+	"crypto/rand"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dioptra-io/irisctl/internal/auth"
+	"github.com/dioptra-io/irisctl/internal/common"
+)
+
+var (
+	// Columns of exported CSV files.
+	csvHeader = []string{
+		"id", "email", "is_active", "is_superuser", "is_verified",
+		"firstname", "lastname", "probing_enabled", "probing_limit",
+		"allow_tag_reserved", "allow_tag_public", "creation_time",
+	}
+
+	ErrInvalidUsersFile = errors.New("invalid users file")
+	ErrRequestRejected  = errors.New("request rejected")
+	ErrNoUserID         = errors.New("no user ID in response")
+	ErrImportFailed     = errors.New("import failed")
+)
+
+// userPatch defines the user fields that are recreated or patched by
+// users import.  Passwords are never exported so they are not part of
+// it.
+type userPatch struct {
+	Email            string `json:"email"`
+	IsActive         bool   `json:"is_active"`
+	IsSuperuser      bool   `json:"is_superuser"`
+	IsVerified       bool   `json:"is_verified"`
+	FirstName        string `json:"firstname"`
+	LastName         string `json:"lastname"`
+	ProbingEnabled   bool   `json:"probing_enabled"`
+	ProbingLimit     int32  `json:"probing_limit"`
+	AllowTagReserved bool   `json:"allow_tag_reserved"`
+	AllowTagPublic   bool   `json:"allow_tag_public"`
+}
+
+func newUserPatch(user common.User) userPatch {
+	return userPatch{
+		Email:            user.Email,
+		IsActive:         user.IsActive,
+		IsSuperuser:      user.IsSuperuser,
+		IsVerified:       user.IsVerified,
+		FirstName:        user.FirstName,
+		LastName:         user.LastName,
+		ProbingEnabled:   user.ProbingEnabled,
+		ProbingLimit:     user.ProbingLimit,
+		AllowTagReserved: user.AllowTagReserveed,
+		AllowTagPublic:   user.AllowTagPublic,
+	}
+}
+
+// isCSV returns true if the users file is in CSV format (i.e., its
+// extension is .csv); otherwise it is in JSON format.
+func isCSV(file string) bool {
+	return strings.EqualFold(filepath.Ext(file), ".csv")
+}
+
+// exportUsers writes all users of the current Iris instance to file.
+func exportUsers(file string) error {
+	users, err := getAllUsers()
+	if err != nil {
+		return err
+	}
+	if isCSV(file) {
+		err = writeUsersCSV(file, users)
+	} else {
+		var jsonData []byte
+		jsonData, err = json.MarshalIndent(users, "", "  ")
+		if err == nil {
+			err = os.WriteFile(file, append(jsonData, '\n'), 0600)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("exported %d users to %s\n", len(users), file)
+	return nil
+}
+
+// importUsers recreates the users of file that do not exist in the
+// current Iris instance and patches the ones that do.  Users are
+// matched by email because user IDs differ between instances.
+func importUsers(file string, dryRun bool) error {
+	users, err := readUsersFile(file)
+	if err != nil {
+		return err
+	}
+	existing, err := getAllUsers()
+	if err != nil {
+		return err
+	}
+	ids := make(map[string]string)
+	for _, user := range existing {
+		ids[strings.ToLower(user.Email)] = user.UUID
+	}
+	nCreated, nPatched, nFailed := 0, 0, 0
+	for _, user := range users {
+		patch := newUserPatch(user)
+		id, ok := ids[strings.ToLower(user.Email)]
+		if dryRun {
+			if ok {
+				fmt.Printf("dry-run: would patch %s (%s)\n", user.Email, id)
+			} else {
+				fmt.Printf("dry-run: would create %s\n", user.Email)
+			}
+			continue
+		}
+		created, err := importUser(user, patch, id, ok)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %s: %v\n", user.Email, err)
+			nFailed++
+			continue
+		}
+		if created {
+			nCreated++
+		} else {
+			nPatched++
+		}
+	}
+	if dryRun {
+		return nil
+	}
+	fmt.Printf("created %d, patched %d, and failed to import %d users\n", nCreated, nPatched, nFailed)
+	if nCreated > 0 {
+		fmt.Println("created users have random passwords and must reset them")
+	}
+	if nFailed > 0 {
+		return fmt.Errorf("%s: %w: %d users", file, ErrImportFailed, nFailed)
+	}
+	return nil
+}
+
+// importUser creates the user if it does not exist (i.e., if it has no
+// ID) and patches it.  It returns true if the user was created.
+func importUser(user common.User, patch userPatch, id string, exists bool) (bool, error) {
+	if !exists {
+		var err error
+		if id, err = registerUser(patch); err != nil {
+			return false, err
+		}
+		verbose("created %s (%s)\n", user.Email, id)
+	}
+	// Registration ignores privileged fields (e.g., is_superuser)
+	// so newly created users are patched as well.
+	data, err := json.Marshal(patch)
+	if err != nil {
+		return !exists, err
+	}
+	if _, err := patchUser(id, data); err != nil {
+		return !exists, err
+	}
+	verbose("patched %s (%s)\n", user.Email, id)
+	return !exists, nil
+}
+
+// getAllUsers returns all users of the current Iris instance.
+func getAllUsers() ([]common.User, error) {
+	jsonData, err := getUsersAll(false)
+	if err != nil {
+		return nil, err
+	}
+	var users common.Users
+	if err := common.DecodeJSON(jsonData, &users); err != nil {
+		return nil, err
+	}
+	return users.Results, nil
+}
+
+// registerUser registers the user with a random password.
+func registerUser(patch userPatch) (string, error) {
+	password, err := randomPassword()
+	if err != nil {
+		return "", err
+	}
+	var body struct {
+		userPatch
+		Password string `json:"password"`
+	}
+	body.userPatch = patch
+	body.Password = password
+	data, err := json.Marshal(body)
+	if err != nil {
+		return "", err
+	}
+	return postUser(data)
+}
+
+// postUser registers the user with the specified JSON object and
+// returns its ID.
+func postUser(data []byte) (string, error) {
+	url := fmt.Sprintf("%s/register", common.APIEndpoint(common.AuthAPISuffix))
+	jsonData, status, err := common.CurlStatus(auth.GetAccessToken(), false, "POST", url,
+		"-H", "Content-Type: application/json",
+		"-d", string(data),
+	)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", err, jsonData)
+	}
+	if status < 200 || status >= 300 {
+		return "", fmt.Errorf("register: %w: %d: %s", ErrRequestRejected, status, jsonData)
+	}
+	var user common.User
+	if err := common.DecodeJSON(jsonData, &user); err != nil {
+		return "", err
+	}
+	// Users cannot be patched without their ID.
+	if user.UUID == "" {
+		return "", fmt.Errorf("register: %w: %s", ErrNoUserID, jsonData)
+	}
+	return user.UUID, nil
+}
+
+// patchUser patches the user with the specified JSON object and
+// returns the patched user.
+func patchUser(id string, data []byte) ([]byte, error) {
+	url := fmt.Sprintf("%s/%v", common.APIEndpoint(common.UsersAPISuffix), id)
+	jsonData, status, err := common.CurlStatus(auth.GetAccessToken(), false, "PATCH", url,
+		"-H", "Content-Type: application/json",
+		"-d", string(data),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", err, jsonData)
+	}
+	if status < 200 || status >= 300 {
+		return nil, fmt.Errorf("patch: %w: %d: %s", ErrRequestRejected, status, jsonData)
+	}
+	return jsonData, nil
+}
+
+func randomPassword() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func readUsersFile(file string) ([]common.User, error) {
+	var users []common.User
+	if !isCSV(file) {
+		contents, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(contents, &users); err != nil {
+			return nil, fmt.Errorf("%s: %w: %v", file, ErrInvalidUsersFile, err)
+		}
+		return users, nil
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 || strings.Join(records[0], ",") != strings.Join(csvHeader, ",") {
+		return nil, fmt.Errorf("%s: %w: header must be %s", file, ErrInvalidUsersFile, strings.Join(csvHeader, ","))
+	}
+	for i, r := range records[1:] {
+		user, err := parseUserCSV(r)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w: %v", file, i+2, ErrInvalidUsersFile, err)
+		}
+		users = append(users, user)
+	}
+	return users, nil
+}
+
+func writeUsersCSV(file string, users []common.User) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := csv.NewWriter(f)
+	if err := w.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, u := range users {
+		record := []string{
+			u.UUID, u.Email,
+			strconv.FormatBool(u.IsActive),
+			strconv.FormatBool(u.IsSuperuser),
+			strconv.FormatBool(u.IsVerified),
+			u.FirstName, u.LastName,
+			strconv.FormatBool(u.ProbingEnabled),
+			strconv.Itoa(int(u.ProbingLimit)),
+			strconv.FormatBool(u.AllowTagReserveed),
+			strconv.FormatBool(u.AllowTagPublic),
+			u.CreationTime.Format(time.RFC3339),
+		}
+		if err := w.Write(record); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func parseUserCSV(r []string) (common.User, error) {
+	var user common.User
+	var err error
+	parseBool := func(s string) bool {
+		b, e := strconv.ParseBool(s)
+		if e != nil && err == nil {
+			err = e
+		}
+		return b
+	}
+	user.UUID = r[0]
+	user.Email = r[1]
+	user.IsActive = parseBool(r[2])
+	user.IsSuperuser = parseBool(r[3])
+	user.IsVerified = parseBool(r[4])
+	user.FirstName = r[5]
+	user.LastName = r[6]
+	user.ProbingEnabled = parseBool(r[7])
+	limit, e := strconv.ParseInt(r[8], 10, 32)
+	if e != nil && err == nil {
+		err = e
+	}
+	user.ProbingLimit = int32(limit)
+	user.AllowTagReserveed = parseBool(r[9])
+	user.AllowTagPublic = parseBool(r[10])
+	if r[11] != "" {
+		if e := user.CreationTime.Set(r[11]); e != nil && err == nil {
+			err = e
+		}
+	}
+	return user, err
+}
@@ -0,0 +1,67 @@
+package users
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dioptra-io/irisctl/internal/common"
+)
+
+var (
+	// Output formats of users all.
+	allFormats = []string{"json", "csv", "table"}
+
+	// Columns that can be selected for users all --format csv|table
+	// in their default order.
+	allColumns   = []string{"email", "uuid", "verified", "probing_limit", "creation_time"}
+	columnValues = map[string]func(common.User) string{
+		"email":         func(u common.User) string { return u.Email },
+		"uuid":          func(u common.User) string { return u.UUID },
+		"verified":      func(u common.User) string { return strconv.FormatBool(u.IsVerified) },
+		"probing_limit": func(u common.User) string { return strconv.Itoa(int(u.ProbingLimit)) },
+		"creation_time": func(u common.User) string { return u.CreationTime.Format(time.RFC3339) },
+	}
+)
+
+// validateColumns returns an error if any of the columns is unknown.
+func validateColumns(columns []string) error {
+	if len(columns) == 0 {
+		return fmt.Errorf("no columns (one or more of these: %s)", strings.Join(allColumns, " "))
+	}
+	for _, column := range columns {
+		if _, ok := columnValues[column]; !ok {
+			return fmt.Errorf("invalid column: %s (one or more of these: %s)", column, strings.Join(allColumns, " "))
+		}
+	}
+	return nil
+}
+
+// printUsers prints the specified columns of users in CSV format with
+// a header or as a table with aligned columns.
+func printUsers(users []common.User, format string, columns []string) error {
+	rows := [][]string{columns}
+	for _, user := range users {
+		row := make([]string, len(columns))
+		for i, column := range columns {
+			row[i] = columnValues[column](user)
+		}
+		rows = append(rows, row)
+	}
+	if format == "csv" {
+		w := csv.NewWriter(os.Stdout)
+		if err := w.WriteAll(rows); err != nil {
+			return err
+		}
+		return w.Error()
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, row := range rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	return w.Flush()
+}
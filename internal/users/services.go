@@ -0,0 +1,91 @@
+package users
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dioptra-io/irisctl/internal/auth"
+	"github.com/dioptra-io/irisctl/internal/common"
+)
+
+var (
+	// Modes of users services --export.
+	exportModes = []string{"env", "aws-profile"}
+	// Name of the AWS profile printed by --export aws-profile.
+	awsProfileName = "iris"
+)
+
+// getMeServices returns the ClickHouse and S3 credentials of the
+// current user for accessing the results of the measurement.
+func getMeServices(measUUID string) (common.MeServices, error) {
+	var services common.MeServices
+	url := fmt.Sprintf("%s/me/services?measurement_uuid=%v", common.APIEndpoint(common.UsersAPISuffix), measUUID)
+	jsonData, err := common.Curl(auth.GetAccessToken(), false, "GET", url)
+	if err != nil || common.RootFlagBool("curl") {
+		return services, err
+	}
+	if err := json.Unmarshal(jsonData, &services); err != nil {
+		return services, fmt.Errorf("%w: %s", err, jsonData)
+	}
+	return services, nil
+}
+
+// printServices prints a summary of the ClickHouse and S3 services
+// without passwords and secret keys.
+func printServices(services common.MeServices) {
+	fmt.Printf("clickhouse:\n")
+	fmt.Printf("    %-14s %s\n", "endpoint:", services.ClickHouse.BaseURL)
+	fmt.Printf("    %-14s %s\n", "database:", services.ClickHouse.Database)
+	fmt.Printf("    %-14s %s\n", "username:", services.ClickHouse.Username)
+	fmt.Printf("    %-14s %s\n", "expires:", expiry(services.ClickHouseExpTime))
+	fmt.Printf("s3:\n")
+	fmt.Printf("    %-14s %s\n", "endpoint:", services.S3.EndPointURL)
+	fmt.Printf("    %-14s %s\n", "access key id:", services.S3.AWKAccessKeyId)
+	fmt.Printf("    %-14s %s\n", "expires:", expiry(services.S3ExpTime))
+}
+
+// expiry returns the expiration time and the time left until then.
+func expiry(t time.Time) string {
+	if t.IsZero() {
+		return "unknown"
+	}
+	left := time.Until(t).Round(time.Second)
+	if left <= 0 {
+		return fmt.Sprintf("%s (expired %v ago)", t.Local().Format("2006-01-02 15:04:05"), -left)
+	}
+	return fmt.Sprintf("%s (in %v)", t.Local().Format("2006-01-02 15:04:05"), left)
+}
+
+// exportServices prints the credentials of the services as shell
+// exports (env) or as a profile of the AWS credentials file
+// (aws-profile).
+func exportServices(services common.MeServices, mode string) {
+	switch mode {
+	case "env":
+		for _, kv := range [][2]string{
+			{"CLICKHOUSE_URL", services.ClickHouse.BaseURL},
+			{"CLICKHOUSE_DATABASE", services.ClickHouse.Database},
+			{"CLICKHOUSE_USER", services.ClickHouse.Username},
+			{"CLICKHOUSE_PASSWORD", services.ClickHouse.Password},
+			{"AWS_ACCESS_KEY_ID", services.S3.AWKAccessKeyId},
+			{"AWS_SECRET_ACCESS_KEY", services.S3.AWSSecretAccessKey},
+			{"AWS_SESSION_TOKEN", services.S3.AWSSessionToekn},
+			{"AWS_ENDPOINT_URL", services.S3.EndPointURL},
+		} {
+			fmt.Printf("export %s=%s\n", kv[0], shellQuote(kv[1]))
+		}
+	case "aws-profile":
+		fmt.Printf("[%s]\n", awsProfileName)
+		fmt.Printf("aws_access_key_id = %s\n", services.S3.AWKAccessKeyId)
+		fmt.Printf("aws_secret_access_key = %s\n", services.S3.AWSSecretAccessKey)
+		fmt.Printf("aws_session_token = %s\n", services.S3.AWSSessionToekn)
+		fmt.Printf("endpoint_url = %s\n", services.S3.EndPointURL)
+	}
+}
+
+// shellQuote quotes s for POSIX shells.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -3,11 +3,13 @@ package users
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
-	"os/exec"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/dioptra-io/irisctl/internal/auth"
@@ -15,20 +17,67 @@ import (
 	"github.com/spf13/cobra"
 )
 
+const (
+	// Number of users requested per page of users API.
+	usersPageSize = 200
+
+	// Minimum remaining validity of cached ClickHouse credentials;
+	// they are refreshed when they expire sooner.
+	credentialsMinValidity = 1 * time.Minute
+)
+
 var (
 	// Command, its flags, subcommands, and their flags.
 	//	users <subcommand>
 	//	users me
-	//	users all [--verified]
-	//	users delete [--dry-run] <user-id>...
-	//	users patch <user-id> <user-details>
-	//	users services <meas-uuid>
+	//	users all [--verified] [--format json|csv|table] [--columns <column>,...]
+	//	users delete [--dry-run] [--cascade [--yes]] <user-id>...
+	//	users patch [--set <key>=<value>]... <user-id> [<user-details>]
+	//	users services [--export env|aws-profile] <meas-uuid>
+	//	users groups [<project>...]
+	//	users search <pattern>
+	//	users create <user-file>
+	//	users export <file>
+	//	users import [--dry-run] <file>
+	//	users activity [--sort measurements|last|email] [--dormant <days>] [<meas-md-file>]
 	cmdName       = "users"
-	subcmdNames   = []string{"me", "all", "delete", "patch", "services"}
+	subcmdNames   = []string{"me", "all", "delete", "patch", "services", "groups", "search", "create", "export", "import", "activity"}
 	fAllVerified  bool
+	fAllFormat    string
+	fAllColumns   []string
 	fDeleteDryRun bool
+	fDeleteCasc   bool
+	fDeleteYes    bool
+	fImportDryRun bool
+	fActivitySort string
+	fActivityDays int
+	fServicesExp  string
+	fPatchSet     []string
 
-	meServices common.MeServices
+	// Fields of user files and their JSON types.
+	userFields = map[string]string{
+		"email":              "string",
+		"password":           "string",
+		"is_active":          "bool",
+		"is_superuser":       "bool",
+		"is_verified":        "bool",
+		"firstname":          "string",
+		"lastname":           "string",
+		"probing_enabled":    "bool",
+		"probing_limit":      "number",
+		"allow_tag_reserved": "bool",
+		"allow_tag_public":   "bool",
+	}
+	// Fields that can be set by user registration; the others can
+	// only be set by patching the user as an admin.
+	registerFields = []string{"email", "password", "firstname", "lastname"}
+
+	// Errors.
+	ErrInvalidUserFile = errors.New("invalid user file")
+
+	// ClickHouse credentials obtained from users/me/services.
+	meServices   common.MeServices
+	meServicesMu sync.Mutex
 
 	// Test code changes Fatal to Panic so a fatal error won't exit
 	// the process and can be recovered.
@@ -69,9 +118,11 @@ func UsersCmd() *cobra.Command {
 		Run:   usersAll,
 	}
 	allSubcmd.Flags().BoolVar(&fAllVerified, "verified", false, "verifired users")
+	allSubcmd.Flags().StringVar(&fAllFormat, "format", "json", "output format: json, csv, or table")
+	allSubcmd.Flags().StringSliceVar(&fAllColumns, "columns", allColumns, "comma-separated columns of --format csv or table: "+strings.Join(allColumns, ","))
 	usersCmd.AddCommand(allSubcmd)
 
-	// users delete (has no flags)
+	// users delete and its flags
 	deleteSubcmd := &cobra.Command{
 		Use:   "delete",
 		Short: "delete user(s)",
@@ -80,56 +131,147 @@ func UsersCmd() *cobra.Command {
 		Run:   usersDelete,
 	}
 	deleteSubcmd.Flags().BoolVar(&fDeleteDryRun, "dry-run", false, "enable dry-run mode (i.e., do not execute command)")
+	deleteSubcmd.Flags().BoolVar(&fDeleteCasc, "cascade", false, "first delete the measurements of the user(s) with the maintenance API (admin only)")
+	deleteSubcmd.Flags().BoolVarP(&fDeleteYes, "yes", "y", false, "do not ask for confirmation before deleting the measurements of --cascade")
 	usersCmd.AddCommand(deleteSubcmd)
 
-	// users patch (has no flags)
+	// users patch and its flags
 	patchSubcmd := &cobra.Command{
 		Use:   "patch",
 		Short: "patch user",
-		Long:  "patch the user specified by its id with the contents of the specified file",
+		Long:  "patch the user specified by its id with the contents of the specified file and/or --set flags and show the changed fields",
 		Args:  usersPatchArgs,
 		Run:   usersPatch,
 	}
+	patchSubcmd.Flags().StringArrayVar(&fPatchSet, "set", []string{}, "repeatable: set the specified field (e.g., --set probing_enabled=true)")
 	usersCmd.AddCommand(patchSubcmd)
 
-	// users me/services (has no flags)
+	// users me/services and its flags
 	servicesSubcmd := &cobra.Command{
 		Use:   "services",
 		Short: "get services credentials",
-		Long:  "get external services credentials for the current user for the specified measurement",
+		Long:  "get external services (ClickHouse and S3) credentials for the current user for the specified measurement",
 		Args:  usersServicesArgs,
 		Run:   usersMeServices,
 	}
+	servicesSubcmd.Flags().StringVar(&fServicesExp, "export", "", "print the credentials as shell exports (env) or an AWS credentials profile (aws-profile)")
 	usersCmd.AddCommand(servicesSubcmd)
 
+	// users groups (has no flags)
+	groupsSubcmd := &cobra.Command{
+		Use:   "groups",
+		Short: "get projects",
+		Long:  "get users of all or the specified local projects defined in $HOME/.iris/projects.json",
+		Args:  usersGroupsArgs,
+		Run:   usersGroups,
+	}
+	usersCmd.AddCommand(groupsSubcmd)
+
+	// users search (has no flags)
+	searchSubcmd := &cobra.Command{
+		Use:   "search",
+		Short: "search users",
+		Long:  "search all users for the specified pattern in their email, first or last name, or ID prefix",
+		Args:  usersSearchArgs,
+		Run:   usersSearch,
+	}
+	usersCmd.AddCommand(searchSubcmd)
+
+	// users create (has no flags)
+	createSubcmd := &cobra.Command{
+		Use:   "create",
+		Short: "create a user (admin only)",
+		Long:  "create a user with the details in the specified file, including fields that only an admin can set",
+		Args:  usersCreateArgs,
+		Run:   usersCreate,
+	}
+	usersCmd.AddCommand(createSubcmd)
+
+	// users export (has no flags)
+	exportSubcmd := &cobra.Command{
+		Use:   "export",
+		Short: "export all users",
+		Long:  "export details of all users (minus passwords) to the specified JSON or CSV (.csv extension) file",
+		Args:  usersExportArgs,
+		Run:   usersExport,
+	}
+	usersCmd.AddCommand(exportSubcmd)
+
+	// users import and its flags
+	importSubcmd := &cobra.Command{
+		Use:   "import",
+		Short: "import users",
+		Long:  "create or patch users (matched by email) with the details in the specified file exported by users export",
+		Args:  usersImportArgs,
+		Run:   usersImport,
+	}
+	importSubcmd.Flags().BoolVar(&fImportDryRun, "dry-run", false, "enable dry-run mode (i.e., only print the plan)")
+	usersCmd.AddCommand(importSubcmd)
+
+	// users activity and its flags
+	activitySubcmd := &cobra.Command{
+		Use:   "activity",
+		Short: "report measurement activity of users (admin only)",
+		Long:  "report the number of measurements, last activity date, and measurement states of each user to spot heavy or dormant users",
+		Args:  usersActivityArgs,
+		Run:   usersActivity,
+	}
+	activitySubcmd.Flags().StringVar(&fActivitySort, "sort", "measurements", "sort users by: measurements, last (activity), or email")
+	activitySubcmd.Flags().IntVar(&fActivityDays, "dormant", 0, "only report users without activity in the specified number of days")
+	usersCmd.AddCommand(activitySubcmd)
+
 	return usersCmd
 }
 
 // GetUserPass returns username and password obtained from
-// users/me/services of Iris API.
+// users/me/services of Iris API.  The credentials are cached until
+// shortly before they expire and it is safe to call GetUserPass
+// concurrently.
 //
 // TODO: For now, this function receives the measurement UUID from
 //       flags but going forward it might find a measurement UUID of
 //       the user running this instance of irisctl.
 func GetUserPass() (string, error) {
-	if meServices.ClickHouse.Username == "" {
+	meServicesMu.Lock()
+	defer meServicesMu.Unlock()
+	if meServices.ClickHouse.Username == "" || credentialsExpired(meServices.ClickHouseExpTime) {
 		uuid := common.RootFlagString("meas-uuid")
 		url := fmt.Sprintf("%s/me/services?measurement_uuid=%v", common.APIEndpoint(common.UsersAPISuffix), uuid)
-		jsonData, err := common.Curl(auth.GetAccessToken(), false, "GET", url)
+		jsonData, err := common.CurlNoCache(auth.GetAccessToken(), false, "GET", url)
 		if err != nil {
 			return "", err
 		}
-		if err := json.Unmarshal(jsonData, &meServices); err != nil {
+		var services common.MeServices
+		if err := json.Unmarshal(jsonData, &services); err != nil {
 			return "", err
 		}
+		meServices = services
+		// We wait one second before returning because we have
+		// noticed that sometimes Iris hasn't fully read the user
+		// file that includes the newly created username and
+		// password.
+		time.Sleep(1 * time.Second)
 	}
-	// We wait one second before returning because we have noticed that
-	// sometimes Iris hasn't fully read the user file that includes the
-	// newly created username and password.
-	time.Sleep(1 * time.Second)
 	return meServices.ClickHouse.Username + ":" + meServices.ClickHouse.Password, nil
 }
 
+// credentialsExpired returns true if credentials expiring at expTime
+// expire within credentialsMinValidity.  A zero expTime never expires.
+func credentialsExpired(expTime time.Time) bool {
+	return !expTime.IsZero() && time.Until(expTime) < credentialsMinValidity
+}
+
+// GetMe returns the details of the current user.
+func GetMe() (common.User, error) {
+	var user common.User
+	jsonData, err := getUsersMe(false)
+	if err != nil {
+		return user, err
+	}
+	err = common.DecodeJSON(jsonData, &user)
+	return user, err
+}
+
 func GetUserUUIDs() ([]byte, error) {
 	return getUsersAll(false)
 }
@@ -172,11 +314,27 @@ func usersAllArgs(cmd *cobra.Command, args []string) error {
 	if len(args) != 0 {
 		cliFatal("users all does not take any arguments")
 	}
+	if !common.Contains(allFormats, fAllFormat) {
+		cliFatal("invalid --format: ", fAllFormat, " (one of these: ", strings.Join(allFormats, " "), ")")
+	}
+	if err := validateColumns(fAllColumns); err != nil {
+		cliFatal(err)
+	}
 	return nil
 }
 
 func usersAll(cmd *cobra.Command, args []string) {
-	if _, err := getUsersAll(true); err != nil {
+	if fAllFormat == "json" {
+		if _, err := getUsersAll(true); err != nil {
+			fatal(err)
+		}
+		return
+	}
+	users, err := getAllUsers()
+	if err != nil {
+		fatal(err)
+	}
+	if err := printUsers(users, fAllFormat, fAllColumns); err != nil {
 		fatal(err)
 	}
 }
@@ -197,6 +355,15 @@ func usersDeleteArgs(cmd *cobra.Command, args []string) error {
 
 func usersDelete(cmd *cobra.Command, args []string) {
 	for _, arg := range args {
+		if fDeleteCasc {
+			if err := deleteUserMeasurements(arg, fDeleteDryRun, fDeleteYes); err != nil {
+				fatal(err)
+			}
+		}
+		if fDeleteDryRun {
+			fmt.Printf("dry-run: would delete user %s\n", arg)
+			continue
+		}
 		if err := deleteUsersById(arg); err != nil {
 			fatal(err)
 		}
@@ -205,11 +372,19 @@ func usersDelete(cmd *cobra.Command, args []string) {
 
 func usersPatchArgs(cmd *cobra.Command, args []string) error {
 	if format, ok := common.IsUsage(args); ok {
-		fmt.Printf(format, "<user-id> <user-details>", "user ID and user details in JSON format")
+		fmt.Printf(format, "<user-id> [<user-details>]", "user ID and optional: user details in JSON format")
 		return nil
 	}
-	if len(args) != 2 {
-		cliFatal("users patch requires two arguments: <user-id> <user-details>", common.UserFile)
+	if len(args) < 1 || len(args) > 2 {
+		cliFatal("users patch requires one or two arguments: <user-id> [<user-details>]", common.UserFile)
+	}
+	if len(args) == 1 && len(fPatchSet) == 0 {
+		cliFatal("users patch requires <user-details> or at least one --set <key>=<value>")
+	}
+	for _, set := range fPatchSet {
+		if key, _, ok := strings.Cut(set, "="); !ok || key == "" {
+			cliFatal("invalid --set (must be <key>=<value>): ", set)
+		}
 	}
 	if err := common.ValidateFormat([]string{args[0]}, common.UserID); err != nil {
 		cliFatal(err)
@@ -218,7 +393,11 @@ func usersPatchArgs(cmd *cobra.Command, args []string) error {
 }
 
 func usersPatch(cmd *cobra.Command, args []string) {
-	if err := patchUsersId(args[0], args[1]); err != nil {
+	userFile := ""
+	if len(args) == 2 {
+		userFile = args[1]
+	}
+	if err := patchUsersId(args[0], userFile, fPatchSet); err != nil {
 		fatal(err)
 	}
 }
@@ -234,13 +413,188 @@ func usersServicesArgs(cmd *cobra.Command, args []string) error {
 	if err := common.ValidateFormat(args, common.MeasurementUUID); err != nil {
 		cliFatal(err)
 	}
+	if fServicesExp != "" && !common.Contains(exportModes, fServicesExp) {
+		cliFatal("invalid --export: ", fServicesExp, " (one of these: ", strings.Join(exportModes, " "), ")")
+	}
 	return nil
 }
 
 func usersMeServices(cmd *cobra.Command, args []string) {
-	uuid := args[0]
-	url := fmt.Sprintf("%s/me/services?measurement_uuid=%v", common.APIEndpoint(common.UsersAPISuffix), uuid)
-	if _, err := common.Curl(auth.GetAccessToken(), false, "GET", url); err != nil {
+	services, err := getMeServices(args[0])
+	if err != nil {
+		fatal(err)
+	}
+	if common.RootFlagBool("curl") {
+		return
+	}
+	if fServicesExp != "" {
+		exportServices(services, fServicesExp)
+		return
+	}
+	printServices(services)
+}
+
+func usersGroupsArgs(cmd *cobra.Command, args []string) error {
+	if format, ok := common.IsUsage(args); ok {
+		fmt.Printf(format, "<project>...", "optional: one or more project names")
+		return nil
+	}
+	return nil
+}
+
+func usersGroups(cmd *cobra.Command, args []string) {
+	projects, err := common.ReadProjects()
+	if err != nil {
+		cliFatal(err, "\nprojects file format:", common.ProjectsFile)
+	}
+	names := args
+	if len(names) == 0 {
+		for name := range projects {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+	jsonData, err := getUsersAll(false)
+	if err != nil {
+		fatal(err)
+	}
+	var users common.Users
+	if err := common.DecodeJSON(jsonData, &users); err != nil {
+		fatal(err)
+	}
+	for _, name := range names {
+		userIDs, ok := projects[name]
+		if !ok {
+			fatal(fmt.Errorf("%v: %w", name, common.ErrUnknownProject))
+		}
+		fmt.Println(name)
+		for _, userID := range userIDs {
+			fmt.Printf("    %v ", userID)
+			found := false
+			for _, user := range users.Results {
+				if user.UUID == userID {
+					fmt.Printf("%v %v %v\n", user.FirstName, user.LastName, user.Email)
+					found = true
+					break
+				}
+			}
+			if !found {
+				fmt.Printf("?\n")
+			}
+		}
+	}
+}
+
+func usersSearchArgs(cmd *cobra.Command, args []string) error {
+	if format, ok := common.IsUsage(args); ok {
+		fmt.Printf(format, "<pattern>", "case-insensitive pattern to search for")
+		return nil
+	}
+	if len(args) != 1 {
+		cliFatal("users search requires exactly one argument: <pattern>")
+	}
+	return nil
+}
+
+func usersSearch(cmd *cobra.Command, args []string) {
+	users, err := getAllUsers()
+	if err != nil {
+		fatal(err)
+	}
+	matches := searchUsers(users, args[0])
+	for _, user := range matches {
+		flags := ""
+		for _, f := range []struct {
+			set  bool
+			abbr string
+		}{{user.IsActive, "A"}, {user.IsSuperuser, "S"}, {user.IsVerified, "V"}, {user.ProbingEnabled, "P"}} {
+			if f.set {
+				flags += f.abbr
+			} else {
+				flags += "-"
+			}
+		}
+		fmt.Printf("%s %s %-32s %s %s\n", user.UUID, flags, user.Email, user.FirstName, user.LastName)
+	}
+	verbose("%d of %d users match %q (flags: A=active S=superuser V=verified P=probing enabled)\n", len(matches), len(users), args[0])
+}
+
+func usersCreateArgs(cmd *cobra.Command, args []string) error {
+	if format, ok := common.IsUsage(args); ok {
+		fmt.Printf(format, "<user-file>", "file containing user details in JSON format")
+		return nil
+	}
+	if len(args) != 1 {
+		cliFatal("users create requires exactly one argument: <user-file>", common.UserFile)
+	}
+	return nil
+}
+
+func usersCreate(cmd *cobra.Command, args []string) {
+	id, err := createUser(args[0])
+	if err != nil {
+		fatal(err)
+	}
+	fmt.Println(id)
+}
+
+func usersExportArgs(cmd *cobra.Command, args []string) error {
+	if format, ok := common.IsUsage(args); ok {
+		fmt.Printf(format, "<file>", "output file (CSV if its extension is .csv, JSON otherwise)")
+		return nil
+	}
+	if len(args) != 1 {
+		cliFatal("users export requires exactly one argument: <file>")
+	}
+	return nil
+}
+
+func usersExport(cmd *cobra.Command, args []string) {
+	if err := exportUsers(args[0]); err != nil {
+		fatal(err)
+	}
+}
+
+func usersImportArgs(cmd *cobra.Command, args []string) error {
+	if format, ok := common.IsUsage(args); ok {
+		fmt.Printf(format, "<file>", "file exported by users export")
+		return nil
+	}
+	if len(args) != 1 {
+		cliFatal("users import requires exactly one argument: <file>")
+	}
+	return nil
+}
+
+func usersImport(cmd *cobra.Command, args []string) {
+	if err := importUsers(args[0], fImportDryRun); err != nil {
+		fatal(err)
+	}
+}
+
+func usersActivityArgs(cmd *cobra.Command, args []string) error {
+	if format, ok := common.IsUsage(args); ok {
+		fmt.Printf(format, "<meas-md-file>", "optional: measurements metadata file of all users")
+		return nil
+	}
+	if len(args) > 1 {
+		cliFatal("users activity takes at most one argument: <meas-md-file>")
+	}
+	if !common.Contains(activitySorts, fActivitySort) {
+		cliFatal("invalid --sort: ", fActivitySort, " (one of these: ", strings.Join(activitySorts, " "), ")")
+	}
+	if fActivityDays < 0 {
+		cliFatal("--dormant must not be negative")
+	}
+	return nil
+}
+
+func usersActivity(cmd *cobra.Command, args []string) {
+	measMdFile := ""
+	if len(args) == 1 {
+		measMdFile = args[0]
+	}
+	if err := usersActivityReport(measMdFile, fActivitySort, fActivityDays); err != nil {
 		fatal(err)
 	}
 }
@@ -250,9 +604,43 @@ func getUsersMe(printOut bool) ([]byte, error) {
 	return getUsers(url, printOut)
 }
 
+// getUsersAll returns all users in one document.  Iris API returns at
+// most usersPageSize users per request, so the pages are followed
+// until there are no more users.
 func getUsersAll(printOut bool) ([]byte, error) {
-	url := fmt.Sprintf("%s?filter_verified=%v&offset=0&limit=200", common.APIEndpoint(common.UsersAPISuffix), fAllVerified)
-	return getUsers(url, printOut)
+	var results []json.RawMessage
+	for offset := 0; ; offset += usersPageSize {
+		url := fmt.Sprintf("%s?filter_verified=%v&offset=%d&limit=%d", common.APIEndpoint(common.UsersAPISuffix), fAllVerified, offset, usersPageSize)
+		jsonData, err := common.Curl(auth.GetAccessToken(), false, "GET", url)
+		if err != nil || common.RootFlagBool("curl") {
+			return jsonData, err
+		}
+		var page struct {
+			Next    *string           `json:"next"`
+			Results []json.RawMessage `json:"results"`
+		}
+		if err := json.Unmarshal(jsonData, &page); err != nil {
+			return jsonData, err
+		}
+		results = append(results, page.Results...)
+		if page.Next == nil || *page.Next == "" || len(page.Results) < usersPageSize {
+			break
+		}
+		verbose("getting the next page of users after %d users\n", len(results))
+	}
+	if results == nil {
+		results = []json.RawMessage{}
+	}
+	jsonData, err := json.Marshal(struct {
+		Count    int               `json:"count"`
+		Next     *string           `json:"next"`
+		Previous *string           `json:"previous"`
+		Results  []json.RawMessage `json:"results"`
+	}{len(results), nil, nil, results})
+	if err != nil {
+		return nil, err
+	}
+	return saveUsers(jsonData, printOut)
 }
 
 func deleteUsersById(userId string) error {
@@ -265,8 +653,190 @@ func deleteUsersById(userId string) error {
 	return nil
 }
 
-func patchUsersId(userId, userFile string) error {
-	fmt.Printf("users patch not implemented yet\n")
+// patchUsersId patches the user with the fields of userFile (if any)
+// merged with the fields of sets (which take precedence) and prints
+// the fields whose values changed.
+func patchUsersId(userId, userFile string, sets []string) error {
+	patch := make(map[string]interface{})
+	if userFile != "" {
+		contents, err := os.ReadFile(userFile)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(contents, &patch); err != nil {
+			return fmt.Errorf("%s: %w, user file format:%s", userFile, err, common.UserFile)
+		}
+	}
+	for _, set := range sets {
+		key, value, _ := strings.Cut(set, "=")
+		// Values are JSON (e.g., true, 10) if they can be parsed as
+		// such and strings otherwise.
+		var v interface{}
+		if err := json.Unmarshal([]byte(value), &v); err != nil {
+			v = value
+		}
+		patch[key] = v
+	}
+	data, err := json.Marshal(patch)
+	if err != nil {
+		return err
+	}
+
+	url := fmt.Sprintf("%s/%v", common.APIEndpoint(common.UsersAPISuffix), userId)
+	before, err := getUsers(url, false)
+	if err != nil {
+		return err
+	}
+	after, err := patchUser(userId, data)
+	if err != nil {
+		return err
+	}
+	return printUserDiff(before, after)
+}
+
+// searchUsers returns the users whose email, first name, or last name
+// contains pattern or whose ID starts with pattern (ignoring case)
+// sorted by email.
+func searchUsers(users []common.User, pattern string) []common.User {
+	pattern = strings.ToLower(pattern)
+	var matches []common.User
+	for _, user := range users {
+		if strings.Contains(strings.ToLower(user.Email), pattern) ||
+			strings.Contains(strings.ToLower(user.FirstName), pattern) ||
+			strings.Contains(strings.ToLower(user.LastName), pattern) ||
+			strings.HasPrefix(strings.ToLower(user.UUID), pattern) {
+			matches = append(matches, user)
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].Email < matches[j].Email
+	})
+	return matches
+}
+
+// createUser creates the user whose details are in userFile and
+// returns its ID.  The user is registered and then patched with the
+// fields that registration ignores (e.g., is_superuser).
+func createUser(userFile string) (string, error) {
+	user, err := readUserFile(userFile)
+	if err != nil {
+		return "", err
+	}
+	return registerAndPatchUser(user)
+}
+
+// registerAndPatchUser creates the user with the specified fields and
+// returns its ID.
+func registerAndPatchUser(user map[string]interface{}) (string, error) {
+	register := make(map[string]interface{})
+	patch := make(map[string]interface{})
+	for key, value := range user {
+		if common.Contains(registerFields, key) {
+			register[key] = value
+		} else {
+			patch[key] = value
+		}
+	}
+	data, err := json.Marshal(register)
+	if err != nil {
+		return "", err
+	}
+	id, err := postUser(data)
+	if err != nil {
+		return "", err
+	}
+	verbose("registered %s (%s)\n", user["email"], id)
+	if len(patch) == 0 {
+		return id, nil
+	}
+	if data, err = json.Marshal(patch); err != nil {
+		return id, err
+	}
+	if _, err := patchUser(id, data); err != nil {
+		return id, err
+	}
+	verbose("patched %s (%s)\n", user["email"], id)
+	return id, nil
+}
+
+// readUserFile returns the user details in userFile after checking
+// that all fields are known and have the expected types and that the
+// required fields (email and password) are set.
+func readUserFile(userFile string) (map[string]interface{}, error) {
+	contents, err := os.ReadFile(userFile)
+	if err != nil {
+		return nil, err
+	}
+	var user map[string]interface{}
+	if err := json.Unmarshal(contents, &user); err != nil {
+		return nil, fmt.Errorf("%s: %w: %v\nuser file format:%s", userFile, ErrInvalidUserFile, err, common.UserFile)
+	}
+	problems := checkUserFields(user)
+	for _, key := range []string{"email", "password"} {
+		if s, _ := user[key].(string); s == "" {
+			problems = append(problems, fmt.Sprintf("missing %s", key))
+		}
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return nil, fmt.Errorf("%s: %w: %s\nuser file format:%s", userFile, ErrInvalidUserFile, strings.Join(problems, ", "), common.UserFile)
+	}
+	return user, nil
+}
+
+// checkUserFields returns the fields of the user that are unknown or
+// do not have the expected types.
+func checkUserFields(user map[string]interface{}) []string {
+	var problems []string
+	for key, value := range user {
+		want, ok := userFields[key]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("unknown field %s", key))
+			continue
+		}
+		got := ""
+		switch value.(type) {
+		case string:
+			got = "string"
+		case bool:
+			got = "bool"
+		case float64:
+			got = "number"
+		}
+		if got != want {
+			problems = append(problems, fmt.Sprintf("%s must be a %s", key, want))
+		}
+	}
+	return problems
+}
+
+// printUserDiff prints the fields of the user that changed.
+func printUserDiff(before, after []byte) error {
+	var b, a map[string]interface{}
+	if err := json.Unmarshal(before, &b); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(after, &a); err != nil {
+		return err
+	}
+	var keys []string
+	for key := range a {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	nChanged := 0
+	for _, key := range keys {
+		from, _ := json.Marshal(b[key])
+		to, _ := json.Marshal(a[key])
+		if string(from) == string(to) {
+			continue
+		}
+		nChanged++
+		fmt.Printf("%-20s %s -> %s\n", key, from, to)
+	}
+	if nChanged == 0 {
+		fmt.Println("no fields changed")
+	}
 	return nil
 }
 
@@ -275,13 +845,19 @@ func getUsers(url string, printOut bool) ([]byte, error) {
 	if err != nil {
 		return jsonData, err
 	}
-	tmpFile, err := os.CreateTemp("/tmp", "irisctl-user-")
+	return saveUsers(jsonData, printOut)
+}
+
+// saveUsers saves jsonData in a temporary file and, if printOut is
+// true, prints it.
+func saveUsers(jsonData []byte, printOut bool) ([]byte, error) {
+	tmpFile, err := common.CreateTemp("irisctl-user-")
 	if err != nil {
 		return jsonData, err
 	}
 	defer tmpFile.Close()
 	if common.RootFlagBool("no-delete") {
-		fmt.Fprintf(os.Stderr, "saving in %s\n", tmpFile.Name())
+		common.SavingIn(tmpFile.Name())
 	} else {
 		defer func(f string) { verbose("removing %s\n", f); os.Remove(f) }(tmpFile.Name())
 	}
@@ -289,8 +865,7 @@ func getUsers(url string, printOut bool) ([]byte, error) {
 		return jsonData, err
 	}
 	if printOut && !common.RootFlagBool("no-delete") {
-		cmd := exec.Command("jq", ".", tmpFile.Name())
-		jsonData, err = cmd.Output()
+		jsonData, err = common.JqFile(tmpFile.Name(), []string{"."})
 		if err != nil {
 			return jsonData, err
 		}